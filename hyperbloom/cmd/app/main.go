@@ -1,44 +1,135 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"gopds/hyperbloom/internal/api"
+	"gopds/hyperbloom/internal/api/rpc"
 	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
 	"gopds/hyperbloom/internal/service"
 	"gopds/hyperbloom/pkg/utils"
+
+	"google.golang.org/grpc"
 )
 
 // main sets up the HTTP server and routes, including handling OS interrupts for graceful shutdown.
+// With -migrate, it only creates or migrates the tables and exits, see service.Migrate.
 func main() {
 	var err error
 
+	migrate := flag.Bool("migrate", false, "create or migrate the tables, then exit")
+	flag.Parse()
+
 	// Load application configuration from environment variables or configuration files
 	config.LoadConfigApplication()
 
+	// Set up the default logger, request logs are dropped with LOG_LEVEL=warn
+	if err = config.SetupLogging(os.Stderr); err != nil {
+		log.Fatal(err)
+	}
+
+	// Load metrics configuration, an illegal namespace would produce unscrapable metric names
+	if err = config.LoadConfigMetrics(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Validate the per-endpoint timeouts, a typo would otherwise silently fall back to the defaults
+	if _, err = config.ParseEndpointTimeouts(config.ApplicationCfg().EndpointTimeouts); err != nil {
+		log.Fatal(err)
+	}
+
+	// Load PostgreSQL configuration, a malformed one can't be connected to
+	if err = config.LoadConfigPostgres(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Connect to PostgreSQL, giving it DB_CONNECT_TIMEOUT to come up
+	ctx := context.Background()
+	if timeout := config.PostgresCfg.ConnectTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err = postgres.Connect(ctx, config.PostgresCfg); err != nil {
+		log.Fatal(err)
+	}
+
+	// Apply the schema alone, e.g. from a deployment job when DB_MIGRATE is disabled on the instances
+	if *migrate {
+		if err = service.Migrate(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+		slog.Info("Migrated schema")
+		return
+	}
+
+	// Create the tables and start flushing HyperBlooms to them
+	service.Init()
+
 	// Create a new ServeMux instance to handle HTTP requests
 	mux := http.NewServeMux()
 
-	// Set up a channel to receive OS interrupt signals
+	// Set up a channel to receive OS interrupt and reload signals
 	osChan := make(chan os.Signal, 1)
-	signal.Notify(osChan, syscall.SIGTERM, syscall.SIGINT)
+	signal.Notify(osChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 
-	// Goroutine to handle OS interrupt signals and perform cleanup tasks
-	service.WG.Add(1)
-	go utils.Cleanup(osChan, &service.WG)
+	// Servers failing to serve report it here rather than on osChan, which the shutdown closes. There is room for
+	// both, so that reporting never blocks
+	serveErr := make(chan error, 2)
 
 	// Register HTTP request handlers for specific API endpoints
 	api.Serve(mux)
 
-	// Start the HTTP server on port 5000
-	err = http.ListenAndServe(config.ApplicationCfg.Addr, mux)
-	if err != nil {
-		log.Println("Can't start server:", err) // Log error if the server fails to start
-		osChan <- syscall.SIGTERM               // Signal to initiate graceful shutdown
+	// Serve HTTPS instead when TLS_CERT_FILE and TLS_KEY_FILE are set, requiring client certificates with TLS_CLIENT_CA_FILE
+	server := api.NewServer(mux)
+	if server.TLSConfig, err = api.NewTLSConfig(*config.ApplicationCfg()); err != nil {
+		log.Fatal(err)
+	}
+
+	// Serve the gRPC interface on its own port next to the HTTP server, unless GRPC_ADDR is empty,
+	// over TLS too when HTTPS is served
+	var grpcServer *grpc.Server
+	if addr := config.ApplicationCfg().GRPCAddr; addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		grpcServer = rpc.NewServer(server.TLSConfig)
+		go func() {
+			// The server is stopped by the shutdown sequence, any other error means it failed to serve
+			if err := grpcServer.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				serveErr <- fmt.Errorf("gRPC server: %w", err)
+			}
+		}()
+	}
+
+	// Goroutine to handle OS signals, reloading the configuration and performing cleanup tasks
+	// once the requests in flight on the servers are over
+	service.WG.Add(1)
+	go utils.Cleanup(osChan, serveErr, &service.WG, server, grpcServer)
+
+	// Start the HTTP server on the configured address, the certificate is already in the TLS configuration
+	if server.TLSConfig != nil {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	// The server is closed by the shutdown sequence, any other error means it failed to start
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		serveErr <- fmt.Errorf("HTTP server: %w", err) // Initiate graceful shutdown
 	}
 
 	service.WG.Wait() // Wait for all cleanup tasks to finish before exiting
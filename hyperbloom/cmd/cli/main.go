@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultURL is the base URL of the server when neither -url nor HYPERBLOOM_URL is set, MUX_ADDR's default port.
+const defaultURL = "http://localhost:5000"
+
+// usage is printed for -h and when no valid command is given.
+const usage = `Usage: hyperbloom-cli [-url URL] [-token TOKEN] [-json] [-timeout DURATION] <command> [flags]
+
+Commands:
+  hash    -key KEY -value VALUE    add a value to a key, creating it if needed
+  exists  -key KEY -value VALUE    check whether a value probably exists in a key
+  card    -key KEY                 estimate the cardinality of a key
+  sim     -key1 KEY -key2 KEY      estimate the similarity of two keys
+  list    [-prefix P] [-limit N] [-offset N]
+                                   list the existing keys, a page at a time
+  delete  -key KEY                 delete a key, its filters and its metadata
+
+The base URL and bearer token default to HYPERBLOOM_URL and HYPERBLOOM_TOKEN.
+Responses are written as rendered by the server, or as JSON with -json.
+`
+
+// errUsage is returned for invalid command lines, after the usage or the flag error has been written.
+var errUsage = errors.New("invalid usage")
+
+// client calls the HTTP API of a running server.
+type client struct {
+	baseURL string       // Base URL of the server, without a trailing slash
+	token   string       // Bearer token sent with every request, none if empty
+	json    bool         // Whether to ask for JSON responses rather than their text rendering
+	http    *http.Client // HTTP client, holding the timeout
+}
+
+// main runs the command given on the command line and exits with 0 on success, 2 on invalid usage and 1 otherwise.
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags and the command from args, calls the server and writes its response to stdout.
+func run(args []string, stdout, stderr io.Writer) error {
+	global := flag.NewFlagSet("hyperbloom-cli", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { fmt.Fprint(stderr, usage) }
+
+	baseURL := global.String("url", envOr("HYPERBLOOM_URL", defaultURL), "base URL of the server")
+	token := global.String("token", os.Getenv("HYPERBLOOM_TOKEN"), "bearer token, one of API_KEYS")
+	asJSON := global.Bool("json", false, "write JSON responses")
+	timeout := global.Duration("timeout", 30*time.Second, "timeout of the request")
+	if err := global.Parse(args); err != nil {
+		return errUsage
+	}
+	if global.NArg() == 0 {
+		fmt.Fprint(stderr, usage)
+		return errUsage
+	}
+
+	c := &client{
+		baseURL: strings.TrimRight(*baseURL, "/"),
+		token:   *token,
+		json:    *asJSON,
+		http:    &http.Client{Timeout: *timeout},
+	}
+
+	// Parse the flags of the command and build its request
+	command, args := global.Arg(0), global.Args()[1:]
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var method, path string
+	var query url.Values
+	var body any
+	switch command {
+	case "hash", "exists":
+		key := flags.String("key", "", "key of the HyperBloom")
+		value := flags.String("value", "", "value to "+map[string]string{"hash": "add", "exists": "check"}[command])
+		if err := parseFlags(flags, args, "key", "value"); err != nil {
+			return err
+		}
+		method, path = http.MethodPost, "/hyperbloom/"+command
+		body = map[string]string{"key": *key, "value": *value}
+	case "card":
+		key := flags.String("key", "", "key of the HyperBloom")
+		if err := parseFlags(flags, args, "key"); err != nil {
+			return err
+		}
+		method, path = http.MethodGet, "/hyperbloom/card"
+		query = url.Values{"key": {*key}}
+	case "sim":
+		key1 := flags.String("key1", "", "first key")
+		key2 := flags.String("key2", "", "second key")
+		if err := parseFlags(flags, args, "key1", "key2"); err != nil {
+			return err
+		}
+		method, path = http.MethodPost, "/hyperbloom/sim"
+		body = map[string]string{"key_1": *key1, "key_2": *key2}
+	case "list":
+		prefix := flags.String("prefix", "", "only list keys starting with prefix")
+		limit := flags.Int("limit", 0, "largest number of keys listed, the server's default if 0")
+		offset := flags.Int("offset", 0, "number of keys skipped")
+		if err := parseFlags(flags, args); err != nil {
+			return err
+		}
+		method, path = http.MethodGet, "/hyperbloom/keys"
+		query = url.Values{"prefix": {*prefix}, "offset": {strconv.Itoa(*offset)}}
+		if *limit > 0 {
+			query.Set("limit", strconv.Itoa(*limit))
+		}
+	case "delete":
+		key := flags.String("key", "", "key of the HyperBloom")
+		if err := parseFlags(flags, args, "key"); err != nil {
+			return err
+		}
+		method, path = http.MethodPost, "/hyperbloom/delete"
+		body = map[string]string{"key": *key}
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", command, usage)
+		return errUsage
+	}
+
+	response, err := c.call(method, path, query, body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+
+	// Text responses don't always end with a newline, see TEXT_NEWLINE
+	if len(response) > 0 && !bytes.HasSuffix(response, []byte("\n")) {
+		response = append(response, '\n')
+	}
+	_, err = stdout.Write(response)
+	return err
+}
+
+// parseFlags parses args into flags, then checks that none of the required flags is empty.
+// It writes the error and the flags of the command to the flag set output on failure.
+func parseFlags(flags *flag.FlagSet, args []string, required ...string) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "unexpected argument %q\n", flags.Arg(0))
+		flags.PrintDefaults()
+		return errUsage
+	}
+
+	for _, name := range required {
+		if flags.Lookup(name).Value.String() == "" {
+			fmt.Fprintf(flags.Output(), "-%s is required\n", name)
+			flags.PrintDefaults()
+			return errUsage
+		}
+	}
+	return nil
+}
+
+// call sends a request to the server and returns the body of its response, JSON or its text rendering depending
+// on c.json. body is encoded as JSON unless nil. Responses other than 2xx are returned as errors.
+func (c *client) call(method, path string, query url.Values, body any) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the server render the response, see api.negotiateFormat
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.json {
+		req.Header.Set("Accept", "application/json")
+	} else {
+		req.Header.Set("Accept", "text/plain")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// envOr returns the value of the environment variable name, or fallback if it's unset or empty.
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
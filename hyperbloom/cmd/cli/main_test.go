@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var got *http.Request
+	var gotBody map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, gotBody = r, nil
+		if body, _ := io.ReadAll(r.Body); len(body) > 0 {
+			json.Unmarshal(body, &gotBody)
+		}
+		if r.URL.Path == "/hyperbloom/delete" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Header.Get("Accept") == "application/json" {
+			w.Write([]byte(`{"ok":true}` + "\n"))
+			return
+		}
+		w.Write([]byte("rendered"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		args   []string
+		method string
+		path   string
+		query  string
+		body   map[string]string
+		output string
+	}{
+		{[]string{"hash", "-key", "k", "-value", "v"}, http.MethodPost, "/hyperbloom/hash", "", map[string]string{"key": "k", "value": "v"}, "rendered\n"},
+		{[]string{"-json", "exists", "-key", "k", "-value", "v"}, http.MethodPost, "/hyperbloom/exists", "", map[string]string{"key": "k", "value": "v"}, `{"ok":true}` + "\n"},
+		{[]string{"card", "-key", "a b"}, http.MethodGet, "/hyperbloom/card", "key=a+b", nil, "rendered\n"},
+		{[]string{"sim", "-key1", "a", "-key2", "b"}, http.MethodPost, "/hyperbloom/sim", "", map[string]string{"key_1": "a", "key_2": "b"}, "rendered\n"},
+		{[]string{"list", "-prefix", "p", "-limit", "10"}, http.MethodGet, "/hyperbloom/keys", "limit=10&offset=0&prefix=p", nil, "rendered\n"},
+		{[]string{"delete", "-key", "k"}, http.MethodPost, "/hyperbloom/delete", "", map[string]string{"key": "k"}, ""},
+	}
+	for _, test := range tests {
+		stdout := &bytes.Buffer{}
+		args := append([]string{"-url", server.URL + "/", "-token", "secret"}, test.args...)
+		if err := run(args, stdout, io.Discard); err != nil {
+			t.Fatalf("%v: %v", test.args, err)
+		}
+		if got.Method != test.method || got.URL.Path != test.path || got.URL.RawQuery != test.query {
+			t.Errorf("%v: sent %s %s?%s, want %s %s?%s", test.args, got.Method, got.URL.Path, got.URL.RawQuery, test.method, test.path, test.query)
+		}
+		if len(gotBody) != len(test.body) {
+			t.Errorf("%v: sent body %v, want %v", test.args, gotBody, test.body)
+		}
+		for name, value := range test.body {
+			if gotBody[name] != value {
+				t.Errorf("%v: sent body %v, want %v", test.args, gotBody, test.body)
+			}
+		}
+		if auth := got.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("%v: sent Authorization %q, want the bearer token", test.args, auth)
+		}
+		if stdout.String() != test.output {
+			t.Errorf("%v: wrote %q, want %q", test.args, stdout.String(), test.output)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// Invalid command lines never reach the server
+	for _, args := range [][]string{{}, {"unknown"}, {"hash", "-key", "k"}, {"card", "-key", "k", "extra"}, {"-nope"}} {
+		if err := run(append([]string{"-url", server.URL}, args...), io.Discard, io.Discard); !errors.Is(err, errUsage) {
+			t.Errorf("%v: %v, want errUsage", args, err)
+		}
+	}
+
+	// Errors of the server are reported with their status
+	err := run([]string{"-url", server.URL, "card", "-key", "k"}, io.Discard, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "Key not found") {
+		t.Errorf("missing key: %v, want the status and message", err)
+	}
+}
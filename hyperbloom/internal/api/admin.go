@@ -0,0 +1,344 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// requireAdmin checks that the request carries the configured admin token as a bearer token.
+// It writes an error response and returns false if the request is not allowed to proceed.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	// Admin endpoints are disabled unless a token is configured
+	adminToken := config.ApplicationCfg().AdminToken
+	if adminToken == "" {
+		http.Error(w, "Admin endpoints are disabled", http.StatusForbidden)
+		return false
+	}
+
+	// Compare in constant time to avoid leaking the token through timing
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// adminHashQuality handles GET requests to check how uniformly the hash functions of a key spread its values.
+// It expects query parameters "key" and optionally "samples" (number of random values to hash, default 10000).
+func adminHashQuality(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Hashing many random values is costly, restrict it to admins
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	queries := r.URL.Query()
+	key := queries.Get("key")
+
+	samples, err := paramSamples.optional(r, 10000)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	// Call service to run the diagnostic
+	report, err := service.BloomHashQuality(r.Context(), key, int(samples))
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	// Format the output string, one property per line
+	output := fmt.Sprintf(
+		"Key = %s\n"+
+			"Buckets = %d\n"+
+			"Random samples = %d\n"+
+			"Chi-squared (inserted, random) = (%.2f, %.2f)\n"+
+			"Critical value = %.2f\n"+
+			"Suspicious = %t\n",
+		report.Key,
+		report.Buckets,
+		report.Samples,
+		report.InsertedChiSquared, report.RandomChiSquared,
+		report.CriticalValue,
+		report.Suspicious,
+	)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, HashQualityResponse(*report), output)
+}
+
+// adminIngestSQL handles POST requests to hash the results of an allowlisted SQL query into a key.
+// It expects a JSON body with "key", "query" (name of a query in INGEST_QUERIES) and optionally "args"
+// (values bound to the query placeholders), and writes the number of rows processed.
+func adminIngestSQL(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Running queries against the database is restricted to admins
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key   string `json:"key"`
+		Query string `json:"query"`
+		Args  []any  `json:"args"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}, field{"query", jsonbody.Query}) {
+		return
+	}
+
+	// Call service to stream the query results into the key, stopping if the client goes away
+	processed, hashed, err := service.BloomIngestSQL(r.Context(), jsonbody.Key, jsonbody.Query, jsonbody.Args)
+	if errors.Is(err, service.ErrQueryNotAllowed) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if errors.Is(err, service.ErrTransformFailed) {
+		http.Error(w, fmt.Sprintf("Ingestion failed after %d rows: %v", processed, err), http.StatusBadGateway)
+		return
+	} else if err != nil {
+		// Report the progress made before the failure, the hashed values stay in the key
+		http.Error(w, fmt.Sprintf("Ingestion failed after %d rows: %v", processed, err), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't ingest query", "query", jsonbody.Query, "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Format the output string with the number of rows
+	output := fmt.Sprintf("Ingest (%s, %s) = %d rows processed, %d values hashed", jsonbody.Key, jsonbody.Query, processed, hashed)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, IngestResponse{Key: jsonbody.Key, Query: jsonbody.Query, Processed: processed, Hashed: hashed}, output)
+}
+
+// adminSnapshot handles POST requests to write every in-memory key to a local file, independently of the database.
+// It expects the query parameter "path" (file to write, replaced if it exists) and writes the number of keys written.
+func adminSnapshot(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Writing files on the server is restricted to admins
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+
+	// Call service to write the snapshot
+	count, err := service.BloomSnapshot(path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Snapshot failed: %v", err), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't write snapshot", "path", path, "err", err)
+		return
+	}
+
+	// Format the output string with the number of keys
+	output := fmt.Sprintf("Snapshot (%s) = %d keys", path, count)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, SnapshotResponse{Path: path, Keys: count}, output)
+}
+
+// adminRestore handles POST requests to load every key from a file written by adminSnapshot.
+// It expects the query parameter "path" (file to read) and writes the number of keys restored.
+// The file is validated before anything is loaded, an invalid file leaves the registry untouched.
+func adminRestore(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Reading files on the server and replacing keys is restricted to admins
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+
+	// Call service to restore the snapshot
+	count, err := service.BloomRestore(r.Context(), path)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "Snapshot not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, service.ErrInvalidSnapshot) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Restore failed: %v", err), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't restore snapshot", "path", path, "err", err)
+		return
+	}
+
+	// Format the output string with the number of keys
+	output := fmt.Sprintf("Restore (%s) = %d keys", path, count)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, SnapshotResponse{Path: path, Keys: count}, output)
+}
+
+// adminFlushPause handles POST requests to hold the periodic flush of the in-memory keys to the database,
+// e.g. during a database maintenance. Changes accumulate in memory until adminFlushResume is called.
+// It writes whether the flush was already paused and the number of keys with unflushed changes.
+func adminFlushPause(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Holding the writes to the database is restricted to admins
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// Call service to pause the flush
+	changed := service.PauseFlush()
+	backlog := len(service.FlushBacklog())
+
+	// Format the output string with the state of the flush
+	output := fmt.Sprintf(
+		"Flush paused = true\n"+
+			"Already paused = %t\n"+
+			"Backlog = %d keys\n",
+		!changed,
+		backlog,
+	)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, FlushResponse{Paused: true, WasPaused: !changed, Backlog: backlog}, output)
+}
+
+// adminFlushResume handles POST requests to resume the periodic flush held by adminFlushPause.
+// The backlog is written right away. It writes whether the flush was paused and the number of keys waiting to be flushed.
+func adminFlushResume(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Resuming the writes to the database is restricted to admins
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// Count the backlog before the update goroutine starts writing it
+	backlog := len(service.FlushBacklog())
+
+	// Call service to resume the flush
+	changed := service.ResumeFlush()
+
+	// Format the output string with the state of the flush
+	output := fmt.Sprintf(
+		"Flush paused = false\n"+
+			"Was paused = %t\n"+
+			"Backlog = %d keys\n",
+		changed,
+		backlog,
+	)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, FlushResponse{Paused: false, WasPaused: changed, Backlog: backlog}, output)
+}
+
+// adminQuarantine handles GET requests to list the keys excluded from queries because their persisted blobs
+// can't be decoded, one per line along with the decoding error.
+func adminQuarantine(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Decoding errors reveal storage details, restrict them to admins
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// Call service to list the quarantined keys
+	keys := service.QuarantinedKeys()
+
+	// Format the output string with the count followed by every key
+	output := fmt.Sprintf("Quarantined = %d keys", len(keys))
+	response := QuarantineResponse{Keys: make([]QuarantinedKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		response.Keys = append(response.Keys, QuarantinedKeyResponse(key))
+		output += fmt.Sprintf("\n%s: %s", key.Key, key.Error)
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// adminQuarantineRelease handles POST requests to let a quarantined key be loaded again on its next access,
+// e.g. once its row was repaired. It expects the query parameter "key". A key that fails to decode again
+// is quarantined again.
+func adminQuarantineRelease(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Releasing keys is restricted to admins
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+
+	// Call service to release the key
+	if !service.ReleaseQuarantine(key) {
+		http.Error(w, "Key not quarantined", http.StatusNotFound)
+		return
+	}
+
+	// Format the output string with the released key
+	output := fmt.Sprintf("Released (%s) = true", key)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, ReleaseResponse{Key: key, Released: true}, output)
+}
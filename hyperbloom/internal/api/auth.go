@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+// publicPaths are served without credentials, so that liveness and readiness probes don't need API_KEYS.
+var publicPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// authenticate rejects requests without one of API_KEYS (or ADMIN_TOKEN) as a bearer token with 401 Unauthorized,
+// before they reach next. Requests pass unchecked while API_KEYS is empty, and the health checks always do.
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !publicPaths[r.URL.Path] && !config.ApplicationCfg().Authorized(r.Header.Get("Authorization")) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
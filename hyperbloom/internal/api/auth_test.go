@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+func TestAuthenticate(t *testing.T) {
+	saved := config.ApplicationCfg().APIKeys
+	defer config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.APIKeys = saved })
+
+	handler := authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	cases := []struct {
+		keys          []string
+		path          string
+		authorization string
+		status        int
+	}{
+		{nil, "/hyperbloom/card", "", http.StatusOK},
+		{[]string{"key"}, "/hyperbloom/card", "", http.StatusUnauthorized},
+		{[]string{"key"}, "/hyperbloom/card", "Bearer other", http.StatusUnauthorized},
+		{[]string{"key"}, "/hyperbloom/card", "Bearer key", http.StatusOK},
+		{[]string{"key"}, "/healthz", "", http.StatusOK},
+		{[]string{"key"}, "/readyz", "", http.StatusOK},
+	}
+	for _, c := range cases {
+		config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.APIKeys = c.keys })
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.authorization != "" {
+			r.Header.Set("Authorization", c.authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != c.status {
+			t.Errorf("%s with %q and keys %v: status = %d, want %d", c.path, c.authorization, c.keys, w.Code, c.status)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: missing WWW-Authenticate challenge", c.path)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+// field is a string field of a request body, checked by requireFields.
+type field struct {
+	name  string // Name of the field in the JSON body
+	value string // Value of the field
+}
+
+// writeFieldError responds with status and an ErrorResponse naming the field of the request body that failed,
+// or its text rendering to text clients.
+func writeFieldError(w http.ResponseWriter, r *http.Request, status int, name, reason string) {
+	output := fmt.Sprintf("Invalid %s: %s", name, reason)
+	writeResponseStatus(w, r, status, ErrorResponse{Error: reason, Field: name}, output)
+}
+
+// readBody reads the request body, up to MAX_BODY_BYTES. It responds 413 Request Entity Too Large and returns false
+// if the body is larger, so that a client can't exhaust the memory with a huge body, or 400 if it can't be read.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	return readBodyUpTo(w, r, config.ApplicationCfg().MaxBodyBytes)
+}
+
+// readBodyUpTo is readBody with a cap of limit bytes rather than MAX_BODY_BYTES, 0 for no cap.
+func readBodyUpTo(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	body := r.Body
+	if limit > 0 {
+		body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	bytebody, err := io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeFieldError(w, r, http.StatusRequestEntityTooLarge, "body", fmt.Sprintf("larger than %d bytes", tooLarge.Limit))
+		return nil, false
+	} else if err != nil {
+		writeFieldError(w, r, http.StatusBadRequest, "body", "can't be read")
+		return nil, false
+	}
+	return bytebody, true
+}
+
+// decodeBody decodes body, read by readBody, into the value pointed to by v: as MessagePack if the request
+// Content-Type is application/msgpack, as JSON otherwise.
+func decodeBody(r *http.Request, body []byte, v any) error {
+	if requestFormat(r) == formatMsgpack {
+		return unmarshalMsgpack(body, v)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// requireFields checks that none of fields is empty, before the service layer is involved.
+// It responds 400 naming the first empty one and returns false otherwise.
+func requireFields(w http.ResponseWriter, r *http.Request, fields ...field) bool {
+	for _, f := range fields {
+		if f.value == "" {
+			writeFieldError(w, r, http.StatusBadRequest, f.name, "must not be empty")
+			return false
+		}
+	}
+	return true
+}
+
+// requireKeys checks that keys, the "keys" field of a request body, lists at least one key and no empty one.
+// It responds 400 and returns false otherwise.
+func requireKeys(w http.ResponseWriter, r *http.Request, keys []string) bool {
+	if len(keys) == 0 {
+		writeFieldError(w, r, http.StatusBadRequest, "keys", "must list at least one key")
+		return false
+	}
+	for i, key := range keys {
+		if key == "" {
+			writeFieldError(w, r, http.StatusBadRequest, "keys", fmt.Sprintf("key %d must not be empty", i))
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+func TestRequestBodyValidation(t *testing.T) {
+	saved := *config.ApplicationCfg()
+	defer config.SetApplicationCfg(saved)
+	config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.MaxBodyBytes = 1024 })
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		status  int
+		field   string
+	}{
+		{"oversized body", bloomHash, `{"key": "k", "value": "` + strings.Repeat("v", 2048) + `"}`, http.StatusRequestEntityTooLarge, "body"},
+		{"empty key", bloomHash, `{"value": "v"}`, http.StatusBadRequest, "key"},
+		{"empty value", bloomExists, `{"key": "k", "value": ""}`, http.StatusBadRequest, "value"},
+		{"empty second key", bloomSim, `{"key_1": "a"}`, http.StatusBadRequest, "key_2"},
+		{"no keys", bloomBitwiseExists, `{"keys": [], "value": "v"}`, http.StatusBadRequest, "keys"},
+		{"empty key in keys", bloomChainingExists, `{"keys": ["a", ""], "value": "v"}`, http.StatusBadRequest, "keys"},
+		{"unknown operator", bloomBitwiseExists, `{"keys": ["a"], "value": "v", "operator": "NAND"}`, http.StatusBadRequest, "operator"},
+		{"empty cuckoo value", cuckooAdd, `{"key": "k"}`, http.StatusBadRequest, "value"},
+	}
+
+	// The requests are rejected before the service is involved, naming the field that failed
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/hyperbloom/test", strings.NewReader(c.body))
+		r.Header.Set("Accept", "application/json")
+		c.handler(w, r)
+
+		if w.Code != c.status {
+			t.Errorf("%s: status = %d, want %d", c.name, w.Code, c.status)
+			continue
+		}
+		response := ErrorResponse{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("%s: invalid JSON error %q: %v", c.name, w.Body.String(), err)
+		} else if response.Field != c.field || response.Error == "" {
+			t.Errorf("%s: error = %+v, want one for field %q", c.name, response, c.field)
+		}
+	}
+}
@@ -2,92 +2,338 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"gopds/hyperbloom/internal/config"
 	"gopds/hyperbloom/internal/service"
 	"io"
-	"log"
+	"log/slog"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // bloomHash handles POST requests for hashing a value and adding it to the Bloom filter.
-// It expects a JSON body with "key" and "value" fields.
+// It expects a JSON body with "key" and "value" fields, and optionally "type" (declared type of the value).
+// A value whose type differs from the one established for the key is rejected with 409 Conflict.
+// A new key is created with the optional "capacity" and "fpr" fields, defaulting to HB_CARD and HB_FP when omitted;
+// they are ignored for existing keys, unless they differ from the ones the key was created with (409 Conflict).
+// With HB_TRANSFORM_URL, the value is preprocessed by the webhook first, as it is by every endpoint taking values.
 func bloomHash(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("[POST]", r.URL.Path, r.Header["Content-Type"])
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
 
-	// Read the request body
-	bytebody, _ := io.ReadAll(r.Body)
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
 	defer r.Body.Close()
+	if !ok {
+		return
+	}
 
 	// Struct to unmarshal the JSON body
 	jsonbody := &struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
+		Key      string  `json:"key"`
+		Value    string  `json:"value"`
+		Type     string  `json:"type"`
+		Capacity uint    `json:"capacity"`
+		FPR      float64 `json:"fpr"`
 	}{}
 
-	// Unmarshal the JSON body into the struct
-	if err := json.Unmarshal(bytebody, &jsonbody); err != nil {
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
 		// If there's an error decoding JSON, respond with a Bad Request status
 		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
-		log.Println("Error decoding JSON body:", err)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}, field{"value", jsonbody.Value}) {
+		return
+	}
+
+	if jsonbody.FPR < 0 || jsonbody.FPR >= 1 {
+		http.Error(w, "Invalid fpr", http.StatusBadRequest)
 		return
 	}
 
-	// Add the value to the Bloom filter using the provided key
-	service.BloomHash(jsonbody.Key, jsonbody.Value)
+	// Preprocess the value with the transformation webhook, if configured
+	value, ok := transformValue(w, r, jsonbody.Value)
+	if !ok {
+		return
+	}
+
+	// Create a new key with the requested sizing, or check it against the existing one
+	if jsonbody.Capacity != 0 || jsonbody.FPR != 0 {
+		err := service.BloomEnsureSizing(r.Context(), jsonbody.Key, jsonbody.Capacity, jsonbody.FPR)
+		if errors.Is(err, service.ErrSizingConflict) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if errors.Is(err, service.ErrInfeasibleSizing) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			http.Error(w, "Can't create key", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Can't create key", "key", jsonbody.Key, "err", err)
+			return
+		}
+	}
+
+	// Add the value to the Bloom filter using the provided key, checking its declared type if any
+	err := service.BloomHashTyped(r.Context(), jsonbody.Key, value, jsonbody.Type)
+	if errors.Is(err, service.ErrValueTypeMismatch) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if errors.Is(err, service.ErrKeyQuarantined) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't hash value", "key", jsonbody.Key, "err", err)
+		return
+	}
 
 	// Get the cardinality of the Bloom filter and HyperLogLog
-	bCard, hCard := service.BloomCardinality(jsonbody.Key)
+	bCard, hCard, err := service.BloomCardinality(r.Context(), jsonbody.Key)
+	if err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't compute cardinality", "key", jsonbody.Key, "err", err)
+		return
+	}
 
 	// Format the output string
 	output := fmt.Sprintf("Cardinality (bloom, hyperloglog) = (%d, %d)", bCard, hCard)
 
-	// Write the output string to the response
-	w.Write([]byte(output))
+	// Write the response, or the output string to text clients
+	writeResponse(w, r, CardinalityResponse{BloomCardinality: bCard, HLLCardinality: hCard}, output)
+}
+
+// bloomHashBatch handles POST requests to add several values to the Bloom filter at once.
+// It expects a JSON body with "key" and "values" fields, the filters are updated once for the whole batch
+// and written by a single flush. It responds with the number of values hashed and the resulting cardinalities.
+func bloomHashBatch(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key    string   `json:"key"`
+		Values []string `json:"values"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}) {
+		return
+	}
+
+	// Preprocess the values with the transformation webhook, in a single call for the batch
+	values, ok := transformValues(w, r, jsonbody.Values)
+	if !ok {
+		return
+	}
+
+	// Add the values to the Bloom filter using the provided key
+	err := service.BloomHashBatch(r.Context(), jsonbody.Key, values)
+	if errors.Is(err, service.ErrKeyQuarantined) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't hash values", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Get the cardinality of the Bloom filter and HyperLogLog
+	bCard, hCard, err := service.BloomCardinality(r.Context(), jsonbody.Key)
+	if err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't compute cardinality", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("Hashed %d values, cardinality (bloom, hyperloglog) = (%d, %d)", len(values), bCard, hCard)
+
+	// Write the response, or the output string to text clients
+	response := HashBatchResponse{
+		Hashed:              len(values),
+		CardinalityResponse: CardinalityResponse{BloomCardinality: bCard, HLLCardinality: hCard},
+	}
+	writeResponse(w, r, response, output)
 }
 
 // bloomExists handles POST requests to check if a value exists in the Bloom filter.
-// It expects a JSON body with "key" and "value" fields.
+// It expects a JSON body with "key" and "value" fields, and optionally "method" ("bloom", the default, or "hll"
+// to ask whether the value would change the HyperLogLog estimate, a much weaker signal for saturated filters).
+// The method that answered is written along with the result, a key that doesn't exist is answered with 404.
 func bloomExists(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("[POST]", r.URL.Path, r.Header["Content-Type"])
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
 
-	// Read the request body
-	bytebody, _ := io.ReadAll(r.Body)
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
 	defer r.Body.Close()
+	if !ok {
+		return
+	}
 
 	// Struct to unmarshal the JSON body
 	jsonbody := &struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
+		Key    string `json:"key"`
+		Value  string `json:"value"`
+		Method string `json:"method"`
 	}{}
 
-	// Unmarshal the JSON body into the struct
-	if err := json.Unmarshal(bytebody, &jsonbody); err != nil {
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
 		// If there's an error decoding JSON, respond with a Bad Request status
 		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
-		log.Println("Error decoding JSON body:", err)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}, field{"value", jsonbody.Value}) {
+		return
+	}
+
+	// Preprocess the value the same way it was when inserted
+	value, ok := transformValue(w, r, jsonbody.Value)
+	if !ok {
 		return
 	}
 
 	// Check if the value exists in the Bloom filter using the provided key
-	exists := service.BloomExists(jsonbody.Key, jsonbody.Value)
+	exists, method, err := service.BloomExistsMethod(r.Context(), jsonbody.Key, value, jsonbody.Method)
+	switch {
+	case errors.Is(err, service.ErrInvalidExistsMethod):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrKeyNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrKeyQuarantined):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case err != nil:
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't check existence", "key", jsonbody.Key, "err", err)
+		return
+	}
 
 	// Format the output string
 	output := fmt.Sprintf(
-		"(%s) ⪽ (%s) = %t\n",
+		"(%s) ⪽ (%s) = %t\n"+
+			"Method = %s",
 		jsonbody.Value,
 		jsonbody.Key,
 		exists,
+		method,
 	)
 
-	// Write the output string to the response
-	w.Write([]byte(output))
+	// Write the response, or the output string to text clients
+	writeResponse(w, r, ExistsResponse{Key: jsonbody.Key, Value: jsonbody.Value, Exists: exists, Method: method}, output)
+}
+
+// bloomExistsBatch handles POST requests to check several values against the Bloom filter of one key at once.
+// It expects a JSON body with "key" and "values" fields, the key is loaded once for the whole batch.
+// It responds with an array of booleans in the order of the values, a key that doesn't exist is answered with 404.
+func bloomExistsBatch(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key    string   `json:"key"`
+		Values []string `json:"values"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}) {
+		return
+	}
+
+	// Preprocess the values the same way they were when inserted, in a single call for the batch
+	values, ok := transformValues(w, r, jsonbody.Values)
+	if !ok {
+		return
+	}
+
+	// Check the values against the Bloom filter using the provided key
+	exists, err := service.BloomExistsBatch(r.Context(), jsonbody.Key, values)
+	switch {
+	case errors.Is(err, service.ErrKeyNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrKeyQuarantined):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case err != nil:
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't check existence", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Format the output string, one line per value
+	lines := make([]string, len(exists))
+	for i, value := range jsonbody.Values {
+		lines[i] = fmt.Sprintf("(%s) ⪽ (%s) = %t", value, jsonbody.Key, exists[i])
+	}
+
+	// Write the response, or the output string to text clients
+	writeResponse(w, r, exists, strings.Join(lines, "\n"))
 }
 
 // bloomCard handles GET requests to compute approximate cardinality of the key.
-// It expects query parameter "key" of type string.
+// It expects query parameter "key" of type string, and also writes whether the key is empty and when it was created.
+// With "decayed=true", it also writes the time-decayed distinct count of a key created with a half-life,
+// which fades once insertions stop where the lifetime cardinality doesn't.
 func bloomCard(w http.ResponseWriter, r *http.Request) {
-	// Log the request details (HTTP method, URL path, and Content-Type header)
-	fmt.Println("[GET]", r.URL.Path, r.Header["Content-Type"])
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
 
 	// Parse query parameters from the request URL
 	queries := r.URL.Query()
@@ -96,24 +342,138 @@ func bloomCard(w http.ResponseWriter, r *http.Request) {
 	// Check if the 'key' query parameter is present and not empty
 	if key != "" {
 		// Call service to get the cardinality of the Bloom filter and HyperLogLog for the given key
-		bCard, hCard := service.BloomCardinality(key)
+		state, err := service.BloomCardinalityState(r.Context(), key)
+		if errors.Is(err, service.ErrKeyNotFound) {
+			http.Error(w, "Key not found", http.StatusNotFound)
+			return
+		}
+
+		// Format the output string with the cardinality values and the state of the key
+		output := fmt.Sprintf(
+			"Cardinality (bloom, hyperloglog) = (%d, %d)\n"+
+				"Empty = %t\n"+
+				"Created at = %s",
+			state.BloomCardinality, state.HyperCardinality,
+			state.Empty,
+			formatCreatedAt(state.CreatedAt),
+		)
+
+		response := CardResponse{
+			CardinalityResponse: CardinalityResponse{BloomCardinality: state.BloomCardinality, HLLCardinality: state.HyperCardinality},
+			Empty:               state.Empty,
+			CreatedAt:           timePtr(state.CreatedAt),
+		}
+
+		// Append the time-decayed distinct count if requested
+		if queries.Get("decayed") == "true" {
+			decayed, halfLife, err := service.BloomDecayedCardinality(r.Context(), key)
+			if errors.Is(err, service.ErrRecencyNotTracked) {
+				http.Error(w, "Decayed cardinality is not tracked for this key, create it with a half_life", http.StatusUnprocessableEntity)
+				return
+			}
+			output += fmt.Sprintf("\nDecayed cardinality (half-life %s) = %.1f", halfLife, decayed)
+			response.DecayedCardinality, response.HalfLife = &decayed, halfLife.String()
+		}
 
-		// Format the output string with the cardinality values
-		output := fmt.Sprintf("Cardinality (bloom, hyperloglog) = (%d, %d)", bCard, hCard)
+		// Write the response, or the formatted output string to text clients
+		writeResponse(w, r, response, output)
+	}
+}
 
-		// Write the formatted output string to the HTTP response
-		w.Write([]byte(output))
+// formatCreatedAt formats the creation time of a key, which is unknown for keys created before it was recorded.
+func formatCreatedAt(createdAt time.Time) string {
+	if createdAt.IsZero() {
+		return "unknown"
 	}
+	return createdAt.Format(time.RFC3339)
 }
 
 // bloomSim handles POST requests to calculate Bloom filter similarity.
-// It expects a JSON body with "key_1" and "key_2" fields.
+// It expects a JSON body with "key_1" and "key_2" fields, and an optional "on_mismatch" field choosing what happens
+// when the filters differ in size: "error" (default) or "hll" to fall back to the HyperLogLog estimate.
+// The HyperLogLog estimate is written alongside as "hll_similarity", see service.BloomHyperSimilarity for which
+// of the two to trust.
 func bloomSim(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("[POST]", r.URL.Path, r.Header["Content-Type"])
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key1       string `json:"key_1"`
+		Key2       string `json:"key_2"`
+		OnMismatch string `json:"on_mismatch"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key_1", jsonbody.Key1}, field{"key_2", jsonbody.Key2}) {
+		return
+	}
+
+	// Calculate Bloom filter similarity using service function
+	sim, fallback, err := service.BloomSimilarityOnMismatch(r.Context(), jsonbody.Key1, jsonbody.Key2, jsonbody.OnMismatch)
+	switch {
+	case errors.Is(err, service.ErrInvalidOnMismatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrIncompatibleFilters):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Estimate the similarity from the sketches as well, unless the fallback already did
+	response := SimilarityResponse{Similarity: sim, Fallback: fallback}
+	if fallback {
+		response.HLLSimilarity = &sim
+	} else if hllSim, err := service.BloomHyperSimilarity(r.Context(), jsonbody.Key1, jsonbody.Key2); err == nil {
+		response.HLLSimilarity = &hllSim
+	}
+
+	// Format the output string with the calculated similarities and whether the sketches were used
+	output := fmt.Sprintf(
+		"Jaccard similarity = %f\n"+
+			"Fallback = %t",
+		sim,
+		fallback,
+	)
+	if response.HLLSimilarity != nil {
+		output += fmt.Sprintf("\nHyperLogLog similarity = %f", *response.HLLSimilarity)
+	}
 
-	// Read the request body
-	bytebody, _ := io.ReadAll(r.Body)
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomOverlapCoefficient handles POST requests to estimate the overlap coefficient between two keys.
+// It expects a JSON body with "key_1" and "key_2" fields, and writes the coefficient along with its components.
+func bloomOverlapCoefficient(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
 	defer r.Body.Close()
+	if !ok {
+		return
+	}
 
 	// Struct to unmarshal the JSON body
 	jsonbody := &struct {
@@ -121,32 +481,204 @@ func bloomSim(w http.ResponseWriter, r *http.Request) {
 		Key2 string `json:"key_2"`
 	}{}
 
-	// Unmarshal the JSON body into the struct
-	if err := json.Unmarshal(bytebody, &jsonbody); err != nil {
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
 		// If there's an error decoding JSON, respond with a Bad Request status
 		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
-		log.Println("Error decoding JSON body:", err)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
 		return
 	}
 
-	// Calculate Bloom filter similarity using service function
-	sim := service.BloomSimilarity(jsonbody.Key1, jsonbody.Key2)
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key_1", jsonbody.Key1}, field{"key_2", jsonbody.Key2}) {
+		return
+	}
+
+	// Estimate the components using service function
+	c, err := service.BloomOverlap(r.Context(), jsonbody.Key1, jsonbody.Key2)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, service.ErrIncompatibleSketch) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Format the output string with the coefficient followed by its components
+	output := fmt.Sprintf(
+		"Overlap coefficient = %f\n"+
+			"Cardinality (%s, %s) = (%d, %d)\n"+
+			"Union = %d\n"+
+			"Intersection = %d",
+		c.Coefficient(),
+		jsonbody.Key1, jsonbody.Key2, c.Cardinality1, c.Cardinality2,
+		c.Union,
+		c.Intersection,
+	)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, OverlapResponse{
+		Coefficient:  c.Coefficient(),
+		Cardinality1: c.Cardinality1,
+		Cardinality2: c.Cardinality2,
+		Union:        c.Union,
+		Intersection: c.Intersection,
+	}, output)
+}
+
+// bloomDistance handles POST requests to estimate the Jaccard distance (1 - similarity) between two keys.
+// It expects a JSON body with "key_1" and "key_2" fields, and an optional "on_mismatch" field as for bloomSim,
+// and writes the distance with its error bound.
+func bloomDistance(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key1       string `json:"key_1"`
+		Key2       string `json:"key_2"`
+		OnMismatch string `json:"on_mismatch"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key_1", jsonbody.Key1}, field{"key_2", jsonbody.Key2}) {
+		return
+	}
+
+	// Estimate the distance using service function
+	estimate, err := service.BloomDistance(r.Context(), jsonbody.Key1, jsonbody.Key2, jsonbody.OnMismatch)
+	switch {
+	case errors.Is(err, service.ErrKeyNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrInvalidOnMismatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrIncompatibleFilters), errors.Is(err, service.ErrIncompatibleSketch):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Format the output string with the distance followed by what its error bound derives from
+	output := fmt.Sprintf(
+		"Jaccard distance = %f ± %f\n"+
+			"Jaccard similarity = %f\n"+
+			"Fill (%s, %s) = (%f, %f)\n"+
+			"Fallback = %t",
+		estimate.Distance, estimate.ErrorBound,
+		estimate.Similarity,
+		jsonbody.Key1, jsonbody.Key2, estimate.Fill1, estimate.Fill2,
+		estimate.Fallback,
+	)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, DistanceResponse{
+		Distance:   estimate.Distance,
+		ErrorBound: estimate.ErrorBound,
+		Similarity: estimate.Similarity,
+		Fill1:      estimate.Fill1,
+		Fill2:      estimate.Fill2,
+		Fallback:   estimate.Fallback,
+	}, output)
+}
+
+// bloomSimMatrix handles POST requests to compute the pairwise Jaccard similarities between Bloom filters.
+// It expects a JSON body with a "keys" field, and an optional "on_mismatch" field as for bloomSim,
+// and writes one row of similarities per key in the same order.
+func bloomSimMatrix(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Keys       []string `json:"keys"`
+		OnMismatch string   `json:"on_mismatch"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireKeys(w, r, jsonbody.Keys) {
+		return
+	}
+
+	// Calculate the similarity matrix using service function
+	matrix, err := service.BloomSimilarityMatrix(r.Context(), jsonbody.Keys, jsonbody.OnMismatch)
+	switch {
+	case errors.Is(err, service.ErrInvalidOnMismatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrIncompatibleFilters), errors.Is(err, service.ErrIncompatibleSketch):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// Format the output string with the calculated similarity
-	output := fmt.Sprintf("Jaccard similarity = %f", sim)
+	// Format the output string with one row per key
+	output := "Jaccard similarity matrix"
+	for i, row := range matrix {
+		output += fmt.Sprintf("\n%s =", jsonbody.Keys[i])
+		for _, sim := range row {
+			output += fmt.Sprintf(" %f", sim)
+		}
+	}
 
-	// Write the formatted output string to the HTTP response
-	w.Write([]byte(output))
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, SimilarityMatrixResponse{Keys: jsonbody.Keys, Matrix: matrix}, output)
 }
 
 // bloomBitwiseExists handles POST requests to check bitwise existence in Bloom filters.
-// It expects a JSON body with "keys", "value", and "operator" fields.
+// It expects a JSON body with "keys", "value", and "operator" ("AND", "OR" or "XOR", HB_DEFAULT_OPERATOR when omitted) fields.
+// It responds 400 Bad Request if the filters of the keys differ in size or number of hash functions.
 func bloomBitwiseExists(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("[POST]", r.URL.Path, r.Header["Content-Type"])
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
 
-	// Read the request body
-	bytebody, _ := io.ReadAll(r.Body)
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
 	defer r.Body.Close()
+	if !ok {
+		return
+	}
 
 	// Struct to unmarshal the JSON body
 	jsonbody := &struct {
@@ -155,36 +687,70 @@ func bloomBitwiseExists(w http.ResponseWriter, r *http.Request) {
 		Operator string   `json:"operator"`
 	}{}
 
-	// Unmarshal the JSON body into the struct
-	if err := json.Unmarshal(bytebody, &jsonbody); err != nil {
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
 		// If there's an error decoding JSON, respond with a Bad Request status
 		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
-		log.Println("Error decoding JSON body:", err)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireKeys(w, r, jsonbody.Keys) || !requireFields(w, r, field{"value", jsonbody.Value}) {
+		return
+	}
+
+	// An omitted operator falls back to the configured default
+	operator, err := service.ResolveOperator(jsonbody.Operator)
+	if err != nil {
+		writeFieldError(w, r, http.StatusBadRequest, "operator", fmt.Sprintf("must be one of %v", service.Operators))
+		return
+	}
+
+	// Preprocess the value the same way it was when inserted
+	value, ok := transformValue(w, r, jsonbody.Value)
+	if !ok {
 		return
 	}
 
 	// Call service to determine bitwise existence
-	bitResult := service.BloomBitwiseExists(
+	bitResult, err := service.BloomBitwiseExists(
+		r.Context(),
 		jsonbody.Keys,
-		jsonbody.Value,
-		jsonbody.Operator,
+		value,
+		operator,
 	)
+	switch {
+	case errors.Is(err, service.ErrInvalidOperator):
+		writeFieldError(w, r, http.StatusBadRequest, "operator", err.Error())
+		return
+	case errors.Is(err, service.ErrIncompatibleFilters):
+		// Bit arrays of different sizes can't be combined
+		writeFieldError(w, r, http.StatusBadRequest, "keys", err.Error())
+		return
+	}
 
 	// Prepare output based on bitwise result
-	output := fmt.Sprintf("%s bitwise exists = %t", jsonbody.Operator, bitResult)
+	output := fmt.Sprintf("%s bitwise exists = %t", operator, bitResult)
 
-	// Write response to the client
-	w.Write([]byte(output))
+	// Write the response, or the output string to text clients
+	writeResponse(w, r, MultiExistsResponse{Operator: string(operator), Exists: bitResult}, output)
 }
 
 // bloomChainingExists handles POST requests to check chaining existence in Bloom filters.
-// It expects a JSON body with "keys", "value", and "operator" fields.
+// It expects a JSON body with "keys", "value", and "operator" ("AND", "OR" or "XOR", HB_DEFAULT_OPERATOR when omitted) fields.
 func bloomChainingExists(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("[POST]", r.URL.Path, r.Header["Content-Type"])
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
 
-	// Read the request body
-	bytebody, _ := io.ReadAll(r.Body)
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
 	defer r.Body.Close()
+	if !ok {
+		return
+	}
 
 	// Struct to unmarshal the JSON body
 	jsonbody := &struct {
@@ -193,24 +759,1610 @@ func bloomChainingExists(w http.ResponseWriter, r *http.Request) {
 		Operator string   `json:"operator"`
 	}{}
 
-	// Unmarshal the JSON body into the struct
-	if err := json.Unmarshal(bytebody, &jsonbody); err != nil {
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
 		// If there's an error decoding JSON, respond with a Bad Request status
 		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
-		log.Println("Error decoding JSON body:", err)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireKeys(w, r, jsonbody.Keys) || !requireFields(w, r, field{"value", jsonbody.Value}) {
+		return
+	}
+
+	// An omitted operator falls back to the configured default
+	operator, err := service.ResolveOperator(jsonbody.Operator)
+	if err != nil {
+		writeFieldError(w, r, http.StatusBadRequest, "operator", fmt.Sprintf("must be one of %v", service.Operators))
+		return
+	}
+
+	// Preprocess the value the same way it was when inserted
+	value, ok := transformValue(w, r, jsonbody.Value)
+	if !ok {
 		return
 	}
 
 	// Call service to check existence of value in Bloom filters associated with keys
-	bitResult := service.BloomChainingExists(
+	bitResult, err := service.BloomChainingExists(
+		r.Context(),
 		jsonbody.Keys,
-		jsonbody.Value,
-		jsonbody.Operator,
+		value,
+		operator,
 	)
+	if errors.Is(err, service.ErrInvalidOperator) {
+		writeFieldError(w, r, http.StatusBadRequest, "operator", err.Error())
+		return
+	}
 
 	// Format the output string with the calculated result
-	output := fmt.Sprintf("%s chaining exists = %t", jsonbody.Operator, bitResult)
+	output := fmt.Sprintf("%s chaining exists = %t", operator, bitResult)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, MultiExistsResponse{Operator: string(operator), Exists: bitResult}, output)
+}
+
+// bloomSizing handles GET requests to compute the Bloom filter parameters for a desired capacity.
+// It expects query parameters "n" (expected cardinality) and "fpr" (false positive rate).
+func bloomSizing(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// The expected cardinality must be a positive integer
+	n, err := paramN.required(r)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	// The false positive rate must lie strictly between 0 and 1
+	fpr, err := paramFPR.required(r)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	// Call service to compute the sizing without creating anything
+	m, k, bytes, err := service.BloomSizing(uint(n), fpr)
+	if errors.Is(err, service.ErrInfeasibleSizing) {
+		// Explain why the requested parameters can't be served
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Format the output string with the computed parameters
+	output := fmt.Sprintf("Sizing (m, k, bytes) = (%d, %d, %d)", m, k, bytes)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, SizingResponse{M: m, K: k, Bytes: bytes}, output)
+}
+
+// bloomLookupCount handles POST requests to count the keys that probably contain a value.
+// It expects a JSON body with "value" and optionally "prefix" (only count keys starting with it).
+// False positives inflate the count, the number of them to expect is written along with it.
+func bloomLookupCount(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Value  string `json:"value"`
+		Prefix string `json:"prefix"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"value", jsonbody.Value}) {
+		return
+	}
+
+	// Preprocess the value the same way it was when inserted
+	value, ok := transformValue(w, r, jsonbody.Value)
+	if !ok {
+		return
+	}
+
+	// Call service to check the value against every key
+	result, err := service.BloomLookupCount(r.Context(), value, jsonbody.Prefix)
+	if err != nil {
+		http.Error(w, "Can't list keys", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't list keys", "err", err)
+		return
+	}
+
+	// Format the output string with the count and how much of it may be false positives
+	output := fmt.Sprintf(
+		"Lookup count (%s) = %d of %d keys\n"+
+			"Expected false positives = %.2f",
+		jsonbody.Value,
+		result.Count, result.Scanned,
+		result.ExpectedFalsePositives,
+	)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, LookupCountResponse{
+		Value:                  jsonbody.Value,
+		Count:                  result.Count,
+		Scanned:                result.Scanned,
+		ExpectedFalsePositives: result.ExpectedFalsePositives,
+	}, output)
+}
+
+// bloomAggregateCard handles GET requests to compute the combined cardinality of all keys matching a composite key pattern.
+// It expects query parameter "pattern" (e.g. "US:*") with a wildcard in at most one segment.
+func bloomAggregateCard(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	pattern := r.URL.Query().Get("pattern")
+
+	// Call service to union the sketches of the matching keys
+	keys, hCard, err := service.BloomAggregateCardinality(r.Context(), pattern)
+	if errors.Is(err, service.ErrInvalidPattern) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if errors.Is(err, service.ErrIncompatibleSketch) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Can't list keys", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't list keys", "err", err)
+		return
+	}
+
+	// Format the output string with the combined cardinality
+	output := fmt.Sprintf("Cardinality (hyperloglog) of %s over %d keys = %d", pattern, len(keys), hCard)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, AggregateCardinalityResponse{Pattern: pattern, Keys: keys, HLLCardinality: hCard}, output)
+}
+
+// bloomUnionCard handles POST requests to compute the combined cardinality of several keys, by merging their
+// HyperLogLog sketches. It expects a JSON body with a "keys" array, every key must exist and share the same precision.
+func bloomUnionCard(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Keys []string `json:"keys"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireKeys(w, r, jsonbody.Keys) {
+		return
+	}
+	if len(jsonbody.Keys) == 0 {
+		http.Error(w, "At least one key is required", http.StatusBadRequest)
+		return
+	}
+
+	// Call service to union the sketches of the keys
+	hCard, err := service.HLLUnionCardinality(r.Context(), jsonbody.Keys)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, service.ErrIncompatibleSketch) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't union keys", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't merge sketches", "keys", jsonbody.Keys, "err", err)
+		return
+	}
+
+	// Format the output string with the combined cardinality
+	output := fmt.Sprintf("Cardinality (hyperloglog) of the union of %d keys = %d", len(jsonbody.Keys), hCard)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, UnionCardinalityResponse{Keys: jsonbody.Keys, HLLCardinality: hCard}, output)
+}
+
+// bloomInfo handles GET requests to describe the configuration, content and memory footprint of a key.
+// It expects query parameter "key" of type string.
+func bloomInfo(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+
+	// Call service to describe the HyperBloom
+	info := service.BloomInfo(r.Context(), key)
+	if info == nil {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	// Format the output string, one property per line
+	output := fmt.Sprintf(
+		"Key = %s\n"+
+			"Bit capacity (m) = %d\n"+
+			"Hash functions (k) = %d\n"+
+			"Strict membership = %t\n"+
+			"Counting = %t\n"+
+			"Top-k capacity = %d\n"+
+			"Empty = %t\n"+
+			"Created at = %s\n"+
+			"Cardinality (bloom, hyperloglog) = (%d, %d)\n"+
+			"Memory bytes = %d\n",
+		info.Key,
+		info.BitCapacity,
+		info.HashFunctions,
+		info.Strict,
+		info.Counting,
+		info.TopKCapacity,
+		info.Empty,
+		formatCreatedAt(info.CreatedAt),
+		info.BloomCardinality, info.HyperCardinality,
+		info.MemoryBytes,
+	)
+
+	response := InfoResponse{
+		Key:                 info.Key,
+		BitCapacity:         info.BitCapacity,
+		HashFunctions:       info.HashFunctions,
+		Strict:              info.Strict,
+		Counting:            info.Counting,
+		TopKCapacity:        info.TopKCapacity,
+		Empty:               info.Empty,
+		CreatedAt:           timePtr(info.CreatedAt),
+		MemoryBytes:         info.MemoryBytes,
+		CardinalityResponse: CardinalityResponse{BloomCardinality: info.BloomCardinality, HLLCardinality: info.HyperCardinality},
+	}
+
+	// Memory savings are only known when value lengths are tracked
+	if info.ExactSetBytes > 0 {
+		output += fmt.Sprintf(
+			"Average value length = %.2f\n"+
+				"Exact set bytes (estimated) = %d\n"+
+				"Savings ratio (exact / actual) = %.2f\n",
+			info.AvgValueLength,
+			info.ExactSetBytes,
+			info.SavingsRatio,
+		)
+		response.AvgValueLength, response.ExactSetBytes, response.SavingsRatio = info.AvgValueLength, info.ExactSetBytes, info.SavingsRatio
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomExport handles GET requests to back up the filters and sketch of a key. It expects query parameter "key"
+// and writes the binary encoding of the key, starting with its format version, which bloomImport takes back.
+func bloomExport(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+
+	// Call service to encode the key
+	encoded, err := service.BloomExport(r.Context(), key)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't export key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't export key", "key", key, "err", err)
+		return
+	}
+
+	// Write the encoding as a download named after the key
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(encoded)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": key + ".hbl"}))
+	w.Write(encoded)
+}
+
+// bloomImport handles POST requests to load a backup written by bloomExport under a key. It expects query parameter
+// "key" and optionally "force" ("true" to replace an existing key), and the binary encoding as the body, up to
+// MAX_IMPORT_BYTES. Encodings in an older format are migrated, newer or corrupted ones are rejected.
+func bloomImport(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	queries := r.URL.Query()
+	key := queries.Get("key")
+	force := queries.Get("force") == "true"
+	if key == "" {
+		writeParamError(w, &paramError{"key", "missing"})
+		return
+	}
+
+	// Read the request body, up to MAX_IMPORT_BYTES
+	encoded, ok := readBodyUpTo(w, r, config.ApplicationCfg().MaxImportBytes)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Call service to decode and persist the key
+	err := service.BloomImport(r.Context(), key, encoded, force)
+	switch {
+	case errors.Is(err, service.ErrInvalidBackup):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrKeyExists):
+		http.Error(w, "Key already exists, set force=true to replace it", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Can't import key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't import key", "key", key, "err", err)
+		return
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("Import (%s) = %d bytes", key, len(encoded))
+
+	// Write the response, or the output string to text clients
+	writeResponse(w, r, BackupImportResponse{Key: key, Bytes: len(encoded)}, output)
+}
+
+// bloomSwap handles POST requests to atomically swap the HyperBlooms behind two keys.
+// It expects a JSON body with "key_1" and "key_2" fields.
+func bloomSwap(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key1 string `json:"key_1"`
+		Key2 string `json:"key_2"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key_1", jsonbody.Key1}, field{"key_2", jsonbody.Key2}) {
+		return
+	}
+
+	// Swapping a key with itself is most likely a client mistake
+	if jsonbody.Key1 == jsonbody.Key2 {
+		http.Error(w, "key_1 and key_2 must be different", http.StatusBadRequest)
+		return
+	}
+
+	// Call service to swap the keys in memory and in the database
+	err := service.BloomSwap(r.Context(), jsonbody.Key1, jsonbody.Key2)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Can't swap keys", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't swap keys", "key_1", jsonbody.Key1, "key_2", jsonbody.Key2, "err", err)
+		return
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("Swapped (%s) ⇄ (%s)", jsonbody.Key1, jsonbody.Key2)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, SwapResponse{Key1: jsonbody.Key1, Key2: jsonbody.Key2}, output)
+}
+
+// bloomDelete handles POST requests to delete a key, its filters and its metadata, from memory and the database.
+// It expects a JSON body with a "key" field, and responds 204 No Content once deleted.
+func bloomDelete(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key string `json:"key"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}) {
+		return
+	}
+
+	// Call service to delete the key from memory and the database
+	err := service.BloomDelete(r.Context(), jsonbody.Key)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Can't delete key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't delete key", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Nothing is left to describe
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bloomReset handles POST requests to empty the filters of a key while keeping its configuration and metadata,
+// e.g. before a rebuild. It expects a JSON body with a "key" field, and responds with the cardinalities once emptied.
+func bloomReset(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key string `json:"key"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}) {
+		return
+	}
+
+	// Call service to empty the key, the next flush writes it
+	err := service.BloomReset(r.Context(), jsonbody.Key)
+	switch {
+	case errors.Is(err, service.ErrKeyNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrKeyQuarantined):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case err != nil:
+		http.Error(w, "Can't reset key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't reset key", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Get the cardinality of the Bloom filter and HyperLogLog
+	bCard, hCard, err := service.BloomCardinality(r.Context(), jsonbody.Key)
+	if err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't compute cardinality", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("Reset (%s), cardinality (bloom, hyperloglog) = (%d, %d)", jsonbody.Key, bCard, hCard)
+
+	// Write the response, or the output string to text clients
+	response := ResetResponse{
+		Key:                 jsonbody.Key,
+		CardinalityResponse: CardinalityResponse{BloomCardinality: bCard, HLLCardinality: hCard},
+	}
+	writeResponse(w, r, response, output)
+}
+
+// bloomRemove handles POST requests to remove a value from a key created as a counting filter, see bloomCreate.
+// It expects a JSON body with "key" and "value" fields, and responds whether the value was reported present.
+// Removals are approximate: removing a value never inserted may make others read as absent, and the HyperLogLog
+// cardinality doesn't drop.
+func bloomRemove(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}, field{"value", jsonbody.Value}) {
+		return
+	}
+
+	// Preprocess the value the same way it was when inserted
+	value, ok := transformValue(w, r, jsonbody.Value)
+	if !ok {
+		return
+	}
+
+	// Call service to remove the value, the next flush writes it
+	removed, err := service.BloomRemove(r.Context(), jsonbody.Key, value)
+	switch {
+	case errors.Is(err, service.ErrKeyNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrNotCounting):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrKeyQuarantined):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case err != nil:
+		http.Error(w, "Can't remove value", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't remove value", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("Remove (%s) from (%s) = %t", jsonbody.Value, jsonbody.Key, removed)
+
+	// Write the response, or the output string to text clients
+	writeResponse(w, r, RemoveResponse{Key: jsonbody.Key, Value: jsonbody.Value, Removed: removed}, output)
+}
+
+// bloomRemoveBatch handles POST requests to remove several values at once from a key created as a counting filter.
+// It expects a JSON body with "key" and "values" fields, the values are removed under a single lock and written by
+// a single flush. It responds with the number of values removed, and the values already at zero: reported absent,
+// they were likely never inserted or already removed, and are left alone so that their counters don't underflow.
+func bloomRemoveBatch(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key    string   `json:"key"`
+		Values []string `json:"values"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}) {
+		return
+	}
+
+	// Preprocess the values with the transformation webhook, in a single call for the batch
+	values, ok := transformValues(w, r, jsonbody.Values)
+	if !ok {
+		return
+	}
+
+	// Call service to remove the values, the next flush writes them
+	removed, err := service.BloomRemoveBatch(r.Context(), jsonbody.Key, values)
+	switch {
+	case errors.Is(err, service.ErrKeyNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrNotCounting):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrKeyQuarantined):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case err != nil:
+		http.Error(w, "Can't remove values", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't remove values", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Report the values already at zero as sent by the client, rather than transformed
+	response := RemoveBatchResponse{Key: jsonbody.Key, AtZero: []string{}}
+	for i, ok := range removed {
+		if ok {
+			response.Removed++
+		} else {
+			response.AtZero = append(response.AtZero, jsonbody.Values[i])
+		}
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("Removed %d values from (%s), %d already at zero", response.Removed, jsonbody.Key, len(response.AtZero))
+
+	// Write the response, or the output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomHeadroom handles GET requests to estimate how many more distinct values a key can take
+// before its false positive rate crosses a target.
+// It expects query parameter "key" and optionally "fpr" (target rate, defaults to the configured one).
+func bloomHeadroom(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	queries := r.URL.Query()
+	key := queries.Get("key")
+
+	target, err := paramFPR.optional(r, config.HyperBloomCfg().FalsePositive)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	// Call service to estimate the headroom
+	current, capacity, headroom, err := service.BloomHeadroom(r.Context(), key, target)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	// Format the output string with the estimates
+	output := fmt.Sprintf("Headroom (current, capacity, remaining) = (%d, %d, %d)", current, capacity, headroom)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, HeadroomResponse{Current: current, Capacity: capacity, Remaining: headroom}, output)
+}
+
+// bloomFill handles GET requests to report how saturated the Bloom filter of a key is.
+// It expects query parameter "key" and writes the number of bits set, the total number of bits and the fill ratio.
+func bloomFill(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+
+	// Call service to count the bits set
+	set, total, err := service.BloomFill(r.Context(), key)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	// Format the output string with the counts
+	ratio := float64(set) / float64(total)
+	output := fmt.Sprintf("Fill (set, total, ratio) = (%d, %d, %f)", set, total, ratio)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, FillResponse{Set: set, Total: total, Ratio: ratio}, output)
+}
+
+// bloomStats handles GET requests to report the parameters and saturation of the Bloom filter of a key.
+// It expects query parameter "key" and writes k, m, the fill ratio, the capacity and target rate the key was
+// created with, the rate estimated at the HyperLogLog cardinality, and the precision of the sketch.
+func bloomStats(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+
+	// Call service to compute the statistics
+	stats, err := service.BloomStats(r.Context(), key)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't read key metadata", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't read stats", "key", key, "err", err)
+		return
+	}
+
+	// Format the output string with the statistics
+	output := fmt.Sprintf(
+		"Stats (k, m, fill, capacity, target fpr, estimated fpr, saturated) = (%d, %d, %f, %d, %g, %g, %t)\n"+
+			"HyperLogLog (precision, registers, standard error) = (%d, %d, %g)",
+		stats.HashFunctions, stats.BitCapacity, stats.FillRatio, stats.Capacity, stats.TargetFPR, stats.EstimatedFPR, stats.Saturated,
+		stats.Precision, stats.Registers, stats.StandardError,
+	)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, StatsResponse(*stats), output)
+}
+
+// bloomProjection handles GET requests to project the fill ratio and false positive rate of a key after more
+// distinct values were inserted, without inserting them, to decide whether to create a larger filter before a load.
+// It expects query parameters "key" and "additional" (number of distinct values to insert).
+func bloomProjection(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+	additional, err := paramAdditional.required(r)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	// Call service to compute the projection
+	projection, err := service.BloomProjection(r.Context(), key, uint64(additional))
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't read key metadata", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't read projection", "key", key, "err", err)
+		return
+	}
+
+	// Format the output string with the projection
+	output := fmt.Sprintf(
+		"Projection (additional, fill, fpr, projected fill, projected fpr, projected count, target fpr, exceeds target) = (%d, %f, %g, %f, %g, %d, %g, %t)",
+		projection.Additional, projection.FillRatio, projection.CurrentFPR, projection.ProjectedFillRatio, projection.ProjectedFPR,
+		projection.ProjectedCount, projection.TargetFPR, projection.ExceedsTarget,
+	)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, ProjectionResponse(*projection), output)
+}
+
+// bloomTopKeys handles GET requests to list the in-memory keys holding or costing the most, to find those to rotate
+// or rescale. It expects optional query parameters "by" ("memory" by default, "cardinality" or "fill_ratio")
+// and "limit" (20 by default), and writes one line per key, highest first.
+func bloomTopKeys(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	by := r.URL.Query().Get("by")
+	limit, err := paramLimit.optional(r, 20)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	// Call service to rank the keys
+	usages, err := service.BloomTopKeys(by, int(limit))
+	if errors.Is(err, service.ErrInvalidTopKeysBy) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Format the output string with the count followed by every key
+	output := fmt.Sprintf("Top keys = %d", len(usages))
+	response := TopKeysResponse{Keys: make([]KeyUsageResponse, 0, len(usages))}
+	for _, u := range usages {
+		response.Keys = append(response.Keys, KeyUsageResponse(u))
+		output += fmt.Sprintf("\n%s: cardinality = %d, memory = %d bytes, fill ratio = %f", u.Key, u.Cardinality, u.MemoryBytes, u.FillRatio)
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomListKeys handles GET requests to list the existing keys, sorted by key, a page at a time.
+// It expects optional query parameters "prefix" (only list keys starting with it), "limit" (default 100)
+// and "offset" (number of keys to skip, default 0), and writes the cardinality, filter type, creation
+// and last write times of every key.
+func bloomListKeys(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	prefix := r.URL.Query().Get("prefix")
+	limit, err := paramLimit.optional(r, 100)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	offset, err := paramOffset.optional(r, 0)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	// Call service to list the page of keys
+	keys, err := service.ListKeys(r.Context(), prefix, int(limit), int(offset))
+	if err != nil {
+		http.Error(w, "Can't list keys", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't list keys", "err", err)
+		return
+	}
+
+	// Format the output string with the count followed by every key
+	output := fmt.Sprintf("Keys = %d from offset %d", len(keys), offset)
+	response := ListKeysResponse{Keys: make([]KeyInfoResponse, 0, len(keys)), Limit: int(limit), Offset: int(offset)}
+	for _, info := range keys {
+		response.Keys = append(response.Keys, KeyInfoResponse{
+			Key:         info.Key,
+			Cardinality: info.Cardinality,
+			Type:        info.Type,
+			CreatedAt:   timePtr(info.CreatedAt),
+			UpdatedAt:   timePtr(info.UpdatedAt),
+		})
+		output += fmt.Sprintf("\n%s: cardinality = %d, type = %s", info.Key, info.Cardinality, info.Type)
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomMemory handles GET requests to report how much memory and storage keys take, for capacity planning.
+// It expects the optional query parameter "key", every key is reported along with the totals when omitted.
+func bloomMemory(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+
+	// Call service to measure the keys
+	usage, err := service.BloomMemory(r.Context(), key)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't measure keys", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't measure keys", "key", key, "err", err)
+		return
+	}
+
+	// Format the output string with the totals followed by every key
+	output := fmt.Sprintf(
+		"Keys = %d, in memory = %d\n"+
+			"Memory bytes = %d\n"+
+			"Serialized bytes = %d\n"+
+			"Stored bytes = %d",
+		len(usage.Keys), usage.InMemoryKeys,
+		usage.MemoryBytes,
+		usage.SerializedBytes,
+		usage.StoredBytes,
+	)
+	response := MemoryResponse{
+		Keys:            make([]KeyMemoryResponse, 0, len(usage.Keys)),
+		InMemoryKeys:    usage.InMemoryKeys,
+		MemoryBytes:     usage.MemoryBytes,
+		SerializedBytes: usage.SerializedBytes,
+		StoredBytes:     usage.StoredBytes,
+	}
+	for _, info := range usage.Keys {
+		response.Keys = append(response.Keys, KeyMemoryResponse{
+			Key:             info.Key,
+			InMemory:        info.InMemory,
+			MemoryBytes:     info.MemoryBytes,
+			SerializedBytes: info.SerializedBytes,
+			StoredBytes:     info.StoredBytes,
+		})
+		output += fmt.Sprintf(
+			"\n%s: memory = %d, serialized = %d, stored = %d, in memory = %t",
+			info.Key, info.MemoryBytes, info.SerializedBytes, info.StoredBytes, info.InMemory,
+		)
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomFingerprint handles GET requests to get a stable hash of the filters of a key, to tell whether two keys
+// are bit-identical or whether a key changed between checks without transferring it.
+// It expects query parameter "key" and writes the hex-encoded SHA-256 fingerprint.
+func bloomFingerprint(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+
+	// Call service to hash the filters
+	fingerprint, err := service.BloomFingerprint(r.Context(), key)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't compute the fingerprint", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't fingerprint key", "key", key, "err", err)
+		return
+	}
+
+	// Format the output string with the fingerprint
+	output := fmt.Sprintf("Fingerprint (%s) = %s", key, fingerprint)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, FingerprintResponse{Key: key, Fingerprint: fingerprint}, output)
+}
+
+// bloomCreate handles POST requests to explicitly create a key with the given capacity and false positive rate.
+// It expects a JSON body with "key" and optionally "capacity" and "fpr" fields (defaulting to HB_CARD and HB_FP)
+// and "strict" (check membership against two independent filters, for a false positive rate of about fpr²
+// at twice the bit array memory) and "counting" (keep a counter per bit so that values can be removed, see
+// bloomRemove, at 8 times the bit array memory; not with strict or scalable) and "hashes" (number of hash functions, overriding the one derived from fpr;
+// the theoretical false positive rate it results in is reported) and "half_life" (e.g. "1h", keep a distinct count
+// decayed with this half-life, see bloomCard) and "scalable" (add Bloom filter layers once the filter holds capacity
+// values, each sized for "growth" times as many values at "tightening" times the false positive rate, defaulting
+// to HB_SCALABLE_GROWTH and HB_SCALABLE_TIGHTENING) and "precision" (HyperLogLog precision p, 14 by default or 16,
+// 2^p registers for a standard error of about 1.04/sqrt(2^p); other precisions are rejected) and "ttl_seconds" (delete the key this
+// long after its creation, it then reads as absent; never by default), and an optional query parameter
+// "on_exists" (fail, ignore or replace, default fail).
+func bloomCreate(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key        string  `json:"key"`
+		Capacity   uint    `json:"capacity"`
+		FPR        float64 `json:"fpr"`
+		Hashes     *int    `json:"hashes"`
+		Strict     bool    `json:"strict"`
+		Counting   bool    `json:"counting"`
+		HalfLife   string  `json:"half_life"`
+		Scalable   bool    `json:"scalable"`
+		Growth     float64 `json:"growth"`
+		Tightening float64 `json:"tightening"`
+		Precision  int     `json:"precision"`
+		TTLSeconds int64   `json:"ttl_seconds"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}) {
+		return
+	}
+
+	// Fall back to the configured defaults for the omitted parameters
+	defaults := config.HyperBloomCfg()
+	if jsonbody.Capacity == 0 {
+		jsonbody.Capacity = defaults.Cardinality
+	}
+	if jsonbody.FPR == 0 {
+		jsonbody.FPR = defaults.FalsePositive
+	}
+	if jsonbody.FPR < 0 || jsonbody.FPR >= 1 {
+		http.Error(w, "Invalid fpr", http.StatusBadRequest)
+		return
+	}
+
+	// An explicit number of hash functions overrides the one derived from the false positive rate
+	hashes := uint(0)
+	if jsonbody.Hashes != nil {
+		if *jsonbody.Hashes < 1 {
+			http.Error(w, "Invalid hashes: must be at least 1", http.StatusBadRequest)
+			return
+		}
+		hashes = uint(*jsonbody.Hashes)
+	}
+
+	// A half-life keeps a time-decayed distinct count next to the lifetime one
+	halfLife := time.Duration(0)
+	if jsonbody.HalfLife != "" {
+		var err error
+		if halfLife, err = time.ParseDuration(jsonbody.HalfLife); err != nil {
+			http.Error(w, "Invalid half_life: must be a duration such as 1h", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// A scalable key grows its Bloom filter once saturated, the omitted parameters take the configured defaults
+	var scalable *service.Scalable
+	if jsonbody.Scalable {
+		scalable = &service.Scalable{Growth: jsonbody.Growth, Tightening: jsonbody.Tightening}
+	} else if jsonbody.Growth != 0 || jsonbody.Tightening != 0 {
+		http.Error(w, "Invalid growth or tightening: only apply to scalable keys", http.StatusBadRequest)
+		return
+	}
+
+	// The precision is checked against its bounds by the service, only its type is checked here
+	if jsonbody.Precision < 0 || jsonbody.Precision > math.MaxUint8 {
+		http.Error(w, "Invalid precision", http.StatusBadRequest)
+		return
+	}
+
+	// A time to live beyond the range of durations can't be represented, a negative one is rejected by the service
+	if jsonbody.TTLSeconds > int64(math.MaxInt64/time.Second) {
+		http.Error(w, "Invalid ttl_seconds", http.StatusBadRequest)
+		return
+	}
+	ttl := time.Duration(jsonbody.TTLSeconds) * time.Second
+
+	onExists := r.URL.Query().Get("on_exists")
+	if onExists == "" {
+		onExists = service.OnExistsFail
+	}
+
+	// Call service to create the key
+	opts := service.CreateOptions{
+		Hashes:    hashes,
+		Strict:    jsonbody.Strict,
+		Counting:  jsonbody.Counting,
+		HalfLife:  halfLife,
+		Scalable:  scalable,
+		Precision: uint8(jsonbody.Precision),
+		TTL:       ttl,
+	}
+	outcome, err := service.BloomCreateKey(r.Context(), jsonbody.Key, jsonbody.Capacity, jsonbody.FPR, opts, onExists)
+	switch {
+	case errors.Is(err, service.ErrInvalidOnExists), errors.Is(err, service.ErrInvalidHashes), errors.Is(err, service.ErrInvalidHalfLife),
+		errors.Is(err, service.ErrInvalidScalable), errors.Is(err, service.ErrInvalidCounting), errors.Is(err, service.ErrInvalidPrecision),
+		errors.Is(err, service.ErrInvalidTTL):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrInfeasibleSizing):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, service.ErrKeyExists):
+		http.Error(w, "Key already exists", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Can't create key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't create key", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Format the output string with the outcome
+	output := fmt.Sprintf("Create (%s) = %s", jsonbody.Key, outcome)
+	response := CreateResponse{Key: jsonbody.Key, Outcome: outcome}
+
+	// Report the tradeoff of an overridden number of hash functions, the bit array stays sized for the capacity
+	if hashes > 0 && outcome != service.CreateIgnored {
+		m, _, _, _ := service.BloomSizing(jsonbody.Capacity, jsonbody.FPR)
+		fpr := service.TheoreticalFPR(jsonbody.Capacity, m, hashes)
+		output += fmt.Sprintf(
+			"\nHash functions (k) = %d\n"+
+				"Theoretical false positive rate at capacity = %g",
+			hashes,
+			fpr,
+		)
+		response.HashFunctions, response.TheoreticalFP = hashes, &fpr
+	}
+
+	// Report when the key expires, an ignored creation keeps the expiry of the existing key
+	if db := service.BloomGet(r.Context(), jsonbody.Key); db != nil && !db.ExpiresAt().IsZero() {
+		output += fmt.Sprintf("\nExpires at = %s", db.ExpiresAt().Format(time.RFC3339))
+		response.ExpiresAt = timePtr(db.ExpiresAt())
+	}
+
+	// Only an ignored creation leaves the key as it was
+	status := http.StatusCreated
+	if outcome == service.CreateIgnored {
+		status = http.StatusOK
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponseStatus(w, r, status, response, output)
+}
+
+// bloomRecommend handles POST requests to recommend HyperLogLog precision and Bloom filter parameters for a sample.
+// It expects a JSON body with "values" (at most service.MaxRecommendSample) and optionally "fpr"
+// (target false positive rate, defaults to HB_FP) and "error" (target relative cardinality error, defaults to 2%).
+func bloomRecommend(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Values []string `json:"values"`
+		FPR    float64  `json:"fpr"`
+		Error  float64  `json:"error"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Fall back to the defaults for the omitted targets
+	if jsonbody.FPR == 0 {
+		jsonbody.FPR = config.HyperBloomCfg().FalsePositive
+	}
+	if jsonbody.Error == 0 {
+		jsonbody.Error = 0.02
+	}
+	if jsonbody.FPR < 0 || jsonbody.FPR >= 1 || jsonbody.Error < 0 {
+		http.Error(w, "Invalid targets", http.StatusBadRequest)
+		return
+	}
+
+	// Call service to analyze the sample
+	rec, err := service.BloomRecommend(jsonbody.Values, jsonbody.FPR, jsonbody.Error)
+	if errors.Is(err, service.ErrInvalidSample) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't analyze sample", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't analyze sample", "err", err)
+		return
+	}
+
+	// Format the output string with the recommendation followed by every candidate
+	hyper, bf := rec.Hyper[rec.BestHyper], rec.Bloom[rec.BestBloom]
+	output := fmt.Sprintf(
+		"Recommend (distinct, precision, fpr, m, k) = (%d, %d, %g, %d, %d)",
+		rec.Distinct, hyper.Precision, bf.FalsePositive, bf.M, bf.K,
+	)
+	response := RecommendResponse{
+		Distinct:  rec.Distinct,
+		Precision: hyper.Precision,
+		FPR:       bf.FalsePositive,
+		M:         bf.M,
+		K:         bf.K,
+		Hyper:     make([]HyperCandidateResponse, 0, len(rec.Hyper)),
+		Bloom:     make([]BloomCandidateResponse, 0, len(rec.Bloom)),
+	}
+	for _, c := range rec.Hyper {
+		response.Hyper = append(response.Hyper, HyperCandidateResponse(c))
+		output += fmt.Sprintf(
+			"\nHyper (precision, bytes, estimate, error) = (%d, %d, %d, %.4f)",
+			c.Precision, c.Bytes, c.Estimate, c.Error,
+		)
+	}
+	for _, c := range rec.Bloom {
+		response.Bloom = append(response.Bloom, BloomCandidateResponse{FPR: c.FalsePositive, M: c.M, K: c.K, Bytes: c.Bytes, Measured: c.Measured})
+		output += fmt.Sprintf(
+			"\nBloom (fpr, m, k, bytes, measured) = (%g, %d, %d, %d, %.4f)",
+			c.FalsePositive, c.M, c.K, c.Bytes, c.Measured,
+		)
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomAudit handles POST requests to measure the accuracy of a key against the exact set of values inserted in it.
+// It expects a JSON body with "key" and "values" (the exact set, at most service.MaxAuditValues), and writes
+// the measured and expected false positive rates, the false negatives and the error of both cardinality estimates.
+func bloomAudit(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key    string   `json:"key"`
+		Values []string `json:"values"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}) {
+		return
+	}
+
+	// Reject oversized sets before paying for their transformation
+	if len(jsonbody.Values) > service.MaxAuditValues {
+		http.Error(w, fmt.Sprintf("At most %d values can be audited", service.MaxAuditValues), http.StatusBadRequest)
+		return
+	}
+
+	// Preprocess the values the same way they were when inserted, in a single webhook call
+	values, ok := transformValues(w, r, jsonbody.Values)
+	if !ok {
+		return
+	}
+	originals := make(map[string]string, len(values))
+	for i, value := range values {
+		originals[value] = jsonbody.Values[i]
+	}
+
+	// Call service to compare the key with the exact set
+	audit, err := service.BloomAudit(r.Context(), jsonbody.Key, values)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, service.ErrInvalidSample) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't audit key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't audit key", "key", jsonbody.Key, "err", err)
+		return
+	}
+
+	// Format the output string with the report followed by every false negative, as sent by the client
+	output := fmt.Sprintf(
+		"Audit (%s) = %d distinct values\n"+
+			"False positive rate (measured, expected) = (%.4f, %.4f) over %d probes\n"+
+			"Cardinality (bloom, hyperloglog) = (%d, %d)\n"+
+			"Cardinality error (bloom, hyperloglog) = (%.4f, %.4f)\n"+
+			"False negatives = %d",
+		jsonbody.Key, audit.Distinct,
+		audit.FalsePositiveRate, audit.ExpectedFPR, audit.Probes,
+		audit.BloomCardinality, audit.HyperCardinality,
+		audit.BloomError, audit.HyperError,
+		len(audit.FalseNegatives),
+	)
+	response := AuditResponse{
+		Key:                 jsonbody.Key,
+		Distinct:            audit.Distinct,
+		FalsePositiveRate:   audit.FalsePositiveRate,
+		ExpectedFPR:         audit.ExpectedFPR,
+		Probes:              audit.Probes,
+		CardinalityResponse: CardinalityResponse{BloomCardinality: audit.BloomCardinality, HLLCardinality: audit.HyperCardinality},
+		BloomError:          audit.BloomError,
+		HLLError:            audit.HyperError,
+		FalseNegatives:      make([]string, 0, len(audit.FalseNegatives)),
+	}
+	for _, value := range audit.FalseNegatives {
+		output += "\n" + originals[value]
+		response.FalseNegatives = append(response.FalseNegatives, originals[value])
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomFirstSeen handles GET requests to approximate when a value was first inserted in a Bloom filter.
+// It expects query parameters "key" and "value", the key must have been created with first seen tracking.
+func bloomFirstSeen(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	queries := r.URL.Query()
+	key := queries.Get("key")
+	value := queries.Get("value")
+
+	// Preprocess the value the same way it was when inserted
+	transformed, ok := transformValue(w, r, value)
+	if !ok {
+		return
+	}
+
+	// Call service to look up the first insert time
+	seen, ok, err := service.BloomFirstSeen(r.Context(), key, transformed)
+	switch {
+	case errors.Is(err, service.ErrKeyNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrFirstSeenNotTracked):
+		http.Error(w, "First seen times are not tracked for this key", http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Format the output string with the approximate timestamp
+	output := fmt.Sprintf("FirstSeen (%s, %s) = not seen", key, value)
+	response := FirstSeenResponse{Key: key, Value: value}
+	if ok {
+		output = fmt.Sprintf("FirstSeen (%s, %s) = %s", key, value, seen.Format(time.RFC3339))
+		response.FirstSeen = &seen
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomTopK handles GET requests to list the most frequent values inserted in a key with their estimated counts.
+// It expects query parameter "key" and optionally "k" (10 by default), the key must have been created with top-k
+// tracking. Counts may be overestimated by up to the error written next to them.
+func bloomTopK(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+	k, err := paramK.optional(r, 10)
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+
+	// Call service to rank the values
+	items, err := service.BloomTopK(r.Context(), key, int(k))
+	switch {
+	case errors.Is(err, service.ErrKeyNotFound):
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrTopKNotTracked):
+		http.Error(w, "Most frequent values are not tracked for this key", http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Format the output string with the count followed by every value
+	output := fmt.Sprintf("TopK (%s) = %d", key, len(items))
+	response := TopKResponse{Key: key, Values: make([]TopKItemJSON, 0, len(items))}
+	for _, item := range items {
+		response.Values = append(response.Values, TopKItemJSON(item))
+		output += fmt.Sprintf("\n%s = %d (± %d)", item.Value, item.Count, item.Error)
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomVerifyMembers handles POST requests to check a Bloom filter for false negatives.
+// It expects a JSON body with "key" and "values" (values known to have been inserted),
+// and writes the values incorrectly reported as absent, one per line, which should never happen.
+func bloomVerifyMembers(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key    string   `json:"key"`
+		Values []string `json:"values"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}) {
+		return
+	}
+
+	// Preprocess the values the same way they were when inserted, in a single webhook call
+	values, ok := transformValues(w, r, jsonbody.Values)
+	if !ok {
+		return
+	}
+	originals := make(map[string]string, len(values))
+	for i, value := range values {
+		originals[value] = jsonbody.Values[i]
+	}
+
+	// Call service to look for false negatives
+	falseNegatives, err := service.BloomVerifyMembers(r.Context(), jsonbody.Key, values)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	// Format the output string with the count followed by every false negative, as sent by the client
+	output := fmt.Sprintf("False negatives (%s) = %d", jsonbody.Key, len(falseNegatives))
+	response := FalseNegativesResponse{Key: jsonbody.Key, FalseNegatives: make([]string, 0, len(falseNegatives))}
+	for _, value := range falseNegatives {
+		output += "\n" + originals[value]
+		response.FalseNegatives = append(response.FalseNegatives, originals[value])
+	}
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, response, output)
+}
+
+// bloomExportHyper handles GET requests to export the HyperLogLog sketch of every key without its Bloom filters,
+// for consumers only needing distinct counts. It streams one JSON object per line (NDJSON) with "key", "precision",
+// "cardinality" and "sketch" (base64-encoded), which bloomImportHyper takes back.
+func bloomExportHyper(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Stream every sketch as it's encoded, flushing each line so the client never waits for the whole export
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	count, err := service.BloomExportHyper(r.Context(), func(export service.HyperExport) error {
+		// Stop once the client went away
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if err := encoder.Encode(export); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+
+	// The status can only be changed until the first line is written
+	if err != nil && count == 0 {
+		http.Error(w, "Can't export sketches", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't export sketches", "err", err)
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "Export of sketches interrupted", "exported", count, "err", err)
+	}
+}
+
+// bloomImportHyper handles POST requests to merge HyperLogLog sketches exported by bloomExportHyper into their keys,
+// creating the missing ones. It expects one JSON object per line (NDJSON) with "key", "sketch" and optionally
+// "precision", and writes the number of keys imported. The lines before an invalid one stay imported.
+func bloomImportHyper(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	defer r.Body.Close()
+
+	// Decode and merge the sketches one line at a time, the body is never held in memory
+	decoder := json.NewDecoder(r.Body)
+	imported := 0
+	for {
+		export := service.HyperExport{}
+		err := decoder.Decode(&export)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON after %d keys imported", imported), http.StatusBadRequest)
+			slog.DebugContext(r.Context(), "Invalid sketch", "imported", imported, "err", err)
+			return
+		}
+
+		err = service.BloomImportHyper(r.Context(), export)
+		switch {
+		case errors.Is(err, service.ErrInvalidSketch):
+			http.Error(w, fmt.Sprintf("%v, after %d keys imported", err, imported), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrIncompatibleSketch):
+			http.Error(w, fmt.Sprintf("%v, after %d keys imported", err, imported), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, service.ErrKeyQuarantined):
+			http.Error(w, fmt.Sprintf("%v, after %d keys imported", err, imported), http.StatusInternalServerError)
+			return
+		case err != nil:
+			http.Error(w, fmt.Sprintf("Can't load key %s, after %d keys imported", export.Key, imported), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Can't import sketch", "key", export.Key, "imported", imported, "err", err)
+			return
+		}
+		imported++
+	}
+
+	// Format the output string with the number of keys
+	output := fmt.Sprintf("Import (hll) = %d keys", imported)
 
-	// Write the formatted output string to the HTTP response
-	w.Write([]byte(output))
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, ImportResponse{Imported: imported}, output)
 }
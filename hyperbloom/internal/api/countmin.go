@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+// countMinAdd handles POST requests to count occurrences of a value in the Count-Min Sketch of a key.
+// It expects a JSON body with "key" and "value" fields, and optionally "count" (1 by default). The sketch is
+// created with HB_CMS_EPSILON and HB_CMS_DELTA if the key has none. It writes the new estimate of the value.
+func countMinAdd(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key   string  `json:"key"`
+		Value string  `json:"value"`
+		Count *uint64 `json:"count"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}, field{"value", jsonbody.Value}) {
+		return
+	}
+
+	count := uint64(1)
+	if jsonbody.Count != nil {
+		count = *jsonbody.Count
+	}
+
+	// Preprocess the value with the transformation webhook, if configured
+	value, ok := transformValue(w, r, jsonbody.Value)
+	if !ok {
+		return
+	}
+
+	// Call service to count the occurrences
+	if err := service.CountMinAdd(r.Context(), jsonbody.Key, value, count); err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't add to count-min sketch", "key", jsonbody.Key, "err", err)
+		return
+	}
+	estimate, total, _ := service.CountMinEstimate(r.Context(), jsonbody.Key, value)
+
+	// Format the output string
+	output := fmt.Sprintf("Estimate (count, total) = (%d, %d)", estimate, total)
+
+	// Write the response, or the output string to text clients
+	writeResponse(w, r, CountMinResponse{Estimate: estimate, Total: total}, output)
+}
+
+// countMinEstimate handles GET requests to estimate the number of occurrences of a value in the Count-Min Sketch
+// of a key, which never undercounts. It expects query parameters "key" and "value", and writes the estimate
+// along with the total count of the sketch.
+func countMinEstimate(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Parse query parameters from the request URL
+	key := r.URL.Query().Get("key")
+
+	// Preprocess the value with the transformation webhook, if configured
+	value, ok := transformValue(w, r, r.URL.Query().Get("value"))
+	if !ok {
+		return
+	}
+
+	// Call service to estimate the occurrences
+	estimate, total, err := service.CountMinEstimate(r.Context(), key, value)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't load count-min sketch", "key", key, "err", err)
+		return
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("Estimate (count, total) = (%d, %d)", estimate, total)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, CountMinResponse{Estimate: estimate, Total: total}, output)
+}
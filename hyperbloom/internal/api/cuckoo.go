@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+// readCuckooRequest reads the "key" and "value" fields of the JSON body of a Cuckoo filter request, and preprocesses
+// the value with the transformation webhook, if configured. It writes the error response and returns false
+// if the body can't be read or a field is empty.
+func readCuckooRequest(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	// Read the request body, up to MAX_BODY_BYTES
+	bytebody, ok := readBody(w, r)
+	defer r.Body.Close()
+	if !ok {
+		return "", "", false
+	}
+
+	// Struct to unmarshal the JSON body
+	jsonbody := &struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}{}
+
+	// Decode the JSON or MessagePack body into the struct
+	if err := decodeBody(r, bytebody, &jsonbody); err != nil {
+		// If there's an error decoding JSON, respond with a Bad Request status
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		slog.DebugContext(r.Context(), "Invalid JSON body", "path", r.URL.Path, "err", err)
+		return "", "", false
+	}
+
+	// Reject empty fields before involving the service
+	if !requireFields(w, r, field{"key", jsonbody.Key}, field{"value", jsonbody.Value}) {
+		return "", "", false
+	}
+
+	// Preprocess the value the same way whether it's added, checked or deleted
+	value, ok := transformValue(w, r, jsonbody.Value)
+	return jsonbody.Key, value, ok
+}
+
+// cuckooAdd handles POST requests to add a value to the Cuckoo filter of a key.
+// It expects a JSON body with "key" and "value" fields. The filter is created with HB_CUCKOO_CAPACITY if the key
+// has none. A filter that can't make room for the value responds 507 rather than dropping it.
+func cuckooAdd(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	key, value, ok := readCuckooRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Call service to add the value
+	count, capacity, err := service.CuckooAdd(r.Context(), key, value)
+	if errors.Is(err, service.ErrFilterFull) {
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't add to cuckoo filter", "key", key, "err", err)
+		return
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("Count = %d of %d slots", count, capacity)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, CuckooResponse{Count: count, Capacity: capacity}, output)
+}
+
+// cuckooExists handles POST requests to check if a value exists in the Cuckoo filter of a key.
+// It expects a JSON body with "key" and "value" fields, and responds 404 if the key has no filter.
+func cuckooExists(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	key, value, ok := readCuckooRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Call service to check the value
+	exists, err := service.CuckooContains(r.Context(), key, value)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't load cuckoo filter", "key", key, "err", err)
+		return
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("(%s) ⪽ (%s) = %t", value, key, exists)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, CuckooExistsResponse{Exists: exists}, output)
+}
+
+// cuckooDelete handles POST requests to delete a value from the Cuckoo filter of a key.
+// It expects a JSON body with "key" and "value" fields, and writes whether the value was found. Only values
+// that were added should be deleted, deleting another one may delete a value sharing its fingerprint.
+// It responds 404 if the key has no filter.
+func cuckooDelete(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	key, value, ok := readCuckooRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Call service to delete the value
+	deleted, count, err := service.CuckooDelete(r.Context(), key, value)
+	if errors.Is(err, service.ErrKeyNotFound) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Can't load key", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Can't load cuckoo filter", "key", key, "err", err)
+		return
+	}
+
+	// Format the output string
+	output := fmt.Sprintf("Deleted = %t\nCount = %d", deleted, count)
+
+	// Write the response, or the formatted output string to text clients
+	writeResponse(w, r, CuckooDeleteResponse{Deleted: deleted, Count: count}, output)
+}
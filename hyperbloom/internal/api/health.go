@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+)
+
+// healthLive handles GET requests to check that the process is up, for liveness probes.
+// It always writes 200 OK: a failing database is reported by healthReady, restarting the process wouldn't fix it.
+func healthLive(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	writeResponse(w, r, HealthResponse{Status: "ok"}, "OK")
+}
+
+// healthReady handles GET requests to check that the instance can serve traffic, for readiness probes and load balancers.
+// It writes 200 OK if PostgreSQL answers a ping within READY_TIMEOUT, 503 Service Unavailable with the error otherwise.
+func healthReady(w http.ResponseWriter, r *http.Request) {
+	// Reject the other methods before reading anything
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// Bound the ping, a probe must not hang on an unresponsive database
+	ctx := r.Context()
+	if timeout := config.ApplicationCfg().ReadyTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Call service to ping the database
+	if err := service.Ready(ctx); err != nil {
+		slog.WarnContext(r.Context(), "Not ready", "err", err)
+		writeResponseStatus(w, r, http.StatusServiceUnavailable, HealthResponse{Status: "unavailable", Error: err.Error()}, "Not ready: "+err.Error())
+		return
+	}
+
+	writeResponse(w, r, HealthResponse{Status: "ok"}, "OK")
+}
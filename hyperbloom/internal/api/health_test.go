@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	mux := http.NewServeMux()
+	ServeHealth(mux)
+
+	// No database is connected in these tests, the process is alive but not ready
+	cases := []struct {
+		method, path string
+		status       int
+		health       string
+	}{
+		{http.MethodGet, "/healthz", http.StatusOK, "ok"},
+		{http.MethodGet, "/readyz", http.StatusServiceUnavailable, "unavailable"},
+		{http.MethodPost, "/healthz", http.StatusMethodNotAllowed, ""},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set("Accept", "application/json")
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != c.status {
+			t.Errorf("%s %s: status = %d, want %d", c.method, c.path, rec.Code, c.status)
+			continue
+		}
+		if c.health == "" {
+			continue
+		}
+		var response HealthResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil || response.Status != c.health {
+			t.Errorf("%s %s: response = %+v (%v), want status %q", c.method, c.path, response, err, c.health)
+		}
+		if c.status != http.StatusOK && response.Error == "" {
+			t.Errorf("%s %s: error not reported", c.method, c.path)
+		}
+	}
+}
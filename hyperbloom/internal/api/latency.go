@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// withLatency wraps next to observe its handling time by path in the http_request_duration_seconds histogram.
+// It only wraps registered routes, so the path label is bounded by the routes of the server.
+func withLatency(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next(w, r)
+		metrics.Histogram("http_request_duration_seconds", "path", metrics.LatencyBuckets).
+			Observe(r.URL.Path, time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// concurrencyLimiter caps the number of handlers executing concurrently with a semaphore,
+// rejecting requests beyond the cap instead of queueing them.
+type concurrencyLimiter struct {
+	slots    chan struct{} // Semaphore of the limiter, nil for no cap
+	inFlight *expvar.Map   // Requests currently executing, by path
+	rejected *expvar.Map   // Requests rejected while saturated, by path
+}
+
+// heavyOnce guards the lazy creation of heavy.
+var heavyOnce sync.Once
+
+// heavy is the limiter shared by the expensive endpoints, see heavyLimiter.
+var heavy *concurrencyLimiter
+
+// heavyLimiter returns the limiter shared by the expensive endpoints (similarity matrices, unions, sample analysis),
+// sized by HEAVY_LIMIT. It is created on first use, once the metrics namespace is loaded.
+func heavyLimiter() *concurrencyLimiter {
+	heavyOnce.Do(func() {
+		heavy = newConcurrencyLimiter(config.ApplicationCfg().HeavyLimit, "heavy")
+	})
+	return heavy
+}
+
+// newConcurrencyLimiter creates a limiter admitting size concurrent requests, or any number if size is 0.
+// Its in-flight and rejected counts are published as <name>_in_flight and <name>_rejected_total.
+func newConcurrencyLimiter(size int, name string) *concurrencyLimiter {
+	limiter := &concurrencyLimiter{
+		inFlight: metrics.Map(name+"_in_flight", "path"),
+		rejected: metrics.Map(name+"_rejected_total", "path"),
+	}
+	if size > 0 {
+		limiter.slots = make(chan struct{}, size)
+	}
+	return limiter
+}
+
+// limit wraps next so that it only runs when a slot is free, responding 503 with a Retry-After header otherwise.
+func (limiter *concurrencyLimiter) limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if limiter.slots != nil {
+			select {
+			case limiter.slots <- struct{}{}:
+				defer func() { <-limiter.slots }()
+			default:
+				// Saturated, let the client come back shortly rather than piling up work
+				limiter.rejected.Add(r.URL.Path, 1)
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many concurrent heavy requests", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		limiter.inFlight.Add(r.URL.Path, 1)
+		defer limiter.inFlight.Add(r.URL.Path, -1)
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := newConcurrencyLimiter(2, "test_limiter")
+
+	// Hold the handlers until every slot is taken
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := limiter.limit(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/heavy", nil))
+		}()
+		<-entered
+	}
+
+	if inFlight := limiter.inFlight.Get("/heavy").String(); inFlight != "2" {
+		t.Errorf("in-flight = %s, want 2", inFlight)
+	}
+
+	// The limiter is saturated
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/heavy", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("saturated response = %d with Retry-After %q, want 503 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if rejected := limiter.rejected.Get("/heavy").String(); rejected != "1" {
+		t.Errorf("rejected = %s, want 1", rejected)
+	}
+
+	// Slots are given back once the handlers return
+	close(release)
+	wg.Wait()
+	if inFlight := limiter.inFlight.Get("/heavy").String(); inFlight != "0" {
+		t.Errorf("in-flight after completion = %s, want 0", inFlight)
+	}
+
+	go func() { <-entered }()
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/heavy", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("response after release = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// statusRecorder records the status of the response written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int // Status of the response, 0 until the handler writes
+}
+
+// WriteHeader records status before sending it.
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 of a response written without WriteHeader.
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush sends the buffered response to the client, for streaming handlers.
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// logRequests wraps next to log every request with its method, path, key query parameter if any, status and latency.
+// Successful requests are logged at the info level, client errors at warn and server errors at error,
+// so that LOG_LEVEL=warn keeps the failures only.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{slog.String("method", r.Method), slog.String("path", r.URL.Path)}
+		if key := r.URL.Query().Get("key"); key != "" {
+			attrs = append(attrs, slog.String("key", key))
+		}
+		attrs = append(attrs, slog.Int("status", status), slog.Duration("latency", time.Since(start)))
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogRequests(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	handler := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "Key not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	cases := []struct {
+		target string
+		status int
+		level  string
+	}{
+		{"/hyperbloom/card?key=k", http.StatusOK, "INFO"},
+		{"/missing?key=k", http.StatusNotFound, "WARN"},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.target, nil))
+
+		record := struct {
+			Level   string
+			Method  string
+			Path    string
+			Key     string
+			Status  int
+			Latency *int64
+		}{}
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("%s: %v in %q", c.target, err, buf.String())
+		}
+		if record.Level != c.level || record.Method != http.MethodGet || record.Key != "k" || record.Status != c.status || record.Latency == nil {
+			t.Errorf("%s: record = %s", c.target, buf.String())
+		}
+	}
+}
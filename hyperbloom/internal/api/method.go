@@ -0,0 +1,19 @@
+package api
+
+import "net/http"
+
+// requireMethod checks that the request uses the method the handler expects, HEAD being accepted along with GET.
+// It writes a 405 Method Not Allowed response listing the allowed methods and returns false otherwise.
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method || (method == http.MethodGet && r.Method == http.MethodHead) {
+		return true
+	}
+
+	allow := method
+	if method == http.MethodGet {
+		allow += ", " + http.MethodHead
+	}
+	w.Header().Set("Allow", allow)
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
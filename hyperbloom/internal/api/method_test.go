@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireMethod(t *testing.T) {
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		allow   string
+	}{
+		{"GET on a POST handler", bloomHash, http.MethodGet, "POST"},
+		{"PUT on a POST handler", bloomSim, http.MethodPut, "POST"},
+		{"POST on a GET handler", bloomCard, http.MethodPost, "GET, HEAD"},
+		{"DELETE on an admin handler", adminSnapshot, http.MethodDelete, "POST"},
+	}
+
+	// The method is checked before the body, the service or the admin token
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		c.handler(w, httptest.NewRequest(c.method, "/hyperbloom/test", nil))
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: status = %d, want 405", c.name, w.Code)
+		}
+		if got := w.Header().Get("Allow"); got != c.allow {
+			t.Errorf("%s: Allow = %q, want %q", c.name, got, c.allow)
+		}
+	}
+
+	// The expected method goes through, HEAD along with GET
+	for _, c := range []struct{ expected, method string }{
+		{http.MethodPost, http.MethodPost},
+		{http.MethodGet, http.MethodGet},
+		{http.MethodGet, http.MethodHead},
+	} {
+		w := httptest.NewRecorder()
+		if !requireMethod(w, httptest.NewRequest(c.method, "/hyperbloom/test", nil), c.expected) {
+			t.Errorf("%s rejected by a %s handler", c.method, c.expected)
+		}
+	}
+}
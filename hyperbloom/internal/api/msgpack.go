@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// MessagePack (https://github.com/msgpack/msgpack/blob/master/spec.md) encoding of the request and response bodies,
+// for clients sending large batches of values. Values are mapped following the same `json` struct tags as
+// encoding/json, so every handler takes and returns the same fields in either encoding. Times are encoded with the
+// timestamp extension, byte slices as binary.
+
+// maxMsgpackDepth bounds the nesting of decoded values, so that a small body can't exhaust the stack.
+const maxMsgpackDepth = 100
+
+// errMsgpackTruncated is returned when the body ends in the middle of a value, or announces more elements than
+// it has bytes left.
+var errMsgpackTruncated = errors.New("msgpack: unexpected end of data")
+
+func init() {
+	// Reject negative numbers for unsigned fields like encoding/json does, rather than wrapping them around
+	for _, value := range []any{uint(0), uint8(0), uint16(0), uint32(0), uint64(0)} {
+		msgpack.Register(value, nil, decodeMsgpackUnsigned)
+	}
+}
+
+// marshalMsgpack returns the MessagePack encoding of v.
+func marshalMsgpack(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(false)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalMsgpack decodes the single MessagePack value of data into the value pointed to by v. Numbers decoded
+// into interfaces are int64, uint64 or float64, maps map[string]any. The body is checked by checkMsgpack first.
+func unmarshalMsgpack(data []byte, v any) (err error) {
+	if err := checkMsgpack(data); err != nil {
+		return err
+	}
+
+	// The decoder panics on some malformed bodies, e.g. a key repeated over a slice of interfaces
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("msgpack: can't decode: %v", p)
+		}
+	}()
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	dec.UseLooseInterfaceDecoding(true)
+	return dec.Decode(v)
+}
+
+// checkMsgpack walks the structure of data without decoding it, and returns an error unless it holds exactly one
+// value nested at most maxMsgpackDepth deep, whose arrays and maps announce no more elements than there are bytes
+// left. The decoder recurses and preallocates by the announced lengths, which a body as small as a few bytes could
+// otherwise turn into a stack overflow or an allocation of gigabytes.
+func checkMsgpack(data []byte) error {
+	r := bytes.NewReader(data)
+	dec := msgpack.NewDecoder(r)
+
+	// Number of values left to read in each enclosing array or map, the outermost one being the body
+	pending := []int{1}
+	for len(pending) > 0 {
+		if pending[len(pending)-1] == 0 {
+			pending = pending[:len(pending)-1]
+			continue
+		}
+		pending[len(pending)-1]--
+
+		code, err := dec.PeekCode()
+		if err != nil {
+			return errMsgpackTruncated
+		}
+
+		var n int
+		switch {
+		case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+			n, err = dec.DecodeArrayLen()
+		case msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32:
+			n, err = dec.DecodeMapLen()
+			n *= 2
+		default:
+			if err = dec.Skip(); err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("msgpack: %w", err)
+		}
+
+		// Every element takes at least a byte
+		if n > r.Len() {
+			return errMsgpackTruncated
+		}
+		if len(pending) > maxMsgpackDepth {
+			return fmt.Errorf("msgpack: nested deeper than %d", maxMsgpackDepth)
+		}
+		pending = append(pending, n)
+	}
+
+	if r.Len() > 0 {
+		return fmt.Errorf("msgpack: %d trailing bytes", r.Len())
+	}
+	return nil
+}
+
+// decodeMsgpackUnsigned decodes a number into an unsigned integer value, failing on negative numbers, on numbers
+// the value can't hold and on other types.
+func decodeMsgpackUnsigned(dec *msgpack.Decoder, v reflect.Value) error {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	var u uint64
+	switch {
+	case msgpcode.IsFixedNum(code) || code == msgpcode.Int8 || code == msgpcode.Int16 || code == msgpcode.Int32 || code == msgpcode.Int64:
+		i, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		if i < 0 {
+			return fmt.Errorf("msgpack: can't decode %d into %s", i, v.Type())
+		}
+		u = uint64(i)
+	default:
+		if u, err = dec.DecodeUint64(); err != nil {
+			return err
+		}
+	}
+
+	if v.OverflowUint(u) {
+		return fmt.Errorf("msgpack: %d overflows %s", u, v.Type())
+	}
+	v.SetUint(u)
+	return nil
+}
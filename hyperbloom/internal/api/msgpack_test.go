@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	decayed := 12.5
+	info := CardResponse{
+		CardinalityResponse: CardinalityResponse{BloomCardinality: 41, HLLCardinality: 42},
+		CreatedAt:           &created,
+		DecayedCardinality:  &decayed,
+	}
+
+	encoded, err := marshalMsgpack(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded CardResponse
+	if err = unmarshalMsgpack(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	// Timestamps are decoded in the local time zone
+	if decoded.CreatedAt == nil || !decoded.CreatedAt.Equal(created) {
+		t.Errorf("created_at decoded as %v, want %v", decoded.CreatedAt, created)
+	}
+	decoded.CreatedAt = &created
+	if !reflect.DeepEqual(decoded, info) {
+		t.Errorf("round trip = %+v, want %+v", decoded, info)
+	}
+
+	// Empty omitempty fields are left out, embedded fields are promoted
+	var generic map[string]any
+	if err = unmarshalMsgpack(encoded, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := generic["half_life"]; ok {
+		t.Errorf("empty omitempty field encoded: %v", generic)
+	}
+	if at, ok := generic["created_at"].(time.Time); generic["hll_cardinality"] != int64(42) || !ok || !at.Equal(created) {
+		t.Errorf("generic decoding = %v", generic)
+	}
+}
+
+func TestMsgpackEncoding(t *testing.T) {
+	cases := []struct {
+		value any
+		want  []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{uint(7), []byte{0x07}},
+		{-1, []byte{0xff}},
+		{200, []byte{0xcc, 0xc8}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{uint64(math.MaxUint64), []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"ab", []byte{0xa2, 'a', 'b'}},
+		{[]byte{1, 2}, []byte{0xc4, 0x02, 0x01, 0x02}},
+		{[]string{"a"}, []byte{0x91, 0xa1, 'a'}},
+		{ErrorResponse{Error: "e"}, []byte{0x82, 0xa5, 'e', 'r', 'r', 'o', 'r', 0xa1, 'e', 0xa5, 'f', 'i', 'e', 'l', 'd', 0xa0}},
+	}
+
+	for _, c := range cases {
+		got, err := marshalMsgpack(c.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, c.want) {
+			t.Errorf("marshalMsgpack(%#v) = %x, want %x", c.value, got, c.want)
+		}
+	}
+
+	// Lengths past the fixed formats take the wider headers
+	long := strings.Repeat("v", 300)
+	got, _ := marshalMsgpack(long)
+	if !bytes.Equal(got[:3], []byte{0xda, 0x01, 0x2c}) || len(got) != 303 {
+		t.Errorf("string of 300 bytes encoded with header %x, length %d", got[:3], len(got))
+	}
+	got, _ = marshalMsgpack(make([]bool, 20))
+	if !bytes.Equal(got[:3], []byte{0xdc, 0x00, 0x14}) {
+		t.Errorf("array of 20 elements encoded with header %x", got[:3])
+	}
+}
+
+func TestUnmarshalMsgpackErrors(t *testing.T) {
+	var body struct {
+		Key      string   `json:"key"`
+		Values   []string `json:"values"`
+		Capacity uint     `json:"capacity"`
+	}
+
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated string", []byte{0x81, 0xa3, 'k', 'e', 'y', 0xa5, 'a'}},
+		{"array longer than the data", []byte{0x81, 0xa6, 'v', 'a', 'l', 'u', 'e', 's', 0xdd, 0xff, 0xff, 0xff, 0xff}},
+		{"negative capacity", []byte{0x81, 0xa8, 'c', 'a', 'p', 'a', 'c', 'i', 't', 'y', 0xff}},
+		{"fractional capacity", []byte{0x81, 0xa8, 'c', 'a', 'p', 'a', 'c', 'i', 't', 'y', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"number for a string", []byte{0x81, 0xa3, 'k', 'e', 'y', 0x01}},
+		{"trailing bytes", []byte{0x80, 0x80}},
+		{"nested too deeply", append(bytes.Repeat([]byte{0x91}, maxMsgpackDepth+2), 0xc0)},
+	}
+
+	for _, c := range cases {
+		if err := unmarshalMsgpack(c.data, &body); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
+
+func TestDecodeBodyMsgpack(t *testing.T) {
+	var body struct {
+		Key    string   `json:"key"`
+		Values []string `json:"values"`
+		Args   []any    `json:"args"`
+	}
+
+	// Unknown entries are skipped, strings may be sent as bin
+	encoded, err := marshalMsgpack(map[string]any{
+		"key":     "users",
+		"values":  []any{"a", []byte("b\xff")},
+		"args":    []any{int64(-3), "x", nil},
+		"ignored": map[string]any{"nested": []int{1, 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/hyperbloom/hashbatch", bytes.NewReader(encoded))
+	r.Header.Set("Content-Type", "application/msgpack; charset=binary")
+	if err = decodeBody(r, encoded, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Key != "users" || !reflect.DeepEqual(body.Values, []string{"a", "b\xff"}) {
+		t.Errorf("decoded (%q, %q)", body.Key, body.Values)
+	}
+	if !reflect.DeepEqual(body.Args, []any{int64(-3), "x", nil}) {
+		t.Errorf("decoded args %#v", body.Args)
+	}
+
+	// JSON stays the default
+	r.Header.Del("Content-Type")
+	if err = decodeBody(r, []byte(`{"key":"json"}`), &body); err != nil || body.Key != "json" {
+		t.Errorf("JSON body decoded as %q, %v", body.Key, err)
+	}
+}
+
+func TestWriteResponseMsgpack(t *testing.T) {
+	r := httptest.NewRequest("GET", "/hyperbloom/card", nil)
+	r.Header.Set("Accept", "application/msgpack")
+	w := httptest.NewRecorder()
+	writeResponse(w, r, CardinalityResponse{BloomCardinality: 3, HLLCardinality: 3}, "Cardinality = 3")
+
+	if got := w.Header().Get("Content-Type"); got != "application/msgpack" {
+		t.Errorf("Content-Type = %q, want application/msgpack", got)
+	}
+	var decoded CardinalityResponse
+	if err := unmarshalMsgpack(w.Body.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.BloomCardinality != 3 || decoded.HLLCardinality != 3 {
+		t.Errorf("decoded %+v", decoded)
+	}
+}
+
+func FuzzUnmarshalMsgpack(f *testing.F) {
+	seed, _ := marshalMsgpack(map[string]any{"key": "users", "values": []string{"a", "b"}, "capacity": 1000, "args": []any{1.5, nil}})
+	f.Add(seed)
+	f.Add([]byte{0x81, 0xa6, 'v', 'a', 'l', 'u', 'e', 's', 0xdd, 0xff, 0xff, 0xff, 0xff})
+	f.Add(append(bytes.Repeat([]byte{0x91}, maxMsgpackDepth+2), 0xc0))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var body struct {
+			Key      string         `json:"key"`
+			Values   []string       `json:"values"`
+			Capacity uint           `json:"capacity"`
+			Args     []any          `json:"args"`
+			Extra    map[string]any `json:"extra"`
+		}
+		if err := unmarshalMsgpack(data, &body); err != nil {
+			return
+		}
+
+		// Whatever was accepted encodes again
+		if _, err := marshalMsgpack(body); err != nil {
+			t.Errorf("can't encode the decoded body %+v: %v", body, err)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// intParam describes an integer query parameter accepted within [min, max].
+type intParam struct {
+	name     string // Name of the query parameter
+	min, max int64  // Inclusive bounds
+}
+
+// floatParam describes a float query parameter accepted within (min, max), or [min, max] if inclusive.
+type floatParam struct {
+	name      string  // Name of the query parameter
+	min, max  float64 // Bounds
+	inclusive bool    // Whether the bounds themselves are accepted
+}
+
+// Numeric query parameters shared by the endpoints.
+var (
+	paramN          = intParam{name: "n", min: 1, max: math.MaxInt64}          // Expected cardinality
+	paramAdditional = intParam{name: "additional", min: 0, max: math.MaxInt64} // Number of values to insert
+	paramK          = intParam{name: "k", min: 1, max: 1000}                   // Number of most frequent values
+	paramSamples    = intParam{name: "samples", min: 1, max: 1000000}          // Number of random values to hash
+	paramLimit      = intParam{name: "limit", min: 1, max: 1000}               // Number of results to list
+	paramOffset     = intParam{name: "offset", min: 0, max: math.MaxInt32}     // Number of results to skip
+	paramFPR        = floatParam{name: "fpr", min: 0, max: 1}                  // False positive rate
+)
+
+// paramError describes a missing or invalid query parameter, its message names the parameter.
+type paramError struct {
+	name   string // Name of the query parameter
+	reason string // What is wrong with it
+}
+
+func (err *paramError) Error() string {
+	return fmt.Sprintf("Invalid query parameter %s: %s", err.name, err.reason)
+}
+
+// writeParamError responds with a Bad Request status naming the offending parameter.
+func writeParamError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// parse returns the value of the parameter in r and whether it is present.
+func (p intParam) parse(r *http.Request) (int64, bool, error) {
+	raw := r.URL.Query().Get(p.name)
+	if raw == "" {
+		return 0, false, nil
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, true, &paramError{p.name, fmt.Sprintf("%q is not an integer", raw)}
+	}
+	if value < p.min || value > p.max {
+		return 0, true, &paramError{p.name, fmt.Sprintf("must be between %d and %d, got %d", p.min, p.max, value)}
+	}
+
+	return value, true, nil
+}
+
+// required returns the value of the parameter in r, which must be present.
+func (p intParam) required(r *http.Request) (int64, error) {
+	value, ok, err := p.parse(r)
+	if err == nil && !ok {
+		err = &paramError{p.name, "missing"}
+	}
+	return value, err
+}
+
+// optional returns the value of the parameter in r, or def if it is absent.
+func (p intParam) optional(r *http.Request, def int64) (int64, error) {
+	value, ok, err := p.parse(r)
+	if err == nil && !ok {
+		value = def
+	}
+	return value, err
+}
+
+// parse returns the value of the parameter in r and whether it is present.
+func (p floatParam) parse(r *http.Request) (float64, bool, error) {
+	raw := r.URL.Query().Get(p.name)
+	if raw == "" {
+		return 0, false, nil
+	}
+
+	// ParseFloat accepts "NaN" and "Inf", which no bound can rule out
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, true, &paramError{p.name, fmt.Sprintf("%q is not a number", raw)}
+	}
+
+	if p.inclusive && (value < p.min || value > p.max) {
+		return 0, true, &paramError{p.name, fmt.Sprintf("must be between %g and %g inclusive, got %g", p.min, p.max, value)}
+	}
+	if !p.inclusive && (value <= p.min || value >= p.max) {
+		return 0, true, &paramError{p.name, fmt.Sprintf("must be strictly between %g and %g, got %g", p.min, p.max, value)}
+	}
+
+	return value, true, nil
+}
+
+// required returns the value of the parameter in r, which must be present.
+func (p floatParam) required(r *http.Request) (float64, error) {
+	value, ok, err := p.parse(r)
+	if err == nil && !ok {
+		err = &paramError{p.name, "missing"}
+	}
+	return value, err
+}
+
+// optional returns the value of the parameter in r, or def if it is absent.
+func (p floatParam) optional(r *http.Request, def float64) (float64, error) {
+	value, ok, err := p.parse(r)
+	if err == nil && !ok {
+		value = def
+	}
+	return value, err
+}
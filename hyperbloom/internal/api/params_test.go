@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIntParam(t *testing.T) {
+	p := intParam{name: "limit", min: 1, max: 100}
+
+	cases := []struct {
+		query string
+		value int64
+		valid bool
+	}{
+		{"limit=10", 10, true},
+		{"limit=1", 1, true},
+		{"limit=100", 100, true},
+		{"limit=0", 0, false},
+		{"limit=-5", 0, false},
+		{"limit=101", 0, false},
+		{"limit=abc", 0, false},
+		{"limit=1.5", 0, false},
+		{"limit=99999999999999999999", 0, false},
+		{"", 42, true},
+	}
+
+	for _, c := range cases {
+		value, err := p.optional(httptest.NewRequest("GET", "/?"+c.query, nil), 42)
+		if c.valid && (err != nil || value != c.value) {
+			t.Errorf("%q: got (%d, %v), want %d", c.query, value, err, c.value)
+		}
+		if !c.valid && (err == nil || !strings.Contains(err.Error(), "limit")) {
+			t.Errorf("%q: expected an error naming the parameter, got %v", c.query, err)
+		}
+	}
+
+	if _, err := p.required(httptest.NewRequest("GET", "/", nil)); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("missing required parameter: expected an error naming it, got %v", err)
+	}
+}
+
+func TestFloatParam(t *testing.T) {
+	cases := []struct {
+		p     floatParam
+		query string
+		value float64
+		valid bool
+	}{
+		{paramFPR, "fpr=0.01", 0.01, true},
+		{paramFPR, "fpr=1e-9", 1e-9, true},
+		{paramFPR, "fpr=0", 0, false},
+		{paramFPR, "fpr=1", 0, false},
+		{paramFPR, "fpr=-0.1", 0, false},
+		{paramFPR, "fpr=2", 0, false},
+		{paramFPR, "fpr=abc", 0, false},
+		{paramFPR, "fpr=NaN", 0, false},
+		{paramFPR, "fpr=Inf", 0, false},
+		{floatParam{name: "q", min: 0, max: 1, inclusive: true}, "q=0", 0, true},
+		{floatParam{name: "q", min: 0, max: 1, inclusive: true}, "q=1", 1, true},
+		{floatParam{name: "q", min: 0, max: 1, inclusive: true}, "q=1.01", 0, false},
+	}
+
+	for _, c := range cases {
+		value, err := c.p.required(httptest.NewRequest("GET", "/?"+c.query, nil))
+		if c.valid && (err != nil || value != c.value) {
+			t.Errorf("%q: got (%g, %v), want %g", c.query, value, err, c.value)
+		}
+		if !c.valid && (err == nil || !strings.Contains(err.Error(), c.p.name)) {
+			t.Errorf("%q: expected an error naming the parameter, got %v", c.query, err)
+		}
+	}
+
+	if value, err := paramFPR.optional(httptest.NewRequest("GET", "/", nil), 0.0081); err != nil || value != 0.0081 {
+		t.Errorf("absent optional parameter: got (%g, %v), want the default", value, err)
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// recoverPanics wraps next to turn a panicking handler, e.g. on a corrupted blob decoded along the way,
+// into a 500 Internal Server Error instead of a connection closed without a response. The panic is logged
+// with its stack trace and the ID of the request, see traceRequests, and counted as http_panics_total.
+// Nothing more is written when the handler already started its response, and http.ErrAbortHandler is
+// passed through to abort the response as intended.
+func recoverPanics(next http.Handler) http.Handler {
+	panics := metrics.Int("http_panics_total")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			panics.Add(1)
+			slog.ErrorContext(r.Context(), "ALERT: handler panicked", "method", r.Method, "path", r.URL.Path,
+				"panic", p, "stack", string(debug.Stack()))
+			if rec.status == 0 {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
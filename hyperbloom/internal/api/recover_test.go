@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopds/hyperbloom/internal/requestid"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(requestid.NewHandler(slog.NewJSONHandler(&buf, nil))))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("corrupted blob")
+	})
+	mux.HandleFunc("/partial", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("after the response started")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	server := httptest.NewServer(traceRequests(logRequests(recoverPanics(mux))))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/panic", nil)
+	req.Header.Set(requestid.Header, "panicking-request")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", resp.StatusCode)
+	}
+
+	// The panic is logged with the request ID and the stack trace
+	out := buf.String()
+	for _, want := range []string{`"panic":"corrupted blob"`, `"request_id":"panicking-request"`, "recover_test.go", `"status":500`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log = %q, want %s", out, want)
+		}
+	}
+
+	// A response already started is left as is
+	resp, err = http.Get(server.URL + "/partial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("status = %d, want the 202 already written", resp.StatusCode)
+	}
+
+	// The server keeps serving
+	resp, err = http.Get(server.URL + "/ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status after panics = %d, want 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"gopds/hyperbloom/internal/requestid"
+)
+
+// traceRequests wraps next to tag every request with an ID: the X-Request-ID header of the client when valid,
+// see requestid.Valid, a generated one otherwise. The ID is echoed in the X-Request-ID header of the response
+// and carried by the request context, so that every log line written on its behalf reports it as request_id,
+// including the flush its inserts trigger.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopds/hyperbloom/internal/requestid"
+)
+
+func TestTraceRequests(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(requestid.NewHandler(slog.NewJSONHandler(&buf, nil))))
+
+	var seen string
+	handler := traceRequests(logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestid.FromContext(r.Context())
+	})))
+
+	cases := []struct {
+		header    string
+		preserved bool
+	}{
+		{"client-id-1", true},
+		{"", false},
+		{"with space", false},
+		{"line\nbreak", false},
+		{strings.Repeat("x", requestid.MaxLength+1), false},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/hyperbloom/card?key=k", nil)
+		if c.header != "" {
+			req.Header.Set(requestid.Header, c.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		// The ID is echoed, seen by the handler and logged with the request
+		id := rec.Header().Get(requestid.Header)
+		if c.preserved && id != c.header || !c.preserved && (id == c.header || !requestid.Valid(id)) {
+			t.Errorf("%q: response ID = %q", c.header, id)
+		}
+		if seen != id {
+			t.Errorf("%q: handler saw %q, response has %q", c.header, seen, id)
+		}
+		if !strings.Contains(buf.String(), `"request_id":"`+id+`"`) {
+			t.Errorf("%q: log = %q, want request_id %q", c.header, buf.String(), id)
+		}
+	}
+
+	// Generated IDs are distinct
+	if requestid.New() == requestid.New() {
+		t.Error("generated the same ID twice")
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"gopds/hyperbloom/internal/config"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Response formats, JSON or MessagePack for programmatic clients, or the text rendering meant to be read.
+const (
+	formatJSON    = "json"
+	formatMsgpack = "msgpack"
+	formatText    = "text"
+)
+
+// mediaTypes maps the media types of the Accept and Content-Type headers to the formats.
+var mediaTypes = map[string]string{
+	"application/json":      formatJSON,
+	"application/msgpack":   formatMsgpack,
+	"application/x-msgpack": formatMsgpack,
+	"text/plain":            formatText,
+}
+
+// requestFormat returns the format of the request body from its Content-Type header: MessagePack for
+// application/msgpack, JSON otherwise.
+func requestFormat(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaTypes[mediaType] == formatMsgpack {
+		return formatMsgpack
+	}
+	return formatJSON
+}
+
+// negotiateFormat picks the format of a response: the first one listed in the Accept header of the request,
+// or the configured default for clients accepting anything.
+func negotiateFormat(r *http.Request) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		// Drop the parameters, the first supported media type wins
+		name, _, _ := strings.Cut(accepted, ";")
+		if format, ok := mediaTypes[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return format
+		}
+	}
+
+	if strings.ToLower(config.ApplicationCfg().ResponseFormat) == formatText {
+		return formatText
+	}
+	return formatJSON
+}
+
+// writeResponse writes the response of a handler in the negotiated format: response encoded as JSON or MessagePack,
+// or output, its text rendering, as written by writeText.
+func writeResponse(w http.ResponseWriter, r *http.Request, response any, output string) {
+	writeResponseStatus(w, r, http.StatusOK, response, output)
+}
+
+// writeResponseStatus is writeResponse with a status other than 200 OK.
+func writeResponseStatus(w http.ResponseWriter, r *http.Request, status int, response any, output string) {
+	switch negotiateFormat(r) {
+	case formatText:
+		writeTextStatus(w, r, status, output)
+		return
+	case formatMsgpack:
+		encoded, err := marshalMsgpack(response)
+		if err != nil {
+			http.Error(w, "Can't encode response", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Can't encode response", "path", r.URL.Path, "err", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/msgpack")
+		w.WriteHeader(status)
+		w.Write(encoded)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Charsets supported for text responses.
+const (
+	charsetUTF8   = "utf-8"
+	charsetLatin1 = "iso-8859-1"
+)
+
+// charsetAliases maps the accepted charset names to the supported charsets.
+var charsetAliases = map[string]string{
+	"utf-8":      charsetUTF8,
+	"utf8":       charsetUTF8,
+	"iso-8859-1": charsetLatin1,
+	"latin1":     charsetLatin1,
+	"latin-1":    charsetLatin1,
+}
+
+// negotiateCharset picks the charset of a text response: the first supported one listed in the
+// Accept-Charset header of the request, or the configured default.
+func negotiateCharset(r *http.Request) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept-Charset"), ",") {
+		// Drop the quality value, the first supported charset wins
+		name, _, _ := strings.Cut(accepted, ";")
+		if charset, ok := charsetAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return charset
+		}
+	}
+
+	if charset, ok := charsetAliases[strings.ToLower(config.ApplicationCfg().TextCharset)]; ok {
+		return charset
+	}
+	return charsetUTF8
+}
+
+// encodeLatin1 encodes a string in ISO-8859-1, replacing the characters it can't represent with '?'.
+func encodeLatin1(output string) []byte {
+	encoded := make([]byte, 0, len(output))
+	for _, char := range output {
+		if char > 0xFF {
+			char = '?'
+		}
+		encoded = append(encoded, byte(char))
+	}
+	return encoded
+}
+
+// writeText writes a plain text response in the negotiated charset.
+// Trailing newlines of output are normalized to a single one, or none if disabled in the configuration,
+// so that every endpoint formats its text the same way.
+func writeText(w http.ResponseWriter, r *http.Request, output string) {
+	writeTextStatus(w, r, http.StatusOK, output)
+}
+
+// writeTextStatus is writeText with a status other than 200 OK.
+func writeTextStatus(w http.ResponseWriter, r *http.Request, status int, output string) {
+	output = strings.TrimRight(output, "\n")
+	if config.ApplicationCfg().TextNewline {
+		output += "\n"
+	}
+
+	charset := negotiateCharset(r)
+	w.Header().Set("Content-Type", "text/plain; charset="+charset)
+	w.WriteHeader(status)
+
+	if charset == charsetLatin1 {
+		w.Write(encodeLatin1(output))
+		return
+	}
+	w.Write([]byte(output))
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+func TestWriteText(t *testing.T) {
+	saved := *config.ApplicationCfg()
+	defer config.SetApplicationCfg(saved)
+
+	cases := []struct {
+		name          string
+		charset       string
+		newline       bool
+		acceptCharset string
+		output        string
+		contentType   string
+		body          []byte
+	}{
+		{
+			"utf-8 with newline", "utf-8", true, "", "(a) ⪽ (b) = true",
+			"text/plain; charset=utf-8", []byte("(a) \xe2\xaa\xbd (b) = true\n"),
+		},
+		{
+			"utf-8 without newline", "utf-8", false, "", "Jaccard similarity = 0.500000",
+			"text/plain; charset=utf-8", []byte("Jaccard similarity = 0.500000"),
+		},
+		{
+			"trailing newlines are normalized", "utf-8", true, "", "Key = foo\nMemory bytes = 8\n\n",
+			"text/plain; charset=utf-8", []byte("Key = foo\nMemory bytes = 8\n"),
+		},
+		{
+			"latin-1 by configuration", "latin1", true, "", "café ⪽",
+			"text/plain; charset=iso-8859-1", []byte("caf\xe9 ?\n"),
+		},
+		{
+			"latin-1 by request", "utf-8", true, "iso-8859-1;q=0.9, utf-8;q=0.5", "café",
+			"text/plain; charset=iso-8859-1", []byte("caf\xe9\n"),
+		},
+		{
+			"unsupported requested charset", "utf-8", true, "utf-16", "café",
+			"text/plain; charset=utf-8", []byte("caf\xc3\xa9\n"),
+		},
+	}
+
+	for _, c := range cases {
+		config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) {
+			cfg.TextCharset = c.charset
+			cfg.TextNewline = c.newline
+		})
+
+		r := httptest.NewRequest("GET", "/hyperbloom/card", nil)
+		if c.acceptCharset != "" {
+			r.Header.Set("Accept-Charset", c.acceptCharset)
+		}
+		w := httptest.NewRecorder()
+
+		writeText(w, r, c.output)
+
+		if got := w.Header().Get("Content-Type"); got != c.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", c.name, got, c.contentType)
+		}
+		if got := w.Body.Bytes(); !bytes.Equal(got, c.body) {
+			t.Errorf("%s: body = %q, want %q", c.name, got, c.body)
+		}
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	saved := *config.ApplicationCfg()
+	defer config.SetApplicationCfg(saved)
+	config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) {
+		cfg.TextCharset = "utf-8"
+		cfg.TextNewline = true
+	})
+
+	response := CardinalityResponse{BloomCardinality: 3, HLLCardinality: 4}
+	output := "Cardinality (bloom, hyperloglog) = (3, 4)"
+
+	cases := []struct {
+		name        string
+		format      string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			"json by default", "json", "",
+			"application/json", `{"bloom_cardinality":3,"hll_cardinality":4}` + "\n",
+		},
+		{
+			"json for clients accepting anything", "json", "*/*",
+			"application/json", `{"bloom_cardinality":3,"hll_cardinality":4}` + "\n",
+		},
+		{
+			"text by request", "json", "text/plain;q=0.9, application/json;q=0.5",
+			"text/plain; charset=utf-8", output + "\n",
+		},
+		{
+			"text by configuration", "text", "*/*",
+			"text/plain; charset=utf-8", output + "\n",
+		},
+		{
+			"json by request over the configuration", "text", "application/json",
+			"application/json", `{"bloom_cardinality":3,"hll_cardinality":4}` + "\n",
+		},
+	}
+
+	for _, c := range cases {
+		config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.ResponseFormat = c.format })
+
+		r := httptest.NewRequest("GET", "/hyperbloom/card", nil)
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		w := httptest.NewRecorder()
+
+		writeResponseStatus(w, r, http.StatusCreated, response, output)
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("%s: status = %d, want %d", c.name, w.Code, http.StatusCreated)
+		}
+		if got := w.Header().Get("Content-Type"); got != c.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", c.name, got, c.contentType)
+		}
+		if got := w.Body.String(); got != c.body {
+			t.Errorf("%s: body = %q, want %q", c.name, got, c.body)
+		}
+	}
+}
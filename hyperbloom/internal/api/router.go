@@ -1,32 +1,210 @@
 package api
 
-import "net/http"
+import (
+	"expvar"
+	"net/http"
+
+	"gopds/hyperbloom/internal/metrics"
+)
 
 // ServeHyperBloom registers HTTP request handlers for specific endpoints related to HyperBloom operations.
+// Expensive endpoints share a concurrency cap (HEAVY_LIMIT) and a generous deadline (HEAVY_TIMEOUT),
+// cheap ones get a tight deadline (LIGHT_TIMEOUT).
 func ServeHyperBloom(mux *http.ServeMux) {
 	// Register various HTTP request handlers for specific endpoints
 
+	// Handler for explicitly creating a key with a given capacity and false positive rate
+	mux.HandleFunc("/hyperbloom/create", cheap(bloomCreate))
+
+	// Handler for recommending HyperLogLog and Bloom filter parameters for a sample of values
+	mux.HandleFunc("/hyperbloom/recommend", expensive(bloomRecommend))
+
+	// Handler for approximating when a value was first added to the Bloom filter
+	mux.HandleFunc("/hyperbloom/firstseen", cheap(bloomFirstSeen))
+
+	// Handler for the most frequent values inserted in a key, with their estimated counts
+	mux.HandleFunc("/hyperbloom/topk", cheap(bloomTopK))
+
+	// Handler for checking that values known to be inserted are never reported as absent
+	mux.HandleFunc("/hyperbloom/verify/members", cheap(bloomVerifyMembers))
+
+	// Handler for measuring the accuracy of a key against the exact set of values inserted in it
+	mux.HandleFunc("/hyperbloom/audit", expensive(bloomAudit))
+
 	// Handler for hashing a value and adding it to the Bloom filter
-	mux.HandleFunc("/hyperbloom/hash", bloomHash)
+	mux.HandleFunc("/hyperbloom/hash", cheap(bloomHash))
+
+	// Handler for hashing several values at once, for bulk loads
+	mux.HandleFunc("/hyperbloom/hash/batch", expensive(bloomHashBatch))
+
+	// Handler for counting the keys that probably contain a value, checking every key
+	mux.HandleFunc("/hyperbloom/lookup/count", expensive(bloomLookupCount))
 
 	// Handler for checking if a value exists in the Bloom filter
-	mux.HandleFunc("/hyperbloom/exists", bloomExists)
+	mux.HandleFunc("/hyperbloom/exists", cheap(bloomExists))
+
+	// Handler for checking several values at once against the Bloom filter of one key, for membership screening
+	mux.HandleFunc("/hyperbloom/exists/batch", expensive(bloomExistsBatch))
 
 	// Handler for bitwise existence check in Bloom filters associated with multiple keys
-	mux.HandleFunc("/hyperbloom/exists/bitwise", bloomBitwiseExists)
+	mux.HandleFunc("/hyperbloom/exists/bitwise", cheap(bloomBitwiseExists))
 
 	// Handler for chaining existence check in Bloom filters associated with multiple keys
-	mux.HandleFunc("/hyperbloom/exists/chaining", bloomChainingExists)
+	mux.HandleFunc("/hyperbloom/exists/chaining", cheap(bloomChainingExists))
 
 	// Handler for computing approximate cardinality of a Bloom filter and HyperLogLog for a given key
-	mux.HandleFunc("/hyperbloom/card", bloomCard)
+	mux.HandleFunc("/hyperbloom/card", cheap(bloomCard))
+
+	// Handler for computing the combined cardinality of all composite keys matching a pattern
+	mux.HandleFunc("/hyperbloom/card/aggregate", expensive(bloomAggregateCard))
+
+	// Handler for computing the combined cardinality of a list of keys
+	mux.HandleFunc("/hyperbloom/card/union", expensive(bloomUnionCard))
 
 	// Handler for calculating Jaccard similarity using Bloom filters for two different keys
-	mux.HandleFunc("/hyperbloom/sim", bloomSim)
+	mux.HandleFunc("/hyperbloom/sim", cheap(bloomSim))
+
+	// Handler for calculating the pairwise Jaccard similarities between the Bloom filters of several keys
+	mux.HandleFunc("/hyperbloom/sim/matrix", expensive(bloomSimMatrix))
+
+	// Handler for estimating the Jaccard distance between two keys along with its error bound
+	mux.HandleFunc("/hyperbloom/distance", cheap(bloomDistance))
+
+	// Handler for estimating the overlap coefficient |A∩B|/min(|A|,|B|) between two keys from their HyperLogLog sketches
+	mux.HandleFunc("/hyperbloom/overlap-coefficient", cheap(bloomOverlapCoefficient))
+
+	// Handler for atomically swapping the HyperBlooms behind two keys (e.g. blue/green dataset cutover)
+	mux.HandleFunc("/hyperbloom/swap", cheap(bloomSwap))
+
+	// Handlers for backing up the filters and sketch of a key as a binary blob, and loading it back under a key
+	mux.HandleFunc("/hyperbloom/export", cheap(bloomExport))
+	mux.HandleFunc("/hyperbloom/import", expensive(bloomImport))
+
+	// Handler for deleting a key, its filters and its metadata
+	mux.HandleFunc("/hyperbloom/delete", cheap(bloomDelete))
+
+	// Handler for emptying the filters of a key while keeping its configuration, e.g. before a rebuild
+	mux.HandleFunc("/hyperbloom/reset", cheap(bloomReset))
+
+	// Handlers for removing a value, or several at once, from a key created as a counting filter
+	mux.HandleFunc("/hyperbloom/remove", cheap(bloomRemove))
+	mux.HandleFunc("/hyperbloom/remove/batch", expensive(bloomRemoveBatch))
+
+	// Handler for describing the configuration, content and memory footprint of a key
+	mux.HandleFunc("/hyperbloom/info", cheap(bloomInfo))
+
+	// Handler for reporting the memory and storage taken by every key, or a single one, for capacity planning
+	mux.HandleFunc("/hyperbloom/memory", expensive(bloomMemory))
+
+	// Handler for estimating how many more distinct values a key can take before crossing a false positive rate
+	mux.HandleFunc("/hyperbloom/headroom", cheap(bloomHeadroom))
+
+	// Handler for the number of bits set and fill ratio of a key's Bloom filter
+	mux.HandleFunc("/hyperbloom/fill", cheap(bloomFill))
+
+	// Handler for the parameters and saturation of a key's Bloom filter against its target false positive rate
+	mux.HandleFunc("/hyperbloom/stats", cheap(bloomStats))
+
+	// Handler for projecting the fill ratio and false positive rate of a key after inserting more values
+	mux.HandleFunc("/hyperbloom/projection", cheap(bloomProjection))
+
+	// Handler for listing the keys with the highest cardinality, memory footprint or fill ratio
+	mux.HandleFunc("/hyperbloom/top-keys", expensive(bloomTopKeys))
+
+	// Handler for listing the existing keys, a page at a time
+	mux.HandleFunc("/hyperbloom/keys", cheap(bloomListKeys))
+
+	// Handler for a stable hash of a key's filters, for change detection and deduplication
+	mux.HandleFunc("/hyperbloom/fingerprint", cheap(bloomFingerprint))
+
+	// Handlers for exporting the HyperLogLog sketches of every key without their Bloom filters, and importing them back.
+	// The export is streamed, which a deadline would buffer, so it's only capped by HEAVY_LIMIT
+	mux.HandleFunc("/hyperbloom/export/hll/all", heavyLimiter().limit(bloomExportHyper))
+	mux.HandleFunc("/hyperbloom/import/hll", expensive(bloomImportHyper))
+
+	// Handler for previewing the Bloom filter parameters and memory cost for a desired capacity
+	mux.HandleFunc("/hyperbloom/sizing", cheap(bloomSizing))
+}
+
+// ServeAdmin registers HTTP request handlers for admin endpoints, which require the admin token.
+// Snapshots, restores and flush controls have no deadline: they can't be cancelled halfway.
+func ServeAdmin(mux *http.ServeMux) {
+	// Handler for checking the hash distribution of a key's Bloom filter
+	mux.HandleFunc("/admin/hash-quality", expensive(adminHashQuality))
+
+	// Handler for streaming the results of an allowlisted SQL query into a key
+	mux.HandleFunc("/admin/ingest/sql", expensive(adminIngestSQL))
+
+	// Handler for writing every in-memory key to a local snapshot file
+	mux.HandleFunc("/admin/snapshot", adminSnapshot)
+
+	// Handler for loading every key from a local snapshot file
+	mux.HandleFunc("/admin/restore", adminRestore)
+
+	// Handlers for holding and resuming the periodic flush to the database
+	mux.HandleFunc("/admin/flush/pause", adminFlushPause)
+	mux.HandleFunc("/admin/flush/resume", adminFlushResume)
+
+	// Handlers for listing the keys whose persisted blobs can't be decoded and retrying them
+	mux.HandleFunc("/admin/quarantine", cheap(adminQuarantine))
+	mux.HandleFunc("/admin/quarantine/release", cheap(adminQuarantineRelease))
+}
+
+// ServeCountMin registers HTTP request handlers for the Count-Min Sketch API endpoints.
+func ServeCountMin(mux *http.ServeMux) {
+	// Handler for counting occurrences of a value
+	mux.HandleFunc("/cms/add", cheap(countMinAdd))
+
+	// Handler for estimating the number of occurrences of a value
+	mux.HandleFunc("/cms/estimate", cheap(countMinEstimate))
+}
+
+// ServeCuckoo registers HTTP request handlers for the Cuckoo filter API endpoints.
+func ServeCuckoo(mux *http.ServeMux) {
+	// Handler for adding a value
+	mux.HandleFunc("/cuckoo/add", cheap(cuckooAdd))
+
+	// Handler for checking if a value exists
+	mux.HandleFunc("/cuckoo/exists", cheap(cuckooExists))
+
+	// Handler for deleting a value
+	mux.HandleFunc("/cuckoo/delete", cheap(cuckooDelete))
+}
+
+// ServeMetrics registers the HTTP request handler exposing the published metrics.
+func ServeMetrics(mux *http.ServeMux) {
+	// Handler for the expvar metrics (in-flight and rejected heavy requests, runtime statistics) as JSON
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	// Handler for the same metrics, operation counts and request latencies in the Prometheus text format
+	mux.Handle("/metrics", metrics.Handler())
+}
+
+// ServeHealth registers the HTTP request handlers of the liveness and readiness checks for orchestrators.
+// They have no deadline nor concurrency cap, a probe must not be rejected because the instance is busy.
+func ServeHealth(mux *http.ServeMux) {
+	// Handler for the liveness check, answering as long as the process is up
+	mux.HandleFunc("/healthz", healthLive)
+
+	// Handler for the readiness check, answering only while PostgreSQL is reachable
+	mux.HandleFunc("/readyz", healthReady)
+}
+
+// ServeUI registers the HTTP request handler for the embedded page for exploring filters, enabled by UI_ENABLED.
+func ServeUI(mux *http.ServeMux) {
+	// Handler for the page and its static assets
+	mux.Handle("/ui", uiHandler())
+	mux.Handle("/ui/", uiHandler())
 }
 
-// Serve is a wrapper function that calls ServeHyperBloom to register HTTP request handlers.
+// Serve is a wrapper function that calls ServeHyperBloom, ServeCountMin, ServeCuckoo, ServeAdmin, ServeMetrics, ServeHealth and ServeUI to register HTTP request handlers.
 // It provides a convenient way to initialize the server with the desired handlers.
 func Serve(mux *http.ServeMux) {
 	ServeHyperBloom(mux)
+	ServeCountMin(mux)
+	ServeCuckoo(mux)
+	ServeAdmin(mux)
+	ServeMetrics(mux)
+	ServeHealth(mux)
+	ServeUI(mux)
 }
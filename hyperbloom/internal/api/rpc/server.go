@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+	pb "gopds/hyperbloom/protos"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// hyperBloomServer implements the HyperBloom gRPC service on top of the service layer, the same functions
+// the HTTP handlers call, so that both transports validate, transform and answer alike.
+type hyperBloomServer struct {
+	pb.UnimplementedHyperBloomServer
+}
+
+// NewServer creates the gRPC server with the HyperBloom service registered. Every call is bounded by
+// LIGHT_TIMEOUT and logged, see intercept. With a TLS configuration, the one of the HTTP server, calls are
+// served over TLS and client certificates are checked alike, in cleartext when it is nil.
+func NewServer(tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(intercept)}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	pb.RegisterHyperBloomServer(server, &hyperBloomServer{})
+	return server
+}
+
+// intercept bounds a call by LIGHT_TIMEOUT, like the HTTP endpoints it mirrors, and logs it with its method,
+// status code and latency. Successful calls are logged at the info level, the others at warn, or error
+// for failures on the server side. Calls without one of API_KEYS in their "authorization" metadata, set like
+// the Authorization header, are rejected with Unauthenticated.
+func intercept(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if timeout := config.ApplicationCfg().LightTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	var resp any
+	var err error
+	if authorized(ctx) {
+		resp, err = handler(ctx, req)
+	} else {
+		err = status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	code := status.Code(err)
+	slog.Log(ctx, codeLevel(code), "Call", "method", info.FullMethod, "code", code.String(), "latency", time.Since(start))
+	return resp, err
+}
+
+// authorized reports whether the call carries credentials the HTTP server accepts too,
+// see config.ApplicationConfig.Authorized.
+func authorized(ctx context.Context) bool {
+	authorization := ""
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		authorization = values[0]
+	}
+	return config.ApplicationCfg().Authorized(authorization)
+}
+
+// Hash adds the value to the key, creating the key with the requested sizing or checking it against
+// the existing one, and returns the resulting cardinalities, see bloomHash.
+func (srv *hyperBloomServer) Hash(ctx context.Context, req *pb.HashRequest) (*pb.CardinalityResponse, error) {
+	// Reject empty fields before involving the service
+	if err := requireFields(field{"key", req.GetKey()}, field{"value", req.GetValue()}); err != nil {
+		return nil, err
+	}
+	if req.GetFpr() < 0 || req.GetFpr() >= 1 {
+		return nil, fieldError("fpr", "must lie in [0, 1)")
+	}
+
+	// Preprocess the value with the transformation webhook, if configured
+	value, err := service.TransformValue(ctx, req.GetValue())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	// Create a new key with the requested sizing, or check it against the existing one
+	if req.GetCapacity() != 0 || req.GetFpr() != 0 {
+		if err = service.BloomEnsureSizing(ctx, req.GetKey(), uint(req.GetCapacity()), req.GetFpr()); err != nil {
+			return nil, statusError(err)
+		}
+	}
+
+	// Add the value to the Bloom filter using the provided key, checking its declared type if any
+	if err = service.BloomHashTyped(ctx, req.GetKey(), value, req.GetType()); err != nil {
+		return nil, statusError(err)
+	}
+
+	return srv.Cardinality(ctx, &pb.CardinalityRequest{Key: req.GetKey()})
+}
+
+// Exists reports whether the value may have been added to the key, see bloomExists.
+func (srv *hyperBloomServer) Exists(ctx context.Context, req *pb.ExistsRequest) (*pb.ExistsResponse, error) {
+	// Reject empty fields before involving the service
+	if err := requireFields(field{"key", req.GetKey()}, field{"value", req.GetValue()}); err != nil {
+		return nil, err
+	}
+
+	// Preprocess the value the same way it was when inserted
+	value, err := service.TransformValue(ctx, req.GetValue())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	exists, method, err := service.BloomExistsMethod(ctx, req.GetKey(), value, req.GetMethod())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.ExistsResponse{Exists: exists, Method: method}, nil
+}
+
+// Cardinality returns the approximate cardinalities of the key, from its Bloom filter and its HyperLogLog.
+func (srv *hyperBloomServer) Cardinality(ctx context.Context, req *pb.CardinalityRequest) (*pb.CardinalityResponse, error) {
+	if err := requireFields(field{"key", req.GetKey()}); err != nil {
+		return nil, err
+	}
+
+	bCard, hCard, err := service.BloomCardinality(ctx, req.GetKey())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.CardinalityResponse{BloomCardinality: bCard, HllCardinality: hCard}, nil
+}
+
+// Similarity returns the Jaccard similarity of two keys, and whether it fell back to the HyperLogLog estimate
+// because the filters differ in size, along with the HyperLogLog estimate unless the sketches can't be merged,
+// see bloomSim.
+func (srv *hyperBloomServer) Similarity(ctx context.Context, req *pb.SimilarityRequest) (*pb.SimilarityResponse, error) {
+	// Reject empty fields before involving the service
+	if err := requireFields(field{"key_1", req.GetKey_1()}, field{"key_2", req.GetKey_2()}); err != nil {
+		return nil, err
+	}
+
+	sim, fallback, err := service.BloomSimilarityOnMismatch(ctx, req.GetKey_1(), req.GetKey_2(), req.GetOnMismatch())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	// Estimate the similarity from the sketches as well, unless the fallback already did
+	resp := &pb.SimilarityResponse{Similarity: sim, Fallback: fallback}
+	if fallback {
+		resp.HllSimilarity = &sim
+	} else if hllSim, err := service.BloomHyperSimilarity(ctx, req.GetKey_1(), req.GetKey_2()); err == nil {
+		resp.HllSimilarity = &hllSim
+	}
+	return resp, nil
+}
+
+// BitwiseExists combines the filters of the keys with the operator before checking the value, see bloomBitwiseExists.
+func (srv *hyperBloomServer) BitwiseExists(ctx context.Context, req *pb.MultiExistsRequest) (*pb.MultiExistsResponse, error) {
+	return multiExists(ctx, req, service.BloomBitwiseExists)
+}
+
+// ChainingExists checks the value in the filter of every key and combines the results with the operator,
+// see bloomChainingExists.
+func (srv *hyperBloomServer) ChainingExists(ctx context.Context, req *pb.MultiExistsRequest) (*pb.MultiExistsResponse, error) {
+	return multiExists(ctx, req, service.BloomChainingExists)
+}
+
+// multiExists validates a request checking a value against several keys and answers it with check.
+// An omitted operator falls back to HB_DEFAULT_OPERATOR.
+func multiExists(
+	ctx context.Context,
+	req *pb.MultiExistsRequest,
+	check func(ctx context.Context, keys []string, value string, operator service.Operator) (bool, error),
+) (*pb.MultiExistsResponse, error) {
+	// Reject empty fields before involving the service
+	if err := requireKeys(req.GetKeys()); err != nil {
+		return nil, err
+	}
+	if err := requireFields(field{"value", req.GetValue()}); err != nil {
+		return nil, err
+	}
+
+	operator, err := service.ResolveOperator(req.GetOperator())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	// Preprocess the value the same way it was when inserted
+	value, err := service.TransformValue(ctx, req.GetValue())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	exists, err := check(ctx, req.GetKeys(), value, operator)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.MultiExistsResponse{Operator: string(operator), Exists: exists}, nil
+}
@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"gopds/hyperbloom/internal/service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// field is a named request field checked by requireFields.
+type field struct {
+	name  string
+	value string
+}
+
+// fieldError returns the InvalidArgument error for the field, worded like the HTTP field errors.
+func fieldError(name, reason string) error {
+	return status.Errorf(codes.InvalidArgument, "Invalid %s: %s", name, reason)
+}
+
+// requireFields checks that none of fields is empty, before the service layer is involved.
+func requireFields(fields ...field) error {
+	for _, f := range fields {
+		if f.value == "" {
+			return fieldError(f.name, "must not be empty")
+		}
+	}
+	return nil
+}
+
+// requireKeys checks that keys, the "keys" field of a request, lists at least one key and no empty one.
+func requireKeys(keys []string) error {
+	if len(keys) == 0 {
+		return fieldError("keys", "must list at least one key")
+	}
+	for i, key := range keys {
+		if key == "" {
+			return fieldError("keys", fmt.Sprintf("key %d must not be empty", i))
+		}
+	}
+	return nil
+}
+
+// statusError converts an error of the service layer to the gRPC status matching the HTTP status of the same
+// failure: invalid parameters are InvalidArgument, missing keys NotFound, conflicts with the existing key
+// FailedPrecondition and a failed transformation webhook Unavailable. Unexpected errors are logged and hidden
+// behind Internal.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, service.ErrInvalidExistsMethod),
+		errors.Is(err, service.ErrInvalidOperator),
+		errors.Is(err, service.ErrInvalidOnMismatch),
+		errors.Is(err, service.ErrInfeasibleSizing):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrKeyNotFound):
+		return status.Error(codes.NotFound, "Key not found")
+	case errors.Is(err, service.ErrValueTypeMismatch),
+		errors.Is(err, service.ErrSizingConflict),
+		errors.Is(err, service.ErrIncompatibleFilters):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrTransformFailed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, service.ErrKeyQuarantined):
+		return status.Error(codes.Internal, err.Error())
+	default:
+		slog.Error("Can't serve call", "err", err)
+		return status.Error(codes.Internal, "Can't load key")
+	}
+}
+
+// codeLevel returns the level a call ending with code is logged at, see intercept.
+func codeLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unimplemented:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"gopds/hyperbloom/internal/service"
+	pb "gopds/hyperbloom/protos"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusError(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{fmt.Errorf("%w: %q", service.ErrInvalidOperator, "NAND"), codes.InvalidArgument},
+		{fmt.Errorf("%w: %q", service.ErrInvalidExistsMethod, "guess"), codes.InvalidArgument},
+		{fmt.Errorf("%w: missing", service.ErrKeyNotFound), codes.NotFound},
+		{fmt.Errorf("%w: 1024 bits vs 2048 bits", service.ErrIncompatibleFilters), codes.FailedPrecondition},
+		{fmt.Errorf("%w: int vs string", service.ErrValueTypeMismatch), codes.FailedPrecondition},
+		{fmt.Errorf("%w: 502 Bad Gateway", service.ErrTransformFailed), codes.Unavailable},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{errors.New("connection refused"), codes.Internal},
+	}
+
+	for _, c := range cases {
+		if got := status.Code(statusError(c.err)); got != c.code {
+			t.Errorf("statusError(%v) code = %s, want %s", c.err, got, c.code)
+		}
+	}
+}
+
+func TestRequireFields(t *testing.T) {
+	srv := &hyperBloomServer{}
+	ctx := context.Background()
+
+	// Empty fields are rejected before the service is involved, no database is needed
+	_, err := srv.Exists(ctx, &pb.ExistsRequest{Key: "key"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Exists without a value: err = %v, want InvalidArgument", err)
+	}
+	_, err = srv.Similarity(ctx, &pb.SimilarityRequest{Key_1: "key"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Similarity without key_2: err = %v, want InvalidArgument", err)
+	}
+	_, err = srv.BitwiseExists(ctx, &pb.MultiExistsRequest{Keys: []string{"key", ""}, Value: "value"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BitwiseExists with an empty key: err = %v, want InvalidArgument", err)
+	}
+	_, err = srv.Hash(ctx, &pb.HashRequest{Key: "key", Value: "value", Fpr: 1})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Hash with fpr 1: err = %v, want InvalidArgument", err)
+	}
+}
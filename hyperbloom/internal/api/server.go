@@ -0,0 +1,115 @@
+package api
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// NewServer creates the HTTP server serving mux, hardened against clients holding connections open:
+// headers must arrive within HTTP_READ_HEADER_TIMEOUT and the whole request within HTTP_READ_TIMEOUT,
+// responses must be written within HTTP_WRITE_TIMEOUT, keep-alive connections are closed after
+// HTTP_IDLE_TIMEOUT, and a reaper closes any connection without a request in progress for HTTP_REAP_IDLE.
+// The number of open connections is published as http_connections, every request is tagged with an ID,
+// see traceRequests, logged, see logRequests, and requires one of API_KEYS when set, see authenticate.
+// A panicking handler results in a 500 Internal Server Error, see recoverPanics.
+func NewServer(mux *http.ServeMux) *http.Server {
+	reaper := newConnReaper()
+	go reaper.run()
+
+	cfg := config.ApplicationCfg()
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           traceRequests(logRequests(recoverPanics(authenticate(mux)))),
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+		ConnState:         reaper.track,
+	}
+}
+
+// connReaper tracks the state of the server connections through http.Server.ConnState,
+// and closes the ones that have been new or idle for too long.
+type connReaper struct {
+	mutex  sync.Mutex
+	conns  map[net.Conn]connInfo // Open connections
+	open   *expvar.Int           // Number of open connections
+	reaped *expvar.Int           // Number of connections closed by the reaper
+}
+
+// connInfo is the last known state of a connection.
+type connInfo struct {
+	state http.ConnState // Last state reported by the server
+	since time.Time      // When the connection entered the state
+}
+
+// newConnReaper creates a reaper publishing its counts as http_connections and http_connections_reaped_total.
+func newConnReaper() *connReaper {
+	return &connReaper{
+		conns:  map[net.Conn]connInfo{},
+		open:   metrics.Int("http_connections"),
+		reaped: metrics.Int("http_connections_reaped_total"),
+	}
+}
+
+// track records the new state of conn, it is called by the server on every transition.
+func (reaper *connReaper) track(conn net.Conn, state http.ConnState) {
+	reaper.mutex.Lock()
+	defer reaper.mutex.Unlock()
+
+	_, known := reaper.conns[conn]
+	switch state {
+	case http.StateHijacked, http.StateClosed:
+		// The connection is no longer the server's
+		if known {
+			delete(reaper.conns, conn)
+			reaper.open.Add(-1)
+		}
+	default:
+		if !known {
+			reaper.open.Add(1)
+		}
+		reaper.conns[conn] = connInfo{state: state, since: time.Now()}
+	}
+}
+
+// reap closes the connections that have been new or idle since before deadline and returns how many.
+// Active connections are left to the handlers.
+func (reaper *connReaper) reap(deadline time.Time) int {
+	reaper.mutex.Lock()
+	defer reaper.mutex.Unlock()
+
+	count := 0
+	for conn, info := range reaper.conns {
+		if info.state != http.StateActive && info.since.Before(deadline) {
+			// Forget it right away, the server reports it closed later on
+			conn.Close()
+			delete(reaper.conns, conn)
+			reaper.open.Add(-1)
+			count++
+		}
+	}
+	reaper.reaped.Add(int64(count))
+
+	return count
+}
+
+// run reaps the connections idle for longer than HTTP_REAP_IDLE, checking every second
+// so that a reloaded threshold applies right away.
+func (reaper *connReaper) run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if threshold := config.ApplicationCfg().HTTPReapIdle; threshold > 0 {
+			reaper.reap(now.Add(-threshold))
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"expvar"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConnReaper(t *testing.T) {
+	reaper := &connReaper{
+		conns:  map[net.Conn]connInfo{},
+		open:   new(expvar.Int),
+		reaped: new(expvar.Int),
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		ConnState: reaper.track,
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	// A client connecting without ever sending a request
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for reaper.open.Value() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("open connections = %d, want 1", reaper.open.Value())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Connections younger than the threshold are kept
+	if count := reaper.reap(time.Now().Add(-time.Minute)); count != 0 {
+		t.Errorf("reaped %d fresh connections", count)
+	}
+
+	if count := reaper.reap(time.Now().Add(time.Second)); count != 1 {
+		t.Fatalf("reaped %d connections, want 1", count)
+	}
+	if reaper.open.Value() != 0 || reaper.reaped.Value() != 1 {
+		t.Errorf("(open, reaped) = (%d, %d), want (0, 1)", reaper.open.Value(), reaper.reaped.Value())
+	}
+
+	// The client sees the connection closed
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err = conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected EOF on the reaped connection, got %v", err)
+	}
+}
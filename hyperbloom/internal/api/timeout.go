@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// cheap bounds a cheap handler by LIGHT_TIMEOUT, see withTimeout, and observes its latency.
+func cheap(next http.HandlerFunc) http.HandlerFunc {
+	return withLatency(withTimeout(next, false))
+}
+
+// expensive caps an expensive handler by HEAVY_LIMIT and bounds it by HEAVY_TIMEOUT, see withTimeout.
+// The deadline wraps the limiter, so a timed out handler keeps its slot until it actually returns.
+// The observed latency includes the time rejected requests took to be rejected.
+func expensive(next http.HandlerFunc) http.HandlerFunc {
+	return withLatency(withTimeout(heavyLimiter().limit(next), true))
+}
+
+// endpointTimeout returns the deadline of the handling of a request to path: its ENDPOINT_TIMEOUTS entry if any,
+// HEAVY_TIMEOUT or LIGHT_TIMEOUT otherwise. The configuration is read on every request, so a reload applies live.
+func endpointTimeout(path string, isHeavy bool) time.Duration {
+	cfg := config.ApplicationCfg()
+
+	// Entries were validated at startup and on reload
+	timeouts, _ := config.ParseEndpointTimeouts(cfg.EndpointTimeouts)
+	if timeout, ok := timeouts[path]; ok {
+		return timeout
+	}
+
+	if isHeavy {
+		return cfg.HeavyTimeout
+	}
+	return cfg.LightTimeout
+}
+
+// withTimeout wraps next with a deadline on its request context, responding 503 once it expires.
+// Database work started with the request context (e.g. SQL ingestion) is cancelled with it, the rest of the handler
+// runs to completion in the background with its response discarded. Timed out requests are counted
+// by path in timeouts_total.
+func withTimeout(next http.HandlerFunc, isHeavy bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeout := endpointTimeout(r.URL.Path, isHeavy)
+		if timeout <= 0 {
+			next(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		http.TimeoutHandler(next, timeout, "Request timed out").ServeHTTP(w, r.WithContext(ctx))
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			metrics.Map("timeouts_total", "path").Add(r.URL.Path, 1)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+func TestWithTimeout(t *testing.T) {
+	saved := *config.ApplicationCfg()
+	defer config.SetApplicationCfg(saved)
+	config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) {
+		cfg.LightTimeout = time.Minute
+		cfg.EndpointTimeouts = []string{"/slow=20ms"}
+	})
+
+	// The handler waits for its context to be cancelled
+	cancelled := make(chan error, 1)
+	handler := cheap(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			cancelled <- r.Context().Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("timed out response = %d, want 503", w.Code)
+	}
+	if err := <-cancelled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("handler context error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Without an override, LIGHT_TIMEOUT leaves time to answer
+	w = httptest.NewRecorder()
+	cheap(func(w http.ResponseWriter, r *http.Request) {
+		writeText(w, r, "done")
+	})(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("fast response = %d, want 200", w.Code)
+	}
+}
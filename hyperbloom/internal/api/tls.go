@@ -0,0 +1,105 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+// strongCipherSuites are the TLS 1.2 cipher suites used unless TLS_CIPHER_SUITES says otherwise:
+// forward secret key exchanges with AEAD ciphers only.
+var strongCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// tlsVersions maps the accepted TLS_MIN_VERSION values to their protocol versions.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig builds the TLS configuration of the server from TLS_*, or returns nil if HTTPS isn't enabled.
+// With TLS_CLIENT_CA_FILE, handshakes without a client certificate chaining to the bundle are rejected,
+// before any request is read. It returns an error if a file can't be loaded or a setting is invalid.
+func NewTLSConfig(cfg config.ApplicationConfig) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, errors.New("invalid TLS_CLIENT_CA_FILE: client certificates need TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS_CERT_FILE or TLS_KEY_FILE: %w", err)
+	}
+
+	minVersion, ok := tlsVersions[cfg.TLSMinVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid TLS_MIN_VERSION %q: must be 1.2 or 1.3", cfg.TLSMinVersion)
+	}
+
+	cipherSuites, err := parseCipherSuites(cfg.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+	}
+
+	// Require and verify client certificates against the configured CA bundle
+	if cfg.TLSClientCAFile != "" {
+		bundle, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS_CLIENT_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("invalid TLS_CLIENT_CA_FILE %s: no PEM certificate found", cfg.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// parseCipherSuites resolves TLS_CIPHER_SUITES names to their IDs, defaulting to strongCipherSuites.
+// It returns an error naming the first unknown or insecure suite.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	secure := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		secure[suite.Name] = suite.ID
+	}
+
+	suites := []uint16{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := secure[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS_CIPHER_SUITES entry %q: not a secure cipher suite", name)
+		}
+		suites = append(suites, id)
+	}
+
+	if len(suites) == 0 {
+		return strongCipherSuites, nil
+	}
+	return suites, nil
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+// testCert is a certificate and its key, signed by a test CA or self-signed.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert creates a certificate for name signed by parent, or a CA when parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and its key as PEM files in dir, and returns their paths.
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// tlsCertificate returns the certificate and its key for a tls.Config.
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	server := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth)
+	client := newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth)
+	rogueCA := newTestCert(t, "rogue-ca", nil, x509.ExtKeyUsageAny)
+	rogue := newTestCert(t, "rogue", rogueCA, x509.ExtKeyUsageClientAuth)
+
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := server.write(t, dir, "server")
+
+	cfg := config.ApplicationConfig{
+		TLSCertFile:     certFile,
+		TLSKeyFile:      keyFile,
+		TLSClientCAFile: caFile,
+		TLSMinVersion:   "1.2",
+	}
+	tlsConfig, err := NewTLSConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("client auth = %v, want RequireAndVerifyClientCert", tlsConfig.ClientAuth)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certificates ...tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: certificates,
+		}}}
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	// A client certificate signed by the configured CA is accepted
+	if err = get(client.tlsCertificate()); err != nil {
+		t.Errorf("client certificate rejected: %v", err)
+	}
+
+	// Handshakes without a certificate or with one from another CA are rejected
+	if err = get(); err == nil {
+		t.Error("handshake without a client certificate accepted")
+	}
+	if err = get(rogue.tlsCertificate()); err == nil {
+		t.Error("client certificate from another CA accepted")
+	}
+
+	// HTTPS is disabled without a certificate, a client CA alone is a mistake
+	if tlsConfig, err = NewTLSConfig(config.ApplicationConfig{}); tlsConfig != nil || err != nil {
+		t.Errorf("no TLS settings: config %v, error %v", tlsConfig, err)
+	}
+	if _, err = NewTLSConfig(config.ApplicationConfig{TLSClientCAFile: caFile}); err == nil {
+		t.Error("client CA without a server certificate accepted")
+	}
+
+	// Invalid versions and suites are rejected
+	invalid := cfg
+	invalid.TLSMinVersion = "1.0"
+	if _, err = NewTLSConfig(invalid); err == nil {
+		t.Error("TLS 1.0 accepted")
+	}
+	invalid = cfg
+	invalid.TLSCipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+	if _, err = NewTLSConfig(invalid); err == nil {
+		t.Error("insecure cipher suite accepted")
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	suites, err := parseCipherSuites(nil)
+	if err != nil || len(suites) != len(strongCipherSuites) {
+		t.Errorf("default suites = %v, %v", suites, err)
+	}
+
+	suites, err = parseCipherSuites([]string{" TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", ""})
+	if err != nil || len(suites) != 1 || suites[0] != tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 {
+		t.Errorf("configured suites = %v, %v", suites, err)
+	}
+
+	if _, err = parseCipherSuites([]string{"TLS_NOT_A_SUITE"}); err == nil {
+		t.Error("unknown suite accepted")
+	}
+}
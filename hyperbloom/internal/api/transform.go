@@ -0,0 +1,30 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+// transformValues preprocesses the values of a request with the transformation webhook (HB_TRANSFORM_URL),
+// in a single call bounded by the request context. It writes a 502 Bad Gateway response and returns false
+// if the webhook failed and HB_TRANSFORM_FALLBACK is reject.
+func transformValues(w http.ResponseWriter, r *http.Request, values []string) ([]string, bool) {
+	transformed, err := service.TransformValues(r.Context(), values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		slog.WarnContext(r.Context(), "Can't transform values", "path", r.URL.Path, "err", err)
+		return nil, false
+	}
+	return transformed, true
+}
+
+// transformValue preprocesses a single value, see transformValues.
+func transformValue(w http.ResponseWriter, r *http.Request, value string) (string, bool) {
+	transformed, ok := transformValues(w, r, []string{value})
+	if !ok {
+		return "", false
+	}
+	return transformed[0], true
+}
@@ -0,0 +1,416 @@
+package api
+
+import "time"
+
+// CardinalityResponse is the body of bloomHash, and is embedded by the responses reporting both cardinality estimates.
+type CardinalityResponse struct {
+	BloomCardinality uint32 `json:"bloom_cardinality"` // Estimated cardinality from the Bloom filter
+	HLLCardinality   uint64 `json:"hll_cardinality"`   // Estimated cardinality from the HyperLogLog sketch
+}
+
+// ExistsResponse is the body of bloomExists.
+type ExistsResponse struct {
+	Key    string `json:"key"`    // Key checked
+	Value  string `json:"value"`  // Value checked, as sent by the client
+	Exists bool   `json:"exists"` // Whether the value probably exists
+	Method string `json:"method"` // Method that answered, "bloom" or "hll"
+}
+
+// RemoveResponse is the body of bloomRemove.
+type RemoveResponse struct {
+	Key     string `json:"key"`     // Key the value was removed from
+	Value   string `json:"value"`   // Value removed, as sent by the client
+	Removed bool   `json:"removed"` // Whether the value was reported present, and its counters decremented
+}
+
+// RemoveBatchResponse is the body of bloomRemoveBatch.
+type RemoveBatchResponse struct {
+	Key     string   `json:"key"`     // Key the values were removed from
+	Removed int      `json:"removed"` // Number of values reported present, and their counters decremented
+	AtZero  []string `json:"at_zero"` // Values, as sent by the client, already at zero and likely not present
+}
+
+// HashBatchResponse is the body of bloomHashBatch.
+type HashBatchResponse struct {
+	Hashed int `json:"hashed"` // Number of values hashed
+	CardinalityResponse
+}
+
+// CardResponse is the body of bloomCard.
+type CardResponse struct {
+	CardinalityResponse
+	Empty              bool       `json:"empty"`                         // Whether no value was ever inserted
+	CreatedAt          *time.Time `json:"created_at"`                    // Creation time, null if unknown
+	DecayedCardinality *float64   `json:"decayed_cardinality,omitempty"` // Time-decayed distinct count, only when requested
+	HalfLife           string     `json:"half_life,omitempty"`           // Half-life of the decayed count, only when requested
+}
+
+// SimilarityResponse is the body of bloomSim.
+type SimilarityResponse struct {
+	Similarity float32 `json:"similarity"` // Jaccard similarity
+	Fallback   bool    `json:"fallback"`   // Whether it was estimated from the HyperLogLog sketches
+
+	// HLLSimilarity is the Jaccard similarity estimated from the HyperLogLog sketches, to compare with the Bloom
+	// filter estimate. It is omitted when the sketches can't be merged.
+	HLLSimilarity *float32 `json:"hll_similarity,omitempty"`
+}
+
+// OverlapResponse is the body of bloomOverlapCoefficient.
+type OverlapResponse struct {
+	Coefficient  float64 `json:"coefficient"`   // Overlap coefficient
+	Cardinality1 uint64  `json:"cardinality_1"` // Estimated cardinality of key_1
+	Cardinality2 uint64  `json:"cardinality_2"` // Estimated cardinality of key_2
+	Union        uint64  `json:"union"`         // Estimated cardinality of the union
+	Intersection uint64  `json:"intersection"`  // Estimated cardinality of the intersection
+}
+
+// DistanceResponse is the body of bloomDistance.
+type DistanceResponse struct {
+	Distance   float64 `json:"distance"`    // Jaccard distance
+	ErrorBound float64 `json:"error_bound"` // Approximate bound on the error of the distance
+	Similarity float64 `json:"similarity"`  // Jaccard similarity
+	Fill1      float64 `json:"fill_1"`      // Fraction of bits set in the filter of key_1
+	Fill2      float64 `json:"fill_2"`      // Fraction of bits set in the filter of key_2
+	Fallback   bool    `json:"fallback"`    // Whether the similarity was estimated from the HyperLogLog sketches
+}
+
+// SimilarityMatrixResponse is the body of bloomSimMatrix.
+type SimilarityMatrixResponse struct {
+	Keys   []string    `json:"keys"`   // Keys, in the order of the rows and columns
+	Matrix [][]float32 `json:"matrix"` // Pairwise Jaccard similarities
+}
+
+// MultiExistsResponse is the body of bloomBitwiseExists and bloomChainingExists.
+type MultiExistsResponse struct {
+	Operator string `json:"operator"` // Operator applied, "AND", "OR" or "XOR"
+	Exists   bool   `json:"exists"`   // Whether the value probably exists
+}
+
+// SizingResponse is the body of bloomSizing.
+type SizingResponse struct {
+	M     uint   `json:"m"`     // Bit array size
+	K     uint   `json:"k"`     // Number of hash functions
+	Bytes uint64 `json:"bytes"` // Size of the bit array
+}
+
+// LookupCountResponse is the body of bloomLookupCount.
+type LookupCountResponse struct {
+	Value                  string  `json:"value"`                    // Value looked up, as sent by the client
+	Count                  int     `json:"count"`                    // Number of keys reporting the value as present
+	Scanned                int     `json:"scanned"`                  // Number of keys checked
+	ExpectedFalsePositives float64 `json:"expected_false_positives"` // Number of keys expected to report the value without containing it
+}
+
+// AggregateCardinalityResponse is the body of bloomAggregateCard.
+type AggregateCardinalityResponse struct {
+	Pattern        string   `json:"pattern"`         // Composite key pattern
+	Keys           []string `json:"keys"`            // Keys matching the pattern
+	HLLCardinality uint64   `json:"hll_cardinality"` // Estimated cardinality of the union of their sketches
+}
+
+// UnionCardinalityResponse is the body of bloomUnionCard.
+type UnionCardinalityResponse struct {
+	Keys           []string `json:"keys"`            // Keys merged
+	HLLCardinality uint64   `json:"hll_cardinality"` // Estimated cardinality of the union of their sketches
+}
+
+// ResetResponse is the body of bloomReset.
+type ResetResponse struct {
+	Key string `json:"key"` // Key emptied
+	CardinalityResponse
+}
+
+// InfoResponse is the body of bloomInfo.
+type InfoResponse struct {
+	Key           string     `json:"key"`            // Key described
+	BitCapacity   uint       `json:"bit_capacity"`   // Size of the Bloom filter bit array (m)
+	HashFunctions uint       `json:"hash_functions"` // Number of hash functions (k)
+	Strict        bool       `json:"strict"`         // Whether membership is checked against two independent filters
+	Counting      bool       `json:"counting"`       // Whether values can be removed
+	TopKCapacity  int        `json:"topk_capacity"`  // Number of most frequent values tracked, 0 if not tracked
+	Empty         bool       `json:"empty"`          // Whether no value was ever inserted
+	CreatedAt     *time.Time `json:"created_at"`     // Creation time, null if unknown
+	MemoryBytes   uint64     `json:"memory_bytes"`   // Memory used by the bit array and the sketch
+	CardinalityResponse
+	AvgValueLength float64 `json:"avg_value_length,omitempty"` // Average length of the inserted values, only when tracked
+	ExactSetBytes  uint64  `json:"exact_set_bytes,omitempty"`  // Estimated size of an exact set, only when tracked
+	SavingsRatio   float64 `json:"savings_ratio,omitempty"`    // ExactSetBytes / MemoryBytes, only when tracked
+}
+
+// SwapResponse is the body of bloomSwap.
+type SwapResponse struct {
+	Key1 string `json:"key_1"` // First key swapped
+	Key2 string `json:"key_2"` // Second key swapped
+}
+
+// HeadroomResponse is the body of bloomHeadroom.
+type HeadroomResponse struct {
+	Current   uint64 `json:"current"`   // Estimated number of distinct values
+	Capacity  uint64 `json:"capacity"`  // Number of distinct values at which the target rate is crossed
+	Remaining uint64 `json:"remaining"` // Number of distinct values left before crossing it
+}
+
+// FillResponse is the body of bloomFill.
+type FillResponse struct {
+	Set   uint64  `json:"set"`   // Number of bits set
+	Total uint64  `json:"total"` // Number of bits
+	Ratio float64 `json:"ratio"` // Fraction of bits set
+}
+
+// StatsResponse is the body of bloomStats.
+type StatsResponse struct {
+	HashFunctions uint    `json:"hash_functions"` // Number of hash functions (k)
+	BitCapacity   uint    `json:"bit_capacity"`   // Size of the Bloom filter bit array (m)
+	FillRatio     float64 `json:"fill_ratio"`     // Fraction of the bits set
+	Capacity      uint    `json:"capacity"`       // Expected number of elements the filter was sized for, 0 if unknown
+	TargetFPR     float64 `json:"target_fpr"`     // False positive rate the filter was sized for, 0 if unknown
+	EstimatedFPR  float64 `json:"estimated_fpr"`  // False positive rate at the HyperLogLog cardinality
+	Saturated     bool    `json:"saturated"`      // Whether the estimated rate exceeds the target
+	Precision     uint8   `json:"precision"`      // Precision of the HyperLogLog sketch (p)
+	Registers     uint    `json:"registers"`      // Number of registers of the sketch (2^p)
+	StandardError float64 `json:"standard_error"` // Relative standard error of the cardinality estimate
+}
+
+// ProjectionResponse is the body of bloomProjection.
+type ProjectionResponse struct {
+	Additional         uint64  `json:"additional"`           // Number of distinct values assumed inserted
+	FillRatio          float64 `json:"fill_ratio"`           // Fraction of the bits set now
+	CurrentFPR         float64 `json:"current_fpr"`          // False positive rate at the current fill
+	ProjectedFillRatio float64 `json:"projected_fill_ratio"` // Fraction of the bits set after the insertions
+	ProjectedFPR       float64 `json:"projected_fpr"`        // False positive rate at the projected fill
+	ProjectedCount     uint64  `json:"projected_count"`      // Estimated cardinality after the insertions
+	TargetFPR          float64 `json:"target_fpr"`           // False positive rate the filter was sized for, 0 if unknown
+	ExceedsTarget      bool    `json:"exceeds_target"`       // Whether the projected rate exceeds the target
+}
+
+// BackupImportResponse is the body of bloomImport.
+type BackupImportResponse struct {
+	Key   string `json:"key"`   // Key the filter was imported under
+	Bytes int    `json:"bytes"` // Size of the imported encoding
+}
+
+// TopKeysResponse is the body of bloomTopKeys.
+type TopKeysResponse struct {
+	Keys []KeyUsageResponse `json:"keys"` // Keys ranked, highest first
+}
+
+// KeyUsageResponse describes a key ranked by bloomTopKeys.
+type KeyUsageResponse struct {
+	Key         string  `json:"key"`          // Key ranked
+	Cardinality uint64  `json:"cardinality"`  // Estimated cardinality from the HyperLogLog sketch
+	MemoryBytes uint64  `json:"memory_bytes"` // Memory used by the filters and sketches
+	FillRatio   float64 `json:"fill_ratio"`   // Fraction of bits set in the Bloom filter
+}
+
+// ListKeysResponse is the body of bloomListKeys.
+type ListKeysResponse struct {
+	Keys   []KeyInfoResponse `json:"keys"`   // Keys of the page, sorted by key
+	Limit  int               `json:"limit"`  // Largest number of keys of the page
+	Offset int               `json:"offset"` // Number of keys skipped before the page
+}
+
+// KeyInfoResponse describes a key listed by bloomListKeys.
+type KeyInfoResponse struct {
+	Key         string     `json:"key"`         // Key listed
+	Cardinality uint64     `json:"cardinality"` // Estimated cardinality from the HyperLogLog sketch
+	Type        string     `json:"type"`        // Type of filter, "standard", "strict", "scalable" or "counting"
+	CreatedAt   *time.Time `json:"created_at"`  // Creation time, null if unknown
+	UpdatedAt   *time.Time `json:"updated_at"`  // Time of the last write to the database, null if unknown
+}
+
+// MemoryResponse is the body of bloomMemory.
+type MemoryResponse struct {
+	Keys            []KeyMemoryResponse `json:"keys"`             // Footprint of every key, sorted by key
+	InMemoryKeys    int                 `json:"in_memory_keys"`   // Number of keys loaded
+	MemoryBytes     uint64              `json:"memory_bytes"`     // Total memory used by the keys loaded
+	SerializedBytes uint64              `json:"serialized_bytes"` // Total size of the encoded filters and sketches
+	StoredBytes     uint64              `json:"stored_bytes"`     // Total space taken in the database
+}
+
+// KeyMemoryResponse is the footprint of a key listed by bloomMemory.
+type KeyMemoryResponse struct {
+	Key             string `json:"key"`              // Key measured
+	InMemory        bool   `json:"in_memory"`        // Whether the key is loaded
+	MemoryBytes     uint64 `json:"memory_bytes"`     // Memory used by its filters and sketches, 0 when not loaded
+	SerializedBytes uint64 `json:"serialized_bytes"` // Size of its encoded filters and sketches as last written
+	StoredBytes     uint64 `json:"stored_bytes"`     // Space its blobs take in the database once compressed
+}
+
+// FingerprintResponse is the body of bloomFingerprint.
+type FingerprintResponse struct {
+	Key         string `json:"key"`         // Key fingerprinted
+	Fingerprint string `json:"fingerprint"` // Hex-encoded SHA-256 of its filters
+}
+
+// ImportResponse is the body of bloomImportHyper.
+type ImportResponse struct {
+	Imported int `json:"imported"` // Number of keys imported
+}
+
+// CreateResponse is the body of bloomCreate.
+type CreateResponse struct {
+	Key           string     `json:"key"`                                   // Key created
+	Outcome       string     `json:"outcome"`                               // "created", "ignored" or "replaced"
+	HashFunctions uint       `json:"hash_functions,omitempty"`              // Overridden number of hash functions, only when overridden
+	TheoreticalFP *float64   `json:"theoretical_fpr_at_capacity,omitempty"` // False positive rate it results in, only when overridden
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`                  // Time the key expires, only for keys created with a time to live
+}
+
+// RecommendResponse is the body of bloomRecommend.
+type RecommendResponse struct {
+	Distinct  uint64                   `json:"distinct"`  // Exact number of distinct values in the sample
+	Precision uint8                    `json:"precision"` // Recommended HyperLogLog precision
+	FPR       float64                  `json:"fpr"`       // False positive rate of the recommended Bloom filter
+	M         uint                     `json:"m"`         // Bit array size of the recommended Bloom filter
+	K         uint                     `json:"k"`         // Number of hash functions of the recommended Bloom filter
+	Hyper     []HyperCandidateResponse `json:"hll"`       // Every evaluated HyperLogLog precision
+	Bloom     []BloomCandidateResponse `json:"bloom"`     // Every evaluated Bloom filter parameters
+}
+
+// HyperCandidateResponse is a HyperLogLog precision evaluated by bloomRecommend.
+type HyperCandidateResponse struct {
+	Precision uint8   `json:"precision"` // Number of bits used for register indexes
+	Bytes     uint64  `json:"bytes"`     // Size of the dense registers
+	Estimate  uint64  `json:"estimate"`  // Estimated cardinality of the sample
+	Error     float64 `json:"error"`     // Relative error of the estimate
+}
+
+// BloomCandidateResponse is a set of Bloom filter parameters evaluated by bloomRecommend.
+type BloomCandidateResponse struct {
+	FPR      float64 `json:"fpr"`      // Configured false positive rate
+	M        uint    `json:"m"`        // Bit array size
+	K        uint    `json:"k"`        // Number of hash functions
+	Bytes    uint64  `json:"bytes"`    // Size of the bit array
+	Measured float64 `json:"measured"` // False positive rate measured over values outside the sample
+}
+
+// AuditResponse is the body of bloomAudit.
+type AuditResponse struct {
+	Key               string  `json:"key"`                 // Key audited
+	Distinct          uint64  `json:"distinct"`            // Exact number of distinct values in the set
+	FalsePositiveRate float64 `json:"false_positive_rate"` // Measured false positive rate
+	ExpectedFPR       float64 `json:"expected_fpr"`        // Theoretical false positive rate
+	Probes            int     `json:"probes"`              // Number of values outside the set queried
+	CardinalityResponse
+	BloomError     float64  `json:"bloom_error"`     // Relative error of the Bloom filter cardinality
+	HLLError       float64  `json:"hll_error"`       // Relative error of the HyperLogLog cardinality
+	FalseNegatives []string `json:"false_negatives"` // Values reported as absent, as sent by the client
+}
+
+// FirstSeenResponse is the body of bloomFirstSeen.
+type FirstSeenResponse struct {
+	Key       string     `json:"key"`        // Key checked
+	Value     string     `json:"value"`      // Value checked, as sent by the client
+	FirstSeen *time.Time `json:"first_seen"` // Approximate first insert time, null if not seen
+}
+
+// TopKResponse is the body of bloomTopK.
+type TopKResponse struct {
+	Key    string         `json:"key"`    // Key queried
+	Values []TopKItemJSON `json:"values"` // Most frequent values, highest count first
+}
+
+// TopKItemJSON is a value of TopKResponse with its estimated count.
+type TopKItemJSON struct {
+	Value string `json:"value"` // Value, as preprocessed when inserted
+	Count uint64 `json:"count"` // Estimated number of inserts, never below the actual number
+	Error uint64 `json:"error"` // Largest overestimation of the count
+}
+
+// FalseNegativesResponse is the body of bloomVerifyMembers.
+type FalseNegativesResponse struct {
+	Key            string   `json:"key"`             // Key checked
+	FalseNegatives []string `json:"false_negatives"` // Values reported as absent, as sent by the client
+}
+
+// HashQualityResponse is the body of adminHashQuality.
+type HashQualityResponse struct {
+	Key                string  `json:"key"`                  // Key checked
+	Buckets            int     `json:"buckets"`              // Number of equal ranges of the bit array
+	Samples            int     `json:"samples"`              // Number of random values hashed
+	InsertedChiSquared float64 `json:"inserted_chi_squared"` // Chi-squared statistic of the bits set by the inserted values
+	RandomChiSquared   float64 `json:"random_chi_squared"`   // Chi-squared statistic of the positions of the random values
+	CriticalValue      float64 `json:"critical_value"`       // Chi-squared value above which a distribution is flagged
+	Suspicious         bool    `json:"suspicious"`           // Whether either distribution is flagged as not uniform
+}
+
+// IngestResponse is the body of adminIngestSQL.
+type IngestResponse struct {
+	Key       string `json:"key"`       // Key the values were hashed into
+	Query     string `json:"query"`     // Name of the query run
+	Processed uint64 `json:"processed"` // Number of rows processed
+	Hashed    uint64 `json:"hashed"`    // Number of values hashed
+}
+
+// SnapshotResponse is the body of adminSnapshot and adminRestore.
+type SnapshotResponse struct {
+	Path string `json:"path"` // Snapshot file
+	Keys int    `json:"keys"` // Number of keys written or restored
+}
+
+// FlushResponse is the body of adminFlushPause and adminFlushResume.
+type FlushResponse struct {
+	Paused    bool `json:"paused"`     // Whether the flush is now paused
+	WasPaused bool `json:"was_paused"` // Whether it was paused before the request
+	Backlog   int  `json:"backlog"`    // Number of keys with unflushed changes
+}
+
+// QuarantineResponse is the body of adminQuarantine.
+type QuarantineResponse struct {
+	Keys []QuarantinedKeyResponse `json:"keys"` // Quarantined keys
+}
+
+// QuarantinedKeyResponse is a key listed by adminQuarantine.
+type QuarantinedKeyResponse struct {
+	Key   string `json:"key"`   // Key quarantined
+	Error string `json:"error"` // Decoding error that got it quarantined
+}
+
+// ReleaseResponse is the body of adminQuarantineRelease.
+type ReleaseResponse struct {
+	Key      string `json:"key"`      // Key released
+	Released bool   `json:"released"` // Always true, unknown keys are answered with 404
+}
+
+// timePtr returns a pointer to t, or nil for the zero time so that it's encoded as null.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// CountMinResponse is the body of countMinAdd and countMinEstimate.
+type CountMinResponse struct {
+	Estimate uint64 `json:"estimate"` // Estimated number of occurrences of the value, never below the actual one
+	Total    uint64 `json:"total"`    // Sum of the counts added to the sketch
+}
+
+// CuckooResponse is the body of cuckooAdd.
+type CuckooResponse struct {
+	Count    uint64 `json:"count"`    // Number of values in the filter
+	Capacity uint64 `json:"capacity"` // Number of slots of the filter, adds start failing somewhat below
+}
+
+// CuckooExistsResponse is the body of cuckooExists.
+type CuckooExistsResponse struct {
+	Exists bool `json:"exists"` // Whether the value probably exists
+}
+
+// CuckooDeleteResponse is the body of cuckooDelete.
+type CuckooDeleteResponse struct {
+	Deleted bool   `json:"deleted"` // Whether the value was found and deleted
+	Count   uint64 `json:"count"`   // Number of values left in the filter
+}
+
+// ErrorResponse is the body of the requests rejected by readBody and requireFields.
+type ErrorResponse struct {
+	Error string `json:"error"` // What is wrong with the field
+	Field string `json:"field"` // Field of the request body that failed, "body" for the body as a whole
+}
+
+// HealthResponse is the body of the liveness and readiness checks.
+type HealthResponse struct {
+	Status string `json:"status"`          // "ok", or "unavailable" if the instance isn't ready
+	Error  string `json:"error,omitempty"` // Why the instance isn't ready
+}
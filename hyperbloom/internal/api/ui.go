@@ -0,0 +1,36 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+// uiAssets holds the static files of the page for exploring filters.
+//
+//go:embed ui
+var uiAssets embed.FS
+
+// uiHandler serves the embedded page for exploring filters under /ui/.
+// It answers 404 Not Found while UI_ENABLED is off, so the flag can be flipped by a reload.
+func uiHandler() http.Handler {
+	assets, _ := fs.Sub(uiAssets, "ui")
+	files := http.StripPrefix("/ui/", http.FileServer(http.FS(assets)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.ApplicationCfg().UI {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Relative asset paths in the page resolve against the directory
+		if r.URL.Path == "/ui" {
+			http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
+			return
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
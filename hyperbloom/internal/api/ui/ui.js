@@ -0,0 +1,46 @@
+// Queries the HyperBloom endpoints with the values of the form and shows their responses.
+const form = document.getElementById("query");
+const output = document.getElementById("output");
+
+// Requests sent by each button, built from the form values
+const actions = {
+  exists: (v) => post("/hyperbloom/exists", { key: v.key, value: v.value }),
+  card: (v) => fetch("/hyperbloom/card?" + new URLSearchParams({ key: v.key })),
+  sim: (v) => post("/hyperbloom/sim", { key_1: v.key, key_2: v.key2 }),
+  info: (v) => fetch("/hyperbloom/info?" + new URLSearchParams({ key: v.key })),
+};
+
+function post(path, body) {
+  return fetch(path, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(body),
+  });
+}
+
+form.addEventListener("click", async (event) => {
+  const action = actions[event.target.dataset.action];
+  if (!action) {
+    return;
+  }
+  if (!form.reportValidity()) {
+    return;
+  }
+
+  const values = Object.fromEntries(new FormData(form));
+  output.className = "";
+  output.textContent = "...";
+
+  try {
+    const response = await action(values);
+    output.className = response.ok ? "" : "error";
+    // JSON responses are indented, errors and text responses are shown as is
+    const body = await response.text();
+    output.textContent = (response.headers.get("Content-Type") || "").startsWith("application/json")
+      ? JSON.stringify(JSON.parse(body), null, 2)
+      : body;
+  } catch (err) {
+    output.className = "error";
+    output.textContent = String(err);
+  }
+});
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+func TestUIHandler(t *testing.T) {
+	saved := *config.ApplicationCfg()
+	defer config.SetApplicationCfg(saved)
+
+	handler := uiHandler()
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Disabled by default
+	config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.UI = false })
+	if w := get("/ui/"); w.Code != http.StatusNotFound {
+		t.Errorf("disabled UI answered %d, expected 404", w.Code)
+	}
+
+	config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.UI = true })
+	if w := get("/ui"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/ui/" {
+		t.Errorf("expected a redirect to /ui/, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w := get("/ui/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<script src="ui.js">`) {
+		t.Errorf("expected the page, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, asset := range []string{"/ui/ui.js", "/ui/style.css"} {
+		if w := get(asset); w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("%s answered %d", asset, w.Code)
+		}
+	}
+}
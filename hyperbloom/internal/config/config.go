@@ -1,8 +1,13 @@
 package config
 
 import (
+	"crypto/subtle"
 	"fmt"
-	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/caarlos0/env"
@@ -10,9 +15,53 @@ import (
 
 // ApplicationConfig holds configuration related to the application's HTTP server.
 type ApplicationConfig struct {
-	Addr        string      `env:"MUX_ADDR" envDefault:":5000"` // Addr is the address the HTTP server listens on.
-	InfoLogger  *log.Logger // InfoLogger is the logger for informational messages.
-	ErrorLogger *log.Logger // ErrorLogger is the logger for error messages.
+	Addr           string `env:"MUX_ADDR" envDefault:":5000"`       // Addr is the address the HTTP server listens on.
+	GRPCAddr       string `env:"GRPC_ADDR" envDefault:":50051"`     // GRPCAddr is the address the gRPC server listens on, the gRPC server is disabled when empty.
+	AdminToken     string `env:"ADMIN_TOKEN"`                       // AdminToken is the bearer token required by admin endpoints, which are disabled when empty.
+	ResponseFormat string `env:"RESPONSE_FORMAT" envDefault:"json"` // ResponseFormat is the format of responses to clients without a preference in Accept (json or text).
+	TextCharset    string `env:"TEXT_CHARSET" envDefault:"utf-8"`   // TextCharset is the default charset of text responses (utf-8 or iso-8859-1).
+	TextNewline    bool   `env:"TEXT_NEWLINE" envDefault:"true"`    // TextNewline terminates every text response with a newline.
+	HeavyLimit     int    `env:"HEAVY_LIMIT" envDefault:"4"`        // HeavyLimit caps the number of expensive handlers executing concurrently, 0 disables the cap.
+	UI             bool   `env:"UI_ENABLED" envDefault:"false"`     // UI serves the embedded page for exploring filters at /ui.
+
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"` // ShutdownTimeout bounds the final flush on shutdown before forcing the exit, 0 waits forever.
+	ReadyTimeout    time.Duration `env:"READY_TIMEOUT" envDefault:"2s"`     // ReadyTimeout bounds the database ping of the readiness check, 0 disables the deadline.
+
+	// APIKeys are the bearer tokens accepted in the Authorization header of every request but the health checks,
+	// as a list separated by ",". Requests are served without credentials when empty.
+	APIKeys []string `env:"API_KEYS" envSeparator:","`
+
+	LightTimeout time.Duration `env:"LIGHT_TIMEOUT" envDefault:"5s"`   // LightTimeout bounds the handling of a request to a cheap endpoint, 0 disables the deadline.
+	HeavyTimeout time.Duration `env:"HEAVY_TIMEOUT" envDefault:"120s"` // HeavyTimeout bounds the handling of a request to an expensive endpoint (see HEAVY_LIMIT), 0 disables the deadline.
+
+	// EndpointTimeouts overrides LIGHT_TIMEOUT and HEAVY_TIMEOUT for single endpoints, as path=duration
+	// entries separated by "," (e.g. "/hyperbloom/sim/matrix=5m,/hyperbloom/exists=500ms").
+	EndpointTimeouts []string `env:"ENDPOINT_TIMEOUTS" envSeparator:","`
+
+	HTTPReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" envDefault:"10s"` // HTTPReadHeaderTimeout bounds the time a client may take to send the request headers.
+	HTTPReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" envDefault:"60s"`        // HTTPReadTimeout bounds the time a client may take to send a whole request, body included, 0 disables the deadline.
+	HTTPWriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" envDefault:"10m"`       // HTTPWriteTimeout bounds the time from the end of the request headers to the end of the response, 0 disables the deadline.
+	HTTPIdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" envDefault:"60s"`        // HTTPIdleTimeout closes keep-alive connections waiting longer than this for the next request.
+	HTTPMaxHeaderBytes    int           `env:"HTTP_MAX_HEADER_BYTES" envDefault:"65536"`  // HTTPMaxHeaderBytes caps the size of the request headers.
+	HTTPReapIdle          time.Duration `env:"HTTP_REAP_IDLE" envDefault:"120s"`          // HTTPReapIdle closes connections without a request in progress for longer than this, 0 disables the reaper.
+	MaxBodyBytes          int64         `env:"MAX_BODY_BYTES" envDefault:"1048576"`       // MaxBodyBytes caps the size of the JSON request bodies, 0 disables the cap.
+	MaxImportBytes        int64         `env:"MAX_IMPORT_BYTES" envDefault:"268435456"`   // MaxImportBytes caps the size of the filters imported at /hyperbloom/import, 0 disables the cap.
+
+	TLSCertFile     string `env:"TLS_CERT_FILE"`                    // TLSCertFile is the PEM certificate chain the servers present, HTTPS and gRPC over TLS are served when set along with TLSKeyFile.
+	TLSKeyFile      string `env:"TLS_KEY_FILE"`                     // TLSKeyFile is the PEM private key of TLSCertFile.
+	TLSClientCAFile string `env:"TLS_CLIENT_CA_FILE"`               // TLSClientCAFile is the PEM CA bundle client certificates must chain to, client certificates are required when set.
+	TLSMinVersion   string `env:"TLS_MIN_VERSION" envDefault:"1.2"` // TLSMinVersion is the lowest TLS version accepted (1.2 or 1.3).
+
+	// TLSCipherSuites restricts the TLS 1.2 cipher suites to the listed names separated by "," (e.g.
+	// "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"), defaulting to the ECDHE AEAD suites. TLS 1.3 suites aren't configurable.
+	TLSCipherSuites []string `env:"TLS_CIPHER_SUITES" envSeparator:","`
+
+	// IngestQueries is the allowlist of SQL queries the admin ingestion endpoint may run, as a JSON object
+	// mapping a name to {"sql": "...", "column": "..."}. Queries take their arguments as $1, $2... placeholders.
+	IngestQueries string `env:"INGEST_QUERIES"`
+
+	LogLevel  string `env:"LOG_LEVEL" envDefault:"info"`  // LogLevel drops log records below this level (debug, info, warn or error), request logs are at info.
+	LogFormat string `env:"LOG_FORMAT" envDefault:"text"` // LogFormat is the format of log records (text or json).
 }
 
 // PostgresConfig holds configuration related to PostgreSQL database connection.
@@ -23,46 +72,253 @@ type PostgresConfig struct {
 	Username string `env:"DB_USER" envDefault:"admin"`     // Username is the username for PostgreSQL authentication.
 	Password string `env:"DB_PASS" envDefault:"123"`       // Password is the password for PostgreSQL authentication.
 	SSLMode  string `env:"DB_SSL" envDefault:"disable"`    // SSLMode specifies whether to use SSL for PostgreSQL connection.
+
+	// ConnectTimeout bounds the attempts to reach the primary at startup, which are retried with backoff
+	// while it comes up. 0 retries forever.
+	ConnectTimeout time.Duration `env:"DB_CONNECT_TIMEOUT" envDefault:"30s"`
+
+	// Migrate creates or migrates the tables on startup. Disable it where the schema is managed separately,
+	// applying it with the -migrate flag or the statements of internal/service/schema.sql.
+	Migrate bool `env:"DB_MIGRATE" envDefault:"true"`
+
+	// DSN is a complete data source name (key=value pairs or a postgres:// URL) for the primary.
+	// When set, it is used as is and the individual fields above are ignored.
+	DSN string `env:"DB_DSN"`
+
+	// Replicas lists data source names of read-only replicas, separated by ";".
+	// When set, listings and reports are balanced across them, while writes and lazy-loads of HyperBlooms stay on
+	// the primary. Replicas lag behind: a key created moments ago may be missing from a listing.
+	Replicas []string `env:"DB_REPLICAS" envSeparator:";"`
+
+	// Sizing of the connection pools to the primary and to each replica. The flushes and the reads of every instance
+	// share these, keep MaxOpenConns times the number of instances below the max_connections of the server.
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" envDefault:"20"`     // MaxOpenConns caps the connections open at once, 0 is unlimited.
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" envDefault:"10"`     // MaxIdleConns caps the idle connections kept for reuse, 0 keeps none.
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" envDefault:"30m"` // ConnMaxLifetime closes connections once they are this old, 0 keeps them forever.
 }
 
 // HyperBloomConfig holds configuration specific to HyperBloom.
 type HyperBloomConfig struct {
-	FalsePositive float64       `env:"HB_FP" envDefault:"0.0081"`       // FalsePositive is the desired false positive rate for HyperBloom.
-	Cardinality   uint          `env:"HB_CARD" envDefault:"10000"`      // Cardinality is the expected number of elements to be stored in HyperBloom.
-	Decay         time.Duration `env:"HB_DECAY" envDefault:"120s"`      // Decay is the decay period for HyperBloom data.
-	UpdateRate    time.Duration `env:"HB_UPDATE_RATE" envDefault:"20s"` // UpdateRate is the rate at which HyperBloom should be updated.
+	FalsePositive     float64       `env:"HB_FP" envDefault:"0.0081"`              // FalsePositive is the desired false positive rate for HyperBloom.
+	Cardinality       uint          `env:"HB_CARD" envDefault:"10000"`             // Cardinality is the expected number of elements to be stored in HyperBloom.
+	Decay             time.Duration `env:"HB_DECAY" envDefault:"120s"`             // Decay is the decay period for HyperBloom data.
+	UpdateRate        time.Duration `env:"HB_UPDATE_RATE" envDefault:"20s"`        // UpdateRate is the interval of the periodic flush of the changed HyperBlooms.
+	FlushBatchSize    int           `env:"HB_FLUSH_BATCH_SIZE" envDefault:"1000"`  // FlushBatchSize is the number of changed keys that triggers a flush before the next tick, 0 waits for the tick.
+	FlushStallTimeout time.Duration `env:"HB_FLUSH_STALL_TIMEOUT" envDefault:"5m"` // FlushStallTimeout is how long the updates may go without progress before they are restarted, 0 disables the watchdog.
+	MinFalsePositive  float64       `env:"HB_MIN_FP" envDefault:"1e-9"`            // MinFalsePositive is the lowest false positive rate a client may request.
+	MaxBits           uint64        `env:"HB_MAX_BITS" envDefault:"4294967296"`    // MaxBits caps the size of a single Bloom filter bit array (default 512 MiB).
+	CacheCardinality  bool          `env:"HB_CACHE_CARD" envDefault:"true"`        // CacheCardinality reuses cardinality estimates until the next insert.
+	TrackValueLength  bool          `env:"HB_TRACK_VALUE_LEN" envDefault:"false"`  // TrackValueLength records the average inserted value length to report memory savings.
+	SimParallelWords  int           `env:"HB_SIM_PARALLEL_WORDS" envDefault:"0"`   // SimParallelWords is the bit array size (in 64-bit words) from which similarity is computed in parallel, 0 disables it.
+	SimWorkers        int           `env:"HB_SIM_WORKERS" envDefault:"0"`          // SimWorkers is the number of goroutines computing a parallel similarity, 0 uses GOMAXPROCS.
+	FirstSeenBuckets  uint          `env:"HB_FIRST_SEEN_BUCKETS" envDefault:"0"`   // FirstSeenBuckets is the number of 4-byte first insert time buckets of new keys, 0 disables tracking.
+	TopKCapacity      uint          `env:"HB_TOPK_CAPACITY" envDefault:"0"`        // TopKCapacity is the number of most frequent values counted for new keys, 0 disables tracking.
+	AccuracyInterval  time.Duration `env:"HB_ACCURACY_INTERVAL" envDefault:"0s"`   // AccuracyInterval is the period of the false positive rate sampling of in-memory keys, 0 disables it.
+	AccuracySamples   int           `env:"HB_ACCURACY_SAMPLES" envDefault:"1000"`  // AccuracySamples is the number of values never inserted probed per key by each sampling.
+	Preallocate       bool          `env:"HB_PREALLOCATE" envDefault:"false"`      // Preallocate makes the bit arrays resident and the HyperLogLog registers dense when keys are created.
+	DefaultOperator   string        `env:"HB_DEFAULT_OPERATOR" envDefault:"OR"`    // DefaultOperator is the operator of bitwise and chaining existence checks omitting it, "AND", "OR" or "XOR".
+
+	CountMinEpsilon float64 `env:"HB_CMS_EPSILON" envDefault:"0.001"` // CountMinEpsilon bounds the overcount of new Count-Min Sketches, as a fraction of the total count.
+	CountMinDelta   float64 `env:"HB_CMS_DELTA" envDefault:"0.01"`    // CountMinDelta is the probability for an estimate of new Count-Min Sketches to exceed that bound.
+
+	CuckooCapacity uint `env:"HB_CUCKOO_CAPACITY" envDefault:"100000"` // CuckooCapacity is the number of values new Cuckoo filters are sized for.
+
+	ExpirySweepInterval time.Duration `env:"HB_EXPIRY_SWEEP_INTERVAL" envDefault:"1m"` // ExpirySweepInterval is the period of the deletion of expired keys, 0 disables it.
+
+	CacheMaxKeys  int   `env:"HB_CACHE_MAX_KEYS" envDefault:"0"`  // CacheMaxKeys caps the number of keys kept in memory, evicting the least recently used ones, 0 is unlimited.
+	CacheMaxBytes int64 `env:"HB_CACHE_MAX_BYTES" envDefault:"0"` // CacheMaxBytes caps the memory used by the keys kept in memory, evicting the least recently used ones, 0 is unlimited.
+
+	ScalableGrowth     float64 `env:"HB_SCALABLE_GROWTH" envDefault:"2"`       // ScalableGrowth is the default capacity of a layer of scalable keys relative to the previous one.
+	ScalableTightening float64 `env:"HB_SCALABLE_TIGHTENING" envDefault:"0.8"` // ScalableTightening is the default false positive rate of a layer of scalable keys relative to the previous one.
+
+	TransformURL      string        `env:"HB_TRANSFORM_URL"`                          // TransformURL is the webhook values are posted to for preprocessing before hashing, disabled when empty.
+	TransformTimeout  time.Duration `env:"HB_TRANSFORM_TIMEOUT" envDefault:"200ms"`   // TransformTimeout bounds a single call to the transformation webhook.
+	TransformFallback string        `env:"HB_TRANSFORM_FALLBACK" envDefault:"reject"` // TransformFallback is what to do when the webhook fails: "reject" the values or use the "original" ones.
+}
+
+// MetricsConfig holds configuration related to exported metrics.
+type MetricsConfig struct {
+	Namespace string `env:"METRICS_NAMESPACE" envDefault:""` // Namespace is prefixed to every exported metric name (e.g. "pds_").
 }
 
-// Global variables holding the loaded configurations.
+// Global variables holding the configurations loaded once at startup.
 var (
-	HyperBloomCfg  HyperBloomConfig  // HyperBloomCfg holds the loaded HyperBloom configuration.
-	PostgresCfg    PostgresConfig    // PostgresCfg holds the loaded PostgreSQL configuration.
-	ApplicationCfg ApplicationConfig // ApplicationCfg holds the loaded application configuration.
+	PostgresCfg PostgresConfig // PostgresCfg holds the loaded PostgreSQL configuration.
+	MetricsCfg  MetricsConfig  // MetricsCfg holds the loaded metrics configuration.
 )
 
+// The HyperBloom and application configurations change at runtime, see Reload. Each is published as
+// an immutable snapshot, so that requests read a consistent configuration while it is replaced.
+var (
+	hyperBloomCfg  atomic.Pointer[HyperBloomConfig]
+	applicationCfg atomic.Pointer[ApplicationConfig]
+
+	// updateMutex serializes the updates of the snapshots, which copy the current one before replacing it
+	updateMutex sync.Mutex
+)
+
+func init() {
+	hyperBloomCfg.Store(&HyperBloomConfig{})
+	applicationCfg.Store(&ApplicationConfig{})
+}
+
+// HyperBloomCfg returns the current HyperBloom configuration, which must not be modified.
+func HyperBloomCfg() *HyperBloomConfig {
+	return hyperBloomCfg.Load()
+}
+
+// ApplicationCfg returns the current application configuration, which must not be modified.
+func ApplicationCfg() *ApplicationConfig {
+	return applicationCfg.Load()
+}
+
+// SetHyperBloomCfg replaces the HyperBloom configuration with cfg.
+func SetHyperBloomCfg(cfg HyperBloomConfig) {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+
+	hyperBloomCfg.Store(&cfg)
+}
+
+// SetApplicationCfg replaces the application configuration with cfg.
+func SetApplicationCfg(cfg ApplicationConfig) {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+
+	applicationCfg.Store(&cfg)
+}
+
+// UpdateHyperBloomCfg replaces the HyperBloom configuration with a copy of the current one changed by update.
+func UpdateHyperBloomCfg(update func(cfg *HyperBloomConfig)) {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+
+	cfg := *hyperBloomCfg.Load()
+	update(&cfg)
+	hyperBloomCfg.Store(&cfg)
+}
+
+// UpdateApplicationCfg replaces the application configuration with a copy of the current one changed by update.
+func UpdateApplicationCfg(update func(cfg *ApplicationConfig)) {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+
+	cfg := *applicationCfg.Load()
+	update(&cfg)
+	applicationCfg.Store(&cfg)
+}
+
+// metricNamespacePattern matches a legal leading component of a Prometheus metric name.
+// Colons are left out since they are reserved for recording rules.
+var metricNamespacePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 // LoadConfigPostgres loads PostgreSQL configuration from environment variables.
-func LoadConfigPostgres() {
+// It returns an error if a variable is malformed, if a pool setting is negative or, without DB_DSN,
+// if the port is out of range.
+func LoadConfigPostgres() error {
 	if err := env.Parse(&PostgresCfg); err != nil {
-		fmt.Printf("%+v\n", err)
+		return fmt.Errorf("invalid PostgreSQL configuration: %w", err)
 	}
+
+	if PostgresCfg.DSN == "" && (PostgresCfg.Port < 1 || PostgresCfg.Port > 65535) {
+		return fmt.Errorf("invalid DB_PORT %d: must be between 1 and 65535", PostgresCfg.Port)
+	}
+
+	if PostgresCfg.MaxOpenConns < 0 {
+		return fmt.Errorf("invalid DB_MAX_OPEN_CONNS %d: must not be negative", PostgresCfg.MaxOpenConns)
+	}
+	if PostgresCfg.MaxIdleConns < 0 {
+		return fmt.Errorf("invalid DB_MAX_IDLE_CONNS %d: must not be negative", PostgresCfg.MaxIdleConns)
+	}
+	if PostgresCfg.ConnMaxLifetime < 0 {
+		return fmt.Errorf("invalid DB_CONN_MAX_LIFETIME %s: must not be negative", PostgresCfg.ConnMaxLifetime)
+	}
+
+	return nil
 }
 
 // LoadConfigHyperBloom loads HyperBloom configuration from environment variables.
 func LoadConfigHyperBloom() {
-	if err := env.Parse(&HyperBloomCfg); err != nil {
-		fmt.Printf("%+v\n", err)
-	}
+	UpdateHyperBloomCfg(func(cfg *HyperBloomConfig) {
+		if err := env.Parse(cfg); err != nil {
+			fmt.Printf("%+v\n", err)
+		}
+	})
 }
 
 // LoadConfigApplication loads application configuration from environment variables.
 func LoadConfigApplication() {
-	if err := env.Parse(&ApplicationCfg); err != nil {
+	UpdateApplicationCfg(func(cfg *ApplicationConfig) {
+		if err := env.Parse(cfg); err != nil {
+			fmt.Printf("%+v\n", err)
+		}
+	})
+}
+
+// LoadConfigMetrics loads metrics configuration from environment variables.
+// It returns an error if the namespace is not a legal metric name component.
+func LoadConfigMetrics() error {
+	if err := env.Parse(&MetricsCfg); err != nil {
 		fmt.Printf("%+v\n", err)
 	}
+
+	if MetricsCfg.Namespace != "" && !metricNamespacePattern.MatchString(MetricsCfg.Namespace) {
+		return fmt.Errorf("invalid METRICS_NAMESPACE %q: must match %s", MetricsCfg.Namespace, metricNamespacePattern)
+	}
+
+	return nil
+}
+
+// ParseEndpointTimeouts parses ENDPOINT_TIMEOUTS entries into timeouts by path.
+// It returns an error naming the first entry that isn't a path=duration pair with a non-negative duration.
+func ParseEndpointTimeouts(entries []string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		path, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			return nil, fmt.Errorf("invalid ENDPOINT_TIMEOUTS entry %q: expected /path=duration", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid ENDPOINT_TIMEOUTS entry %q: expected a non-negative duration", entry)
+		}
+
+		timeouts[strings.TrimSpace(path)] = timeout
+	}
+
+	return timeouts, nil
 }
 
-// GetDataSourceName constructs and returns the data source name for PostgreSQL connection.
+// Authorized reports whether authorization, the Authorization header of a request, carries one of API_KEYS
+// or ADMIN_TOKEN as a bearer token. Any request is authorized when API_KEYS is empty.
+func (cfg ApplicationConfig) Authorized(authorization string) bool {
+	keys := slices.DeleteFunc(slices.Clone(cfg.APIKeys), func(key string) bool { return strings.TrimSpace(key) == "" })
+	if len(keys) == 0 {
+		return true
+	}
+	if cfg.AdminToken != "" {
+		keys = append(keys, cfg.AdminToken)
+	}
+
+	// Compare in constant time to avoid leaking the keys through timing
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	authorized := 0
+	for _, key := range keys {
+		authorized |= subtle.ConstantTimeCompare([]byte(token), []byte(strings.TrimSpace(key)))
+	}
+	return ok && authorized == 1
+}
+
+// GetDataSourceName constructs and returns the data source name for PostgreSQL connection, DB_DSN if set.
 func (cfg PostgresConfig) GetDataSourceName() string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+
 	baseStr := "host=%s port=%d user=%s password=%s dbname=%s sslmode=%s"
 	return fmt.Sprintf(
 		baseStr,
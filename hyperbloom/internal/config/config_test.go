@@ -0,0 +1,299 @@
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/requestid"
+)
+
+func TestLoadConfigMetrics(t *testing.T) {
+	cases := []struct {
+		namespace string
+		valid     bool
+	}{
+		{"", true},
+		{"pds_", true},
+		{"tenant_x_", true},
+		{"_pds", true},
+		{"1pds_", false},
+		{"pds-", false},
+		{"pds:", false},
+		{"pds ", false},
+	}
+
+	for _, c := range cases {
+		t.Setenv("METRICS_NAMESPACE", c.namespace)
+		config.MetricsCfg = config.MetricsConfig{}
+
+		err := config.LoadConfigMetrics()
+		if c.valid && err != nil {
+			t.Errorf("namespace %q: unexpected error %v", c.namespace, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("namespace %q: expected an error", c.namespace)
+		}
+	}
+}
+
+func TestLoadConfigPostgres(t *testing.T) {
+	saved := config.PostgresCfg
+	defer func() { config.PostgresCfg = saved }()
+
+	cases := []struct {
+		port, dsn string
+		valid     bool
+		want      string // Data source name, when valid
+	}{
+		{"5433", "", true, "host=db port=5433 user=admin password=123 dbname=postgres sslmode=disable"},
+		{"54x2", "", false, ""},
+		{"70000", "", false, ""},
+		{"0", "", false, ""},
+		{"0", "postgres://u:p@elsewhere/app", true, "postgres://u:p@elsewhere/app"},
+	}
+
+	for name, value := range map[string]string{"DB_HOST": "db", "DB_USER": "admin", "DB_PASS": "123", "DB_NAME": "postgres", "DB_SSL": "disable"} {
+		t.Setenv(name, value)
+	}
+	for _, c := range cases {
+		t.Setenv("DB_PORT", c.port)
+		t.Setenv("DB_DSN", c.dsn)
+		config.PostgresCfg = config.PostgresConfig{}
+
+		err := config.LoadConfigPostgres()
+		if !c.valid {
+			if err == nil {
+				t.Errorf("port %q: expected an error", c.port)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("port %q, dsn %q: unexpected error %v", c.port, c.dsn, err)
+			continue
+		}
+		if dsn := config.PostgresCfg.GetDataSourceName(); dsn != c.want {
+			t.Errorf("port %q, dsn %q: data source name %q, want %q", c.port, c.dsn, dsn, c.want)
+		}
+	}
+
+	// A negative pool setting is rejected rather than taken as unlimited
+	t.Setenv("DB_PORT", "5432")
+	for _, c := range []struct{ name, value string }{
+		{"DB_MAX_OPEN_CONNS", "-1"},
+		{"DB_MAX_IDLE_CONNS", "-1"},
+		{"DB_CONN_MAX_LIFETIME", "-1m"},
+	} {
+		t.Setenv(c.name, c.value)
+		config.PostgresCfg = config.PostgresConfig{}
+
+		if err := config.LoadConfigPostgres(); err == nil {
+			t.Errorf("%s=%s: expected an error", c.name, c.value)
+		}
+		t.Setenv(c.name, "0")
+	}
+}
+
+func TestReload(t *testing.T) {
+	config.LoadConfigHyperBloom()
+	config.LoadConfigApplication()
+	config.LoadConfigPostgres()
+	config.MetricsCfg = config.MetricsConfig{}
+	if err := config.LoadConfigMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	addr := config.ApplicationCfg().Addr
+
+	path := filepath.Join(t.TempDir(), "hyperbloom.env")
+	content := "# reloaded settings\n\nHB_UPDATE_RATE=5s\nTEXT_CHARSET=\"iso-8859-1\"\nMUX_ADDR=:6000\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.ConfigFileEnv, path)
+	t.Setenv("HB_UPDATE_RATE", "")
+	t.Setenv("TEXT_CHARSET", "")
+	t.Setenv("MUX_ADDR", "")
+
+	restart, err := config.Reload(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.HyperBloomCfg().UpdateRate != 5*time.Second {
+		t.Errorf("update rate = %s, want 5s", config.HyperBloomCfg().UpdateRate)
+	}
+	if config.ApplicationCfg().TextCharset != "iso-8859-1" {
+		t.Errorf("text charset = %q, want iso-8859-1", config.ApplicationCfg().TextCharset)
+	}
+	if config.ApplicationCfg().Addr != addr {
+		t.Errorf("listen address changed to %q without a restart", config.ApplicationCfg().Addr)
+	}
+	if len(restart) != 1 || restart[0] != "MUX_ADDR" {
+		t.Errorf("settings needing a restart = %v, want [MUX_ADDR]", restart)
+	}
+}
+
+func TestReloadRejected(t *testing.T) {
+	config.LoadConfigHyperBloom()
+	before := *config.HyperBloomCfg()
+
+	path := filepath.Join(t.TempDir(), "hyperbloom.env")
+	if err := os.WriteFile(path, []byte("HB_UPDATE_RATE=7s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.ConfigFileEnv, path)
+	t.Setenv("HB_UPDATE_RATE", "")
+
+	_, err := config.Reload(func(config.HyperBloomConfig) error { return errors.New("rejected") })
+	if err == nil {
+		t.Fatal("expected the validation error")
+	}
+	if *config.HyperBloomCfg() != before {
+		t.Errorf("rejected configuration was applied: %+v", *config.HyperBloomCfg())
+	}
+}
+
+func TestReloadConcurrentReaders(t *testing.T) {
+	config.LoadConfigHyperBloom()
+	config.LoadConfigApplication()
+
+	path := filepath.Join(t.TempDir(), "hyperbloom.env")
+	if err := os.WriteFile(path, []byte("HB_UPDATE_RATE=3s\nAPI_KEYS=a,b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.ConfigFileEnv, path)
+	t.Setenv("HB_UPDATE_RATE", "")
+	t.Setenv("API_KEYS", "")
+
+	// Read the configuration like requests do while it is reloaded, run with -race
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					config.ApplicationCfg().Authorized("Bearer a")
+					_ = config.HyperBloomCfg().UpdateRate
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, err := config.Reload(nil); err != nil {
+			t.Error(err)
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if !config.ApplicationCfg().Authorized("Bearer a") || config.HyperBloomCfg().UpdateRate != 3*time.Second {
+		t.Error("reloaded configuration not applied")
+	}
+}
+
+func TestParseEndpointTimeouts(t *testing.T) {
+	timeouts, err := config.ParseEndpointTimeouts([]string{"/hyperbloom/sim/matrix=5m", " /hyperbloom/exists = 500ms ", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeouts["/hyperbloom/sim/matrix"] != 5*time.Minute || timeouts["/hyperbloom/exists"] != 500*time.Millisecond {
+		t.Errorf("unexpected timeouts %v", timeouts)
+	}
+
+	for _, entry := range []string{"/hyperbloom/exists", "hyperbloom/exists=1s", "/hyperbloom/exists=soon", "/hyperbloom/exists=-1s"} {
+		if _, err = config.ParseEndpointTimeouts([]string{entry}); err == nil {
+			t.Errorf("expected an error for %q", entry)
+		}
+	}
+}
+
+func TestSetupLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	config.LoadConfigApplication()
+	config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.LogLevel, cfg.LogFormat = "WARN", "json" })
+
+	var buf bytes.Buffer
+	if err := config.SetupLogging(&buf); err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("dropped")
+	slog.Warn("kept", "key", "k")
+	if out := buf.String(); strings.Contains(out, "dropped") || !strings.Contains(out, `"msg":"kept","key":"k"`) {
+		t.Errorf("output = %q, want the warning only", out)
+	}
+
+	// Records logged with the context of a request carry its ID
+	buf.Reset()
+	slog.WarnContext(requestid.NewContext(context.Background(), "abc"), "traced")
+	if !strings.Contains(buf.String(), `"msg":"traced","request_id":"abc"`) {
+		t.Errorf("output = %q, want the request ID", buf.String())
+	}
+
+	// A reloaded level applies to the running logger
+	path := filepath.Join(t.TempDir(), "hyperbloom.env")
+	if err := os.WriteFile(path, []byte("LOG_LEVEL=debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.ConfigFileEnv, path)
+	t.Setenv("LOG_LEVEL", "")
+	if _, err := config.Reload(nil); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	slog.Debug("reloaded")
+	if !strings.Contains(buf.String(), "reloaded") {
+		t.Error("reloaded level not applied")
+	}
+
+	for _, invalid := range []struct{ level, format string }{{"verbose", "json"}, {"info", "xml"}} {
+		config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.LogLevel, cfg.LogFormat = invalid.level, invalid.format })
+		if err := config.SetupLogging(&buf); err == nil {
+			t.Errorf("%+v: expected an error", invalid)
+		}
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	cfg := config.ApplicationConfig{AdminToken: "admin"}
+
+	// Without API keys, anything goes
+	for _, authorization := range []string{"", "Bearer wrong"} {
+		if !cfg.Authorized(authorization) {
+			t.Errorf("Authorized(%q) = false without API_KEYS", authorization)
+		}
+	}
+
+	cfg.APIKeys = []string{"first", " second"}
+	cases := []struct {
+		authorization string
+		authorized    bool
+	}{
+		{"Bearer first", true},
+		{"Bearer second", true},
+		{"Bearer admin", true},
+		{"Bearer wrong", false},
+		{"first", false},
+		{"Bearer ", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := cfg.Authorized(c.authorization); got != c.authorized {
+			t.Errorf("Authorized(%q) = %t, want %t", c.authorization, got, c.authorized)
+		}
+	}
+}
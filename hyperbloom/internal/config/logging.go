@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"gopds/hyperbloom/internal/requestid"
+)
+
+// logLevel is the level of the default logger, shared with its handler so that a reloaded LOG_LEVEL applies live.
+var logLevel = new(slog.LevelVar)
+
+// ParseLogLevel parses a LOG_LEVEL value: "debug", "info", "warn" or "error", case-insensitive.
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid LOG_LEVEL %q: must be debug, info, warn or error", name)
+}
+
+// SetupLogging makes the default slog logger write to w in LOG_FORMAT, dropping records below LOG_LEVEL.
+// Records logged with the context of a request carry its ID as request_id, see requestid.Handler.
+// The log package writes through it as well, at the info level.
+// It returns an error if LOG_LEVEL or LOG_FORMAT is invalid, leaving the default logger untouched.
+func SetupLogging(w io.Writer) error {
+	cfg := ApplicationCfg()
+	level, err := ParseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.LogFormat) {
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %q: must be text or json", cfg.LogFormat)
+	}
+
+	logLevel.Set(level)
+	slog.SetDefault(slog.New(requestid.NewHandler(handler)))
+	return nil
+}
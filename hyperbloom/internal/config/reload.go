@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/caarlos0/env"
+)
+
+// ConfigFileEnv names the environment variable holding the path of an optional dotenv-style config file.
+// Its KEY=VALUE lines override the process environment at startup and on every Reload.
+const ConfigFileEnv = "CONFIG_FILE"
+
+func init() {
+	// Apply the config file before any configuration is parsed
+	if err := loadConfigFile(); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+}
+
+// loadConfigFile copies the KEY=VALUE lines of the file named by CONFIG_FILE into the process environment.
+// Blank lines and lines starting with "#" are skipped, values may be wrapped in single or double quotes.
+func loadConfigFile() error {
+	path := os.Getenv(ConfigFileEnv)
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("can't open %s: %w", ConfigFileEnv, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		if err = os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Reload re-reads the config file and the environment, then applies the settings that are safe to change
+// at runtime: the whole HyperBloom configuration (defaults of new keys, limits, flush interval) and the
+// application configuration except the listen address, the heavy request limit and the HTTP server settings
+// (HTTP_REAP_IDLE is applied live) and the log format. The log level is applied to the default logger.
+// validate may reject the new HyperBloom configuration, in which case nothing is applied, as with invalid
+// ENDPOINT_TIMEOUTS or LOG_LEVEL.
+// It returns the environment variables that changed but need a restart to take effect.
+// As with the initial load, a variable removed from the environment keeps its current value.
+// Requests in progress keep the snapshot they read, see HyperBloomCfg and ApplicationCfg.
+func Reload(validate func(HyperBloomConfig) error) ([]string, error) {
+	if err := loadConfigFile(); err != nil {
+		return nil, err
+	}
+
+	// Reloads replace both snapshots from the current ones, they must not interleave with each other or an update
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+
+	// Parse on top of copies so a failure leaves the running configuration untouched
+	current := *applicationCfg.Load()
+	hyperBloom, application, postgresCfg, metricsCfg := *hyperBloomCfg.Load(), current, PostgresCfg, MetricsCfg
+	for _, cfg := range []interface{}{&hyperBloom, &application, &postgresCfg, &metricsCfg} {
+		if err := env.Parse(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if validate != nil {
+		if err := validate(hyperBloom); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := ParseEndpointTimeouts(application.EndpointTimeouts); err != nil {
+		return nil, err
+	}
+	level, err := ParseLogLevel(application.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	// The listeners, the heavy request limiter, the HTTP server, the database connections and the metric names are set up once at startup
+	restart := []string{}
+	if application.Addr != current.Addr {
+		restart = append(restart, "MUX_ADDR")
+		application.Addr = current.Addr
+	}
+	if application.GRPCAddr != current.GRPCAddr {
+		restart = append(restart, "GRPC_ADDR")
+		application.GRPCAddr = current.GRPCAddr
+	}
+	if application.HeavyLimit != current.HeavyLimit {
+		restart = append(restart, "HEAVY_LIMIT")
+		application.HeavyLimit = current.HeavyLimit
+	}
+	if application.HTTPReadHeaderTimeout != current.HTTPReadHeaderTimeout ||
+		application.HTTPReadTimeout != current.HTTPReadTimeout ||
+		application.HTTPWriteTimeout != current.HTTPWriteTimeout ||
+		application.HTTPIdleTimeout != current.HTTPIdleTimeout ||
+		application.HTTPMaxHeaderBytes != current.HTTPMaxHeaderBytes {
+		restart = append(restart, "HTTP_*")
+		application.HTTPReadHeaderTimeout = current.HTTPReadHeaderTimeout
+		application.HTTPReadTimeout = current.HTTPReadTimeout
+		application.HTTPWriteTimeout = current.HTTPWriteTimeout
+		application.HTTPIdleTimeout = current.HTTPIdleTimeout
+		application.HTTPMaxHeaderBytes = current.HTTPMaxHeaderBytes
+	}
+	if application.LogFormat != current.LogFormat {
+		restart = append(restart, "LOG_FORMAT")
+		application.LogFormat = current.LogFormat
+	}
+	if postgresCfg.GetDataSourceName() != PostgresCfg.GetDataSourceName() || !slices.Equal(postgresCfg.Replicas, PostgresCfg.Replicas) ||
+		postgresCfg.MaxOpenConns != PostgresCfg.MaxOpenConns || postgresCfg.MaxIdleConns != PostgresCfg.MaxIdleConns ||
+		postgresCfg.ConnMaxLifetime != PostgresCfg.ConnMaxLifetime {
+		restart = append(restart, "DB_*")
+	}
+	if metricsCfg.Namespace != MetricsCfg.Namespace {
+		restart = append(restart, "METRICS_NAMESPACE")
+	}
+
+	// Publish the new snapshots, readers pick them up on their next access
+	hyperBloomCfg.Store(&hyperBloom)
+	applicationCfg.Store(&application)
+	logLevel.Set(level)
+
+	return restart, nil
+}
@@ -1,40 +1,181 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"gopds/hyperbloom/internal/config"
+	"log/slog"
+	"sync/atomic"
+	"time"
 
 	// Importing "github.com/lib/pq" for PostgreSQL driver
 	_ "github.com/lib/pq"
 )
 
-// DbClient is a global variable holding the connection pool to the PostgreSQL database.
+// DbClient is a global variable holding the connection pool to the PostgreSQL database, set by Connect.
 var DbClient *sql.DB
 
-// init initializes the PostgreSQL database connection upon package initialization.
-func init() {
-	// Load PostgreSQL configuration from environment or configuration files
-	config.LoadConfigPostgres()
+// Store routes the queries of the service between the primary and its read-only replicas.
+//
+// Replicas apply changes asynchronously, so a read served by a replica may lag behind the primary. Only reads
+// that tolerate it, like listings and reports, go through Replica. Anything whose result is written back, like
+// the lazy-load of a HyperBloom that a later flush persists, must read from Primary: a stale copy loaded from a
+// replica would be flushed over the newer state on the primary.
+type Store interface {
+	// Primary returns the connection pool to the primary, which takes the writes and the reads that must see them.
+	Primary() *sql.DB
+	// Replica returns a connection pool for reads that tolerate replication lag.
+	Replica() *sql.DB
+	// Close closes the connection pools to the primary and the replicas.
+	Close()
+}
 
-	// Retrieve the connection string from the loaded configuration
-	connStr := config.PostgresCfg.GetDataSourceName()
+// Default is the Store set by Connect.
+var Default Store
 
-	// Open a connection to the PostgreSQL database using the retrieved connection string
-	var err error
-	DbClient, err = sql.Open("postgres", connStr)
-	if err != nil {
-		// Panic if there's an error establishing the database connection
-		panic(err)
+// replicatedStore is a Store balancing reads round-robin across its replicas,
+// falling back to the primary when it has none.
+type replicatedStore struct {
+	primary  *sql.DB
+	replicas []*sql.DB
+	cursor   atomic.Uint64
+}
+
+// NewStore returns a Store writing to primary and reading from replicas.
+func NewStore(primary *sql.DB, replicas ...*sql.DB) Store {
+	return &replicatedStore{primary: primary, replicas: replicas}
+}
+
+func (s *replicatedStore) Primary() *sql.DB {
+	return s.primary
+}
+
+func (s *replicatedStore) Replica() *sql.DB {
+	if len(s.replicas) == 0 {
+		return s.primary
 	}
 
-	// Ping the database to verify connectivity
-	err = DbClient.Ping()
+	cursor := s.cursor.Add(1)
+	return s.replicas[cursor%uint64(len(s.replicas))]
+}
+
+func (s *replicatedStore) Close() {
+	for _, replica := range s.replicas {
+		replica.Close()
+	}
+	s.primary.Close()
+}
+
+// Config describes the PostgreSQL primary and replicas to connect to.
+type Config = config.PostgresConfig
+
+// Bounds of the delay between two pings of a database that isn't up yet, doubling after each failure.
+const (
+	minPingDelay = 100 * time.Millisecond
+	maxPingDelay = 5 * time.Second
+)
+
+// NewClient opens a connection pool to the primary described by cfg, sized by its pool settings, and pings it
+// until it answers, backing off between attempts so that a cold start racing PostgreSQL doesn't fail. ctx bounds the attempts:
+// once it is done, the last ping error is returned.
+func NewClient(ctx context.Context, cfg Config) (*sql.DB, error) {
+	client, err := sql.Open("postgres", cfg.GetDataSourceName())
 	if err != nil {
-		// Panic if there's an error pinging the database
-		panic(err)
+		return nil, err
 	}
+	configurePool(client, cfg)
+
+	for delay := minPingDelay; ; delay = min(2*delay, maxPingDelay) {
+		err = client.PingContext(ctx)
+		if err == nil {
+			return client, nil
+		}
+		slog.Warn("Can't reach PostgreSQL, retrying", "delay", delay, "err", err)
 
+		select {
+		case <-ctx.Done():
+			client.Close()
+			return nil, fmt.Errorf("can't reach PostgreSQL: %w", err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Connect sets Default to a Store of a new client of the primary (see NewClient) and of the replicas of cfg
+// that answer a single ping within ctx, skipping the others, and DbClient to its primary. Replica pools are
+// sized like the primary's.
+func Connect(ctx context.Context, cfg Config) error {
+	client, err := NewClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
 	// Print a success message to indicate a successful database connection
-	fmt.Println("Successfully connected to PostgreSQL!")
+	slog.Info("Connected to PostgreSQL")
+
+	// Connect to the read replicas, skipping the ones that can't be reached
+	var replicas []*sql.DB
+	for _, replicaStr := range cfg.Replicas {
+		if replicaStr == "" {
+			continue
+		}
+
+		replica, err := openReplica(ctx, replicaStr)
+		if err != nil {
+			slog.Warn("Can't connect to PostgreSQL replica, skipping it", "err", err)
+			continue
+		}
+
+		configurePool(replica, cfg)
+		replicas = append(replicas, replica)
+	}
+
+	if len(replicas) > 0 {
+		slog.Info("Connected to PostgreSQL replicas", "replicas", len(replicas))
+	}
+
+	Default = NewStore(client, replicas...)
+	DbClient = client
+
+	return nil
+}
+
+// configurePool applies the pool sizing of cfg (DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and DB_CONN_MAX_LIFETIME)
+// to client. Without a cap, a burst of reads on top of a flush opens as many connections as there are queries.
+func configurePool(client *sql.DB, cfg Config) {
+	client.SetMaxOpenConns(cfg.MaxOpenConns)
+	client.SetMaxIdleConns(cfg.MaxIdleConns)
+	client.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+}
+
+// openReplica opens and pings a connection pool to a single read replica.
+func openReplica(ctx context.Context, connStr string) (*sql.DB, error) {
+	replica, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = replica.PingContext(ctx); err != nil {
+		replica.Close()
+		return nil, err
+	}
+
+	return replica, nil
+}
+
+// Ping checks that the primary answers within ctx. It returns an error if Connect hasn't succeeded.
+func Ping(ctx context.Context) error {
+	if DbClient == nil {
+		return errors.New("not connected to PostgreSQL")
+	}
+
+	return DbClient.PingContext(ctx)
+}
+
+// Close closes the connection pools to the primary and all replicas.
+func Close() {
+	if Default != nil {
+		Default.Close()
+	}
 }
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestNewClientRetriesUntilDeadline(t *testing.T) {
+	// Nothing listens on port 1, every ping fails
+	cfg := Config{DSN: "host=127.0.0.1 port=1 user=admin password=123 dbname=postgres sslmode=disable connect_timeout=1"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	client, err := NewClient(ctx, cfg)
+	if err == nil {
+		client.Close()
+		t.Fatal("expected an error from an unreachable database")
+	}
+
+	// The attempts go on until the deadline rather than failing on the first refusal
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("gave up after %s, before the deadline", elapsed)
+	}
+}
+
+func TestConfigurePool(t *testing.T) {
+	// Opening doesn't connect, the pool settings apply before the first connection
+	client, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	configurePool(client, Config{MaxOpenConns: 7, MaxIdleConns: 3, ConnMaxLifetime: time.Minute})
+	if got := client.Stats().MaxOpenConnections; got != 7 {
+		t.Errorf("max open connections = %d, want 7", got)
+	}
+}
+
+func TestStoreReplica(t *testing.T) {
+	open := func() *sql.DB {
+		client, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	// Without replicas, reads go to the primary
+	primary := open()
+	store := NewStore(primary)
+	if store.Replica() != primary {
+		t.Error("expected reads on the primary without replicas")
+	}
+	store.Close()
+
+	// With replicas, reads alternate between them and never reach the primary
+	primary, first, second := open(), open(), open()
+	store = NewStore(primary, first, second)
+	defer store.Close()
+
+	seen := map[*sql.DB]int{}
+	for i := 0; i < 4; i++ {
+		seen[store.Replica()]++
+	}
+	if seen[first] != 2 || seen[second] != 2 {
+		t.Errorf("expected reads balanced across the replicas, got %d and %d", seen[first], seen[second])
+	}
+	if store.Primary() != primary {
+		t.Error("expected writes on the primary")
+	}
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"sort"
+	"sync"
+)
+
+// LatencyBuckets are the upper bounds (in seconds) of the histogram buckets of request latencies,
+// from a cached existence check to a similarity matrix.
+var LatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 120}
+
+// Histograms holds one histogram per label value, e.g. per endpoint, sharing the same buckets.
+// It is published as an expvar.Var, read as JSON on /debug/vars and as a Prometheus histogram on /metrics.
+type Histograms struct {
+	mutex      sync.Mutex
+	buckets    []float64             // Upper bounds of the buckets, sorted
+	histograms map[string]*histogram // Histogram of each label value
+}
+
+// histogram counts the observations of a single label value.
+type histogram struct {
+	counts []uint64 // Observations per bucket, not cumulated, plus the ones above the last bound
+	sum    float64  // Sum of the observations
+	count  uint64   // Number of observations
+}
+
+// Histogram returns the histograms published under Name(name), keyed by label, creating them with buckets
+// on first use. Later calls keep the buckets of the first one.
+func Histogram(name, label string, buckets []float64) *Histograms {
+	if h, ok := expvar.Get(Name(name)).(*Histograms); ok {
+		return h
+	}
+
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+	if h, ok := expvar.Get(Name(name)).(*Histograms); ok {
+		return h
+	}
+	register(name, label)
+
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	h := &Histograms{buckets: sorted, histograms: map[string]*histogram{}}
+	expvar.Publish(Name(name), h)
+	return h
+}
+
+// Observe records value in the histogram of the label value.
+func (h *Histograms) Observe(label string, value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	hist, ok := h.histograms[label]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.histograms[label] = hist
+	}
+
+	// The first bucket whose bound is at least value, the overflow bucket past the last bound
+	hist.counts[sort.SearchFloat64s(h.buckets, value)]++
+	hist.sum += value
+	hist.count++
+}
+
+// HistogramSnapshot is the state of the histogram of a label value.
+type HistogramSnapshot struct {
+	Buckets []uint64 `json:"buckets"` // Cumulated observations up to each bound, then all of them
+	Sum     float64  `json:"sum"`     // Sum of the observations
+	Count   uint64   `json:"count"`   // Number of observations
+}
+
+// Snapshot returns the state of the histogram of every label value, and the bucket bounds.
+func (h *Histograms) Snapshot() (map[string]HistogramSnapshot, []float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	snapshots := make(map[string]HistogramSnapshot, len(h.histograms))
+	for label, hist := range h.histograms {
+		cumulated := make([]uint64, len(hist.counts))
+		total := uint64(0)
+		for i, count := range hist.counts {
+			total += count
+			cumulated[i] = total
+		}
+		snapshots[label] = HistogramSnapshot{Buckets: cumulated, Sum: hist.sum, Count: hist.count}
+	}
+	return snapshots, h.buckets
+}
+
+// String returns the histograms as JSON, for expvar.
+func (h *Histograms) String() string {
+	snapshots, buckets := h.Snapshot()
+	bounds := make([]any, 0, len(buckets)+1)
+	for _, bound := range buckets {
+		bounds = append(bounds, bound)
+	}
+	bounds = append(bounds, "+Inf")
+
+	encoded, _ := json.Marshal(map[string]any{"bounds": bounds, "histograms": snapshots})
+	return string(encoded)
+}
@@ -0,0 +1,78 @@
+// Package metrics provides helpers shared by the collectors exported by the service.
+package metrics
+
+import (
+	"expvar"
+	"sync"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+// publishMutex serializes the creation of collectors on first use: expvar panics when a name is published twice,
+// so two requests creating the same collector at once must not both publish it.
+var publishMutex sync.Mutex
+
+// Name returns the metric name prefixed with the configured namespace,
+// so that several deployments can share a single Prometheus without collisions.
+// Every collector must be registered under a name built by this function.
+func Name(name string) string {
+	return config.MetricsCfg.Namespace + name
+}
+
+// Map returns the expvar map published under Name(name), creating it on first use.
+// Maps are keyed by a label such as the request path, exported to Prometheus under the label name label.
+func Map(name, label string) *expvar.Map {
+	if m, ok := expvar.Get(Name(name)).(*expvar.Map); ok {
+		return m
+	}
+
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+	if m, ok := expvar.Get(Name(name)).(*expvar.Map); ok {
+		return m
+	}
+	register(name, label)
+	return expvar.NewMap(Name(name))
+}
+
+// Int returns the expvar integer published under Name(name), creating it on first use.
+func Int(name string) *expvar.Int {
+	if i, ok := expvar.Get(Name(name)).(*expvar.Int); ok {
+		return i
+	}
+
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+	if i, ok := expvar.Get(Name(name)).(*expvar.Int); ok {
+		return i
+	}
+	register(name, "")
+	return expvar.NewInt(Name(name))
+}
+
+// Float returns the expvar float published under Name(name), creating it on first use.
+func Float(name string) *expvar.Float {
+	if f, ok := expvar.Get(Name(name)).(*expvar.Float); ok {
+		return f
+	}
+
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+	if f, ok := expvar.Get(Name(name)).(*expvar.Float); ok {
+		return f
+	}
+	register(name, "")
+	return expvar.NewFloat(Name(name))
+}
+
+// Gauge publishes under Name(name) a gauge whose value is computed by f whenever the metrics are read,
+// e.g. a count that is cheaper to compute on demand than to maintain. Publishing a name twice keeps the first f.
+func Gauge(name string, f func() float64) {
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+	if expvar.Get(Name(name)) != nil {
+		return
+	}
+	register(name, "")
+	expvar.Publish(Name(name), expvar.Func(func() any { return f() }))
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// registry records the label name of every collector created by this package, by unprefixed name,
+// so that they can be exported in the Prometheus text format. Collectors without a label have an empty one.
+var registry = struct {
+	sync.Mutex
+	labels map[string]string
+}{labels: map[string]string{}}
+
+// register records the collector name and its label name.
+func register(name, label string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.labels[name] = label
+}
+
+// labelEscaper escapes label values as required by the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes every collector in the Prometheus text exposition format, sorted by name.
+// Collectors named with a _total suffix are counters, histograms are histograms and the others are gauges.
+func WritePrometheus(w io.Writer) error {
+	registry.Lock()
+	names := make([]string, 0, len(registry.labels))
+	for name := range registry.labels {
+		names = append(names, name)
+	}
+	labels := make(map[string]string, len(registry.labels))
+	for name, label := range registry.labels {
+		labels[name] = label
+	}
+	registry.Unlock()
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(w)
+	for _, name := range names {
+		fullName, label := Name(name), labels[name]
+		kind := "gauge"
+		if strings.HasSuffix(name, "_total") {
+			kind = "counter"
+		}
+
+		switch v := expvar.Get(fullName).(type) {
+		case *Histograms:
+			fmt.Fprintf(buf, "# TYPE %s histogram\n", fullName)
+			writeHistograms(buf, fullName, label, v)
+		case *expvar.Map:
+			fmt.Fprintf(buf, "# TYPE %s %s\n", fullName, kind)
+			v.Do(func(kv expvar.KeyValue) {
+				fmt.Fprintf(buf, "%s{%s=\"%s\"} %s\n", fullName, label, labelEscaper.Replace(kv.Key), sample(kv.Value))
+			})
+		case expvar.Var:
+			fmt.Fprintf(buf, "# TYPE %s %s\n", fullName, kind)
+			fmt.Fprintf(buf, "%s %s\n", fullName, sample(v))
+		}
+	}
+	return buf.Flush()
+}
+
+// writeHistograms writes the buckets, sum and count of every label value of h, in label order.
+func writeHistograms(w io.Writer, fullName, label string, h *Histograms) {
+	snapshots, bounds := h.Snapshot()
+	values := make([]string, 0, len(snapshots))
+	for value := range snapshots {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	for _, value := range values {
+		snapshot := snapshots[value]
+		selector := fmt.Sprintf(`%s="%s"`, label, labelEscaper.Replace(value))
+		for i, count := range snapshot.Buckets {
+			bound := "+Inf"
+			if i < len(bounds) {
+				bound = strconv.FormatFloat(bounds[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", fullName, selector, bound, count)
+		}
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", fullName, selector, strconv.FormatFloat(snapshot.Sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", fullName, selector, snapshot.Count)
+	}
+}
+
+// sample formats the value of an integer, float or computed collector as a Prometheus sample value.
+func sample(v expvar.Var) string {
+	switch v := v.(type) {
+	case *expvar.Int:
+		return strconv.FormatInt(v.Value(), 10)
+	case *expvar.Float:
+		return strconv.FormatFloat(v.Value(), 'g', -1, 64)
+	case expvar.Func:
+		if f, ok := v.Value().(float64); ok {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	}
+	return v.String()
+}
+
+// Handler returns the handler serving every collector in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
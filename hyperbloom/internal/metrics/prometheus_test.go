@@ -0,0 +1,74 @@
+package metrics_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopds/hyperbloom/internal/metrics"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	metrics.Int("test_requests_total").Add(3)
+	metrics.Float("test_temperature").Set(1.5)
+	metrics.Map("test_errors_total", "path").Add(`/a"b`, 2)
+	metrics.Gauge("test_computed", func() float64 { return 42 })
+	latencies := metrics.Histogram("test_latency_seconds", "path", []float64{1, 0.1})
+	latencies.Observe("/x", 0.05)
+	latencies.Observe("/x", 0.5)
+	latencies.Observe("/x", 5)
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("content type = %q", got)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\ntest_requests_total 3\n",
+		"# TYPE test_temperature gauge\ntest_temperature 1.5\n",
+		"# TYPE test_errors_total counter\ntest_errors_total{path=\"/a\\\"b\"} 2\n",
+		"# TYPE test_computed gauge\ntest_computed 42\n",
+		"# TYPE test_latency_seconds histogram\n" +
+			"test_latency_seconds_bucket{path=\"/x\",le=\"0.1\"} 1\n" +
+			"test_latency_seconds_bucket{path=\"/x\",le=\"1\"} 2\n" +
+			"test_latency_seconds_bucket{path=\"/x\",le=\"+Inf\"} 3\n" +
+			"test_latency_seconds_sum{path=\"/x\"} 5.55\n" +
+			"test_latency_seconds_count{path=\"/x\"} 3\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+
+	// Collectors are created once, later calls return the same one
+	if metrics.Map("test_errors_total", "path") != metrics.Map("test_errors_total", "path") {
+		t.Error("map created twice")
+	}
+}
+
+func TestConcurrentFirstUse(t *testing.T) {
+	// Collectors created lazily by concurrent requests are published once
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			metrics.Map("test_concurrent_total", "path").Add("/x", 1)
+			metrics.Int("test_concurrent_int").Add(1)
+			metrics.Float("test_concurrent_float").Add(1)
+			metrics.Histogram("test_concurrent_seconds", "path", []float64{1}).Observe("/x", 0.5)
+			metrics.Gauge("test_concurrent_gauge", func() float64 { return 1 })
+		}()
+	}
+	wg.Wait()
+
+	if got := metrics.Map("test_concurrent_total", "path").Get("/x").String(); got != "16" {
+		t.Errorf("map counted %s, want 16", got)
+	}
+	if got := metrics.Int("test_concurrent_int").Value(); got != 16 {
+		t.Errorf("int counted %d, want 16", got)
+	}
+}
@@ -0,0 +1,87 @@
+// Package requestid carries the correlation ID of a request through its context, so that every log line written
+// on its behalf, including the ones of the asynchronous flush it triggers, can be traced back to it.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+// Header is the HTTP header carrying the ID of a request, read from the client and echoed in the response.
+const Header = "X-Request-ID"
+
+// MaxLength is the longest ID accepted from a client, longer ones are replaced by a generated ID.
+const MaxLength = 128
+
+// contextKey is the key of the ID in a context.
+type contextKey struct{}
+
+// New generates a random ID of 32 hexadecimal characters.
+func New() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether id can be used as a request ID: between 1 and MaxLength printable ASCII characters,
+// so that a client can't inject anything into the log lines or the response headers.
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id, an empty id leaves ctx as is.
+func NewContext(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the ID carried by ctx, empty if none.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Handler is a slog.Handler adding the ID carried by the context of every record as the request_id attribute.
+// Records logged without a context, or without an ID in it, are passed through unchanged.
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps handler to add request_id to the records.
+func NewHandler(handler slog.Handler) *Handler {
+	return &Handler{Handler: handler}
+}
+
+// Handle adds the request ID of ctx to record before handing it over.
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		record = record.Clone()
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a Handler adding request_id on top of handler.WithAttrs.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewHandler(h.Handler.WithAttrs(attrs))
+}
+
+// WithGroup returns a Handler adding request_id on top of handler.WithGroup.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return NewHandler(h.Handler.WithGroup(name))
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"expvar"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// accuracyProbePrefix starts the values probed to observe the false positive rate,
+// so that they can't collide with values clients insert.
+const accuracyProbePrefix = "\x00accuracy-"
+
+// accuracyTicker triggers the false positive rate sampling in the update goroutine, stopped while disabled.
+var accuracyTicker = time.NewTicker(time.Hour)
+
+// observedFPRGauges returns the gauges of the last observed false positive rate, by key.
+func observedFPRGauges() *expvar.Map {
+	return metrics.Map("bloom_observed_fpr", "key")
+}
+
+// falseNegativeGauges returns the gauges of the false negatives found by the last verification, by key.
+func falseNegativeGauges() *expvar.Map {
+	return metrics.Map("bloom_false_negatives", "key")
+}
+
+// resetAccuracyTicker applies HB_ACCURACY_INTERVAL to accuracyTicker.
+func resetAccuracyTicker() {
+	if interval := config.HyperBloomCfg().AccuracyInterval; interval > 0 {
+		accuracyTicker.Reset(interval)
+		return
+	}
+	accuracyTicker.Stop()
+}
+
+// BloomObservedFPR measures the false positive rate of the in-memory HyperBloom identified by key by probing
+// samples values that were never inserted, and records it in the bloom_observed_fpr gauge of the key.
+// With few samples and a low rate the measure is coarse: it moves in steps of 1/samples.
+// Probing doesn't count as a use of the key, so sampled keys still decay.
+// It returns ErrKeyNotFound if the key isn't in memory.
+func BloomObservedFPR(key string, samples int) (float64, error) {
+	db, ok := dbs.GetHyperBloom(key)
+	if !ok {
+		return 0, ErrKeyNotFound
+	}
+	if samples <= 0 {
+		return 0, nil
+	}
+
+	positives := 0
+	for i := 0; i < samples; i++ {
+		if db.CheckExists(accuracyProbePrefix + strconv.FormatUint(rand.Uint64(), 36)) {
+			positives++
+		}
+	}
+
+	observed := float64(positives) / float64(samples)
+	gauge := new(expvar.Float)
+	gauge.Set(observed)
+	observedFPRGauges().Set(key, gauge)
+
+	return observed, nil
+}
+
+// recordFalseNegatives records the number of false negatives found by the last verification of key,
+// in the bloom_false_negatives gauge of the key.
+func recordFalseNegatives(key string, count int) {
+	gauge := new(expvar.Int)
+	gauge.Set(int64(count))
+	falseNegativeGauges().Set(key, gauge)
+}
+
+// sampleAccuracy measures the false positive rate of every in-memory key with HB_ACCURACY_SAMPLES probes,
+// and drops the gauges of keys that left memory so decayed keys don't accumulate.
+func sampleAccuracy() {
+	inMemory := map[string]bool{}
+	for _, key := range dbs.GetInMemoryHyperBloomKeys() {
+		inMemory[key] = true
+		BloomObservedFPR(key, config.HyperBloomCfg().AccuracySamples)
+	}
+
+	for _, gauges := range []*expvar.Map{observedFPRGauges(), falseNegativeGauges()} {
+		// Collect first, Do holds the lock Delete needs
+		decayed := []string{}
+		gauges.Do(func(kv expvar.KeyValue) {
+			if !inMemory[kv.Key] {
+				decayed = append(decayed, kv.Key)
+			}
+		})
+		for _, key := range decayed {
+			gauges.Delete(key)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package service_test
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/metrics"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomObservedFPR(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("accuracy-", time.Now().UnixNano())
+	if _, err := service.BloomCreateKey(context.Background(), key, 1000, 0.05, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty filter has no false positives
+	observed, err := service.BloomObservedFPR(key, 2000)
+	if err != nil || observed != 0 {
+		t.Fatalf("empty key observed (%g, %v), want (0, nil)", observed, err)
+	}
+
+	// At capacity the observed rate is close to the configured one
+	for i := 0; i < 1000; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+	observed, _ = service.BloomObservedFPR(key, 20000)
+	if observed < 0.03 || observed > 0.07 {
+		t.Errorf("observed false positive rate %g, want about 0.05", observed)
+	}
+
+	gauge, ok := metrics.Map("bloom_observed_fpr", "key").Get(key).(*expvar.Float)
+	if !ok || gauge.Value() != observed {
+		t.Errorf("gauge = %v, want %g", metrics.Map("bloom_observed_fpr", "key").Get(key), observed)
+	}
+
+	if _, err = service.BloomObservedFPR(key+"-missing", 10); err != service.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestBloomVerifyMembersGauge(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("accuracy-verify-", time.Now().UnixNano())
+	service.BloomHash(context.Background(), key, "member")
+
+	service.BloomVerifyMembers(context.Background(), key, []string{"member", "never-1", "never-2"})
+	gauge, ok := metrics.Map("bloom_false_negatives", "key").Get(key).(*expvar.Int)
+	if !ok || gauge.Value() != 2 {
+		t.Errorf("gauge = %v, want 2", metrics.Map("bloom_false_negatives", "key").Get(key))
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MaxAuditValues caps the number of values of the exact set given to BloomAudit.
+const MaxAuditValues = 100000
+
+// auditProbes is the number of values outside the exact set queried to measure the false positive rate.
+const auditProbes = 10000
+
+// Audit is the accuracy of a HyperBloom measured against the exact set of values inserted in it.
+type Audit struct {
+	Distinct          uint64   // Exact number of distinct values in the set
+	Probes            int      // Number of values outside the set queried
+	FalsePositiveRate float64  // Share of the probes reported as present
+	ExpectedFPR       float64  // Theoretical false positive rate of the Bloom filter holding Distinct values
+	FalseNegatives    []string // Values of the set reported as absent, which should never happen
+	BloomCardinality  uint32   // Estimated cardinality from the Bloom filter
+	HyperCardinality  uint64   // Estimated cardinality from the HyperLogLog sketch
+	BloomError        float64  // Relative error of BloomCardinality against Distinct
+	HyperError        float64  // Relative error of HyperCardinality against Distinct
+}
+
+// BloomAudit compares the HyperBloom identified by key with values, the exact set of values inserted in it:
+// it measures the false positive rate over auditProbes values outside the set, looks for false negatives
+// (see BloomVerifyMembers) and measures the error of both cardinality estimates against the exact count.
+// The key must hold the whole set and nothing else for the report to be meaningful.
+// It returns ErrKeyNotFound if the key doesn't exist and ErrInvalidSample if values is empty or larger than MaxAuditValues.
+func BloomAudit(ctx context.Context, key string, values []string) (*Audit, error) {
+	if len(values) == 0 || len(values) > MaxAuditValues {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d, got %d", ErrInvalidSample, MaxAuditValues, len(values))
+	}
+
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	// Count the distinct values exactly
+	distinct := make(map[string]struct{}, len(values))
+	members := make([]string, 0, len(values))
+	for _, value := range values {
+		if _, ok := distinct[value]; !ok {
+			distinct[value] = struct{}{}
+			members = append(members, value)
+		}
+	}
+
+	falseNegatives, err := BloomVerifyMembers(ctx, key, members)
+	if err != nil {
+		return nil, err
+	}
+
+	// Probe with values guaranteed to be outside the set, the same ones on every audit
+	positives := 0
+	for i, probed := 0, 0; probed < auditProbes; i++ {
+		probe := accuracyProbePrefix + strconv.Itoa(i)
+		if _, ok := distinct[probe]; ok {
+			continue
+		}
+		probed++
+
+		if db.CheckExists(probe) {
+			positives++
+		}
+	}
+
+	audit := &Audit{
+		Distinct:          uint64(len(distinct)),
+		Probes:            auditProbes,
+		FalsePositiveRate: float64(positives) / auditProbes,
+		ExpectedFPR:       TheoreticalFPR(uint(len(distinct)), db.Bloom().Cap(), db.Bloom().K()),
+		FalseNegatives:    falseNegatives,
+		BloomCardinality:  db.BloomCardinality(),
+		HyperCardinality:  db.HyperCardinality(),
+	}
+
+	// Strict membership needs both filters, of the same size, to be wrong at once
+	if db.Strict() {
+		audit.ExpectedFPR *= audit.ExpectedFPR
+	}
+	audit.BloomError = math.Abs(float64(audit.BloomCardinality)-float64(audit.Distinct)) / float64(audit.Distinct)
+	audit.HyperError = math.Abs(float64(audit.HyperCardinality)-float64(audit.Distinct)) / float64(audit.Distinct)
+
+	return audit, nil
+}
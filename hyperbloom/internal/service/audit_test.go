@@ -0,0 +1,62 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomAudit(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("audit-", time.Now().UnixNano())
+	if _, err := service.BloomCreateKey(context.Background(), key, 5000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+
+	// Duplicates in the set count once
+	values := make([]string, 0, 5100)
+	for i := 0; i < 5000; i++ {
+		values = append(values, strconv.Itoa(i))
+		service.BloomHash(context.Background(), key, values[i])
+	}
+	values = append(values, values[:100]...)
+
+	audit, err := service.BloomAudit(context.Background(), key, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audit.Distinct != 5000 {
+		t.Errorf("distinct = %d, want 5000", audit.Distinct)
+	}
+	if len(audit.FalseNegatives) != 0 {
+		t.Errorf("found %d false negatives", len(audit.FalseNegatives))
+	}
+	if audit.FalsePositiveRate > 0.02 || audit.ExpectedFPR > 0.011 {
+		t.Errorf("false positive rate (measured, expected) = (%f, %f), want about 0.01", audit.FalsePositiveRate, audit.ExpectedFPR)
+	}
+	if audit.HyperError > 0.05 || audit.BloomError > 0.05 {
+		t.Errorf("cardinality error (bloom, hyperloglog) = (%f, %f)", audit.BloomError, audit.HyperError)
+	}
+
+	// A set the key doesn't hold shows up as false negatives
+	audit, _ = service.BloomAudit(context.Background(), key, []string{"never-1", "never-2", "never-3"})
+	if len(audit.FalseNegatives) == 0 {
+		t.Error("values never inserted were all reported present")
+	}
+
+	if _, err = service.BloomAudit(context.Background(), key, nil); !errors.Is(err, service.ErrInvalidSample) {
+		t.Errorf("expected ErrInvalidSample for an empty set, got %v", err)
+	}
+	if _, err = service.BloomAudit(context.Background(), key, make([]string, service.MaxAuditValues+1)); !errors.Is(err, service.ErrInvalidSample) {
+		t.Errorf("expected ErrInvalidSample for an oversized set, got %v", err)
+	}
+	if _, err = service.BloomAudit(context.Background(), "audit-missing", values); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// ErrInvalidBackup is returned by BloomImport for data that isn't an encoding of BloomExport, or is one in a format
+// version newer than this binary reads.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// BloomExport returns the self-contained encoding of the filters and sketch of the HyperBloom identified by key,
+// starting with the format version (see models.HyperBloom.MarshalBinary), for BloomImport to load back, possibly
+// on another instance. It returns ErrKeyNotFound if the key doesn't exist.
+func BloomExport(ctx context.Context, key string) ([]byte, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	return db.MarshalBinary()
+}
+
+// BloomImport loads an encoding of BloomExport under key and writes it to the database, migrating it from an
+// older format version. It returns ErrInvalidBackup if data can't be decoded, and ErrKeyExists if the key exists
+// unless force is set, in which case the existing key is deleted first along with its metadata. The metadata of the
+// imported key is derived from the size of its Bloom filter, as for restored snapshots, see bloomPersistRestored.
+func BloomImport(ctx context.Context, key string, data []byte, force bool) error {
+	db, err := models.NewHyperBloomFromBinary(key, config.HyperBloomCfg().Decay, time.Now().UTC(), data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+
+	// Quarantined keys exist too, their row would be overwritten
+	_, err = bloomLookup(ctx, key)
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+	case err != nil && !errors.Is(err, ErrKeyQuarantined):
+		return err
+	case !force:
+		return ErrKeyExists
+	default:
+		// Drop the existing rows, and the instance so that a flush in progress doesn't write it back
+		deleteMutex.Lock()
+		_, err = dbs.Delete(key, func() error {
+			_, err := bloomDeleteRows(ctx, key)
+			return err
+		})
+		deleteMutex.Unlock()
+		if err != nil {
+			return err
+		}
+		ReleaseQuarantine(key)
+	}
+
+	// Install the imported key and persist it
+	dbs.Set(db, key)
+	return bloomPersistRestored(ctx, db)
+}
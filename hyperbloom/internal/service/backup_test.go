@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestBloomExportImport(t *testing.T) {
+	requireDatabase(t)
+
+	ctx := context.Background()
+	key := fmt.Sprint("backup-", time.Now().UnixNano())
+	for i := 0; i < 500; i++ {
+		service.BloomHash(ctx, key, strconv.Itoa(i))
+	}
+
+	encoded, err := service.BloomExport(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backup loads under another key, and survives a flush
+	copied := key + "-copy"
+	if err = service.BloomImport(ctx, copied, encoded, false); err != nil {
+		t.Fatal(err)
+	}
+	service.FlushAll(ctx)
+	for i := 0; i < 500; i++ {
+		if !bloomExists(t, ctx, copied, strconv.Itoa(i)) {
+			t.Fatalf("%d missing from the imported key", i)
+		}
+	}
+	_, got := bloomCardinality(t, ctx, copied)
+	if _, want := bloomCardinality(t, ctx, key); got != want {
+		t.Errorf("imported cardinality = %d, want %d", got, want)
+	}
+
+	// Existing keys are only replaced when forced, persisted ones included
+	other := key + "-other"
+	service.BloomHash(ctx, other, "unrelated")
+	service.FlushAll(ctx)
+	if blooms, metadata := persistedRows(t, other); blooms != 1 || metadata != 1 {
+		t.Fatalf("%d filter rows and %d metadata rows before importing, want 1 and 1", blooms, metadata)
+	}
+	if err = service.BloomImport(ctx, other, encoded, false); !errors.Is(err, service.ErrKeyExists) {
+		t.Errorf("import over an existing key: expected ErrKeyExists, got %v", err)
+	}
+	if err = service.BloomImport(ctx, other, encoded, true); err != nil {
+		t.Fatal(err)
+	}
+	if bloomExists(t, ctx, other, "unrelated") || !bloomExists(t, ctx, other, "499") {
+		t.Error("forced import didn't replace the existing key")
+	}
+	if blooms, metadata := persistedRows(t, other); blooms != 1 || metadata != 1 {
+		t.Errorf("%d filter rows and %d metadata rows after a forced import, want 1 and 1", blooms, metadata)
+	}
+
+	if _, err = service.BloomExport(ctx, key+"-missing"); err != service.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestBloomImportRejectsInvalidBackups(t *testing.T) {
+	requireDatabase(t)
+
+	ctx := context.Background()
+	key := fmt.Sprint("backup-invalid-", time.Now().UnixNano())
+	service.BloomHash(ctx, key, "value")
+	encoded, err := service.BloomExport(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A newer format version, a truncated encoding and data that isn't an encoding at all
+	newer := append([]byte(nil), encoded...)
+	binary.BigEndian.PutUint16(newer[4:], models.FormatVersion+1)
+	for _, data := range [][]byte{newer, encoded[:len(encoded)/2], []byte("not a backup")} {
+		err = service.BloomImport(ctx, key+"-imported", data, false)
+		if !errors.Is(err, service.ErrInvalidBackup) {
+			t.Errorf("expected ErrInvalidBackup, got %v", err)
+		}
+	}
+	if service.BloomGet(context.Background(), key+"-imported") != nil {
+		t.Error("invalid backup imported")
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"expvar"
+	"log/slog"
+	"slices"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/metrics"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// Results of a lookup counted in cache_lookups_total.
+const (
+	cacheHit  = "hit"  // The key was in memory
+	cacheMiss = "miss" // The key was loaded from the database, or didn't exist
+)
+
+// cacheLookupsCounter returns the number of key lookups by result, published as cache_lookups_total.
+func cacheLookupsCounter() *expvar.Map {
+	return metrics.Map("cache_lookups_total", "result")
+}
+
+// cacheEvictionsCounter returns the counter of the keys evicted from memory to fit HB_CACHE_MAX_KEYS
+// and HB_CACHE_MAX_BYTES, published as cache_evictions_total.
+func cacheEvictionsCounter() *expvar.Int {
+	return metrics.Int("cache_evictions_total")
+}
+
+// countCacheLookup counts a lookup of key as a hit if it is in memory, a miss otherwise.
+func countCacheLookup(key string) {
+	if _, ok := dbs.GetHyperBloom(key); ok {
+		cacheLookupsCounter().Add(cacheHit, 1)
+		return
+	}
+	cacheLookupsCounter().Add(cacheMiss, 1)
+}
+
+// evictOverBudget evicts the least recently used HyperBlooms from memory until the rest fits HB_CACHE_MAX_KEYS
+// and HB_CACHE_MAX_BYTES, and returns the number evicted. Keys changed since the last flush are skipped,
+// they stay until written, so the budget is exceeded while the database lags behind. Evicted keys are loaded
+// again on their next use.
+func evictOverBudget() int {
+	cfg := config.HyperBloomCfg()
+	maxKeys, maxBytes := cfg.CacheMaxKeys, cfg.CacheMaxBytes
+	if maxKeys <= 0 && maxBytes <= 0 {
+		return 0
+	}
+
+	blooms := dbs.GetInMemoryHyperBlooms()
+	slices.SortFunc(blooms, func(a, b *models.HyperBloom) int {
+		return a.LastUsed().Compare(b.LastUsed())
+	})
+
+	// Measuring the keys isn't free, only do it for a byte budget
+	sizes := make([]uint64, len(blooms))
+	total := uint64(0)
+	if maxBytes > 0 {
+		for i, db := range blooms {
+			sizes[i] = db.MemoryBytes()
+			total += sizes[i]
+		}
+	}
+
+	keys, evicted := len(blooms), 0
+	for i, db := range blooms {
+		if (maxKeys <= 0 || keys <= maxKeys) && (maxBytes <= 0 || total <= uint64(maxBytes)) {
+			break
+		}
+		if !dbs.Evict(db) {
+			continue
+		}
+
+		slog.Debug("Evicted from in-memory Hyperblooms", "key", db.Key())
+		keys--
+		total -= sizes[i]
+		evicted++
+	}
+
+	cacheEvictionsCounter().Add(int64(evicted))
+	return evicted
+}
@@ -0,0 +1,70 @@
+package service_test
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/metrics"
+	"gopds/hyperbloom/internal/service"
+)
+
+// cacheLookups returns the number of lookups counted with result in cache_lookups_total.
+func cacheLookups(result string) int64 {
+	if v, ok := metrics.Map("cache_lookups_total", "result").Get(result).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestCacheMaxKeys(t *testing.T) {
+	requireDatabase(t)
+
+	prefix := fmt.Sprint("cache-", time.Now().UnixNano())
+	keys := []string{prefix + "-1", prefix + "-2", prefix + "-3"}
+
+	saved := *config.HyperBloomCfg()
+	defer func() {
+		config.SetHyperBloomCfg(saved)
+		service.ApplyConfig()
+	}()
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) {
+		cfg.UpdateRate = 50 * time.Millisecond
+		cfg.CacheMaxKeys = 1
+	})
+	service.ApplyConfig()
+	evictions := metrics.Int("cache_evictions_total").Value()
+
+	for _, key := range keys {
+		service.BloomHash(context.Background(), key, "value")
+		time.Sleep(time.Millisecond) // Distinct last used timestamps
+	}
+
+	// The keys are written, then the least recently used ones evicted
+	deadline := time.Now().Add(5 * time.Second)
+	for metrics.Int("cache_evictions_total").Value()-evictions < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("evicted %d keys, want at least 2", metrics.Int("cache_evictions_total").Value()-evictions)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// An evicted key is loaded again with its flushed state, counted as a miss
+	misses := cacheLookups("miss")
+	if exists, err := service.BloomExists(context.Background(), keys[0], "value"); err != nil || !exists {
+		t.Errorf("evicted key lost its value: exists %t, %v", exists, err)
+	}
+	if cacheLookups("miss") == misses {
+		t.Error("lookup of an evicted key not counted as a miss")
+	}
+
+	// A key in memory is a hit
+	hits := cacheLookups("hit")
+	service.BloomExists(context.Background(), keys[0], "value")
+	if cacheLookups("hit") == hits {
+		t.Error("lookup of a key in memory not counted as a hit")
+	}
+}
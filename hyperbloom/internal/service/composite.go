@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CompositeKeySeparator separates the segments of a hierarchical composite key, e.g. "country:device".
+const CompositeKeySeparator = ":"
+
+// CompositeKeyWildcard matches any value of a single segment in a composite key pattern.
+const CompositeKeyWildcard = "*"
+
+// ErrInvalidPattern is returned when a composite key pattern can't be used for aggregation.
+var ErrInvalidPattern = errors.New("invalid composite key pattern")
+
+// ValidateCompositePattern checks that a pattern has no empty segment and a wildcard in at most one segment.
+func ValidateCompositePattern(pattern string) error {
+	wildcards := 0
+	for _, segment := range strings.Split(pattern, CompositeKeySeparator) {
+		if segment == "" {
+			return fmt.Errorf("%w: %q has an empty segment", ErrInvalidPattern, pattern)
+		}
+
+		if segment == CompositeKeyWildcard {
+			wildcards++
+		} else if strings.Contains(segment, CompositeKeyWildcard) {
+			return fmt.Errorf("%w: %q mixes a wildcard with other characters in a segment", ErrInvalidPattern, pattern)
+		}
+	}
+
+	if wildcards > 1 {
+		return fmt.Errorf("%w: %q has a wildcard in more than one segment", ErrInvalidPattern, pattern)
+	}
+
+	return nil
+}
+
+// MatchCompositeKey reports whether key matches pattern segment by segment.
+// Both must have the same number of segments, and a wildcard segment matches any value.
+func MatchCompositeKey(pattern, key string) bool {
+	patternSegments := strings.Split(pattern, CompositeKeySeparator)
+	keySegments := strings.Split(key, CompositeKeySeparator)
+
+	if len(patternSegments) != len(keySegments) {
+		return false
+	}
+
+	for i, segment := range patternSegments {
+		if segment != CompositeKeyWildcard && segment != keySegments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// BloomAggregateCardinality unions on the fly the HyperLogLog sketches of all keys matching a composite key pattern
+// (e.g. "US:*" for every device in country US) and returns the matched keys with their combined cardinality.
+// It returns ErrIncompatibleSketch if the matched keys don't share the same precision.
+func BloomAggregateCardinality(ctx context.Context, pattern string) ([]string, uint64, error) {
+	if err := ValidateCompositePattern(pattern); err != nil {
+		return nil, 0, err
+	}
+
+	keys, err := BloomKeys(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Keep only the keys matching the pattern
+	matched := []string{}
+	for _, key := range keys {
+		if MatchCompositeKey(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+
+	union, err := BloomUnionCardinality(ctx, matched)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return matched, union, nil
+}
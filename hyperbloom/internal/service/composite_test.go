@@ -0,0 +1,67 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestValidateCompositePattern(t *testing.T) {
+	valid := []string{"US:*", "*:mobile", "US:mobile", "US"}
+	invalid := []string{"*:*", "US:", ":mobile", "US:mob*"}
+
+	for _, pattern := range valid {
+		if err := service.ValidateCompositePattern(pattern); err != nil {
+			t.Errorf("pattern %q: unexpected error %v", pattern, err)
+		}
+	}
+	for _, pattern := range invalid {
+		if err := service.ValidateCompositePattern(pattern); !errors.Is(err, service.ErrInvalidPattern) {
+			t.Errorf("pattern %q: expected ErrInvalidPattern, got %v", pattern, err)
+		}
+	}
+}
+
+func TestMatchCompositeKey(t *testing.T) {
+	cases := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"US:*", "US:mobile", true},
+		{"US:*", "VN:mobile", false},
+		{"US:*", "US", false},
+		{"US:*", "US:mobile:ios", false},
+		{"*:mobile", "VN:mobile", true},
+		{"US:mobile", "US:mobile", true},
+	}
+
+	for _, c := range cases {
+		if got := service.MatchCompositeKey(c.pattern, c.key); got != c.match {
+			t.Errorf("MatchCompositeKey(%q, %q) = %t, want %t", c.pattern, c.key, got, c.match)
+		}
+	}
+}
+
+func TestBloomAggregateCardinality(t *testing.T) {
+	requireDatabase(t)
+
+	service.BloomHash(context.Background(), "agg-US:mobile", "alice")
+	service.BloomHash(context.Background(), "agg-US:mobile", "bob")
+	service.BloomHash(context.Background(), "agg-US:desktop", "bob")
+	service.BloomHash(context.Background(), "agg-US:desktop", "carol")
+	service.BloomHash(context.Background(), "agg-VN:mobile", "dave")
+
+	keys, hCard, err := service.BloomAggregateCardinality(context.Background(), "agg-US:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("matched %v, want the two agg-US keys", keys)
+	}
+	if hCard != 3 {
+		t.Errorf("aggregate cardinality = %d, want 3", hCard)
+	}
+}
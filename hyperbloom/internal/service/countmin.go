@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// countMins holds the Count-Min Sketches loaded in memory. Sketches stay in memory once loaded or created,
+// the flush writes the ones that changed.
+var countMins = struct {
+	sync.Mutex
+	sketches map[string]*models.CountMin
+}{sketches: map[string]*models.CountMin{}}
+
+// countMinFetch returns the Count-Min Sketch of key from memory, loading it from the database if needed,
+// or creating it with the configured error bounds if create is set. It returns ErrKeyNotFound if the key
+// has no sketch and create isn't set. Loading gives up when ctx expires.
+func countMinFetch(ctx context.Context, key string, create bool) (*models.CountMin, error) {
+	countMins.Lock()
+	defer countMins.Unlock()
+
+	if cm, ok := countMins.sketches[key]; ok {
+		return cm, nil
+	}
+
+	// Load the persisted sketch, a new one is only written by the next flush
+	var encoded []byte
+	err := postgres.DbClient.QueryRowContext(ctx, `SELECT sketch FROM countmins WHERE key = $1`, key).Scan(&encoded)
+	var cm *models.CountMin
+	switch {
+	case err == nil:
+		if cm, err = models.DecodeCountMin(encoded, key); err != nil {
+			return nil, fmt.Errorf("can't decode the count-min sketch of %s: %w", key, err)
+		}
+		cm.MarkFlushed(cm.Version())
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	case !create:
+		return nil, ErrKeyNotFound
+	default:
+		cfg := config.HyperBloomCfg()
+		cm = models.NewCountMinWithEstimates(cfg.CountMinEpsilon, cfg.CountMinDelta, key)
+	}
+
+	countMins.sketches[key] = cm
+	return cm, nil
+}
+
+// CountMinAdd counts count more occurrences of value in the Count-Min Sketch of key, creating it with the error
+// bounds of HB_CMS_EPSILON and HB_CMS_DELTA if it doesn't exist. The sketch is written by the next flush.
+func CountMinAdd(ctx context.Context, key, value string, count uint64) error {
+	cm, err := countMinFetch(ctx, key, true)
+	if err != nil {
+		return err
+	}
+
+	cm.Add(value, count)
+	return nil
+}
+
+// CountMinEstimate returns the estimated number of occurrences of value in the Count-Min Sketch of key, which never
+// undercounts, along with the total count of the sketch. It returns ErrKeyNotFound if the key has no sketch.
+func CountMinEstimate(ctx context.Context, key, value string) (uint64, uint64, error) {
+	cm, err := countMinFetch(ctx, key, false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return cm.Estimate(value), cm.Total(), nil
+}
+
+// flushCountMins writes every Count-Min Sketch that changed since it was last written, stopping when ctx expires.
+func flushCountMins(ctx context.Context) {
+	countMins.Lock()
+	sketches := make([]*models.CountMin, 0, len(countMins.sketches))
+	for _, cm := range countMins.sketches {
+		if cm.Dirty() {
+			sketches = append(sketches, cm)
+		}
+	}
+	countMins.Unlock()
+
+	for _, cm := range sketches {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Encode the sketch at a known version, adds racing with the write leave it dirty for the next flush
+		version := cm.Version()
+		encoded, _ := cm.MarshalBinary()
+		_, err := postgres.DbClient.ExecContext(ctx, `
+			INSERT INTO countmins (key, sketch) VALUES ($1, $2)
+			ON CONFLICT (key) DO UPDATE SET sketch = EXCLUDED.sketch`,
+			cm.Key(), encoded,
+		)
+		if err != nil {
+			slog.Error("Can't flush count-min sketch", "key", cm.Key(), "err", err)
+			continue
+		}
+		cm.MarkFlushed(version)
+	}
+}
@@ -0,0 +1,53 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestCountMin(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("cms-", time.Now().UnixNano())
+
+	// Value i occurs i times, added once by one and once in bulk
+	for i := 1; i <= 100; i++ {
+		if err := service.CountMinAdd(context.Background(), key, strconv.Itoa(i), 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := service.CountMinAdd(context.Background(), key, strconv.Itoa(i), uint64(i-1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i <= 100; i++ {
+		estimate, total, err := service.CountMinEstimate(context.Background(), key, strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if estimate < uint64(i) || total != 5050 {
+			t.Errorf("estimate of %d = %d (total %d), want at least %d (total 5050)", i, estimate, total, i)
+		}
+	}
+
+	// The sketch is persisted by the flush
+	service.FlushAll(context.Background())
+	var count int
+	if err := postgres.DbClient.QueryRow(`SELECT COUNT(*) FROM countmins WHERE key = $1`, key).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d persisted sketches, want 1", count)
+	}
+
+	if _, _, err := service.CountMinEstimate(context.Background(), key+"-missing", "1"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("missing key: %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// Behaviors when creating a key that already exists.
+const (
+	OnExistsFail    = "fail"    // Reject the creation with ErrKeyExists
+	OnExistsIgnore  = "ignore"  // Keep the existing HyperBloom untouched
+	OnExistsReplace = "replace" // Reset the HyperBloom with the new parameters
+)
+
+// Outcomes of a key creation.
+const (
+	CreateCreated  = "created"  // A new HyperBloom was created
+	CreateIgnored  = "ignored"  // The key already existed and was kept
+	CreateReplaced = "replaced" // The key already existed and was reset
+)
+
+// ErrKeyExists is returned when creating a key that already exists with the OnExistsFail behavior.
+var ErrKeyExists = errors.New("key already exists")
+
+// ErrInvalidOnExists is returned for an unknown behavior on existing keys.
+var ErrInvalidOnExists = errors.New("invalid behavior on existing key")
+
+// MaxHashes is the largest number of hash functions a key can be created with.
+const MaxHashes = 64
+
+// ErrInvalidHashes is returned when creating a key with more than MaxHashes hash functions.
+var ErrInvalidHashes = errors.New("invalid number of hash functions")
+
+// ErrInvalidPrecision is returned when creating a key with a HyperLogLog precision other than
+// models.HyperPrecisions.
+var ErrInvalidPrecision = errors.New("invalid HyperLogLog precision")
+
+// ErrInvalidTTL is returned when creating a key with a negative time to live.
+var ErrInvalidTTL = errors.New("invalid time to live")
+
+// ErrInvalidCounting is returned when creating a counting key that is also strict or scalable, the values
+// removed would remain in the independent filter or in the other layers.
+var ErrInvalidCounting = errors.New("invalid counting filter")
+
+// ErrSizingConflict is returned when hashing into an existing key with a capacity or false positive rate
+// other than the ones it was created with.
+var ErrSizingConflict = errors.New("sizing conflicts with the existing key")
+
+// CreateOptions are the optional parameters of a new HyperBloom, the zero value creates it with the defaults.
+type CreateOptions struct {
+	Hashes    uint          // Overrides the number of hash functions derived from the false positive rate, 0 keeps the derived one
+	Strict    bool          // Checks membership against two independent filters, see models.HyperBloom.EnableStrict
+	Counting  bool          // Allows removals, see BloomRemove
+	HalfLife  time.Duration // Keeps a distinct count decayed with this half-life unless 0, see BloomDecayedCardinality
+	Scalable  *Scalable     // Grows the Bloom filter once saturated unless nil, see Scalable
+	Precision uint8         // Precision of the HyperLogLog sketch, 0 for models.DefaultHyperPrecision
+	TTL       time.Duration // Expires the key this long after its creation unless 0, see SweepExpired
+}
+
+// createMutex serializes key creations, explicit or on the first insert, so that checking for an existing key
+// and creating it are atomic.
+var createMutex sync.Mutex
+
+// BloomCreateKey explicitly creates the HyperBloom identified by key with the given capacity and false positive rate,
+// and the optional parameters in opts, see CreateOptions. The bit array is sized for the capacity and false positive
+// rate even when opts.Hashes overrides the number of hash functions, see TheoreticalFPR for the resulting rate.
+// onExists decides what happens when the key already exists, see OnExistsFail, OnExistsIgnore and OnExistsReplace.
+// It returns the outcome of the creation, ErrInfeasibleSizing if the parameters can't be served,
+// ErrInvalidHashes if opts.Hashes exceeds MaxHashes, ErrInvalidHalfLife if opts.HalfLife is below MinHalfLife,
+// ErrInvalidScalable if the scalable parameters are invalid or combined with strict, ErrInvalidCounting if counting
+// is combined with strict or scalable, ErrInvalidPrecision if opts.Precision isn't supported, ErrInvalidTTL if
+// opts.TTL is negative, or ErrKeyExists if the key exists and onExists is OnExistsFail.
+func BloomCreateKey(ctx context.Context, key string, capacity uint, falsePositive float64, opts CreateOptions, onExists string) (string, error) {
+	if onExists != OnExistsFail && onExists != OnExistsIgnore && onExists != OnExistsReplace {
+		return "", fmt.Errorf("%w: %q", ErrInvalidOnExists, onExists)
+	}
+
+	if opts.Hashes > MaxHashes {
+		return "", fmt.Errorf("%w: %d, at most %d", ErrInvalidHashes, opts.Hashes, MaxHashes)
+	}
+
+	if opts.HalfLife != 0 && opts.HalfLife < MinHalfLife {
+		return "", fmt.Errorf("%w: %s, at least %s", ErrInvalidHalfLife, opts.HalfLife, MinHalfLife)
+	}
+
+	if opts.Precision != 0 && !models.ValidHyperPrecision(opts.Precision) {
+		return "", fmt.Errorf("%w: %d, must be one of %v", ErrInvalidPrecision, opts.Precision, models.HyperPrecisions)
+	}
+
+	if opts.TTL < 0 {
+		return "", fmt.Errorf("%w: %s, must not be negative", ErrInvalidTTL, opts.TTL)
+	}
+
+	if opts.Scalable != nil {
+		defaulted := opts.Scalable.withDefaults()
+		if err := validateScalable(defaulted.Growth, defaulted.Tightening); err != nil {
+			return "", err
+		}
+		if opts.Strict {
+			return "", fmt.Errorf("%w: strict membership can't be combined with scalable", ErrInvalidScalable)
+		}
+		opts.Scalable = &defaulted
+	}
+
+	if opts.Counting && (opts.Strict || opts.Scalable != nil) {
+		return "", fmt.Errorf("%w: counting can't be combined with strict membership or scalable", ErrInvalidCounting)
+	}
+
+	if err := ValidateSizing(capacity, falsePositive); err != nil {
+		return "", err
+	}
+
+	createMutex.Lock()
+	defer createMutex.Unlock()
+
+	// Look for the key in memory first, then in the database. A quarantined key exists, replacing it repairs it
+	_, err := bloomFetch(ctx, key)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, ErrKeyQuarantined) {
+		return "", err
+	}
+	exists := err == nil || errors.Is(err, ErrKeyQuarantined)
+
+	outcome := CreateCreated
+	if exists {
+		switch onExists {
+		case OnExistsFail:
+			return "", ErrKeyExists
+		case OnExistsIgnore:
+			return CreateIgnored, nil
+		}
+
+		// Drop the existing HyperBloom before creating it again with the new parameters
+		if _, err = bloomDeleteRows(ctx, key); err != nil {
+			return "", err
+		}
+		dbs.Remove(key)
+		ReleaseQuarantine(key)
+		outcome = CreateReplaced
+	}
+
+	db, err := BloomCreate(ctx, capacity, falsePositive, key, opts)
+	if err != nil {
+		return "", err
+	}
+	dbs.Set(db, key)
+
+	return outcome, nil
+}
+
+// BloomEnsureSizing creates the HyperBloom identified by key with the given capacity and false positive rate
+// if it doesn't exist yet, so that the values hashed next land in a filter sized for them. 0 stands for the
+// configured default (HB_CARD and HB_FP). An existing key is left untouched, but ErrSizingConflict is returned
+// if a given parameter differs from the one it was created with; keys without metadata accept any.
+// It fails like BloomCreateKey otherwise.
+func BloomEnsureSizing(ctx context.Context, key string, capacity uint, falsePositive float64) error {
+	create := capacity
+	if create == 0 {
+		create = config.HyperBloomCfg().Cardinality
+	}
+	createFP := falsePositive
+	if createFP == 0 {
+		createFP = config.HyperBloomCfg().FalsePositive
+	}
+
+	outcome, err := BloomCreateKey(ctx, key, create, createFP, CreateOptions{}, OnExistsIgnore)
+	if err != nil || outcome != CreateIgnored {
+		return err
+	}
+
+	// Check the given parameters against the existing key, the rate is stored as a REAL
+	storedCapacity, storedFP, err := bloomSizing(ctx, key)
+	if err != nil {
+		return err
+	}
+	if capacity != 0 && storedCapacity != 0 && capacity != storedCapacity {
+		return fmt.Errorf("%w: %s was created for a capacity of %d, got %d", ErrSizingConflict, key, storedCapacity, capacity)
+	}
+	if falsePositive != 0 && storedFP != 0 && float32(falsePositive) != float32(storedFP) {
+		return fmt.Errorf("%w: %s was created for a false positive rate of %g, got %g", ErrSizingConflict, key, storedFP, falsePositive)
+	}
+
+	return nil
+}
+
+// bloomDeleteRows deletes the persisted HyperBloom and metadata of key in a single transaction,
+// and reports whether either row existed.
+func bloomDeleteRows(ctx context.Context, key string) (bool, error) {
+	// Begin a database transaction
+	tx, err := postgres.DbClient.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// The metadata references the HyperBloom, delete it first. Either may be missing
+	persisted := false
+	for _, query := range []string{
+		`DELETE FROM hyperblooms_metadata WHERE key = $1`,
+		`DELETE FROM hyperblooms WHERE key = $1`,
+	} {
+		result, err := tx.ExecContext(ctx, query, key)
+		if err != nil {
+			return false, err
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			persisted = true
+		}
+	}
+
+	// Commit the database transaction
+	return persisted, tx.Commit()
+}
@@ -0,0 +1,220 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestBloomCreateKey(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("create-", time.Now().UnixNano())
+
+	outcome, err := service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{}, service.OnExistsFail)
+	if err != nil || outcome != service.CreateCreated {
+		t.Fatalf("first creation = (%q, %v), want (%q, nil)", outcome, err, service.CreateCreated)
+	}
+	service.BloomHash(context.Background(), key, "value")
+
+	// fail: the existing key is left untouched
+	if _, err = service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != service.ErrKeyExists {
+		t.Errorf("fail mode: expected ErrKeyExists, got %v", err)
+	}
+
+	// ignore: no-op, the content is kept
+	outcome, err = service.BloomCreateKey(context.Background(), key, 5000, 0.001, service.CreateOptions{}, service.OnExistsIgnore)
+	if err != nil || outcome != service.CreateIgnored {
+		t.Errorf("ignore mode = (%q, %v), want (%q, nil)", outcome, err, service.CreateIgnored)
+	}
+	if !bloomExists(t, context.Background(), key, "value") || service.BloomGet(context.Background(), key).Bloom().Cap() != 9586 {
+		t.Error("ignore mode modified the existing key")
+	}
+
+	// replace: the key is reset with the new parameters
+	outcome, err = service.BloomCreateKey(context.Background(), key, 5000, 0.001, service.CreateOptions{}, service.OnExistsReplace)
+	if err != nil || outcome != service.CreateReplaced {
+		t.Errorf("replace mode = (%q, %v), want (%q, nil)", outcome, err, service.CreateReplaced)
+	}
+	if bloomExists(t, context.Background(), key, "value") {
+		t.Error("replace mode kept the previous content")
+	}
+	if m := service.BloomGet(context.Background(), key).Bloom().Cap(); m != 71888 {
+		t.Errorf("replaced bit capacity = %d, want 71888", m)
+	}
+
+	if _, err = service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{}, "merge"); err == nil {
+		t.Error("expected an error for an unknown behavior")
+	}
+}
+
+func TestBloomCreateKeyHashes(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("create-hashes-", time.Now().UnixNano())
+
+	// The override replaces the derived k (7 for 1000 elements at 1%), the bit array keeps its size
+	if _, err := service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{Hashes: 2}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	bf := service.BloomGet(context.Background(), key).Bloom()
+	if bf.K() != 2 || bf.Cap() != 9586 {
+		t.Errorf("(m, k) = (%d, %d), want (9586, 2)", bf.Cap(), bf.K())
+	}
+
+	// Fewer hash functions than optimal cost false positives: (1 - e^(-2*1000/9586))^2
+	want := math.Pow(1-math.Exp(-2.0*1000/9586), 2)
+	if got := service.TheoreticalFPR(1000, 9586, 2); math.Abs(got-want) > 1e-12 {
+		t.Errorf("TheoreticalFPR = %g, want %g", got, want)
+	}
+	if got := service.TheoreticalFPR(1000, 9586, 7); math.Abs(got-0.01) > 0.0005 {
+		t.Errorf("TheoreticalFPR with the derived k = %g, want about 0.01", got)
+	}
+
+	if _, err := service.BloomCreateKey(context.Background(), key+"-many", 1000, 0.01, service.CreateOptions{Hashes: service.MaxHashes + 1}, service.OnExistsFail); !errors.Is(err, service.ErrInvalidHashes) {
+		t.Errorf("expected ErrInvalidHashes, got %v", err)
+	}
+}
+
+func TestBloomCreateKeyScalable(t *testing.T) {
+	requireDatabase(t)
+
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.ScalableGrowth, cfg.ScalableTightening = 2, 0.8 })
+
+	key := fmt.Sprint("create-scalable-", time.Now().UnixNano())
+
+	// The omitted tightening takes the configured default
+	if _, err := service.BloomCreateKey(context.Background(), key, 100, 0.01, service.CreateOptions{Scalable: &service.Scalable{Growth: 3}}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		service.BloomHash(context.Background(), key, fmt.Sprint(i))
+	}
+
+	// 100 + 300 + 900 values fit in 3 layers, all of them checked
+	db := service.BloomGet(context.Background(), key)
+	if db.Scalable() == nil || db.Scalable().Layers() != 3 || db.Scalable().Tightening() != 0.8 {
+		t.Fatal("key created without the requested layers")
+	}
+	for i := 0; i < 1000; i++ {
+		if !bloomExists(t, context.Background(), key, fmt.Sprint(i)) {
+			t.Fatalf("%d missing", i)
+		}
+	}
+
+	for _, invalid := range []service.Scalable{{Growth: 0.5}, {Tightening: 1}, {Tightening: -0.1}} {
+		if _, err := service.BloomCreateKey(context.Background(), key+"-invalid", 100, 0.01, service.CreateOptions{Scalable: &invalid}, service.OnExistsFail); !errors.Is(err, service.ErrInvalidScalable) {
+			t.Errorf("%+v: expected ErrInvalidScalable, got %v", invalid, err)
+		}
+	}
+	if _, err := service.BloomCreateKey(context.Background(), key+"-strict", 100, 0.01, service.CreateOptions{Strict: true, Scalable: &service.Scalable{}}, service.OnExistsFail); !errors.Is(err, service.ErrInvalidScalable) {
+		t.Errorf("strict and scalable: expected ErrInvalidScalable, got %v", err)
+	}
+}
+
+func TestBloomCreateKeyPrecision(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("create-precision-", time.Now().UnixNano())
+
+	if _, err := service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{Precision: models.HighHyperPrecision}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := service.BloomStats(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Precision != models.HighHyperPrecision || stats.Registers != 1<<16 {
+		t.Errorf("(precision, registers) = (%d, %d), want (16, 65536)", stats.Precision, stats.Registers)
+	}
+
+	// Unions of keys of different precisions are rejected
+	other := key + "-default"
+	if _, err = service.BloomCreateKey(context.Background(), other, 1000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = service.HLLUnionCardinality(context.Background(), []string{key, other}); !errors.Is(err, service.ErrIncompatibleSketch) {
+		t.Errorf("union: expected ErrIncompatibleSketch, got %v", err)
+	}
+	if _, err = service.BloomOverlap(context.Background(), key, other); !errors.Is(err, service.ErrIncompatibleSketch) {
+		t.Errorf("overlap: expected ErrIncompatibleSketch, got %v", err)
+	}
+
+	for _, invalid := range []uint8{3, 10, 15, 19} {
+		if _, err = service.BloomCreateKey(context.Background(), key+"-invalid", 1000, 0.01, service.CreateOptions{Precision: invalid}, service.OnExistsFail); !errors.Is(err, service.ErrInvalidPrecision) {
+			t.Errorf("precision %d: expected ErrInvalidPrecision, got %v", invalid, err)
+		}
+	}
+}
+
+func TestBloomCreatePrecision(t *testing.T) {
+	// An unsupported precision is rejected before anything is stored, without a database
+	db, err := service.BloomCreate(context.Background(), 1000, 0.01, "create-precision", service.CreateOptions{Precision: 12})
+	if !errors.Is(err, service.ErrInvalidPrecision) {
+		t.Errorf("expected ErrInvalidPrecision, got %v", err)
+	}
+	if db != nil {
+		t.Error("expected no HyperBloom")
+	}
+}
+
+func TestBloomEnsureSizing(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("ensure-", time.Now().UnixNano())
+
+	// A new key is created with the requested sizing
+	if err := service.BloomEnsureSizing(context.Background(), key, 100000, 0.001); err != nil {
+		t.Fatal(err)
+	}
+	service.BloomHash(context.Background(), key, "value")
+	stats, err := service.BloomStats(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Capacity != 100000 || float32(stats.TargetFPR) != float32(0.001) {
+		t.Errorf("sizing = (%d, %g), want (100000, 0.001)", stats.Capacity, stats.TargetFPR)
+	}
+
+	// The same or omitted parameters are accepted for the existing key, which is left untouched
+	for _, sizing := range []struct {
+		capacity uint
+		fpr      float64
+	}{{100000, 0.001}, {100000, 0}, {0, 0.001}} {
+		if err = service.BloomEnsureSizing(context.Background(), key, sizing.capacity, sizing.fpr); err != nil {
+			t.Errorf("sizing %v: %v", sizing, err)
+		}
+	}
+	if !bloomExists(t, context.Background(), key, "value") {
+		t.Error("existing key reset")
+	}
+
+	// Different parameters conflict
+	if err = service.BloomEnsureSizing(context.Background(), key, 5000, 0); !errors.Is(err, service.ErrSizingConflict) {
+		t.Errorf("other capacity: %v, want ErrSizingConflict", err)
+	}
+	if err = service.BloomEnsureSizing(context.Background(), key, 0, 0.01); !errors.Is(err, service.ErrSizingConflict) {
+		t.Errorf("other rate: %v, want ErrSizingConflict", err)
+	}
+
+	// Omitted parameters fall back to the defaults
+	defaulted := key + "-default"
+	if err = service.BloomEnsureSizing(context.Background(), defaulted, 2000, 0); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = service.BloomStats(context.Background(), defaulted); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Capacity != 2000 || float32(stats.TargetFPR) != float32(config.HyperBloomCfg().FalsePositive) {
+		t.Errorf("sizing = (%d, %g), want (2000, %g)", stats.Capacity, stats.TargetFPR, config.HyperBloomCfg().FalsePositive)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// maxCuckooCapacity bounds HB_CUCKOO_CAPACITY, a filter of that capacity takes 1 GiB.
+const maxCuckooCapacity = 1 << 28
+
+// ErrFilterFull is returned when a value can't be added to a Cuckoo filter that reached its capacity.
+var ErrFilterFull = errors.New("filter full")
+
+// cuckoos holds the Cuckoo filters loaded in memory. Filters stay in memory once loaded or created,
+// the flush writes the ones that changed.
+var cuckoos = struct {
+	sync.Mutex
+	filters map[string]*models.Cuckoo
+}{filters: map[string]*models.Cuckoo{}}
+
+// cuckooFetch returns the Cuckoo filter of key from memory, loading it from the database if needed,
+// or creating it with the configured capacity if create is set. It returns ErrKeyNotFound if the key
+// has no filter and create isn't set. Loading gives up when ctx expires.
+func cuckooFetch(ctx context.Context, key string, create bool) (*models.Cuckoo, error) {
+	cuckoos.Lock()
+	defer cuckoos.Unlock()
+
+	if cf, ok := cuckoos.filters[key]; ok {
+		return cf, nil
+	}
+
+	// Load the persisted filter, a new one is only written by the next flush
+	var encoded []byte
+	err := postgres.DbClient.QueryRowContext(ctx, `SELECT filter FROM cuckoos WHERE key = $1`, key).Scan(&encoded)
+	var cf *models.Cuckoo
+	switch {
+	case err == nil:
+		if cf, err = models.DecodeCuckoo(encoded, key); err != nil {
+			return nil, fmt.Errorf("can't decode the cuckoo filter of %s: %w", key, err)
+		}
+		cf.MarkFlushed(cf.Version())
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	case !create:
+		return nil, ErrKeyNotFound
+	default:
+		cf = models.NewCuckoo(config.HyperBloomCfg().CuckooCapacity, key)
+	}
+
+	cuckoos.filters[key] = cf
+	return cf, nil
+}
+
+// CuckooAdd adds value to the Cuckoo filter of key, creating it with the capacity of HB_CUCKOO_CAPACITY
+// if it doesn't exist, and returns the number of values in the filter along with its number of slots.
+// It returns ErrFilterFull if the filter can't make room for the value, which is then not added.
+// The filter is written by the next flush.
+func CuckooAdd(ctx context.Context, key, value string) (uint64, uint64, error) {
+	cf, err := cuckooFetch(ctx, key, true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = cf.Add(value); errors.Is(err, models.ErrCuckooFull) {
+		err = fmt.Errorf("%w: %s holds %d values in %d slots", ErrFilterFull, key, cf.Count(), cf.Capacity())
+	}
+	return cf.Count(), cf.Capacity(), err
+}
+
+// CuckooContains reports whether value is probably in the Cuckoo filter of key.
+// It returns ErrKeyNotFound if the key has no filter.
+func CuckooContains(ctx context.Context, key, value string) (bool, error) {
+	cf, err := cuckooFetch(ctx, key, false)
+	if err != nil {
+		return false, err
+	}
+
+	return cf.Contains(value), nil
+}
+
+// CuckooDelete removes one occurrence of value from the Cuckoo filter of key, and reports whether it was found,
+// along with the number of values left. Deleting a value that was never added may delete another one sharing
+// its fingerprint. It returns ErrKeyNotFound if the key has no filter.
+func CuckooDelete(ctx context.Context, key, value string) (bool, uint64, error) {
+	cf, err := cuckooFetch(ctx, key, false)
+	if err != nil {
+		return false, 0, err
+	}
+
+	deleted := cf.Delete(value)
+	return deleted, cf.Count(), nil
+}
+
+// flushCuckoos writes every Cuckoo filter that changed since it was last written, stopping when ctx expires.
+func flushCuckoos(ctx context.Context) {
+	cuckoos.Lock()
+	filters := make([]*models.Cuckoo, 0, len(cuckoos.filters))
+	for _, cf := range cuckoos.filters {
+		if cf.Dirty() {
+			filters = append(filters, cf)
+		}
+	}
+	cuckoos.Unlock()
+
+	for _, cf := range filters {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Encode the filter at a known version, changes racing with the write leave it dirty for the next flush
+		version := cf.Version()
+		encoded, _ := cf.MarshalBinary()
+		_, err := postgres.DbClient.ExecContext(ctx, `
+			INSERT INTO cuckoos (key, filter) VALUES ($1, $2)
+			ON CONFLICT (key) DO UPDATE SET filter = EXCLUDED.filter`,
+			cf.Key(), encoded,
+		)
+		if err != nil {
+			slog.Error("Can't flush cuckoo filter", "key", cf.Key(), "err", err)
+			continue
+		}
+		cf.MarkFlushed(version)
+	}
+}
@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestCuckoo(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("cuckoo-", time.Now().UnixNano())
+
+	for i := 0; i < 100; i++ {
+		if _, _, err := service.CuckooAdd(context.Background(), key, strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Deleted values are gone, the others stay
+	for i := 0; i < 50; i++ {
+		deleted, count, err := service.CuckooDelete(context.Background(), key, strconv.Itoa(i))
+		if err != nil || !deleted || count != uint64(99-i) {
+			t.Fatalf("delete %d: (%t, %d, %v), want (true, %d, nil)", i, deleted, count, err, 99-i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		exists, err := service.CuckooContains(context.Background(), key, strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i >= 50 && !exists {
+			t.Errorf("%d missing", i)
+		}
+	}
+
+	// The filter is persisted by the flush
+	service.FlushAll(context.Background())
+	var count int
+	if err := postgres.DbClient.QueryRow(`SELECT COUNT(*) FROM cuckoos WHERE key = $1`, key).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d persisted filters, want 1", count)
+	}
+
+	if _, err := service.CuckooContains(context.Background(), key+"-missing", "1"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("missing key: %v, want ErrKeyNotFound", err)
+	}
+	if _, _, err := service.CuckooDelete(context.Background(), key+"-missing", "1"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("delete from a missing key: %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestCuckooFull(t *testing.T) {
+	requireDatabase(t)
+
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.CuckooCapacity = 10 })
+
+	key := fmt.Sprint("cuckoo-full-", time.Now().UnixNano())
+	var err error
+	added := 0
+	for ; added < 1000; added++ {
+		if _, _, err = service.CuckooAdd(context.Background(), key, strconv.Itoa(added)); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, service.ErrFilterFull) {
+		t.Fatalf("add %d: %v, want ErrFilterFull", added, err)
+	}
+
+	// Nothing was dropped to make room
+	for i := 0; i < added; i++ {
+		if exists, _ := service.CuckooContains(context.Background(), key, strconv.Itoa(i)); !exists {
+			t.Fatalf("%d dropped by the failed add", i)
+		}
+	}
+}
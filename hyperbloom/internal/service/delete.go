@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// deleteMutex orders deletions with the writes of the flush: a deletion waits for the writes in progress, and the
+// writes starting after it see the instance marked deleted, so a deleted key is never written back.
+var deleteMutex sync.RWMutex
+
+// BloomDelete deletes the HyperBloom identified by key, from memory and from the database along with its metadata.
+// A quarantined key is deleted along with its unreadable row and released. It returns ErrKeyNotFound
+// if the key exists neither in memory nor in the database.
+func BloomDelete(ctx context.Context, key string) error {
+	deleteMutex.Lock()
+	defer deleteMutex.Unlock()
+
+	persisted := false
+	inMemory, err := dbs.Delete(key, func() (err error) {
+		persisted, err = bloomDeleteRows(ctx, key)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	ReleaseQuarantine(key)
+
+	if !inMemory && !persisted {
+		return ErrKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomDelete(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	key := fmt.Sprint("delete-", suffix)
+
+	if _, err := service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	service.BloomHash(context.Background(), key, "value")
+
+	// Hold the instance like a flush or a request racing with the deletion would
+	held := service.BloomGet(context.Background(), key)
+	if err := service.BloomUpdate(held, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key is persisted along with its metadata, which references it
+	if blooms, metadata := persistedRows(t, key); blooms != 1 || metadata != 1 {
+		t.Fatalf("%d filter rows and %d metadata rows before deleting, want 1 and 1", blooms, metadata)
+	}
+	if err := service.BloomDelete(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if blooms, metadata := persistedRows(t, key); blooms != 0 || metadata != 0 {
+		t.Errorf("%d filter rows and %d metadata rows left after deleting", blooms, metadata)
+	}
+
+	// Writing the held instance back doesn't resurrect the key, neither in the database nor in memory
+	held.Hash("late")
+	if err := service.BloomUpdate(held, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.BloomHash(context.Background(), key, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if bloomExists(t, context.Background(), key, "value") || bloomExists(t, context.Background(), key, "late") {
+		t.Error("deleted values are still reported after the key was created again")
+	}
+
+	keys, err := service.BloomKeys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for _, k := range keys {
+		if k == key {
+			found++
+		}
+	}
+	if found != 1 {
+		t.Errorf("key listed %d times after being created again, want once", found)
+	}
+
+	// Deleting the new key, then a key that doesn't exist anymore
+	if err = service.BloomDelete(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if blooms, metadata := persistedRows(t, key); blooms != 0 || metadata != 0 {
+		t.Errorf("%d filter rows and %d metadata rows left after deleting again", blooms, metadata)
+	}
+	if service.BloomGet(context.Background(), key) != nil {
+		t.Error("deleted key still loads")
+	}
+	if err = service.BloomDelete(context.Background(), key); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("deleting twice: %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// hashQualityBuckets is the number of equal ranges the bit array is split into to check the hash distribution.
+const hashQualityBuckets = 64
+
+// hashQualityZ is the standard normal quantile of the significance level (0.1%) used to flag a bad distribution.
+const hashQualityZ = 3.09
+
+// HashQualityReport describes how uniformly the hash functions of a Bloom filter spread values over its bit array.
+type HashQualityReport struct {
+	Key                string  // Key of the HyperBloom
+	Buckets            int     // Number of equal ranges of the bit array
+	Samples            int     // Number of random values hashed
+	InsertedChiSquared float64 // Chi-squared statistic of the bits set by the inserted values, against uniform
+	RandomChiSquared   float64 // Chi-squared statistic of the positions of the random values, against uniform
+	CriticalValue      float64 // Chi-squared value above which a distribution is flagged as not uniform
+	Suspicious         bool    // Whether either distribution is flagged as not uniform
+}
+
+// BloomHashQuality samples the bit distribution of the HyperBloom identified by key, both from the bits set by the
+// inserted values and from hashing random values, and compares each against a uniform distribution with a
+// chi-squared test. It returns ErrKeyNotFound if the key doesn't exist.
+func BloomHashQuality(ctx context.Context, key string, samples int) (*HashQualityReport, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	bs := db.BitSet()
+	m, k := db.Bloom().Cap(), db.Bloom().K()
+
+	// Count the bits set by the inserted values in each bucket
+	inserted := make([]float64, hashQualityBuckets)
+	setBits := 0.0
+	for i, ok := bs.NextSet(0); ok && i < m; i, ok = bs.NextSet(i + 1) {
+		inserted[bucketOf(uint64(i), m)]++
+		setBits++
+	}
+
+	// Count the positions random values hash to in each bucket
+	random := make([]float64, hashQualityBuckets)
+	for i := 0; i < samples; i++ {
+		value := strconv.FormatUint(rand.Uint64(), 36)
+		for _, location := range bloom.Locations([]byte(value), k) {
+			random[bucketOf(location%uint64(m), m)]++
+		}
+	}
+
+	report := &HashQualityReport{
+		Key:                key,
+		Buckets:            hashQualityBuckets,
+		Samples:            samples,
+		InsertedChiSquared: chiSquaredUniform(inserted, setBits),
+		RandomChiSquared:   chiSquaredUniform(random, float64(samples)*float64(k)),
+		CriticalValue:      chiSquaredCritical(hashQualityBuckets - 1),
+	}
+	report.Suspicious = report.InsertedChiSquared > report.CriticalValue || report.RandomChiSquared > report.CriticalValue
+
+	return report, nil
+}
+
+// bucketOf returns the bucket of a bit position in a bit array of m bits.
+func bucketOf(position uint64, m uint) int {
+	return int(position * hashQualityBuckets / uint64(m))
+}
+
+// chiSquaredUniform computes the chi-squared statistic of observed bucket counts against a uniform
+// spread of total over the buckets.
+func chiSquaredUniform(observed []float64, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	expected := total / float64(len(observed))
+	chiSquared := 0.0
+	for _, count := range observed {
+		chiSquared += (count - expected) * (count - expected) / expected
+	}
+	return chiSquared
+}
+
+// chiSquaredCritical approximates the critical chi-squared value for df degrees of freedom
+// at the hashQualityZ significance level with the Wilson-Hilferty transformation.
+func chiSquaredCritical(df int) float64 {
+	v := 2.0 / (9.0 * float64(df))
+	return float64(df) * math.Pow(1-v+hashQualityZ*math.Sqrt(v), 3)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+// DistanceEstimate is the Jaccard distance between the Bloom filters of two keys, with how noisy it is.
+type DistanceEstimate struct {
+	Similarity float64 // Jaccard similarity of the bit arrays
+	Distance   float64 // 1 - Similarity
+	ErrorBound float64 // Approximate bound on |Distance - true distance|
+	Fill1      float64 // Fraction of bits set in the first filter
+	Fill2      float64 // Fraction of bits set in the second filter
+	Fallback   bool    // Whether the similarity was estimated from the HyperLogLog sketches
+}
+
+// BloomDistance estimates the Jaccard distance 1 - J between the Bloom filters of two keys, backed by JaccardSimBF.
+// Bits shared by chance by different values inflate the intersection, by about fill1 * fill2 * m bits for independent
+// filters of m bits, and the ratio of bit counts is itself noisy with a standard error of about sqrt(J(1-J)/|A∪B|).
+// The error bound adds the chance overlap relative to the union to 1.96 standard errors (95%), capped at 1:
+// it grows as the filters fill up and shrinks with larger filters.
+// Filters that differ in size or number of hash functions are handled as in BloomSimilarityOnMismatch: it returns
+// ErrIncompatibleFilters, or estimates the similarity from the sketches with OnMismatchHyper, whose error the fill
+// doesn't bound, so the error bound is left at 1.
+// It returns ErrKeyNotFound if one of the keys doesn't exist, and ErrInvalidOnMismatch for an unknown behavior.
+func BloomDistance(ctx context.Context, key1, key2, onMismatch string) (*DistanceEstimate, error) {
+	if err := validateOnMismatch(onMismatch); err != nil {
+		return nil, err
+	}
+
+	db1, db2 := BloomGet(ctx, key1), BloomGet(ctx, key2)
+	if db1 == nil || db2 == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	fallback, err := checkFilters(db1, db2, onMismatch)
+	if err != nil {
+		return nil, err
+	}
+
+	bs1, bs2 := db1.BitSet(), db2.BitSet()
+	estimate := &DistanceEstimate{
+		Fill1:    float64(bs1.Count()) / float64(db1.Bloom().Cap()),
+		Fill2:    float64(bs2.Count()) / float64(db2.Bloom().Cap()),
+		Fallback: fallback,
+	}
+
+	if fallback {
+		sim, err := BloomHyperSimilarity(ctx, key1, key2)
+		if err != nil {
+			return nil, err
+		}
+		estimate.Similarity = float64(sim)
+		estimate.Distance = 1 - estimate.Similarity
+		estimate.ErrorBound = 1
+		return estimate, nil
+	}
+
+	// Two empty filters represent the same (empty) set
+	union := float64(bs1.UnionCardinality(bs2))
+	if union == 0 {
+		estimate.Similarity = 1
+		return estimate, nil
+	}
+
+	estimate.Similarity = float64(models.JaccardSimBF(db1, db2))
+	estimate.Distance = 1 - estimate.Similarity
+
+	chance := estimate.Fill1 * estimate.Fill2 * float64(db1.Bloom().Cap()) / union
+	stderr := math.Sqrt(estimate.Similarity * (1 - estimate.Similarity) / union)
+	estimate.ErrorBound = math.Min(chance+1.96*stderr, 1)
+
+	return estimate, nil
+}
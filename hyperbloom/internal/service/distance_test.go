@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomDistance(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	keys := []string{
+		fmt.Sprint("distance-a-", suffix),
+		fmt.Sprint("distance-b-", suffix),
+		fmt.Sprint("distance-c-", suffix),
+		fmt.Sprint("distance-d-", suffix),
+	}
+
+	// a and b overlap by half with few values, c and d do the same with many values
+	for i := 0; i < 200; i++ {
+		service.BloomHash(context.Background(), keys[0], strconv.Itoa(i))
+		service.BloomHash(context.Background(), keys[1], strconv.Itoa(i+100))
+	}
+	for i := 0; i < 10000; i++ {
+		service.BloomHash(context.Background(), keys[2], strconv.Itoa(i))
+		service.BloomHash(context.Background(), keys[3], strconv.Itoa(i+5000))
+	}
+
+	sparse, err := service.BloomDistance(context.Background(), keys[0], keys[1], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := service.BloomDistance(context.Background(), keys[2], keys[3], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, estimate := range []*service.DistanceEstimate{sparse, full} {
+		if math.Abs(estimate.Distance+estimate.Similarity-1) > 1e-9 {
+			t.Errorf("distance %f + similarity %f != 1", estimate.Distance, estimate.Similarity)
+		}
+		if estimate.ErrorBound <= 0 || estimate.ErrorBound > 1 {
+			t.Errorf("error bound %f out of (0, 1]", estimate.ErrorBound)
+		}
+	}
+
+	// Chance collisions grow with the fill
+	if full.Fill1 <= sparse.Fill1 || full.ErrorBound <= sparse.ErrorBound {
+		t.Errorf("fuller filters have fill %f and bound %f, sparse ones %f and %f",
+			full.Fill1, full.ErrorBound, sparse.Fill1, sparse.ErrorBound)
+	}
+
+	// A key is at distance 0 from itself
+	if self, _ := service.BloomDistance(context.Background(), keys[0], keys[0], ""); self.Distance != 0 {
+		t.Errorf("distance to itself = %f, want 0", self.Distance)
+	}
+
+	if _, err = service.BloomDistance(context.Background(), keys[0], "distance-missing", ""); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	// Filters of another size are refused by default, or compared through their sketches
+	small := fmt.Sprint("distance-small-", suffix)
+	if _, err := service.BloomCreateKey(context.Background(), small, 100, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	service.BloomHash(context.Background(), small, "0")
+	for _, onMismatch := range []string{"", service.OnMismatchError} {
+		if _, err = service.BloomDistance(context.Background(), keys[0], small, onMismatch); !errors.Is(err, service.ErrIncompatibleFilters) {
+			t.Errorf("%q on incompatible filters: %v, want ErrIncompatibleFilters", onMismatch, err)
+		}
+	}
+	fallback, err := service.BloomDistance(context.Background(), keys[0], small, service.OnMismatchHyper)
+	if err != nil || !fallback.Fallback {
+		t.Fatalf("hll on incompatible filters: %+v, %v", fallback, err)
+	}
+	if math.Abs(fallback.Distance+fallback.Similarity-1) > 1e-9 || fallback.ErrorBound != 1 {
+		t.Errorf("hll distance %f, similarity %f, bound %f", fallback.Distance, fallback.Similarity, fallback.ErrorBound)
+	}
+
+	if _, err = service.BloomDistance(context.Background(), keys[0], keys[1], "guess"); !errors.Is(err, service.ErrInvalidOnMismatch) {
+		t.Errorf("unknown behavior: %v, want ErrInvalidOnMismatch", err)
+	}
+}
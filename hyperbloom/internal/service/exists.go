@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Methods answering a membership query.
+const (
+	ExistsMethodBloom = "bloom" // Test the Bloom filter, false positives bounded by its false positive rate
+	ExistsMethodHyper = "hll"   // Check whether the value would change the HyperLogLog estimate, a much weaker signal
+)
+
+// ErrInvalidExistsMethod is returned for an unknown membership query method.
+var ErrInvalidExistsMethod = errors.New("invalid exists method")
+
+// BloomExistsMethod checks if a value exists in the HyperBloom identified by key with the given method,
+// ExistsMethodBloom (the default when method is empty, same as BloomExists) or ExistsMethodHyper, and returns
+// the method that answered. ExistsMethodHyper only tells whether inserting the value would change the cardinality
+// estimate (see models.HyperBloom.CheckExistsHyper): it has no false negatives but many false positives, and is
+// meant for keys whose Bloom filter is saturated. It returns ErrInvalidExistsMethod for an unknown method,
+// and fails like BloomExists otherwise.
+func BloomExistsMethod(ctx context.Context, key, value, method string) (bool, string, error) {
+	switch method {
+	case "", ExistsMethodBloom:
+		exists, err := BloomExists(ctx, key, value)
+		return exists, ExistsMethodBloom, err
+	case ExistsMethodHyper:
+		db, err := bloomLookup(ctx, key)
+		if err != nil {
+			return false, ExistsMethodHyper, err
+		}
+		return db.CheckExistsHyper(value), ExistsMethodHyper, nil
+	}
+
+	return false, "", fmt.Errorf("%w: %q, expected %s or %s", ErrInvalidExistsMethod, method, ExistsMethodBloom, ExistsMethodHyper)
+}
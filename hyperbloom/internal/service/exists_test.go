@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomExistsMethod(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("exists-method-", time.Now().UnixNano())
+	if _, err := service.BloomCreateKey(context.Background(), key, 100000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough values for the sketch to leave its sparse representation
+	for i := 0; i < 50000; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+
+	// Neither method has false negatives
+	for _, method := range []string{service.ExistsMethodBloom, service.ExistsMethodHyper} {
+		for i := 0; i < 50000; i += 97 {
+			exists, answered, err := service.BloomExistsMethod(context.Background(), key, strconv.Itoa(i), method)
+			if err != nil || answered != method {
+				t.Fatalf("%s answered (%s, %v)", method, answered, err)
+			}
+			if !exists {
+				t.Fatalf("%s reported inserted value %d absent", method, i)
+			}
+		}
+	}
+
+	// The HyperLogLog heuristic reports many more values that were never inserted
+	falsePositives := map[string]int{}
+	for _, method := range []string{service.ExistsMethodBloom, service.ExistsMethodHyper} {
+		for i := 0; i < 2000; i++ {
+			if exists, _, _ := service.BloomExistsMethod(context.Background(), key, fmt.Sprint("never-", i), method); exists {
+				falsePositives[method]++
+			}
+		}
+	}
+	if falsePositives[service.ExistsMethodHyper] <= 10*falsePositives[service.ExistsMethodBloom] {
+		t.Errorf("false positives out of 2000 (bloom, hll) = (%d, %d), expected hll to be far weaker",
+			falsePositives[service.ExistsMethodBloom], falsePositives[service.ExistsMethodHyper])
+	}
+
+	// The Bloom filter answers by default
+	if _, answered, _ := service.BloomExistsMethod(context.Background(), key, "0", ""); answered != service.ExistsMethodBloom {
+		t.Errorf("default method = %s, want %s", answered, service.ExistsMethodBloom)
+	}
+	if _, _, err := service.BloomExistsMethod(context.Background(), key, "0", "cuckoo"); !errors.Is(err, service.ErrInvalidExistsMethod) {
+		t.Errorf("expected ErrInvalidExistsMethod, got %v", err)
+	}
+}
+
+func TestReadMissingKey(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("missing-", time.Now().UnixNano())
+
+	// Reads on a key that doesn't exist fail rather than reporting an empty filter, and don't create it
+	if _, err := service.BloomExists(context.Background(), key, "value"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("exists: %v, want ErrKeyNotFound", err)
+	}
+	for _, method := range []string{service.ExistsMethodBloom, service.ExistsMethodHyper} {
+		if _, _, err := service.BloomExistsMethod(context.Background(), key, "value", method); !errors.Is(err, service.ErrKeyNotFound) {
+			t.Errorf("exists (%s): %v, want ErrKeyNotFound", method, err)
+		}
+	}
+	if _, _, err := service.BloomCardinality(context.Background(), key); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("cardinality: %v, want ErrKeyNotFound", err)
+	}
+	if _, err := service.BloomCardinalityState(context.Background(), key); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("cardinality state: %v, want ErrKeyNotFound", err)
+	}
+	if _, err := service.BloomStats(context.Background(), key); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("stats: %v, want ErrKeyNotFound", err)
+	}
+	if service.BloomGet(context.Background(), key) != nil {
+		t.Fatalf("%s created by a read", key)
+	}
+
+	// Hashing still creates the key, a value never inserted is then absent without an error
+	if err := service.BloomHash(context.Background(), key, "value"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := service.BloomExists(context.Background(), key, "other"); err != nil || exists {
+		t.Errorf("exists (other) = (%t, %v), want (false, nil)", exists, err)
+	}
+	if _, hCard, err := service.BloomCardinality(context.Background(), key); err != nil || hCard != 1 {
+		t.Errorf("cardinality = (%d, %v), want (1, nil)", hCard, err)
+	}
+}
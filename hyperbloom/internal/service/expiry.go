@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// expiryTicker triggers the sweep of expired keys, stopped while disabled.
+var expiryTicker = time.NewTicker(time.Hour)
+
+// expirySweepCtx is canceled by Shutdown to stop the sweeper, interrupting a sweep in progress.
+var expirySweepCtx, stopExpirySweep = context.WithCancel(context.Background())
+
+// expirySweepDone is closed once the sweeper has exited.
+var expirySweepDone = make(chan struct{})
+
+// expiredCounter returns the counter of the keys deleted because they expired.
+func expiredCounter() *expvar.Int {
+	return metrics.Int("keys_expired_total")
+}
+
+// resetExpiryTicker applies HB_EXPIRY_SWEEP_INTERVAL to expiryTicker.
+func resetExpiryTicker() {
+	if interval := config.HyperBloomCfg().ExpirySweepInterval; interval > 0 {
+		expiryTicker.Reset(interval)
+		return
+	}
+	expiryTicker.Stop()
+}
+
+// AsyncExpirySweep starts a goroutine deleting the expired keys every HB_EXPIRY_SWEEP_INTERVAL until Shutdown.
+// Expired keys are treated as absent even before they are swept, see bloomFetch.
+func AsyncExpirySweep() {
+	slog.Info("Starting AsyncExpirySweep")
+	WG.Add(1)
+	go func() {
+		defer WG.Done()
+		defer close(expirySweepDone) // Let the shutdown sequence know the sweeper is over
+		for {
+			select {
+			case <-expirySweepCtx.Done():
+				return
+			case <-expiryTicker.C:
+				if _, err := SweepExpired(expirySweepCtx); err != nil && expirySweepCtx.Err() == nil {
+					slog.Error("Can't sweep expired keys", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// SweepExpired deletes every key whose expiry time has passed, stopping early once ctx is canceled,
+// and returns the number of keys deleted. A key failing to be deleted is logged and left for the next sweep.
+func SweepExpired(ctx context.Context) (int, error) {
+	rows, err := postgres.DbClient.QueryContext(ctx, `SELECT key FROM hyperblooms_metadata WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	now := time.Now().UTC()
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return expired, ctx.Err()
+		}
+
+		// The key may have been expired and created again since it was selected
+		if db, ok := dbs.GetHyperBloom(key); ok && !db.Expired(now) {
+			continue
+		}
+
+		if err = expireKey(ctx, key); err != nil {
+			slog.Error("Can't delete expired key", "key", key, "err", err)
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// expireKey deletes the expired key from memory and the database, a key already deleted is left alone.
+func expireKey(ctx context.Context, key string) error {
+	err := BloomDelete(ctx, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	expiredCounter().Add(1)
+	slog.Debug("Expired", "key", key)
+	return nil
+}
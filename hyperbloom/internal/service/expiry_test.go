@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomCreateKeyTTL(t *testing.T) {
+	requireDatabase(t)
+
+	prefix := fmt.Sprint("expiry-", time.Now().UnixNano())
+	accessed, swept, kept := prefix+"-accessed", prefix+"-swept", prefix+"-kept"
+
+	for _, key := range []string{accessed, swept} {
+		if _, err := service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{TTL: time.Second}, service.OnExistsFail); err != nil {
+			t.Fatal(err)
+		}
+		service.BloomHash(context.Background(), key, "value")
+	}
+	if _, err := service.BloomCreateKey(context.Background(), kept, 1000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	if !bloomExists(t, context.Background(), accessed, "value") {
+		t.Fatal("value missing before the key expired")
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	// An expired key reads as absent before it is swept
+	if _, err := service.BloomExists(context.Background(), accessed, "value"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("exists after the key expired: %v, want ErrKeyNotFound", err)
+	}
+	if _, _, err := service.BloomCardinality(context.Background(), accessed); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("cardinality after the key expired: %v, want ErrKeyNotFound", err)
+	}
+
+	// The sweep deletes the other expired key and keeps the one without a time to live
+	if _, err := service.SweepExpired(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.BloomDelete(context.Background(), swept); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("deleting a swept key: %v, want ErrKeyNotFound", err)
+	}
+	keys, err := service.ListKeys(context.Background(), prefix, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Key != kept {
+		t.Errorf("listed %v after the sweep, want only %s", keys, kept)
+	}
+
+	if _, err = service.BloomCreateKey(context.Background(), prefix+"-negative", 1000, 0.01, service.CreateOptions{TTL: -time.Second}, service.OnExistsFail); !errors.Is(err, service.ErrInvalidTTL) {
+		t.Errorf("negative ttl: expected ErrInvalidTTL, got %v", err)
+	}
+}
+
+func TestSweepExpired(t *testing.T) {
+	requireDatabase(t)
+
+	prefix := fmt.Sprint("sweep-", time.Now().UnixNano())
+	keys := []string{prefix + "-a", prefix + "-b", prefix + "-read"}
+	for _, key := range keys {
+		if _, err := service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{TTL: time.Second}, service.OnExistsFail); err != nil {
+			t.Fatal(err)
+		}
+		service.BloomHash(context.Background(), key, "value")
+	}
+	service.FlushAll(context.Background())
+	for _, key := range keys {
+		if blooms, metadata := persistedRows(t, key); blooms != 1 || metadata != 1 {
+			t.Fatalf("%s has %d filter rows and %d metadata rows before expiring, want 1 and 1", key, blooms, metadata)
+		}
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	// Reading an expired key deletes it rather than failing
+	read := keys[2]
+	if _, err := service.BloomExists(context.Background(), read, "value"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("exists after the key expired: %v, want ErrKeyNotFound", err)
+	}
+
+	// The sweep deletes the others, other tests may have left expired keys too
+	expired, err := service.SweepExpired(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired < 2 {
+		t.Errorf("swept %d keys, want at least 2", expired)
+	}
+	for _, key := range keys {
+		if blooms, metadata := persistedRows(t, key); blooms != 0 || metadata != 0 {
+			t.Errorf("%s has %d filter rows and %d metadata rows left after the sweep", key, blooms, metadata)
+		}
+		if service.BloomGet(context.Background(), key) != nil {
+			t.Errorf("%s still loads after the sweep", key)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+// FlushLoop exposes flushLoop to the tests simulating a loop replaced by the watchdog.
+var FlushLoop = flushLoop
+
+// MarkDirty exposes markDirty to the tests.
+var MarkDirty = markDirty
+
+// DirtyKeys returns the number of keys changed since the last flush.
+func DirtyKeys() int {
+	dirtyKeys.Lock()
+	defer dirtyKeys.Unlock()
+	return len(dirtyKeys.keys)
+}
+
+// WakeFlush has the update goroutine flush before the next tick, like markDirty once the batch is full.
+func WakeFlush(id string) {
+	flushNow <- id
+}
+
+// TakeWakeUp removes the pending wake-up of the update goroutine, and reports whether there was one.
+func TakeWakeUp() (string, bool) {
+	select {
+	case id := <-flushNow:
+		return id, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,14 @@
+package service
+
+import "context"
+
+// BloomFill returns the number of bits set in the Bloom filter of the HyperBloom identified by key and its size m,
+// the fill ratio being set / m. It returns ErrKeyNotFound if the key doesn't exist.
+func BloomFill(ctx context.Context, key string) (uint64, uint64, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return 0, 0, ErrKeyNotFound
+	}
+
+	return db.SetBits(), uint64(db.Bloom().Cap()), nil
+}
@@ -0,0 +1,34 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomFill(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("fill-", time.Now().UnixNano())
+	for i := 0; i < 1000; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+
+	set, total, err := service.BloomFill(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := service.BloomGet(context.Background(), key).BitSet()
+	if set != uint64(bs.Count()) || total != uint64(bs.Len()) {
+		t.Errorf("fill = (%d, %d), want (%d, %d)", set, total, bs.Count(), bs.Len())
+	}
+
+	if _, _, err = service.BloomFill(context.Background(), key+"-missing"); err != service.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
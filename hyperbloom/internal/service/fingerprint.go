@@ -0,0 +1,15 @@
+package service
+
+import "context"
+
+// BloomFingerprint returns the SHA-256 fingerprint of the filters of the HyperBloom identified by key,
+// equal for keys with bit-identical filters and changing with any new bit set. It returns ErrKeyNotFound
+// if the key doesn't exist.
+func BloomFingerprint(ctx context.Context, key string) (string, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return "", ErrKeyNotFound
+	}
+
+	return db.Fingerprint()
+}
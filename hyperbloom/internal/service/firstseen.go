@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrFirstSeenNotTracked is returned when querying first insert times of a key created without tracking them.
+var ErrFirstSeenNotTracked = errors.New("first seen times not tracked")
+
+// BloomFirstSeen returns the approximate time value was first inserted in the HyperBloom identified by key,
+// at a one-second resolution and possibly too early, see models.FirstSeen.
+// It returns false if the value was never inserted, ErrKeyNotFound if the key doesn't exist,
+// or ErrFirstSeenNotTracked if the key was created without HB_FIRST_SEEN_BUCKETS.
+func BloomFirstSeen(ctx context.Context, key, value string) (time.Time, bool, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return time.Time{}, false, ErrKeyNotFound
+	}
+	if db.FirstSeen() == nil {
+		return time.Time{}, false, ErrFirstSeenNotTracked
+	}
+
+	// The Bloom filter is more selective than the buckets, rule out absent values with it first
+	if !db.CheckExists(value) {
+		return time.Time{}, false, nil
+	}
+
+	seen, ok := db.FirstSeen().Lookup([]byte(value))
+	return seen, ok, nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/metrics"
+	"gopds/hyperbloom/internal/requestid"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// dirtyKeys holds the keys inserted into since the last flush started, counted towards HB_FLUSH_BATCH_SIZE,
+// along with the ID of the last request inserting into each, empty outside of a request, see requestid.
+var dirtyKeys = struct {
+	sync.Mutex
+	keys map[string]string
+}{keys: map[string]string{}}
+
+// flushNow wakes the update goroutine to flush before the next tick once HB_FLUSH_BATCH_SIZE keys changed.
+// It carries the ID of the request that completed the batch, for the flush to log it.
+var flushNow = make(chan string, 1)
+
+// flushBatchGauge returns the gauge of the number of keys written by the last periodic flush.
+func flushBatchGauge() *expvar.Int {
+	return metrics.Int("flush_batch_keys")
+}
+
+// flushedKeysCounter returns the counter of the keys written by the periodic flushes.
+func flushedKeysCounter() *expvar.Int {
+	return metrics.Int("flushed_keys_total")
+}
+
+// markDirty records that key was inserted into, and has the update goroutine flush without waiting for the next
+// tick once HB_FLUSH_BATCH_SIZE distinct keys changed since the last flush. Repeated inserts into a key count once.
+// The ID of the request carried by ctx is recorded for the flush writing key to log it.
+func markDirty(ctx context.Context, key string) {
+	id := requestid.FromContext(ctx)
+
+	dirtyKeys.Lock()
+	dirtyKeys.keys[key] = id
+	size := config.HyperBloomCfg().FlushBatchSize
+	full := size > 0 && len(dirtyKeys.keys) >= size
+	dirtyKeys.Unlock()
+
+	// A pending wake-up already covers this one
+	if full {
+		select {
+		case flushNow <- id:
+		default:
+		}
+	}
+}
+
+// resetDirtyKeys starts counting the changed keys towards the next flush, and returns the IDs of the last requests
+// inserting into the keys counted so far.
+func resetDirtyKeys() map[string]string {
+	dirtyKeys.Lock()
+	defer dirtyKeys.Unlock()
+
+	requests := dirtyKeys.keys
+	dirtyKeys.keys = map[string]string{}
+	return requests
+}
+
+// keyContext returns ctx carrying the ID of the last request inserting into key out of requests, if any,
+// for the log lines of the flush writing key.
+func keyContext(ctx context.Context, requests map[string]string, key string) context.Context {
+	return requestid.NewContext(ctx, requests[key])
+}
+
+// bloomWriteBatch writes the HyperBloom instances to the database in a single transaction, giving up when ctx
+// expires, and returns the number written. When the transaction fails, e.g. on a value the database rejects,
+// every instance is written on its own instead, so that a single key can't hold the others back.
+// The log lines about a key carry the ID of the last request inserting into it out of requests.
+func bloomWriteBatch(ctx context.Context, blooms []*models.HyperBloom, requests map[string]string) int {
+	if len(blooms) == 0 {
+		return 0
+	}
+
+	written, err := bloomWriteTx(ctx, blooms, requests)
+	if err == nil || ctx.Err() != nil {
+		return written
+	}
+	slog.ErrorContext(ctx, "Can't flush in a single transaction, writing keys one by one", "keys", len(blooms), "err", err)
+
+	written = 0
+	for _, db := range blooms {
+		if ctx.Err() != nil {
+			break
+		}
+		if err = bloomUpdateContext(ctx, db); err != nil {
+			slog.ErrorContext(keyContext(ctx, requests, db.Key()), "Can't flush", "key", db.Key(), "err", err)
+			continue
+		}
+		written++
+		touchFlushHeartbeat() // Each key written is progress, however many keys there are
+	}
+
+	return written
+}
+
+// bloomWriteTx writes the HyperBloom instances in a single transaction and marks them flushed once it commits.
+// Deleted instances are skipped, see BloomDelete.
+func bloomWriteTx(ctx context.Context, blooms []*models.HyperBloom, requests map[string]string) (int, error) {
+	// Hold deletions off until the commit, a deleted instance must not be written back
+	deleteMutex.RLock()
+	defer deleteMutex.RUnlock()
+
+	tx, err := postgres.DbClient.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	written := []*models.HyperBloom{}
+	versions := []uint64{}
+	for _, db := range blooms {
+		if db.Deleted() {
+			continue
+		}
+
+		slog.DebugContext(keyContext(ctx, requests, db.Key()), "Sync Hyperbloom object with database", "key", db.Key())
+		version, err := bloomWrite(ctx, tx, db)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", db.Key(), err)
+		}
+		written = append(written, db)
+		versions = append(versions, version)
+		touchFlushHeartbeat() // Each key written is progress, however many keys there are
+	}
+
+	// Commit the database transaction
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for i, db := range written {
+		db.MarkFlushed(versions[i])
+	}
+	return len(written), nil
+}
@@ -0,0 +1,54 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/metrics"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestFlushBatchSize(t *testing.T) {
+	requireDatabase(t)
+
+	prefix := fmt.Sprint("flush-batch-", time.Now().UnixNano())
+	first, second := prefix+"-1", prefix+"-2"
+
+	saved := *config.HyperBloomCfg()
+	defer func() {
+		config.SetHyperBloomCfg(saved)
+		service.ApplyConfig()
+	}()
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) {
+		cfg.UpdateRate = time.Hour
+		cfg.FlushBatchSize = 2
+	})
+	service.ApplyConfig()
+	flushed := metrics.Int("flushed_keys_total").Value()
+
+	// Repeated inserts into a single key don't fill the batch
+	for i := 0; i < 10; i++ {
+		service.BloomHash(context.Background(), first, fmt.Sprint("value-", i))
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !slices.Contains(service.FlushBacklog(), first) {
+		t.Fatalf("%s flushed before the batch was full", first)
+	}
+
+	// A second changed key fills it, both are written without waiting for the tick
+	service.BloomHash(context.Background(), second, "value")
+	deadline := time.Now().Add(5 * time.Second)
+	for backlog := service.FlushBacklog(); slices.Contains(backlog, first) || slices.Contains(backlog, second); backlog = service.FlushBacklog() {
+		if time.Now().After(deadline) {
+			t.Fatalf("batch still in the backlog %v", backlog)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if written := metrics.Int("flushed_keys_total").Value() - flushed; written < 2 {
+		t.Errorf("flushed_keys_total grew by %d, want at least 2", written)
+	}
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"math"
+)
+
+// BloomCapacityAt returns how many distinct values a Bloom filter of m bits and k hash functions can hold
+// before its false positive rate reaches fpr, by inverting fpr = (1 - e^(-kn/m))^k for n.
+func BloomCapacityAt(m, k uint, fpr float64) uint64 {
+	return uint64(-float64(m) / float64(k) * math.Log(1-math.Pow(fpr, 1/float64(k))))
+}
+
+// BloomHeadroom estimates how many more distinct values can be inserted in the HyperBloom identified by key
+// before its false positive rate crosses target. It returns the current cardinality estimated from the fill
+// of the bit array, the capacity at target and the remaining headroom, or ErrKeyNotFound if the key doesn't exist.
+func BloomHeadroom(ctx context.Context, key string, target float64) (uint64, uint64, uint64, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return 0, 0, 0, ErrKeyNotFound
+	}
+
+	current := uint64(db.BloomCardinality())
+	capacity := BloomCapacityAt(db.Bloom().Cap(), db.Bloom().K(), target)
+
+	// The filter is already past the target
+	if current >= capacity {
+		return current, capacity, 0, nil
+	}
+
+	return current, capacity, capacity - current, nil
+}
@@ -0,0 +1,58 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomCapacityAt(t *testing.T) {
+	// Expected values computed from n = -m/k ln(1 - fpr^(1/k))
+	cases := []struct {
+		m        uint
+		k        uint
+		fpr      float64
+		capacity uint64
+	}{
+		{9586, 7, 0.01, 999},
+		{14377588, 10, 0.001, 999996},
+		{100237, 7, 0.0081, 9999},
+		{100237, 7, 0.05, 15108},
+	}
+
+	for _, c := range cases {
+		if capacity := service.BloomCapacityAt(c.m, c.k, c.fpr); capacity != c.capacity {
+			t.Errorf("BloomCapacityAt(%d, %d, %g) = %d, want %d", c.m, c.k, c.fpr, capacity, c.capacity)
+		}
+	}
+}
+
+func TestBloomHeadroom(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("headroom-", time.Now().UnixNano())
+	for i := 0; i < 100; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+
+	current, capacity, headroom, err := service.BloomHeadroom(context.Background(), key, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current+headroom != capacity {
+		t.Errorf("current (%d) + headroom (%d) != capacity (%d)", current, headroom, capacity)
+	}
+
+	// A target below the rate already reached leaves no headroom
+	if _, _, headroom, _ = service.BloomHeadroom(context.Background(), key, 1e-300); headroom != 0 {
+		t.Errorf("headroom at an unreachable target = %d, want 0", headroom)
+	}
+
+	if _, _, _, err = service.BloomHeadroom(context.Background(), key+"-missing", 0.01); err != service.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+package service
+
+import (
+	"context"
+
+	"gopds/hyperbloom/internal/database/postgres"
+)
+
+// Ready checks that the primary database answers within ctx, keys can't be loaded nor flushed otherwise.
+// In-memory keys keep being served meanwhile, so it doesn't affect liveness.
+func Ready(ctx context.Context) error {
+	return postgres.Ping(ctx)
+}
@@ -1,69 +1,128 @@
 package service
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
 	"gopds/hyperbloom/internal/config"
 	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/requestid"
 	"gopds/hyperbloom/pkg/models"
 
+	"github.com/bits-and-blooms/bitset"
 	"github.com/bits-and-blooms/bloom/v3"
 )
 
 // AsyncBloomUpdate starts a goroutine that periodically updates all HyperBloom instances in memory
 // at the specified interval (in milliseconds). The updates are performed asynchronously.
+// Updates also run as soon as HB_FLUSH_BATCH_SIZE keys changed, see markDirty.
+// Between updates, it samples the false positive rate of the in-memory keys every HB_ACCURACY_INTERVAL.
+// Updates are skipped while paused by PauseFlush, and run as soon as ResumeFlush is called.
+// A watchdog restarts the updates when they stall for HB_FLUSH_STALL_TIMEOUT, see superviseFlush.
 func AsyncBloomUpdate(ticker *time.Ticker, done chan bool) {
-	fmt.Println("AsyncBloomUpdate") // Print a message indicating the function has started
-	mutex := &sync.Mutex{}          // Initialize a new mutex for thread-safe operations
+	slog.Info("Starting AsyncBloomUpdate")
 	WG.Add(1)
-	// Start a new goroutine to handle the periodic updates
+	// Start a new goroutine to supervise the periodic updates
 	go func() {
 		defer WG.Done()
-		for {
-			// Loop indefinitely, executing at each tick of the ticker or done signal
-			select {
-			case <-done:
-				fmt.Println("Received signal to stop AsyncBloomUpdate")
-				return // Exit the goroutine when done signal is received
+		defer close(AsyncBloomUpdateDone) // Let the shutdown sequence know the final flush is over
+		superviseFlush(ticker, done)
+	}()
+}
+
+// flushLoop runs the periodic updates until done is closed, after the final flush, or until ctx is canceled
+// by the watchdog replacing it. It reports whether it stopped because of done.
+// Every write uses ctx, so a replaced loop recovering from a stall can't write anything anymore. The loops share
+// the ticks and wake-ups: a replaced loop receiving one exits at once, handing the wake-ups back to its replacement.
+func flushLoop(ctx context.Context, ticker *time.Ticker, done chan bool) bool {
+	mutex := &sync.Mutex{} // Initialize a new mutex for thread-safe operations
+	for {
+		// Loop indefinitely, executing at each tick of the ticker or done signal
+		select {
+		case <-ctx.Done():
+			return false // Replaced by the watchdog
+
+		case <-done:
+			// The replacement runs the final flush if this loop was replaced meanwhile
+			if ctx.Err() != nil {
+				return false
+			}
+			slog.Info("Received signal to stop AsyncBloomUpdate")
 
-			case <-ticker.C:
-				// Execute when the ticker ticks
+			// Flush every in-memory HyperBloom one last time, anything hashed since the last tick would be lost otherwise
+			flushCtx, cancel := context.WithCancel(shutdownCtx)
+			stop := context.AfterFunc(ctx, cancel)
+			mutex.Lock()
+			FlushAll(flushCtx)
+			mutex.Unlock()
+			stop()
+			cancel()
 
-				keysToPrune := []string{} // Initialize an empty slice to store keys that need pruning
+			return true // Exit the goroutine when done signal is received
 
-				// Lock the mutex for writing to ensure exclusive access to the dbs resource
-				mutex.Lock()
+		case <-ticker.C:
+			if ctx.Err() != nil {
+				return false
+			}
 
-				tx, _ := postgres.DbClient.Begin() // Begin a database transaction
+			// Keep every change in memory while the flush is paused, decayed keys included
+			if FlushPaused() {
+				flushBacklogGauge().Set(int64(len(FlushBacklog())))
+				touchFlushHeartbeat()
+				continue
+			}
 
-				// Iterate over all HyperBloom instances and update each one
-				currentTime := time.Now().UTC() // Get the current time in UTC
-				for _, db := range dbs.GetInMemoryHyperBlooms() {
-					fmt.Println("Sync Hyperbloom object with database", db.Key()) // Print a synchronization message
-					BloomUpdate(db, false)                                        // Update the HyperBloom instance
+			// Lock the mutex for writing to ensure exclusive access to the dbs resource
+			mutex.Lock()
+			flushInMemory(ctx)
+			mutex.Unlock()
 
-					// Check if the HyperBloom instance has decayed
-					if db.CheckDecayed(currentTime) {
-						keysToPrune = append(keysToPrune, db.Key()) // Add the key to prune list if decayed
-					}
+		case <-resumeFlush:
+			if ctx.Err() != nil {
+				select {
+				case resumeFlush <- struct{}{}:
+				default:
 				}
+				return false
+			}
 
-				tx.Commit() // Commit the database transaction
+			// Write the backlog accumulated while paused without waiting for the next tick
+			mutex.Lock()
+			flushInMemory(ctx)
+			mutex.Unlock()
 
-				// Remove decayed HyperBloom instances from memory
-				for _, key := range keysToPrune {
-					fmt.Println("Decay", key, "from in-memory Hyperblooms") // Print a message for each decayed instance
-					dbs.Remove(key)                                         // Remove the decayed instance from memory
+		case id := <-flushNow:
+			if ctx.Err() != nil {
+				select {
+				case flushNow <- id:
+				default:
 				}
+				return false
+			}
+
+			// Enough keys changed to flush before the next tick, unless the flush is paused
+			if FlushPaused() {
+				continue
+			}
+			mutex.Lock()
+			flushInMemory(requestid.NewContext(ctx, id)) // Log the ID of the request completing the batch
+			mutex.Unlock()
 
-				// Unlock the mutex after updates are done
-				mutex.Unlock()
+		case <-accuracyTicker.C:
+			if ctx.Err() != nil {
+				return false
 			}
+
+			// Sample the false positive rates between flushes, so the sampling never runs concurrently with itself
+			sampleAccuracy()
+			touchFlushHeartbeat()
 		}
-	}()
+	}
 }
 
 // BloomList retrieves all HyperBloom instances stored in memory
@@ -71,79 +130,249 @@ func BloomList() []*models.HyperBloom {
 	return dbs.GetHyperBlooms()
 }
 
-// BloomGet retrieves a HyperBloom instance by key.
-// It first attempts to get the HyperBloom from memory,
-// and if not found, it fetches it from the database.
-func BloomGet(key string) *models.HyperBloom {
-	// Attempt to get the HyperBloom from memory or fetch it from the database
-	db, err := dbs.GetOrFetchHyperBloom(key)
-
-	// If an error occurred (e.g., the HyperBloom couldn't be fetched from the database), return nil
+// BloomGet retrieves a HyperBloom instance by key. It first attempts to get the HyperBloom from memory,
+// and if not found, it fetches it from the database, giving up when ctx expires (e.g. the client disconnected),
+// in which case it returns nil like for a missing key.
+func BloomGet(ctx context.Context, key string) *models.HyperBloom {
+	// If an error occurred (e.g., the HyperBloom couldn't be fetched from the database or is quarantined), return nil
+	db, err := bloomLookup(ctx, key)
 	if err != nil {
 		return nil
 	}
 
+	// Return the retrieved HyperBloom instance
+	return db
+}
+
+// bloomLookup retrieves the HyperBloom identified by key from memory or the database without creating it,
+// refreshing its last used timestamp. It returns ErrKeyNotFound if the key doesn't exist, ErrKeyQuarantined
+// if its blobs can't be decoded, and the error of the database otherwise, e.g. once ctx expires.
+func bloomLookup(ctx context.Context, key string) (*models.HyperBloom, error) {
+	// Attempt to get the HyperBloom from memory or fetch it from the database
+	db, err := bloomFetch(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	// Refresh the HyperBloom instance to update its last used timestamp or any other necessary fields
 	db.Refresh()
 
-	// Return the retrieved HyperBloom instance
-	return db
+	return db, nil
 }
 
 // BloomHash adds a value to the Bloom filter and HyperLogLog sketch of the HyperBloom identified by key.
 // If the HyperBloom does not exist, it creates a new one.
-func BloomHash(key, value string) {
-	var err error
-	var db *models.HyperBloom
-
-	// Attempt to fetch or retrieve the HyperBloom for the given key
-	db, err = dbs.GetOrFetchHyperBloom(key)
-
-	// If there's an error (HyperBloom not found in memory or database)
+// It returns an error if the persisted HyperBloom can't be loaded (e.g. written in an unsupported format,
+// or ctx expired before it was), creating a new one in its place would overwrite it on the next flush. Keys whose blobs can't be decoded
+// are quarantined and fail with ErrKeyQuarantined.
+func BloomHash(ctx context.Context, key, value string) error {
+	db, err := bloomGetOrCreate(ctx, key)
 	if err != nil {
-		// Create a new HyperBloom instance using default configuration
-		db = BloomCreate(
-			config.HyperBloomCfg.Cardinality,
-			config.HyperBloomCfg.FalsePositive,
-			key,
-		)
+		return err
 	}
 
 	// Hash the value using Bloom filter and HyperLogLog
 	db.Hash(value)
+	operationsCounter().Add(operationHash, 1)
 
 	// Add the HyperBloom instance into memory (or update if already exists)
 	dbs.Set(db, key)
+	markDirty(ctx, key)
+
+	return nil
+}
+
+// BloomHashBatch adds several values to the HyperBloom identified by key like BloomHash, updating the filters
+// and the cached cardinalities once for the whole batch. The key is written by the next flush as a single change.
+// It fails like BloomHash, hashing none of the values.
+func BloomHashBatch(ctx context.Context, key string, values []string) error {
+	db, err := bloomGetOrCreate(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	// Hash the values using Bloom filter and HyperLogLog
+	db.HashBatch(values)
+	operationsCounter().Add(operationHash, int64(len(values)))
+
+	// Add the HyperBloom instance into memory (or update if already exists)
+	dbs.Set(db, key)
+	markDirty(ctx, key)
+
+	return nil
+}
+
+// bloomGetOrCreate retrieves the HyperBloom identified by key, creating it with the default configuration
+// if it exists neither in memory nor in the database. Created instances are added to memory, so that concurrent
+// first inserts into a key all land in the same instance.
+func bloomGetOrCreate(ctx context.Context, key string) (*models.HyperBloom, error) {
+	// Attempt to fetch or retrieve the HyperBloom for the given key
+	db, err := bloomFetch(ctx, key)
+	if err == nil {
+		return db, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// Look again under the creation lock, another request may have created the key meanwhile
+	createMutex.Lock()
+	defer createMutex.Unlock()
+
+	db, err = bloomFetch(ctx, key)
+	if err == nil {
+		return db, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// Create a new HyperBloom instance using default configuration
+	cfg := config.HyperBloomCfg()
+	db, err = BloomCreate(ctx, cfg.Cardinality, cfg.FalsePositive, key, CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	dbs.Set(db, key)
+
+	return db, nil
+}
+
+// flushInMemory writes the in-memory HyperBloom instances changed since the last flush to the database in a single
+// transaction, see bloomWriteBatch, and removes the decayed ones from memory, as well as the least recently used ones
+// beyond the cache budget, see evictOverBudget. Any number of inserts into a key between two flushes results
+// in a single write. It stops early once ctx is canceled.
+func flushInMemory(ctx context.Context) {
+	// The changed keys of a loop replaced by the watchdog are left to its replacement
+	if ctx.Err() != nil {
+		return
+	}
+
+	keysToPrune := []string{} // Initialize an empty slice to store keys that need pruning
+	batch := []*models.HyperBloom{}
+
+	// Inserts racing with this flush count towards the next one
+	requests := resetDirtyKeys()
+
+	// Collect the changed HyperBloom instances and the decayed ones
+	currentTime := time.Now().UTC() // Get the current time in UTC
+	for _, db := range dbs.GetInMemoryHyperBlooms() {
+		if db.Dirty() {
+			batch = append(batch, db)
+		}
+
+		// Check if the HyperBloom instance has decayed
+		if db.CheckDecayed(currentTime) {
+			keysToPrune = append(keysToPrune, db.Key()) // Add the key to prune list if decayed
+		}
+	}
+
+	start := time.Now()
+	written := bloomWriteBatch(ctx, batch, requests)
+	flushBatchGauge().Set(int64(written))
+	flushedKeysCounter().Add(int64(written))
+	if len(batch) > 0 {
+		slog.InfoContext(ctx, "Flushed HyperBlooms", "keys", written, "changed", len(batch), "latency", time.Since(start))
+	}
+
+	// Stop as soon as the watchdog replaced this flush, the replacement writes the remaining keys
+	if ctx.Err() != nil {
+		return
+	}
+
+	// Remove decayed HyperBloom instances from memory
+	for _, key := range keysToPrune {
+		slog.Debug("Decay from in-memory Hyperblooms", "key", key)
+		dbs.Remove(key) // Remove the decayed instance from memory
+	}
+
+	// Evict the least recently used instances beyond HB_CACHE_MAX_KEYS and HB_CACHE_MAX_BYTES, now that most are flushed
+	evictOverBudget()
+
+	// Write the Count-Min Sketches and Cuckoo filters that changed along with the HyperBlooms
+	flushCountMins(ctx)
+	flushCuckoos(ctx)
+
+	// Keys that failed to flush remain in the backlog
+	flushBacklogGauge().Set(int64(len(FlushBacklog())))
+	touchFlushHeartbeat()
+}
+
+// BloomUpdate synchronizes the HyperBloom instance in memory with the database, in a transaction if doCommit is true.
+// It returns the error of the write, or of the commit.
+func BloomUpdate(db *models.HyperBloom, doCommit bool) error {
+	if doCommit {
+		_, err := bloomWriteTx(context.Background(), []*models.HyperBloom{db}, nil)
+		return err
+	}
+
+	return bloomUpdateContext(context.Background(), db)
+}
+
+// bloomUpdateContext writes the HyperBloom instance to the database, giving up when ctx expires.
+// Deleted instances are skipped, see BloomDelete.
+func bloomUpdateContext(ctx context.Context, db *models.HyperBloom) error {
+	// Hold deletions off while writing, a deleted instance must not be written back
+	deleteMutex.RLock()
+	defer deleteMutex.RUnlock()
+	if db.Deleted() {
+		return nil
+	}
+
+	version, err := bloomWrite(ctx, postgres.DbClient, db)
+	if err != nil {
+		return err
+	}
+
+	db.MarkFlushed(version)
+	return nil
+}
+
+// execer runs a statement on the database, or within a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 }
 
-// BloomUpdate synchronizes the HyperBloom instance in memory with the database.
-func BloomUpdate(db *models.HyperBloom, doCommit bool) {
+// bloomWrite inserts or updates the persisted filters of the HyperBloom instance through exec and returns the version
+// written, which the caller marks flushed once the write is durable. The caller holds deleteMutex.
+func bloomWrite(ctx context.Context, exec execer, db *models.HyperBloom) (uint64, error) {
 	// Define the SQL query to insert or update the bloom_filters table
 	query := `
-		INSERT INTO hyperblooms (key, bloombyte, hyperbyte)
-		VALUES ($1, $2, $3)
+		INSERT INTO hyperblooms (key, format_version, bloombyte, hyperbyte, firstseen, strictbyte, recencybyte, scalablebyte, countingbyte, topkbyte, cardinality, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
 		ON CONFLICT (key) DO UPDATE
-		SET bloombyte = EXCLUDED.bloombyte,
-			hyperbyte = EXCLUDED.hyperbyte;
+		SET format_version = EXCLUDED.format_version,
+			bloombyte = EXCLUDED.bloombyte,
+			hyperbyte = EXCLUDED.hyperbyte,
+			firstseen = EXCLUDED.firstseen,
+			strictbyte = EXCLUDED.strictbyte,
+			recencybyte = EXCLUDED.recencybyte,
+			scalablebyte = EXCLUDED.scalablebyte,
+			countingbyte = EXCLUDED.countingbyte,
+			topkbyte = EXCLUDED.topkbyte,
+			cardinality = EXCLUDED.cardinality,
+			updated_at = EXCLUDED.updated_at;
 	`
 
-	// Initialize a transaction if doCommit is true
-	var tx *sql.Tx
-	if doCommit {
-		tx, _ = postgres.DbClient.Begin() // Begin a transaction
-	}
+	// Read the version first, inserts applied while encoding are left for the next flush
+	version := db.Version()
 
-	// Encode the Bloom filter and HyperLogLog data into byte representations
-	bloomByterepr, _ := db.Bloom().GobEncode()     // Encode Bloom filter data
-	hyperByterepr, _ := db.Hyper().MarshalBinary() // Marshal HyperLogLog data
+	// Encode the Bloom filter, HyperLogLog and the optional structures in the current format
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		return 0, fmt.Errorf("can't encode: %w", err)
+	}
 
 	// Execute the SQL query to insert or update the record
-	postgres.DbClient.Exec(query, db.Key(), bloomByterepr, hyperByterepr)
-
-	// Commit the transaction if doCommit is true
-	if doCommit {
-		tx.Commit() // Commit the transaction
+	_, err = exec.ExecContext(ctx, query, db.Key(), models.FormatVersion, blobs.Bloom, blobs.Hyper, blobs.FirstSeen, blobs.Strict, blobs.Recency, blobs.Scalable, blobs.Counting, blobs.TopK, db.HyperCardinality())
+	if err != nil {
+		return 0, err
 	}
+
+	return version, nil
 }
 
 // BloomDecay removes a HyperBloom instance from memory if it has decayed (i.e., last used timestamp exceeds decay duration).
@@ -156,12 +385,32 @@ func BloomDecay(key string) {
 }
 
 // BloomExists checks if a value exists in the Bloom filter of the HyperBloom identified by key.
-func BloomExists(key, value string) bool {
-	db := BloomGet(key)
-	if db != nil {
-		return db.CheckExists(value)
+// It returns ErrKeyNotFound if the key doesn't exist, rather than reporting the value absent, and fails
+// like bloomLookup otherwise.
+func BloomExists(ctx context.Context, key, value string) (bool, error) {
+	operationsCounter().Add(operationExists, 1)
+	db, err := bloomLookup(ctx, key)
+	if err != nil {
+		return false, err
 	}
-	return false
+	return db.CheckExists(value), nil
+}
+
+// BloomExistsBatch checks several values against the HyperBloom identified by key like BloomExists, loading it
+// once for the whole batch. The results are in the order of values. It fails like BloomExists, checking none
+// of the values.
+func BloomExistsBatch(ctx context.Context, key string, values []string) ([]bool, error) {
+	operationsCounter().Add(operationExists, int64(len(values)))
+	db, err := bloomLookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make([]bool, len(values))
+	for i, value := range values {
+		exists[i] = db.CheckExists(value)
+	}
+	return exists, nil
 }
 
 // AllBoolList checks if all elements in boolList are equal.
@@ -190,15 +439,32 @@ func AnyBoolList(boolList []bool) bool {
 	return false
 }
 
-// BloomChainingExists checks existence of a value in Bloom filters associated with given keys.
-func BloomChainingExists(keys []string, value string, operator string) bool {
+// OddBoolList checks if an odd number of elements in boolList are equal to true.
+func OddBoolList(boolList []bool) bool {
+	odd := false
+	for _, b := range boolList {
+		odd = odd != b
+	}
+	return odd
+}
+
+// BloomChainingExists checks existence of a value in Bloom filters associated with given keys, combining the
+// membership in every key with operator, HB_DEFAULT_OPERATOR when omitted. A key that doesn't exist doesn't
+// contain the value. It returns ErrInvalidOperator for an unknown operator.
+func BloomChainingExists(ctx context.Context, keys []string, value string, operator Operator) (bool, error) {
+	// An omitted operator falls back to HB_DEFAULT_OPERATOR
+	operator, err := ResolveOperator(string(operator))
+	if err != nil {
+		return false, err
+	}
+
 	// Initialize an empty boolean slice to store results for each key
 	boolList := []bool{}
 
 	// Iterate through each key
 	for _, key := range keys {
 		// Retrieve Bloom filter for the key
-		db := BloomGet(key)
+		db := BloomGet(ctx, key)
 		_bool := false
 
 		// If Bloom filter exists for the key, check if value exists in it
@@ -211,147 +477,303 @@ func BloomChainingExists(keys []string, value string, operator string) bool {
 	}
 
 	// Determine the final result based on the specified operator
-	if operator == "AND" {
+	switch operator {
+	case OperatorAnd:
 		// Return true if all elements in boolList are true
-		return AllBoolList(boolList)
-	} else if operator == "OR" {
+		return AllBoolList(boolList), nil
+	case OperatorOr:
 		// Return true if any element in boolList is true
-		return AnyBoolList(boolList)
-	} else {
-		// Default case: return false if operator is neither "AND" nor "OR"
-		return false
+		return AnyBoolList(boolList), nil
+	default:
+		// Return true if an odd number of elements in boolList are true
+		return OddBoolList(boolList), nil
 	}
 }
 
-// BloomBitwiseExists checks the existence of a value in Bloom filters associated with given keys using bitwise operations.
-func BloomBitwiseExists(keys []string, value string, operator string) bool {
-	// Check if there are keys provided
-	if len(keys) < 1 {
-		return false
-	}
-
-	// Get the Bloom filter for the first key
-	db := BloomGet(keys[0])
-	if db == nil {
-		return false
+// BloomBitwiseExists checks the existence of a value in Bloom filters associated with given keys using bitwise
+// operations: the bit arrays of the keys are combined with operator, HB_DEFAULT_OPERATOR when omitted, and the value
+// is tested against the result. With XOR, the bits the value shares with values of other keys cancel out, so the
+// result may differ from BloomChainingExists. It returns ErrInvalidOperator for an unknown operator, and
+// ErrIncompatibleFilters if the filters differ in size or number of hash functions.
+func BloomBitwiseExists(ctx context.Context, keys []string, value string, operator Operator) (bool, error) {
+	// An omitted operator falls back to HB_DEFAULT_OPERATOR
+	operator, err := ResolveOperator(string(operator))
+	if err != nil {
+		return false, err
 	}
 
-	// Get the BitSet of the Bloom filter for the first key
-	bs := db.Bloom().BitSet()
+	// The combined bit array, and the size and number of hash functions of the first existing filter
+	var (
+		bs    *bitset.BitSet
+		first *models.HyperBloom
+	)
 
-	// Iterate through the rest of the keys
-	for i := 1; i < len(keys); i++ {
+	for _, key := range keys {
 		// Get the Bloom filter for the current key
-		db = BloomGet(keys[i])
-		switch operator {
-		case "AND":
-			// If the Bloom filter does not exist, return false for AND operation
-			if db == nil {
-				return false
+		db := BloomGet(ctx, key)
+		if db == nil {
+			// A missing key fails AND, and flips no bit under OR and XOR
+			if operator == OperatorAnd {
+				return false, nil
 			}
+			continue
+		}
+
+		// The first existing filter sets the size of the result, the others must match it bit for bit
+		if first == nil {
+			first, bs = db, db.BitSet()
+			continue
+		}
+		bf, want := db.Bloom(), first.Bloom()
+		if bf.Cap() != want.Cap() || bf.K() != want.K() {
+			return false, fmt.Errorf("%w: %s has %d bits and %d hashes, %s has %d bits and %d hashes",
+				ErrIncompatibleFilters, first.Key(), want.Cap(), want.K(), key, bf.Cap(), bf.K())
+		}
+
+		switch operator {
+		case OperatorAnd:
 			// Perform bitwise AND operation with the BitSet of the current Bloom filter
 			bs = bs.Intersection(db.BitSet())
-		case "OR":
-			// If the Bloom filter does not exist, skip to the next key for OR operation
-			if db == nil {
-				continue
-			}
+		case OperatorOr:
 			// Perform bitwise OR operation with the BitSet of the current Bloom filter
 			bs = bs.Union(db.BitSet())
+		case OperatorXor:
+			// Perform bitwise XOR operation with the BitSet of the current Bloom filter
+			bs = bs.SymmetricDifference(db.BitSet())
 		}
 	}
 
+	// No key exists, the value is in none of them
+	if first == nil {
+		return false, nil
+	}
+
 	// Create a new Bloom filter using the resulting BitSet
 	b := bloom.FromWithM(
 		bs.Bytes(),
-		db.Bloom().Cap(),
-		db.Bloom().K(),
+		first.Bloom().Cap(),
+		first.Bloom().K(),
 	)
 
 	// Test if the value exists in the new Bloom filter
-	return b.TestString(value)
+	return b.TestString(value), nil
 }
 
 // BloomCardinality returns the cardinality of the Bloom filter and HyperLogLog sketch of the HyperBloom identified by key.
-func BloomCardinality(key string) (uint32, uint64) {
-	db := BloomGet(key)
-	if db != nil {
-		bCard := db.BloomCardinality()
-		hCard := db.HyperCardinality()
+// It returns ErrKeyNotFound if the key doesn't exist, rather than a zero cardinality, and fails like bloomLookup otherwise.
+func BloomCardinality(ctx context.Context, key string) (uint32, uint64, error) {
+	operationsCounter().Add(operationCardinality, 1)
+	db, err := bloomLookup(ctx, key)
+	if err != nil {
+		return 0, 0, err
+	}
+	return db.BloomCardinality(), db.HyperCardinality(), nil
+}
 
-		return bCard, hCard
+// BloomUnionCardinality returns the estimated number of distinct values across the HyperLogLog sketches
+// of the HyperBlooms identified by keys. Keys that don't exist are skipped. It returns ErrIncompatibleSketch
+// if the sketches don't share the same precision.
+func BloomUnionCardinality(ctx context.Context, keys []string) (uint64, error) {
+	blooms := []*models.HyperBloom{}
+	for _, key := range keys {
+		if db := BloomGet(ctx, key); db != nil {
+			blooms = append(blooms, db)
+		}
 	}
-	return 0, 0
+
+	return unionCardinality(blooms)
 }
 
-// BloomSimilarity calculates the Jaccard similarity between two Bloom filters identified by key1 and key2.
-// It returns a float32 value representing the similarity score.
-func BloomSimilarity(key1, key2 string) float32 {
-	// Retrieve Bloom filter for key1
-	db1 := BloomGet(key1)
-	// If Bloom filter for key1 is not found, return similarity score of 0.0
-	if db1 == nil {
-		return 0.0
+// HLLUnionCardinality returns the estimated number of distinct values across the HyperLogLog sketches
+// of the HyperBlooms identified by keys, by merging their registers. Unlike BloomUnionCardinality, every key
+// must exist: it returns ErrKeyNotFound naming the first missing one, and ErrIncompatibleSketch if the sketches
+// don't share the same precision.
+func HLLUnionCardinality(ctx context.Context, keys []string) (uint64, error) {
+	blooms := make([]*models.HyperBloom, 0, len(keys))
+	for _, key := range keys {
+		db := BloomGet(ctx, key)
+		if db == nil {
+			return 0, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+		}
+		blooms = append(blooms, db)
 	}
 
-	// Retrieve Bloom filter for key2
-	db2 := BloomGet(key2)
-	// If Bloom filter for key2 is not found, return similarity score of 0.0
-	if db2 == nil {
-		return 0.0
+	return unionCardinality(blooms)
+}
+
+// unionCardinality merges the HyperLogLog sketches of blooms into a copy of the first one and returns its estimate,
+// 0 if there is none. Sketches of different precisions can't be merged, it returns ErrIncompatibleSketch naming
+// the first key whose precision differs from the first one's.
+func unionCardinality(blooms []*models.HyperBloom) (uint64, error) {
+	if len(blooms) == 0 {
+		return 0, nil
 	}
 
-	// Calculate Jaccard similarity between db1 and db2 using models.JaccardSimBF function
-	return models.JaccardSimBF(db1, db2)
+	first := blooms[0]
+	precision := first.HyperPrecision()
+	union := first.Hyper()
+	for _, db := range blooms[1:] {
+		if other := db.HyperPrecision(); other != precision {
+			return 0, fmt.Errorf("%w: %s has precision %d, %s has %d", ErrIncompatibleSketch, first.Key(), precision, db.Key(), other)
+		}
+		if err := union.Merge(db.Hyper()); err != nil {
+			return 0, fmt.Errorf("%w: %s: %v", ErrIncompatibleSketch, db.Key(), err)
+		}
+	}
+
+	return union.Estimate(), nil
+}
+
+// BloomKeys returns the keys of all HyperBlooms, whether persisted in the database or only held in memory.
+func BloomKeys(ctx context.Context) ([]string, error) {
+	rows, err := postgres.Default.Replica().QueryContext(ctx, `SELECT key FROM hyperblooms`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect the persisted keys
+	seen := map[string]bool{}
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Add the keys that haven't reached the database (or the replica) yet
+	for _, key := range dbs.GetInMemoryHyperBloomKeys() {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// BloomSimilarity calculates the Jaccard similarity between two Bloom filters identified by key1 and key2.
+// It returns a float32 value representing the similarity score, 0 if one of the keys doesn't exist,
+// and ErrIncompatibleFilters if the filters differ in size or number of hash functions, see BloomSimilarityOnMismatch.
+func BloomSimilarity(ctx context.Context, key1, key2 string) (float32, error) {
+	sim, _, err := BloomSimilarityOnMismatch(ctx, key1, key2, OnMismatchError)
+	return sim, err
 }
 
 // BloomCreate creates a new HyperBloom instance with specified parameters and stores it in the database.
-func BloomCreate(capacity uint, falsePositive float64, key string) *models.HyperBloom {
+// The optional parameters in opts are validated by BloomCreateKey, see CreateOptions.
+// It returns ErrInvalidPrecision if opts.Precision isn't supported, or an error if either row can't be inserted,
+// and stores nothing then.
+func BloomCreate(ctx context.Context, capacity uint, falsePositive float64, key string, opts CreateOptions) (*models.HyperBloom, error) {
 	// Create a new HyperBloom instance using provided parameters
 	db := models.NewHyperBloomFromParams(capacity, falsePositive, key)
+	if opts.Hashes > 0 {
+		// Keep the bit array sized for the capacity, only the number of hash functions changes
+		m, _ := bloom.EstimateParameters(capacity, falsePositive)
+		db = models.NewHyperBloomFromSize(m, opts.Hashes, key)
+	}
+	if opts.Strict {
+		db.EnableStrict()
+	}
+	if opts.Counting {
+		db.EnableCounting()
+	}
+	if opts.HalfLife > 0 {
+		db.EnableRecency(opts.HalfLife)
+	}
+	if opts.Scalable != nil {
+		db.EnableScalable(capacity, falsePositive, opts.Scalable.Growth, opts.Scalable.Tightening)
+	}
+	if opts.Precision != 0 {
+		if err := db.SetHyperPrecision(opts.Precision); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPrecision, err)
+		}
+	}
+	if opts.TTL > 0 {
+		db.SetExpiry(db.CreatedAt().Add(opts.TTL))
+	}
 
-	// Serialize the Bloom filter and HyperLogLog data structures to bytes
-	bloomByterepr, _ := db.Bloom().GobEncode()     // Serialize Bloom filter
-	hyperByterepr, _ := db.Hyper().MarshalBinary() // Serialize HyperLogLog
+	// Serialize the Bloom filter, HyperLogLog and the optional structures in the current format
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		return nil, fmt.Errorf("can't encode: %w", err)
+	}
 
 	// Begin a database transaction
-	tx, _ := postgres.DbClient.Begin()
+	tx, err := postgres.DbClient.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
 	// Insert the serialized data into the hyperblooms table
-	postgres.DbClient.Exec(
+	_, err = tx.ExecContext(ctx,
 		`INSERT INTO hyperblooms (
 			key, 
+			format_version,
 			bloombyte, 
-			hyperbyte
+			hyperbyte,
+			firstseen,
+			strictbyte,
+			recencybyte,
+			scalablebyte,
+			countingbyte,
+			topkbyte,
+			cardinality,
+			updated_at
 		) 
-		VALUES ($1, $2, $3)`,
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 0, NOW())`,
 		key,
-		bloomByterepr,
-		hyperByterepr,
+		models.FormatVersion,
+		blobs.Bloom,
+		blobs.Hyper,
+		blobs.FirstSeen,
+		blobs.Strict,
+		blobs.Recency,
+		blobs.Scalable,
+		blobs.Counting,
+		blobs.TopK,
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	// Insert metadata about the HyperBloom instance into the hyperblooms_metadata table
-	postgres.DbClient.Exec(
+	_, err = tx.ExecContext(ctx,
 		`INSERT INTO hyperblooms_metadata (
 			key, 
 			max_cardinality, 
 			false_positive, 
 			bit_capacity, 
 			no_hash_func, 
-			decay_sec
+			decay_sec,
+			created_at,
+			expires_at
 		) 
-		VALUES ($1, $2, $3, $4, $5, $6)`,
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
 		key,
 		capacity,
 		falsePositive,
 		db.Bloom().Cap(),
 		db.Bloom().K(),
 		db.Decay(),
+		db.CreatedAt(),
+		sql.NullTime{Time: db.ExpiresAt(), Valid: !db.ExpiresAt().IsZero()},
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	// Commit the database transaction
-	tx.Commit()
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	// Return the created HyperBloom instance
-	return db
+	return db, nil
 }
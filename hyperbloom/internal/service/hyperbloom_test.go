@@ -1,13 +1,21 @@
 package service_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"gopds/hyperbloom/internal/service"
 )
 
 func TestCreateBloom(t *testing.T) {
+	requireDatabase(t)
+
 	// Define some initial IDs for testing
 	ids := map[string][]string{
 		"123": {"123", "456", "789"},
@@ -22,9 +30,9 @@ func TestCreateBloom(t *testing.T) {
 		// Iterate over each ID associated with the current key
 		for _, id := range ids[key] {
 			// Hash the 'id' using the 'key'
-			service.BloomHash(key, id)
+			service.BloomHash(context.Background(), key, id)
 			// Print the approximate size of the bloom filter for the current 'key'
-			outStr := fmt.Sprintf("New appr. size (%s) = %d", key, service.BloomGet(key).BloomCardinality())
+			outStr := fmt.Sprintf("New appr. size (%s) = %d", key, service.BloomGet(context.Background(), key).BloomCardinality())
 			fmt.Println(outStr)
 		}
 	}
@@ -38,7 +46,7 @@ func TestCreateBloom(t *testing.T) {
 	// Iterate over each key in 'ids' again
 	for key := range ids {
 		// Retrieve the bloom filter for the current 'key'
-		b := service.BloomGet(key)
+		b := service.BloomGet(context.Background(), key)
 		// Check each merged ID against the current bloom filter
 		for _, id := range mergedIds {
 			fmt.Printf("(%s) ⪽ (%s) = %t\n", id, key, b.CheckExists(id))
@@ -52,3 +60,219 @@ func TestCreateBloom(t *testing.T) {
 	// Print the number of keys in the bloom filter list after hashing
 	fmt.Println("Num of keys after hashing:", len(service.BloomList()))
 }
+
+func TestBloomHashBatch(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	batch := fmt.Sprint("batch-", suffix)
+	single := fmt.Sprint("batch-single-", suffix)
+
+	values := make([]string, 1000)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+		if err := service.BloomHash(context.Background(), single, values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Hold the flush so the batch is still waiting for it when checked
+	service.PauseFlush()
+	defer service.ResumeFlush()
+	if err := service.BloomHashBatch(context.Background(), batch, values); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch reports the cardinalities of one insert per value
+	bCard, hCard := bloomCardinality(t, context.Background(), batch)
+	wantB, wantH := bloomCardinality(t, context.Background(), single)
+	if bCard != wantB || hCard != wantH {
+		t.Errorf("cardinalities = (%d, %d), want (%d, %d)", bCard, hCard, wantB, wantH)
+	}
+	for _, value := range values {
+		if !bloomExists(t, context.Background(), batch, value) {
+			t.Fatalf("%s missing after the batch", value)
+		}
+	}
+
+	// The batch is a single change waiting for the flush
+	if !slices.Contains(service.FlushBacklog(), batch) {
+		t.Errorf("%s missing from the backlog %v", batch, service.FlushBacklog())
+	}
+}
+
+// TestBloomHashConcurrent hammers a single key with inserts and lookups from many goroutines while it is flushed.
+// Run with -race to catch unsynchronized accesses to its filter and sketch.
+func TestBloomHashConcurrent(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("concurrent-", time.Now().UnixNano())
+	sequential := key + "-sequential"
+
+	const writers, perWriter = 8, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := service.BloomHash(context.Background(), key, strconv.Itoa(w*perWriter+i)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter/10; i++ {
+				service.BloomExists(context.Background(), key, strconv.Itoa(i))
+				service.BloomCardinality(context.Background(), key)
+				service.FlushAll(context.Background())
+			}
+		}()
+	}
+	wg.Wait()
+
+	// No insert was lost, in memory or in the database
+	service.FlushAll(context.Background())
+	for i := 0; i < writers*perWriter; i++ {
+		if err := service.BloomHash(context.Background(), sequential, strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+		if !bloomExists(t, context.Background(), key, strconv.Itoa(i)) {
+			t.Fatalf("value %d missing after concurrent inserts", i)
+		}
+	}
+	bCard, hCard := bloomCardinality(t, context.Background(), key)
+	wantB, wantH := bloomCardinality(t, context.Background(), sequential)
+	if bCard != wantB || hCard != wantH {
+		t.Errorf("cardinalities = (%d, %d), want (%d, %d) as inserted sequentially", bCard, hCard, wantB, wantH)
+	}
+}
+
+func TestBloomHashFirstInsertConcurrent(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("first-insert-", time.Now().UnixNano())
+
+	// Every writer creates the key with its first insert at once
+	const writers = 32
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if err := service.BloomHash(context.Background(), key, strconv.Itoa(w)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// A single instance was created, holding every insert
+	service.FlushAll(context.Background())
+	for w := 0; w < writers; w++ {
+		if !bloomExists(t, context.Background(), key, strconv.Itoa(w)) {
+			t.Errorf("value %d lost to a concurrent creation", w)
+		}
+	}
+	if _, hCard := bloomCardinality(t, context.Background(), key); hCard != writers {
+		t.Errorf("hyperloglog cardinality = %d, want %d", hCard, writers)
+	}
+}
+
+func TestBloomExistsBatch(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("exists-batch-", time.Now().UnixNano())
+	if err := service.BloomHashBatch(context.Background(), key, []string{"a", "b", "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The results follow the order of the values, and agree with the single checks
+	values := []string{"c", "missing", "a", "b", "also missing"}
+	exists, err := service.BloomExistsBatch(context.Background(), key, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exists) != len(values) {
+		t.Fatalf("%d results for %d values", len(exists), len(values))
+	}
+	for i, value := range values {
+		if want := bloomExists(t, context.Background(), key, value); exists[i] != want {
+			t.Errorf("%s exists = %t, want %t", value, exists[i], want)
+		}
+	}
+	if !exists[0] || !exists[2] || !exists[3] {
+		t.Errorf("inserted values reported absent: %v", exists)
+	}
+
+	if _, err = service.BloomExistsBatch(context.Background(), key+"-missing", values); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("missing key: %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestBloomHashCanceled(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("canceled-", time.Now().UnixNano())
+
+	// A request that went away can't load the key, nor create it in place of the one it couldn't load
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := service.BloomHash(ctx, key, "value"); !errors.Is(err, context.Canceled) {
+		t.Errorf("hash with a canceled context: %v, want context.Canceled", err)
+	}
+	if _, err := service.BloomExists(ctx, key, "value"); !errors.Is(err, context.Canceled) {
+		t.Errorf("exists with a canceled context: %v, want context.Canceled", err)
+	}
+	if db := service.BloomGet(context.Background(), key); db != nil {
+		t.Errorf("%s created by a canceled hash", key)
+	}
+
+	// Keys already in memory don't need the database
+	if err := service.BloomHash(context.Background(), key, "value"); err != nil {
+		t.Fatal(err)
+	}
+	if !bloomExists(t, ctx, key, "value") {
+		t.Error("value missing from a key in memory")
+	}
+}
+
+func TestHLLUnionCardinality(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	first := fmt.Sprint("union-first-", suffix)
+	second := fmt.Sprint("union-second-", suffix)
+
+	// 1000 values each, 500 of them shared
+	for i := 0; i < 1000; i++ {
+		service.BloomHash(context.Background(), first, strconv.Itoa(i))
+		service.BloomHash(context.Background(), second, strconv.Itoa(i+500))
+	}
+
+	union, err := service.HLLUnionCardinality(context.Background(), []string{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if union < 1400 || union > 1600 {
+		t.Errorf("union cardinality = %d, want about 1500", union)
+	}
+	if lenient, err := service.BloomUnionCardinality(context.Background(), []string{first, second}); err != nil || union != lenient {
+		t.Errorf("union cardinality = %d, BloomUnionCardinality = (%d, %v)", union, lenient, err)
+	}
+
+	// The merge leaves the sketches of the keys untouched
+	if _, hCard := bloomCardinality(t, context.Background(), first); hCard > 1100 {
+		t.Errorf("cardinality of %s = %d after the union", first, hCard)
+	}
+
+	// Every key must exist
+	if _, err = service.HLLUnionCardinality(context.Background(), []string{first, second + "-missing"}); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("missing key: %v, want ErrKeyNotFound", err)
+	}
+}
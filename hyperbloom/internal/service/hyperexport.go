@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/axiomhq/hyperloglog"
+)
+
+// ErrInvalidSketch is returned when importing a HyperLogLog sketch that can't be decoded.
+var ErrInvalidSketch = errors.New("invalid sketch")
+
+// ErrIncompatibleSketch is returned when importing a HyperLogLog sketch whose precision differs from the key's.
+var ErrIncompatibleSketch = errors.New("incompatible sketch")
+
+// HyperExport is the HyperLogLog sketch of a key without its Bloom filters, for consumers only needing distinct counts.
+type HyperExport struct {
+	Key         string `json:"key"`         // Key of the HyperBloom
+	Precision   uint8  `json:"precision"`   // Number of bits used for register indexes
+	Cardinality uint64 `json:"cardinality"` // Estimated cardinality of the sketch, as exported
+	Sketch      []byte `json:"sketch"`      // Binary encoding of the sketch, base64-encoded in JSON
+}
+
+// sketchPrecision returns the precision of a sketch from its binary encoding, which starts with a version byte
+// followed by the precision.
+func sketchPrecision(encoded []byte) uint8 {
+	if len(encoded) < 2 {
+		return 0
+	}
+	return encoded[1]
+}
+
+// BloomExportHyper calls emit with the HyperLogLog sketch of every key, in the order of BloomKeys, and returns
+// the number of keys exported. Keys not in memory are loaded from the database. It stops at the first error
+// returned by emit, e.g. when the client goes away.
+func BloomExportHyper(ctx context.Context, emit func(HyperExport) error) (int, error) {
+	keys, err := BloomKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	for _, key := range keys {
+		// Keys deleted or quarantined since they were listed are skipped
+		db := BloomGet(ctx, key)
+		if db == nil {
+			continue
+		}
+
+		encoded, err := db.Hyper().MarshalBinary()
+		if err != nil {
+			return exported, fmt.Errorf("can't encode %s: %w", key, err)
+		}
+		err = emit(HyperExport{
+			Key:         key,
+			Precision:   sketchPrecision(encoded),
+			Cardinality: db.HyperCardinality(),
+			Sketch:      encoded,
+		})
+		if err != nil {
+			return exported, err
+		}
+		exported++
+	}
+
+	return exported, nil
+}
+
+// BloomImportHyper merges an exported HyperLogLog sketch into the sketch of its key, creating the key with
+// the default configuration if it doesn't exist. The Bloom filters are left untouched, so the imported values
+// are counted but not reported as members. Merging keeps the highest registers, importing the same sketch twice
+// leaves the counts unchanged. It returns ErrInvalidSketch if the sketch can't be decoded and
+// ErrIncompatibleSketch if its precision differs from the key's.
+func BloomImportHyper(ctx context.Context, export HyperExport) error {
+	if export.Key == "" {
+		return fmt.Errorf("%w: missing key", ErrInvalidSketch)
+	}
+
+	// Decode the sketch, the precision it was exported with must match its encoding
+	sketch := &hyperloglog.Sketch{}
+	if err := sketch.UnmarshalBinary(export.Sketch); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidSketch, export.Key, err)
+	}
+	if export.Precision != 0 && export.Precision != sketchPrecision(export.Sketch) {
+		return fmt.Errorf("%w: %s: precision %d, encoded with %d", ErrInvalidSketch, export.Key, export.Precision, sketchPrecision(export.Sketch))
+	}
+
+	db, err := bloomGetOrCreate(ctx, export.Key)
+	if err != nil {
+		return err
+	}
+
+	// Sketches of different precisions can't be merged
+	if precision := db.HyperPrecision(); precision != sketchPrecision(export.Sketch) {
+		return fmt.Errorf("%w: %s has precision %d, got %d", ErrIncompatibleSketch, export.Key, precision, sketchPrecision(export.Sketch))
+	}
+	if err = db.MergeHyper(sketch); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrIncompatibleSketch, export.Key, err)
+	}
+
+	// Add the HyperBloom instance into memory (or update if already exists)
+	dbs.Set(db, export.Key)
+	markDirty(ctx, export.Key)
+
+	return nil
+}
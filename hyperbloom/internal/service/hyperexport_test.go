@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+
+	"github.com/axiomhq/hyperloglog"
+)
+
+func TestBloomExportImportHyper(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	small := fmt.Sprint("export-small-", suffix)
+	large := fmt.Sprint("export-large-", suffix)
+
+	for key, values := range map[string]int{small: 100, large: 5000} {
+		for i := 0; i < values; i++ {
+			service.BloomHash(context.Background(), key, strconv.Itoa(i))
+		}
+	}
+
+	// Other tests leave keys behind, only these two are checked
+	exports := map[string]service.HyperExport{}
+	count, err := service.BloomExportHyper(context.Background(), func(export service.HyperExport) error {
+		if export.Key == small || export.Key == large {
+			exports[export.Key] = export
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count < 2 || len(exports) != 2 {
+		t.Fatalf("exported %d keys including %d of 2", count, len(exports))
+	}
+
+	// The exported sketches reconstruct the cardinalities of the keys
+	for _, key := range []string{small, large} {
+		export := exports[key]
+		sketch := &hyperloglog.Sketch{}
+		if err = sketch.UnmarshalBinary(export.Sketch); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		_, hCard := bloomCardinality(t, context.Background(), key)
+		if sketch.Estimate() != hCard || export.Cardinality != hCard {
+			t.Errorf("%s: exported sketch estimates %d (reported %d), key estimates %d", key, sketch.Estimate(), export.Cardinality, hCard)
+		}
+		if export.Precision == 0 {
+			t.Errorf("%s: precision missing", key)
+		}
+	}
+
+	// Importing under a new key reconstructs the same cardinality, importing twice changes nothing
+	imported := fmt.Sprint("export-imported-", suffix)
+	export := exports[large]
+	export.Key = imported
+	for i := 0; i < 2; i++ {
+		if err = service.BloomImportHyper(context.Background(), export); err != nil {
+			t.Fatal(err)
+		}
+		if _, hCard := bloomCardinality(t, context.Background(), imported); hCard != export.Cardinality {
+			t.Errorf("import %d: cardinality = %d, want %d", i+1, hCard, export.Cardinality)
+		}
+	}
+
+	// Importing into an existing key counts the values of both
+	export = exports[large]
+	export.Key = small
+	if err = service.BloomImportHyper(context.Background(), export); err != nil {
+		t.Fatal(err)
+	}
+	if _, hCard := bloomCardinality(t, context.Background(), small); hCard != export.Cardinality {
+		t.Errorf("merged cardinality = %d, want %d (the small key's values are a subset)", hCard, export.Cardinality)
+	}
+
+	// Sketches that aren't sketches are rejected
+	if err = service.BloomImportHyper(context.Background(), service.HyperExport{Key: imported, Sketch: []byte("garbage")}); !errors.Is(err, service.ErrInvalidSketch) {
+		t.Errorf("garbage sketch: %v, want ErrInvalidSketch", err)
+	}
+}
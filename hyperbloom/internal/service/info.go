@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// exactSetEntryOverhead approximates the per-entry cost of a map[string]struct{} on top of the value bytes:
+// the 16-byte string header plus the bucket slot, top hash and load factor slack of the map.
+const exactSetEntryOverhead = 48
+
+// HyperBloomInfo describes the configuration, content and memory footprint of a HyperBloom.
+type HyperBloomInfo struct {
+	Key              string    // Key of the HyperBloom
+	BitCapacity      uint      // Size of the Bloom filter bit array (m)
+	HashFunctions    uint      // Number of hash functions of the Bloom filter (k)
+	Strict           bool      // Whether membership is checked against two independent filters
+	Counting         bool      // Whether values can be removed, see BloomRemove
+	TopKCapacity     int       // Number of most frequent values tracked, see BloomTopK, 0 if not tracked
+	Empty            bool      // Whether no value was ever inserted
+	CreatedAt        time.Time // Creation time, zero for keys created before creation times were recorded
+	BloomCardinality uint32    // Estimated cardinality from the Bloom filter
+	HyperCardinality uint64    // Estimated cardinality from the HyperLogLog sketch
+	MemoryBytes      uint64    // Memory used by the bit array and the sketch
+	AvgValueLength   float64   // Average length of the inserted values, 0 when not tracked
+	ExactSetBytes    uint64    // Estimated memory an exact set of the same cardinality would use, 0 when not tracked
+	SavingsRatio     float64   // ExactSetBytes / MemoryBytes, 0 when not tracked
+}
+
+// CardinalityState is the cardinality of a HyperBloom along with what tells an empty key apart from a fresh or missing one.
+type CardinalityState struct {
+	BloomCardinality uint32    // Estimated cardinality from the Bloom filter
+	HyperCardinality uint64    // Estimated cardinality from the HyperLogLog sketch
+	Empty            bool      // Whether no value was ever inserted
+	CreatedAt        time.Time // Creation time, zero for keys created before creation times were recorded
+}
+
+// BloomCardinalityState returns the cardinality and state of the HyperBloom identified by key,
+// or ErrKeyNotFound if it doesn't exist.
+func BloomCardinalityState(ctx context.Context, key string) (*CardinalityState, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	return &CardinalityState{
+		BloomCardinality: db.BloomCardinality(),
+		HyperCardinality: db.HyperCardinality(),
+		Empty:            db.Empty(),
+		CreatedAt:        db.CreatedAt(),
+	}, nil
+}
+
+// BloomInfo returns information about the HyperBloom identified by key, or nil if it doesn't exist.
+// The memory savings versus an exact set are only reported when value lengths are tracked (HB_TRACK_VALUE_LEN).
+func BloomInfo(ctx context.Context, key string) *HyperBloomInfo {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return nil
+	}
+
+	info := &HyperBloomInfo{
+		Key:              db.Key(),
+		BitCapacity:      db.Bloom().Cap(),
+		HashFunctions:    db.Bloom().K(),
+		Strict:           db.Strict(),
+		Counting:         db.Counting(),
+		TopKCapacity:     db.TopKCapacity(),
+		Empty:            db.Empty(),
+		CreatedAt:        db.CreatedAt(),
+		BloomCardinality: db.BloomCardinality(),
+		HyperCardinality: db.HyperCardinality(),
+		MemoryBytes:      db.MemoryBytes(),
+	}
+
+	// Estimate what storing the distinct values themselves would have cost
+	if avgLength, ok := db.AverageValueLength(); ok {
+		info.AvgValueLength = avgLength
+		info.ExactSetBytes = uint64(float64(info.HyperCardinality) * (avgLength + exactSetEntryOverhead))
+		info.SavingsRatio = float64(info.ExactSetBytes) / float64(info.MemoryBytes)
+	}
+
+	return info
+}
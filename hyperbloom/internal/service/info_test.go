@@ -0,0 +1,51 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomCardinalityStateFreshKey(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("state-", time.Now().UnixNano())
+
+	before := time.Now().UTC()
+	if _, err := service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now().UTC()
+
+	// A freshly created key is empty and knows when it was created
+	state, err := service.BloomCardinalityState(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Empty || state.BloomCardinality != 0 || state.HyperCardinality != 0 {
+		t.Errorf("fresh key state = %+v, want empty with zero cardinality", state)
+	}
+	if state.CreatedAt.Before(before) || state.CreatedAt.After(after) {
+		t.Errorf("created at %s, want between %s and %s", state.CreatedAt, before, after)
+	}
+	if info := service.BloomInfo(context.Background(), key); !info.Empty || !info.CreatedAt.Equal(state.CreatedAt) {
+		t.Errorf("info = (%t, %s), want (true, %s)", info.Empty, info.CreatedAt, state.CreatedAt)
+	}
+
+	// Inserting keeps the creation time
+	service.BloomHash(context.Background(), key, "value")
+	state, _ = service.BloomCardinalityState(context.Background(), key)
+	if state.Empty || state.HyperCardinality != 1 {
+		t.Errorf("state after an insert = %+v, want non-empty with cardinality 1", state)
+	}
+	if state.CreatedAt.Before(before) || state.CreatedAt.After(after) {
+		t.Errorf("creation time changed to %s", state.CreatedAt)
+	}
+
+	if _, err = service.BloomCardinalityState(context.Background(), key+"-missing"); err != service.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+)
+
+// ErrQueryNotAllowed is returned when ingesting from a query that isn't in the INGEST_QUERIES allowlist.
+var ErrQueryNotAllowed = errors.New("query not allowed")
+
+// IngestQuery is an allowlisted SQL query to ingest values from.
+type IngestQuery struct {
+	SQL    string `json:"sql"`    // Query to run, taking its arguments as $1, $2... placeholders
+	Column string `json:"column"` // Column of the result whose values are hashed
+}
+
+// ingestQueries parses the INGEST_QUERIES allowlist.
+func ingestQueries() (map[string]IngestQuery, error) {
+	queries := map[string]IngestQuery{}
+	allowlist := config.ApplicationCfg().IngestQueries
+	if allowlist == "" {
+		return queries, nil
+	}
+
+	if err := json.Unmarshal([]byte(allowlist), &queries); err != nil {
+		return nil, fmt.Errorf("invalid INGEST_QUERIES: %w", err)
+	}
+	return queries, nil
+}
+
+// BloomIngestSQL runs the allowlisted query called name with args bound to its placeholders, and hashes the
+// designated column of every row into the HyperBloom identified by key, creating it if needed.
+// Rows are streamed from the database as they are hashed rather than buffered, NULL values are skipped.
+// With HB_TRANSFORM_URL, every value goes through the transformation webhook, one call per row.
+// Only queries named in INGEST_QUERIES can run, with caller input only ever bound as arguments, and they
+// run in a read-only transaction so that a misconfigured query can't modify the database.
+// It returns the number of rows processed and hashed, or ErrQueryNotAllowed if name isn't in the allowlist.
+func BloomIngestSQL(ctx context.Context, key, name string, args []any) (uint64, uint64, error) {
+	queries, err := ingestQueries()
+	if err != nil {
+		return 0, 0, err
+	}
+	query, ok := queries[name]
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %q", ErrQueryNotAllowed, name)
+	}
+
+	db, err := bloomGetOrCreate(ctx, key)
+	if err != nil {
+		return 0, 0, err
+	}
+	dbs.Set(db, key)
+	defer markDirty(ctx, key) // Count the key towards the next flush once its rows are hashed
+
+	tx, err := postgres.DbClient.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query.SQL, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	// Locate the designated column, every other one is scanned and discarded
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, 0, err
+	}
+	index := -1
+	for i, column := range columns {
+		if column == query.Column {
+			index = i
+		}
+	}
+	if index < 0 {
+		return 0, 0, fmt.Errorf("query %q has no column %q", name, query.Column)
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var processed, hashed uint64
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return processed, hashed, err
+		}
+		processed++
+
+		if values[index] != nil {
+			value, err := TransformValue(ctx, string(values[index]))
+			if err != nil {
+				return processed, hashed, err
+			}
+			db.Hash(value)
+			hashed++
+		}
+	}
+
+	return processed, hashed, rows.Err()
+}
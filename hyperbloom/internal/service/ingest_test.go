@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomIngestSQL(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	table := fmt.Sprint("ingest_users_", suffix)
+	key := fmt.Sprint("ingest-", suffix)
+
+	_, err := postgres.DbClient.Exec(fmt.Sprintf(`CREATE TABLE %s (id INTEGER, email VARCHAR)`, table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postgres.DbClient.Exec(fmt.Sprintf(`DROP TABLE %s`, table))
+
+	_, err = postgres.DbClient.Exec(fmt.Sprintf(
+		`INSERT INTO %s VALUES (1, 'a@example.com'), (2, 'b@example.com'), (3, NULL), (4, 'd@example.com')`, table,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := config.ApplicationCfg().IngestQueries
+	defer config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.IngestQueries = saved })
+	queries := fmt.Sprintf(`{
+		"users_from": {"sql": "SELECT id, email FROM %[1]s WHERE id >= $1", "column": "email"},
+		"users_write": {"sql": "INSERT INTO %[1]s VALUES (5, 'e@example.com') RETURNING email", "column": "email"}
+	}`, table)
+	config.UpdateApplicationCfg(func(cfg *config.ApplicationConfig) { cfg.IngestQueries = queries })
+
+	processed, hashed, err := service.BloomIngestSQL(context.Background(), key, "users_from", []any{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed != 3 || hashed != 2 {
+		t.Errorf("(processed, hashed) = (%d, %d), want (3, 2)", processed, hashed)
+	}
+	if !bloomExists(t, context.Background(), key, "b@example.com") || !bloomExists(t, context.Background(), key, "d@example.com") {
+		t.Error("ingested values are missing")
+	}
+
+	// Only allowlisted queries run
+	if _, _, err = service.BloomIngestSQL(context.Background(), key, "SELECT 1", nil); !errors.Is(err, service.ErrQueryNotAllowed) {
+		t.Errorf("expected ErrQueryNotAllowed, got %v", err)
+	}
+
+	// Even an allowlisted query can't write
+	if _, _, err = service.BloomIngestSQL(context.Background(), key, "users_write", nil); err == nil {
+		t.Error("a writing query ran outside of a read-only transaction")
+	}
+}
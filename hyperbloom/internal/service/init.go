@@ -1,13 +1,13 @@
 package service
 
 import (
-	"fmt"
+	"context"
 	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"gopds/hyperbloom/internal/config"
-	"gopds/hyperbloom/internal/database/postgres"
 	"gopds/hyperbloom/pkg/models"
 )
 
@@ -17,59 +17,65 @@ var dbs = models.NewHyperBlooms()
 // StopAsyncBloomUpdate is a channel used to signal stopping the asynchronous Bloom filter update process.
 var StopAsyncBloomUpdate = make(chan bool, 1)
 
+// AsyncBloomUpdateDone is closed once the asynchronous Bloom filter update process has flushed its final state and exited.
+var AsyncBloomUpdateDone = make(chan struct{})
+
+// updateTicker paces the asynchronous Bloom filter update process, ApplyConfig resets it to a reloaded rate.
+var updateTicker *time.Ticker
+
 // WG is a WaitGroup used to synchronize concurrent operations.
 var WG sync.WaitGroup
 
-func init() {
-	// Initialize database client
+// Init loads the HyperBloom configuration, creates or migrates the tables unless DB_MIGRATE is false (see Migrate)
+// and starts the asynchronous update process. It must be called once, after postgres.Connect.
+func Init() {
 	var err error
 	config.LoadConfigHyperBloom()
-	client := postgres.DbClient
-
-	// Begin a new transaction
-	tx, _ := client.Begin()
-
-	// Execute SQL query to create 'hyperblooms' table if it does not exist
-	_, err = client.Exec(`
-	CREATE TABLE IF NOT EXISTS hyperblooms (
-		key VARCHAR PRIMARY KEY,
-		bloombyte BYTEA, 
-		hyperbyte BYTEA
-	)`)
 
-	// Rollback transaction and log fatal error if table creation fails
+	// The default parameters are used for every auto-created key, refuse to start if they can't be served
+	err = ValidateConfig(*config.HyperBloomCfg())
 	if err != nil {
-		log.Fatal("Can't create table hyperblooms", err)
-		tx.Rollback()
+		log.Fatal("Invalid default HyperBloom configuration: ", err)
 	}
 
-	// Execute SQL query to create 'hyperblooms_metadata' table if it does not exist
-	_, err = client.Exec(`
-	CREATE TABLE IF NOT EXISTS hyperblooms_metadata (
-		key VARCHAR,
-		max_cardinality INTEGER,
-		false_positive REAL,
-		bit_capacity INTEGER,
-		no_hash_func INTEGER,
-		decay_sec BIGINT,
-		FOREIGN KEY (key) REFERENCES hyperblooms(key)
-	)`)
-
-	// Rollback transaction and log fatal error if table creation fails
-	if err != nil {
-		log.Fatal("Can't create table hyperblooms_metadata", err)
-		tx.Rollback()
+	// Create or migrate the tables, unless they are managed separately
+	if config.PostgresCfg.Migrate {
+		if err = Migrate(context.Background()); err != nil {
+			log.Fatal("Can't migrate the schema: ", err)
+		}
 	}
 
-	// Commit the transaction after successful table creations
-	tx.Commit()
-
 	// Create a new ticker that ticks at the specified interval in milliseconds
-	ticker := time.NewTicker(config.HyperBloomCfg.UpdateRate)
+	updateTicker = time.NewTicker(config.HyperBloomCfg().UpdateRate)
+
+	// Schedule the false positive rate sampling, if enabled
+	resetAccuracyTicker()
+
+	// Schedule the sweep of expired keys, if enabled
+	resetExpiryTicker()
+
+	// Publish the number and memory of the keys in memory, computed on every scrape
+	publishUsageGauges()
 
 	// Start asynchronous process to update bloom filters using the ticker
-	AsyncBloomUpdate(ticker, StopAsyncBloomUpdate)
+	AsyncBloomUpdate(updateTicker, StopAsyncBloomUpdate)
+
+	// Start asynchronous process to delete the expired keys
+	AsyncExpirySweep()
+
+	// Log a message indicating successful initialization
+	slog.Info("Init service")
+}
+
+// ApplyConfig applies a reloaded HyperBloom configuration to the running service.
+// Keys created afterwards use the new defaults, existing keys keep their parameters.
+func ApplyConfig() {
+	// The next flush happens one new interval from now
+	updateTicker.Reset(config.HyperBloomCfg().UpdateRate)
+
+	// So does the next false positive rate sampling, unless it was disabled
+	resetAccuracyTicker()
 
-	// Print a message indicating successful initialization
-	fmt.Println("Init service")
+	// And the next sweep of expired keys
+	resetExpiryTicker()
 }
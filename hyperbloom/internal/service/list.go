@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// Types of filter a key can be created with.
+const (
+	FilterStandard = "standard" // A single Bloom filter
+	FilterStrict   = "strict"   // Two independent Bloom filters, see models.HyperBloom.EnableStrict
+	FilterScalable = "scalable" // Bloom filter layers added once saturated, see models.HyperBloom.EnableScalable
+	FilterCounting = "counting" // A Bloom filter with a counter per bit, see models.HyperBloom.EnableCounting
+)
+
+// KeyInfo describes a key listed by ListKeys.
+type KeyInfo struct {
+	Key         string    // Key of the HyperBloom
+	Cardinality uint64    // Estimated cardinality from the HyperLogLog sketch
+	Type        string    // Type of filter, FilterStandard, FilterStrict, FilterScalable or FilterCounting
+	CreatedAt   time.Time // Creation time, zero for keys created before creation times were recorded
+	UpdatedAt   time.Time // Time of the last write to the database, zero for keys not written since it was recorded
+}
+
+// filterType returns the type of filter of the HyperBloom instance.
+func filterType(db *models.HyperBloom) string {
+	switch {
+	case db.Scalable() != nil:
+		return FilterScalable
+	case db.Strict():
+		return FilterStrict
+	case db.Counting():
+		return FilterCounting
+	default:
+		return FilterStandard
+	}
+}
+
+// ListKeys returns the at most limit keys starting with prefix (any key if empty) after skipping offset of them,
+// sorted by key. Keys are listed from the database without loading them: the cardinality is the one of their last
+// write, unless they are in memory, whose cardinality is current. Expired keys are left out even before they are
+// swept. Keys created moments ago may be missing when reading from a replica.
+func ListKeys(ctx context.Context, prefix string, limit, offset int) ([]KeyInfo, error) {
+	rows, err := postgres.Default.Replica().QueryContext(ctx, `
+		SELECT
+			hb.key,
+			COALESCE(hb.cardinality, 0),
+			CASE
+				WHEN hb.scalablebyte IS NOT NULL THEN $4
+				WHEN hb.strictbyte IS NOT NULL THEN $5
+				WHEN hb.countingbyte IS NOT NULL THEN $6
+				ELSE $7
+			END,
+			hb_meta.created_at,
+			hb.updated_at
+		FROM hyperblooms hb
+		LEFT JOIN hyperblooms_metadata hb_meta
+		ON hb.key = hb_meta.key
+		WHERE LEFT(hb.key, LENGTH($1)) = $1
+		AND (hb_meta.expires_at IS NULL OR hb_meta.expires_at > NOW())
+		ORDER BY hb.key
+		LIMIT $2 OFFSET $3`,
+		prefix, limit, offset, FilterScalable, FilterStrict, FilterCounting, FilterStandard,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []KeyInfo{}
+	for rows.Next() {
+		info := KeyInfo{}
+		var created, updated sql.NullTime
+		if err = rows.Scan(&info.Key, &info.Cardinality, &info.Type, &created, &updated); err != nil {
+			return nil, err
+		}
+		info.CreatedAt, info.UpdatedAt = created.Time, updated.Time
+
+		// Keys in memory may have taken values since their last write
+		if db, ok := dbs.GetHyperBloom(info.Key); ok {
+			info.Cardinality, info.Type = db.HyperCardinality(), filterType(db)
+		}
+		keys = append(keys, info)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,58 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestListKeys(t *testing.T) {
+	requireDatabase(t)
+
+	prefix := fmt.Sprint("list-", time.Now().UnixNano(), "-")
+	keys := []string{prefix + "a", prefix + "b", prefix + "c"}
+
+	if _, err := service.BloomCreateKey(context.Background(), keys[0], 1000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.BloomCreateKey(context.Background(), keys[1], 1000, 0.01, service.CreateOptions{Strict: true}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.BloomCreateKey(context.Background(), keys[2], 1000, 0.01, service.CreateOptions{Scalable: &service.Scalable{}}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		service.BloomHash(context.Background(), keys[0], fmt.Sprint(i))
+	}
+
+	// Only the keys with the prefix, sorted, along with their current cardinality and type
+	listed, err := service.ListKeys(context.Background(), prefix, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 3 {
+		t.Fatalf("%d keys listed, want 3", len(listed))
+	}
+	for i, want := range []string{service.FilterStandard, service.FilterStrict, service.FilterScalable} {
+		if listed[i].Key != keys[i] || listed[i].Type != want || listed[i].CreatedAt.IsZero() || listed[i].UpdatedAt.IsZero() {
+			t.Errorf("key %d = %+v, want %s of type %s with its times", i, listed[i], keys[i], want)
+		}
+	}
+	if listed[0].Cardinality != 10 {
+		t.Errorf("cardinality = %d, want 10", listed[0].Cardinality)
+	}
+
+	// Pages follow each other
+	for offset, want := range keys {
+		page, err := service.ListKeys(context.Background(), prefix, 1, offset)
+		if err != nil || len(page) != 1 || page[0].Key != want {
+			t.Errorf("page at offset %d = (%+v, %v), want %s", offset, page, err, want)
+		}
+	}
+	if page, err := service.ListKeys(context.Background(), prefix, 10, 3); err != nil || len(page) != 0 {
+		t.Errorf("page past the end = (%+v, %v), want empty", page, err)
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strings"
+)
+
+// LookupCount is the number of keys that probably contain a value.
+type LookupCount struct {
+	Scanned                int     // Number of keys checked
+	Count                  int     // Number of keys reporting the value as present
+	ExpectedFalsePositives float64 // Number of keys expected to report the value without containing it
+}
+
+// BloomLookupCount counts the keys starting with prefix (every key if empty) whose Bloom filter reports value
+// as present. Every matching key is checked, so keys not yet in memory are loaded from the database.
+// Each key is a false positive with probability fill^k, its current false positive rate, so the count is
+// inflated by up to ExpectedFalsePositives, which adds up these probabilities over the keys checked.
+func BloomLookupCount(ctx context.Context, value, prefix string) (*LookupCount, error) {
+	keys, err := BloomKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LookupCount{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		db := BloomGet(ctx, key)
+		if db == nil {
+			continue
+		}
+
+		result.Scanned++
+		if db.CheckExists(value) {
+			result.Count++
+		}
+
+		fill := float64(db.SetBits()) / float64(db.Bloom().Cap())
+		result.ExpectedFalsePositives += math.Pow(fill, float64(db.Bloom().K()))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,38 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomLookupCount(t *testing.T) {
+	requireDatabase(t)
+
+	prefix := fmt.Sprint("lookup-", time.Now().UnixNano(), "-")
+	service.BloomHash(context.Background(), prefix+"a", "x")
+	service.BloomHash(context.Background(), prefix+"b", "x")
+	service.BloomHash(context.Background(), prefix+"c", "y")
+
+	result, err := service.BloomLookupCount(context.Background(), "x", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Scanned != 3 || result.Count != 2 {
+		t.Errorf("lookup count = %d of %d keys, want 2 of 3", result.Count, result.Scanned)
+	}
+
+	// Nearly empty filters are unlikely to yield false positives
+	if result.ExpectedFalsePositives <= 0 || result.ExpectedFalsePositives > 0.01 {
+		t.Errorf("expected false positives = %g, want a small positive number", result.ExpectedFalsePositives)
+	}
+
+	// The prefix narrows the keys checked
+	result, _ = service.BloomLookupCount(context.Background(), "x", prefix+"c")
+	if result.Scanned != 1 || result.Count != 0 {
+		t.Errorf("lookup count = %d of %d keys, want 0 of 1", result.Count, result.Scanned)
+	}
+}
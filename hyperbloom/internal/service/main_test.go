@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/service"
+)
+
+// databaseReady is set once TestMain has connected to the database and initialized the service.
+var databaseReady bool
+
+// TestMain connects to the database described by the DB_* variables and initializes the service, as main does.
+// Unless DB_HOST or DB_DSN is set, it only loads the configuration, and the tests needing the database are skipped.
+func TestMain(m *testing.M) {
+	if err := config.LoadConfigPostgres(); err != nil {
+		log.Fatal(err)
+	}
+	if os.Getenv("DB_HOST") == "" && os.Getenv("DB_DSN") == "" {
+		config.LoadConfigHyperBloom()
+		os.Exit(m.Run())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.PostgresCfg.ConnectTimeout)
+	err := postgres.Connect(ctx, config.PostgresCfg)
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+	service.Init()
+	databaseReady = true
+
+	os.Exit(m.Run())
+}
+
+// requireDatabase skips the test unless TestMain connected to the database.
+func requireDatabase(t *testing.T) {
+	t.Helper()
+	if !databaseReady {
+		t.Skip("no database configured, set DB_HOST or DB_DSN")
+	}
+}
+
+// bloomExists checks value against key like service.BloomExists, reporting an error if the key can't be read.
+func bloomExists(t *testing.T, ctx context.Context, key, value string) bool {
+	t.Helper()
+	exists, err := service.BloomExists(ctx, key, value)
+	if err != nil {
+		t.Errorf("exists (%s, %s): %v", key, value, err)
+	}
+	return exists
+}
+
+// bloomCardinality returns the cardinalities of key like service.BloomCardinality, reporting an error if the key can't be read.
+func bloomCardinality(t *testing.T, ctx context.Context, key string) (uint32, uint64) {
+	t.Helper()
+	bCard, hCard, err := service.BloomCardinality(ctx, key)
+	if err != nil {
+		t.Errorf("cardinality (%s): %v", key, err)
+	}
+	return bCard, hCard
+}
+
+// persistedRows counts the rows of key in the hyperblooms and hyperblooms_metadata tables.
+func persistedRows(t *testing.T, key string) (int, int) {
+	t.Helper()
+	var blooms, metadata int
+	err := postgres.DbClient.QueryRow(
+		`SELECT (SELECT COUNT(*) FROM hyperblooms WHERE key = $1), (SELECT COUNT(*) FROM hyperblooms_metadata WHERE key = $1)`,
+		key,
+	).Scan(&blooms, &metadata)
+	if err != nil {
+		t.Fatalf("counting the rows of %s: %v", key, err)
+	}
+	return blooms, metadata
+}
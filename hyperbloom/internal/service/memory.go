@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopds/hyperbloom/internal/database/postgres"
+)
+
+// blobColumns are the columns of hyperblooms holding the encoded filters and sketches of a key.
+var blobColumns = []string{"bloombyte", "hyperbyte", "strictbyte", "firstseen", "recencybyte", "scalablebyte", "countingbyte", "topkbyte"}
+
+// KeyMemory is the footprint of a key in memory and in the database, see BloomMemory.
+type KeyMemory struct {
+	Key             string // Key of the HyperBloom
+	InMemory        bool   // Whether the key is loaded
+	MemoryBytes     uint64 // Memory used by its filters and sketches, 0 when not loaded
+	SerializedBytes uint64 // Size of its encoded filters and sketches as last written, about the memory it takes once loaded
+	StoredBytes     uint64 // Space its blobs take in the database once compressed by PostgreSQL, 0 until first written
+}
+
+// MemoryUsage is the footprint of a set of keys along with their totals, see BloomMemory.
+type MemoryUsage struct {
+	Keys            []KeyMemory // Footprint of every key, sorted by key
+	InMemoryKeys    int         // Number of keys loaded
+	MemoryBytes     uint64      // Total memory used by the keys loaded
+	SerializedBytes uint64      // Total size of the encoded filters and sketches
+	StoredBytes     uint64      // Total space taken in the database
+}
+
+// BloomMemory returns the footprint of the HyperBloom identified by key, or of every HyperBloom when key is empty,
+// in memory and in the database. Keys written since they were loaded may serialize to more than reported, their
+// memory footprint is current. Keys not written yet are reported from memory only. It returns ErrKeyNotFound
+// if key isn't empty and exists neither in memory nor in the database.
+func BloomMemory(ctx context.Context, key string) (*MemoryUsage, error) {
+	serialized := make([]string, len(blobColumns))
+	stored := make([]string, len(blobColumns))
+	for i, column := range blobColumns {
+		serialized[i] = fmt.Sprintf("COALESCE(OCTET_LENGTH(%s), 0)", column)
+		stored[i] = fmt.Sprintf("COALESCE(PG_COLUMN_SIZE(%s), 0)", column)
+	}
+
+	// Read the sizes of the blobs without transferring them
+	rows, err := postgres.Default.Replica().QueryContext(ctx, fmt.Sprintf(
+		`SELECT key, %s, %s FROM hyperblooms WHERE $1 = '' OR key = $1`,
+		strings.Join(serialized, " + "), strings.Join(stored, " + "),
+	), key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []KeyMemory{}
+	written := map[string]bool{}
+	for rows.Next() {
+		info := KeyMemory{}
+		if err = rows.Scan(&info.Key, &info.SerializedBytes, &info.StoredBytes); err != nil {
+			return nil, err
+		}
+		keys = append(keys, info)
+		written[info.Key] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Add the keys created since the last flush
+	for _, db := range dbs.GetInMemoryHyperBlooms() {
+		if !written[db.Key()] && (key == "" || db.Key() == key) {
+			keys = append(keys, KeyMemory{Key: db.Key()})
+		}
+	}
+	if key != "" && len(keys) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	usage := &MemoryUsage{Keys: keys}
+	for i := range keys {
+		if db, ok := dbs.GetHyperBloom(keys[i].Key); ok {
+			keys[i].InMemory, keys[i].MemoryBytes = true, db.MemoryBytes()
+			usage.InMemoryKeys++
+		}
+		usage.MemoryBytes += keys[i].MemoryBytes
+		usage.SerializedBytes += keys[i].SerializedBytes
+		usage.StoredBytes += keys[i].StoredBytes
+	}
+	slices.SortFunc(keys, func(a, b KeyMemory) int { return strings.Compare(a.Key, b.Key) })
+
+	return usage, nil
+}
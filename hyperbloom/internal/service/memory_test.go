@@ -0,0 +1,57 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomMemory(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("memory-", time.Now().UnixNano())
+	if _, err := service.BloomCreateKey(context.Background(), key, 10000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+	service.FlushAll(context.Background())
+
+	// The key is loaded and written, both footprints are known
+	usage, err := service.BloomMemory(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage.Keys) != 1 || usage.Keys[0].Key != key {
+		t.Fatalf("keys = %+v, want only %s", usage.Keys, key)
+	}
+	info := usage.Keys[0]
+	if !info.InMemory || info.MemoryBytes != service.BloomInfo(context.Background(), key).MemoryBytes {
+		t.Errorf("memory = (%t, %d), want (true, %d)", info.InMemory, info.MemoryBytes, service.BloomInfo(context.Background(), key).MemoryBytes)
+	}
+	if info.SerializedBytes == 0 || info.StoredBytes == 0 {
+		t.Errorf("database sizes = (%d, %d), want both set", info.SerializedBytes, info.StoredBytes)
+	}
+	if usage.InMemoryKeys != 1 || usage.MemoryBytes != info.MemoryBytes || usage.SerializedBytes != info.SerializedBytes || usage.StoredBytes != info.StoredBytes {
+		t.Errorf("totals = %+v, want the ones of %s", usage, key)
+	}
+
+	// The totals cover every key
+	all, err := service.BloomMemory(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.MemoryBytes < info.MemoryBytes || all.SerializedBytes < info.SerializedBytes || all.StoredBytes < info.StoredBytes {
+		t.Errorf("totals of every key = %+v, below the ones of %s", all, key)
+	}
+
+	if _, err = service.BloomMemory(context.Background(), key+"-missing"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("missing key: %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	_ "embed"
+
+	"gopds/hyperbloom/internal/database/postgres"
+)
+
+// schema creates or migrates the tables the service reads and writes, see schema.sql.
+//
+//go:embed schema.sql
+var schema string
+
+// Migrate creates the tables of a fresh database, or adds the columns and indexes introduced since an older one
+// was created, in a single transaction: a failed migration leaves the schema as it was. It is idempotent, Init runs
+// it on every startup unless DB_MIGRATE is false. It must be called after postgres.Connect.
+func Migrate(ctx context.Context) error {
+	// Begin a database transaction, PostgreSQL rolls back schema changes too
+	tx, err := postgres.DbClient.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Without arguments the statements are sent at once, in the simple query protocol
+	if _, err = tx.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+
+	// Commit the database transaction
+	return tx.Commit()
+}
@@ -0,0 +1,46 @@
+package service_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/service"
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestMigrate(t *testing.T) {
+	requireDatabase(t)
+
+	// TestMain already migrated, migrating again changes nothing
+	for i := 0; i < 2; i++ {
+		if err := service.Migrate(context.Background()); err != nil {
+			t.Fatalf("migration %d: %v", i+1, err)
+		}
+	}
+
+	// Rows written before the format was versioned are read as raw
+	var defaultFormat string
+	err := postgres.DbClient.QueryRow(`
+		SELECT column_default FROM information_schema.columns
+		WHERE table_name = 'hyperblooms' AND column_name = 'format_version'`,
+	).Scan(&defaultFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultFormat != strconv.Itoa(models.FormatRaw) {
+		t.Errorf("format_version defaults to %s, want models.FormatRaw (%d)", defaultFormat, models.FormatRaw)
+	}
+
+	// The indexes the loads and the expiry sweep rely on exist
+	for _, index := range []string{"hyperblooms_metadata_key_idx", "hyperblooms_metadata_expires_at_idx"} {
+		var found bool
+		if err = postgres.DbClient.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = $1)`, index).Scan(&found); err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Errorf("index %s missing", index)
+		}
+	}
+}
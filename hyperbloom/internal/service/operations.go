@@ -0,0 +1,37 @@
+package service
+
+import (
+	"expvar"
+
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// Operations counted by operation in operations_total.
+const (
+	operationHash        = "hash"
+	operationExists      = "exists"
+	operationRemove      = "remove"
+	operationCardinality = "cardinality"
+	operationSimilarity  = "similarity"
+)
+
+// operationsCounter returns the number of filter operations by operation, published as operations_total.
+// Hashes and existence checks count the values, so that batches weigh as much as the single calls they replace.
+func operationsCounter() *expvar.Map {
+	return metrics.Map("operations_total", "operation")
+}
+
+// publishUsageGauges publishes the number of HyperBlooms in memory as hyperblooms_in_memory, and the memory
+// used by their filters and sketches as hyperbloom_memory_bytes. Both are computed whenever the metrics are read.
+func publishUsageGauges() {
+	metrics.Gauge("hyperblooms_in_memory", func() float64 {
+		return float64(len(dbs.GetInMemoryHyperBlooms()))
+	})
+	metrics.Gauge("hyperbloom_memory_bytes", func() float64 {
+		bytes := uint64(0)
+		for _, db := range dbs.GetInMemoryHyperBlooms() {
+			bytes += db.MemoryBytes()
+		}
+		return float64(bytes)
+	})
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+// Operator combines the membership of a value in several keys, see BloomChainingExists and BloomBitwiseExists.
+type Operator string
+
+// Operators supported by the existence checks over several keys.
+const (
+	OperatorAnd Operator = "AND" // The value must be in every key
+	OperatorOr  Operator = "OR"  // The value must be in at least one key
+	OperatorXor Operator = "XOR" // The value must be in an odd number of keys
+)
+
+// Operators lists the supported operators.
+var Operators = []Operator{OperatorAnd, OperatorOr, OperatorXor}
+
+// ErrInvalidOperator is returned for an unknown operator.
+var ErrInvalidOperator = errors.New("invalid operator")
+
+// ParseOperator returns the operator named by operator, matched exactly.
+// It returns ErrInvalidOperator for anything but one of Operators.
+func ParseOperator(operator string) (Operator, error) {
+	for _, supported := range Operators {
+		if operator == string(supported) {
+			return supported, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q, expected one of %v", ErrInvalidOperator, operator, Operators)
+}
+
+// ResolveOperator returns the operator named by operator, or HB_DEFAULT_OPERATOR when it is omitted.
+// It returns ErrInvalidOperator for anything but one of Operators.
+func ResolveOperator(operator string) (Operator, error) {
+	if operator == "" {
+		operator = config.HyperBloomCfg().DefaultOperator
+	}
+	return ParseOperator(operator)
+}
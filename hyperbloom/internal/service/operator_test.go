@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestDefaultOperator(t *testing.T) {
+	requireDatabase(t)
+
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+
+	suffix := time.Now().UnixNano()
+	keys := []string{fmt.Sprint("operator-a-", suffix), fmt.Sprint("operator-b-", suffix)}
+
+	// The value is only in the first key, AND and OR disagree on it
+	service.BloomHash(context.Background(), keys[0], "shared")
+	service.BloomHash(context.Background(), keys[1], "other")
+
+	for _, operator := range []service.Operator{service.OperatorAnd, service.OperatorOr} {
+		config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.DefaultOperator = string(operator) })
+		want := operator == service.OperatorOr
+
+		if got, err := service.ResolveOperator(""); got != operator || err != nil {
+			t.Errorf("omitted operator resolved to (%q, %v), want %q", got, err, operator)
+		}
+		if got, err := service.BloomChainingExists(context.Background(), keys, "shared", ""); got != want || err != nil {
+			t.Errorf("chaining with omitted operator and default %s = (%t, %v), want %t", operator, got, err, want)
+		}
+		if got, err := service.BloomBitwiseExists(context.Background(), keys, "shared", ""); got != want || err != nil {
+			t.Errorf("bitwise with omitted operator and default %s = (%t, %v), want %t", operator, got, err, want)
+		}
+
+		// An explicit operator wins over the default
+		if got, _ := service.ResolveOperator(string(service.OperatorAnd)); got != service.OperatorAnd {
+			t.Errorf("explicit AND resolved to %q with default %s", got, operator)
+		}
+	}
+
+	// A default that isn't an operator is refused at startup and on reload
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.DefaultOperator = "and" })
+	if err := service.ValidateConfig(*config.HyperBloomCfg()); err == nil {
+		t.Error("expected HB_DEFAULT_OPERATOR=and to be rejected")
+	}
+}
+
+func TestOperators(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	a, b, c := fmt.Sprint("operators-a-", suffix), fmt.Sprint("operators-b-", suffix), fmt.Sprint("operators-c-", suffix)
+	missing, small := fmt.Sprint("operators-missing-", suffix), fmt.Sprint("operators-small-", suffix)
+
+	// small holds "all" too, in a filter of another size than the others
+	if _, err := service.BloomCreateKey(context.Background(), small, 100, 0.1, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+
+	// "all" is in every key, "two" in a and b, "one" in a only, "none" in no key
+	for key, values := range map[string][]string{
+		a:     {"all", "two", "one"},
+		b:     {"all", "two"},
+		c:     {"all"},
+		small: {"all"},
+	} {
+		if err := service.BloomHashBatch(context.Background(), key, values); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		operator service.Operator
+		keys     []string
+		value    string
+		want     bool
+		err      error // Expected from BloomBitwiseExists only, BloomChainingExists tests each filter on its own
+	}{
+		{service.OperatorAnd, []string{a, b, c}, "all", true, nil},
+		{service.OperatorAnd, []string{a, b, c}, "two", false, nil},
+		{service.OperatorAnd, []string{a, b}, "two", true, nil},
+		{service.OperatorAnd, []string{a, b, c}, "none", false, nil},
+		{service.OperatorOr, []string{a, b, c}, "one", true, nil},
+		{service.OperatorOr, []string{b, c}, "one", false, nil},
+		{service.OperatorOr, []string{a, b, c}, "none", false, nil},
+		{service.OperatorXor, []string{a, b, c}, "all", true, nil},
+		{service.OperatorXor, []string{a, b, c}, "two", false, nil},
+		{service.OperatorXor, []string{a, b, c}, "one", true, nil},
+		{service.OperatorXor, []string{a, b}, "all", false, nil},
+		{service.OperatorXor, []string{a, b, c}, "none", false, nil},
+		{service.OperatorAnd, []string{a, b, missing}, "all", false, nil},
+		{service.OperatorOr, []string{b, a, missing}, "one", true, nil},
+		{service.OperatorOr, []string{missing, a}, "one", true, nil},
+		{service.OperatorXor, []string{a, b, c, missing}, "one", true, nil},
+		{service.OperatorAnd, []string{a, small}, "all", false, service.ErrIncompatibleFilters},
+		{service.OperatorOr, []string{missing, a, small}, "all", false, service.ErrIncompatibleFilters},
+		{service.OperatorXor, []string{a, b, small}, "all", false, service.ErrIncompatibleFilters},
+	}
+
+	// Both checks agree on values whose bits no other value of the keys shares
+	for _, c := range cases {
+		if got, err := service.BloomBitwiseExists(context.Background(), c.keys, c.value, c.operator); got != c.want || !errors.Is(err, c.err) {
+			t.Errorf("bitwise %s of %q over %d keys = (%t, %v), want (%t, %v)", c.operator, c.value, len(c.keys), got, err, c.want, c.err)
+		}
+		if c.err != nil {
+			continue
+		}
+		if got, err := service.BloomChainingExists(context.Background(), c.keys, c.value, c.operator); got != c.want || err != nil {
+			t.Errorf("chaining %s of %q over %d keys = (%t, %v), want %t", c.operator, c.value, len(c.keys), got, err, c.want)
+		}
+	}
+
+	// Operators are matched exactly, unknown ones are refused
+	for _, name := range []string{"NAND", "and", " OR"} {
+		if _, err := service.ParseOperator(name); !errors.Is(err, service.ErrInvalidOperator) {
+			t.Errorf("ParseOperator(%q): %v, want ErrInvalidOperator", name, err)
+		}
+		if _, err := service.BloomChainingExists(context.Background(), []string{a}, "all", service.Operator(name)); !errors.Is(err, service.ErrInvalidOperator) {
+			t.Errorf("chaining with %q: %v, want ErrInvalidOperator", name, err)
+		}
+		if _, err := service.BloomBitwiseExists(context.Background(), []string{a}, "all", service.Operator(name)); !errors.Is(err, service.ErrInvalidOperator) {
+			t.Errorf("bitwise with %q: %v, want ErrInvalidOperator", name, err)
+		}
+	}
+}
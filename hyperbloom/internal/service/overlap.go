@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+// OverlapComponents holds the estimates the overlap coefficient of two keys is computed from.
+type OverlapComponents struct {
+	Cardinality1 uint64 // Estimated cardinality of the first key
+	Cardinality2 uint64 // Estimated cardinality of the second key
+	Union        uint64 // Estimated cardinality of the union of both keys
+	Intersection uint64 // Estimated cardinality of the intersection, by inclusion-exclusion
+}
+
+// Coefficient returns the Szymkiewicz–Simpson overlap coefficient |A∩B|/min(|A|,|B|),
+// or 0 if one of the sets is empty.
+func (c OverlapComponents) Coefficient() float64 {
+	smaller := min(c.Cardinality1, c.Cardinality2)
+	if smaller == 0 {
+		return 0
+	}
+	return float64(c.Intersection) / float64(smaller)
+}
+
+// BloomOverlap estimates the cardinalities of the keys, their union and their intersection from the HyperLogLog sketches.
+// The intersection |A|+|B|-|A∪B| is clamped to [0, min(|A|,|B|)], since the errors of the three estimates can push it out.
+// It returns ErrKeyNotFound if one of the keys doesn't exist, and ErrIncompatibleSketch if their precisions differ.
+func BloomOverlap(ctx context.Context, key1, key2 string) (OverlapComponents, error) {
+	db1, db2 := BloomGet(ctx, key1), BloomGet(ctx, key2)
+	if db1 == nil || db2 == nil {
+		return OverlapComponents{}, ErrKeyNotFound
+	}
+
+	union, err := unionCardinality([]*models.HyperBloom{db1, db2})
+	if err != nil {
+		return OverlapComponents{}, err
+	}
+
+	c := OverlapComponents{
+		Cardinality1: db1.HyperCardinality(),
+		Cardinality2: db2.HyperCardinality(),
+		Union:        union,
+	}
+
+	// Inclusion-exclusion, computed so that it can't underflow
+	if sum := c.Cardinality1 + c.Cardinality2; sum > c.Union {
+		c.Intersection = min(sum-c.Union, c.Cardinality1, c.Cardinality2)
+	}
+
+	return c, nil
+}
+
+// BloomOverlapCoefficient returns the approximate overlap coefficient |A∩B|/min(|A|,|B|) of two keys,
+// the right similarity when one set is much smaller than the other. It returns 0 if one of the keys doesn't exist
+// or their precisions differ.
+func BloomOverlapCoefficient(ctx context.Context, key1, key2 string) float64 {
+	c, err := BloomOverlap(ctx, key1, key2)
+	if err != nil {
+		return 0
+	}
+	return c.Coefficient()
+}
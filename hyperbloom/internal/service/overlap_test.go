@@ -0,0 +1,66 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomOverlapCoefficient(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	small := fmt.Sprint("overlap-small-", suffix)
+	large := fmt.Sprint("overlap-large-", suffix)
+
+	// The small set is entirely contained in the large one
+	for i := 0; i < 10000; i++ {
+		service.BloomHash(context.Background(), large, strconv.Itoa(i))
+		if i%10 == 0 {
+			service.BloomHash(context.Background(), small, strconv.Itoa(i))
+		}
+	}
+
+	c, err := service.BloomOverlap(context.Background(), small, large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Intersection > min(c.Cardinality1, c.Cardinality2) {
+		t.Errorf("intersection %d above the smaller cardinality", c.Intersection)
+	}
+
+	// The coefficient of a subset is 1, up to the HyperLogLog error
+	if coef := service.BloomOverlapCoefficient(context.Background(), small, large); math.Abs(coef-1) > 0.1 {
+		t.Errorf("overlap coefficient = %f, want about 1", coef)
+	}
+
+	if coef := service.BloomOverlapCoefficient(context.Background(), small, "overlap-missing"); coef != 0 {
+		t.Errorf("overlap coefficient with a missing key = %f, want 0", coef)
+	}
+	if _, err = service.BloomOverlap(context.Background(), small, "overlap-missing"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestOverlapComponentsCoefficient(t *testing.T) {
+	cases := []struct {
+		c    service.OverlapComponents
+		want float64
+	}{
+		{service.OverlapComponents{Cardinality1: 100, Cardinality2: 1000, Intersection: 50}, 0.5},
+		{service.OverlapComponents{Cardinality1: 1000, Cardinality2: 100, Intersection: 100}, 1},
+		{service.OverlapComponents{Cardinality1: 0, Cardinality2: 100}, 0},
+	}
+
+	for _, c := range cases {
+		if coef := c.c.Coefficient(); coef != c.want {
+			t.Errorf("%+v coefficient = %f, want %f", c.c, coef, c.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"expvar"
+	"sort"
+	"sync/atomic"
+
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// flushPaused holds the periodic flush of the update goroutine while set.
+var flushPaused atomic.Bool
+
+// resumeFlush wakes the update goroutine to write the backlog once the flush resumes.
+var resumeFlush = make(chan struct{}, 1)
+
+// flushBacklogGauge returns the gauge of the number of in-memory keys with changes not written to the database yet.
+func flushBacklogGauge() *expvar.Int {
+	return metrics.Int("flush_backlog")
+}
+
+// PauseFlush holds the periodic flush, e.g. during a database maintenance, and returns false if it was already paused.
+// Changes accumulate in memory and reads keep working meanwhile, decayed keys stay in memory until the flush resumes.
+// A flush already running completes. The shutdown flush still runs while paused, so the stop signal is never held.
+func PauseFlush() bool {
+	return flushPaused.CompareAndSwap(false, true)
+}
+
+// ResumeFlush resumes the periodic flush and has the update goroutine write the backlog right away.
+// It returns false if the flush wasn't paused.
+func ResumeFlush() bool {
+	if !flushPaused.CompareAndSwap(true, false) {
+		return false
+	}
+
+	// A pending wake-up already covers this one
+	select {
+	case resumeFlush <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+// FlushPaused reports whether the periodic flush is paused.
+func FlushPaused() bool {
+	return flushPaused.Load()
+}
+
+// FlushBacklog returns the in-memory keys with changes not written to the database yet, sorted.
+func FlushBacklog() []string {
+	keys := []string{}
+	for _, db := range dbs.GetInMemoryHyperBlooms() {
+		if db.Dirty() {
+			keys = append(keys, db.Key())
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestPauseFlush(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("pause-", time.Now().UnixNano())
+
+	if !service.PauseFlush() {
+		t.Fatal("flush was already paused")
+	}
+	defer service.ResumeFlush()
+	if service.PauseFlush() {
+		t.Error("pausing twice reported a change")
+	}
+
+	// Changes accumulate in the backlog while reads keep working
+	service.BloomHash(context.Background(), key, "value")
+	if !bloomExists(t, context.Background(), key, "value") {
+		t.Error("value missing while the flush is paused")
+	}
+	if !slices.Contains(service.FlushBacklog(), key) {
+		t.Fatalf("%s missing from the backlog %v", key, service.FlushBacklog())
+	}
+
+	if !service.ResumeFlush() {
+		t.Fatal("resuming reported no change")
+	}
+	if service.ResumeFlush() {
+		t.Error("resuming twice reported a change")
+	}
+
+	// Resuming writes the backlog
+	deadline := time.Now().Add(5 * time.Second)
+	for slices.Contains(service.FlushBacklog(), key) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s still in the backlog after resuming", key)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
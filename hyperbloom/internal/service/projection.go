@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"math"
+)
+
+// HyperBloomProjection describes the saturation the Bloom filter of a HyperBloom would reach after a number of
+// distinct values were inserted, against its configuration.
+type HyperBloomProjection struct {
+	Additional         uint64  // Number of distinct values the projection assumes are inserted
+	FillRatio          float64 // Fraction of the bits set now
+	CurrentFPR         float64 // False positive rate at the current fill, FillRatio^k
+	ProjectedFillRatio float64 // Fraction of the bits expected to be set after the insertions
+	ProjectedFPR       float64 // False positive rate at the projected fill
+	ProjectedCount     uint64  // Cardinality estimated by the HyperLogLog sketch plus Additional
+	TargetFPR          float64 // False positive rate the filter was sized for, 0 if unknown
+	ExceedsTarget      bool    // Whether ProjectedFPR exceeds TargetFPR, a larger filter should be created first
+}
+
+// BloomProjection estimates the fill ratio and false positive rate of the Bloom filter of the HyperBloom identified
+// by key after additional distinct values were inserted, without inserting anything. It returns ErrKeyNotFound if
+// the key doesn't exist. Every new value sets each of the k bits it hashes to with probability 1/m, so the fraction
+// of unset bits shrinks by e^(-k/m) per value, starting from the bits actually set rather than from a cardinality
+// estimate.
+func BloomProjection(ctx context.Context, key string, additional uint64) (*HyperBloomProjection, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	m, k := float64(db.Bloom().Cap()), float64(db.Bloom().K())
+	fill := float64(db.SetBits()) / m
+	projectedFill := 1 - (1-fill)*math.Exp(-k*float64(additional)/m)
+
+	projection := &HyperBloomProjection{
+		Additional:         additional,
+		FillRatio:          fill,
+		CurrentFPR:         math.Pow(fill, k),
+		ProjectedFillRatio: projectedFill,
+		ProjectedFPR:       math.Pow(projectedFill, k),
+		ProjectedCount:     uint64(db.HyperCardinality()) + additional,
+	}
+
+	// Read the rate the key was created with
+	var err error
+	if _, projection.TargetFPR, err = bloomSizing(ctx, key); err != nil {
+		return nil, err
+	}
+
+	projection.ExceedsTarget = projection.TargetFPR > 0 && projection.ProjectedFPR > projection.TargetFPR
+
+	return projection, nil
+}
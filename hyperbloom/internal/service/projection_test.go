@@ -0,0 +1,64 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomProjection(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("projection-", time.Now().UnixNano())
+	if _, err := service.BloomCreate(context.Background(), 1000, 0.01, key, service.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+	db := service.BloomGet(context.Background(), key)
+	setBits := db.SetBits()
+
+	// Projecting no insertions reports the current saturation
+	projection, err := service.BloomProjection(context.Background(), key, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projection.ProjectedFillRatio != projection.FillRatio || projection.ProjectedFPR != projection.CurrentFPR {
+		t.Errorf("no insertions: projected (%g, %g), current (%g, %g)",
+			projection.ProjectedFillRatio, projection.ProjectedFPR, projection.FillRatio, projection.CurrentFPR)
+	}
+	if projection.ExceedsTarget {
+		t.Errorf("half full filter exceeds its target: %g > %g", projection.ProjectedFPR, projection.TargetFPR)
+	}
+
+	// Projecting the rest of the load matches the filter once it's inserted
+	projection, err = service.BloomProjection(context.Background(), key, 4500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !projection.ExceedsTarget || projection.ProjectedFPR <= projection.CurrentFPR {
+		t.Errorf("overfilling projection: rate %g from %g, exceeds target %t",
+			projection.ProjectedFPR, projection.CurrentFPR, projection.ExceedsTarget)
+	}
+	if db.SetBits() != setBits {
+		t.Errorf("projection set bits: %d, was %d", db.SetBits(), setBits)
+	}
+
+	for i := 500; i < 5000; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+	actual := float64(db.SetBits()) / float64(db.Bloom().Cap())
+	if math.Abs(actual-projection.ProjectedFillRatio) > 0.05 {
+		t.Errorf("projected fill ratio = %g, actual %g", projection.ProjectedFillRatio, actual)
+	}
+
+	if _, err = service.BloomProjection(context.Background(), key+"-missing", 10); err != service.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"gopds/hyperbloom/internal/metrics"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// ErrKeyQuarantined is returned for operations on a key whose persisted blobs can't be decoded.
+var ErrKeyQuarantined = errors.New("key quarantined")
+
+// quarantine holds the keys whose persisted blobs can't be decoded, along with the decoding error.
+// They are excluded from queries rather than decoded again on every access, and never overwritten by a new key.
+var quarantine = struct {
+	sync.Mutex
+	keys map[string]error
+}{keys: map[string]error{}}
+
+// QuarantinedKey is a key excluded from queries because its persisted blobs can't be decoded.
+type QuarantinedKey struct {
+	Key   string // Key of the HyperBloom
+	Error string // Decoding error that got it quarantined
+}
+
+// quarantinedGauge returns the gauge of the number of quarantined keys.
+func quarantinedGauge() *expvar.Int {
+	return metrics.Int("quarantined_keys")
+}
+
+// bloomFetch retrieves the HyperBloom identified by key from memory or the database. A key whose blobs
+// can't be decoded is quarantined, and ErrKeyQuarantined returned for it until ReleaseQuarantine is called.
+// A key past its expiry time is deleted and reported missing like a key that doesn't exist, see SweepExpired.
+func bloomFetch(ctx context.Context, key string) (*models.HyperBloom, error) {
+	quarantine.Lock()
+	cause, quarantined := quarantine.keys[key]
+	quarantine.Unlock()
+	if quarantined {
+		return nil, fmt.Errorf("%w: %v", ErrKeyQuarantined, cause)
+	}
+
+	countCacheLookup(key)
+	db, err := dbs.GetOrFetchHyperBloom(ctx, key)
+	var decodeErr *models.DecodeError
+	if errors.As(err, &decodeErr) {
+		quarantineKey(key, err)
+		return nil, fmt.Errorf("%w: %v", ErrKeyQuarantined, err)
+	}
+
+	if err == nil && db.Expired(time.Now().UTC()) {
+		// The key is absent either way, a failed deletion is left to the next sweep
+		if err = expireKey(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "Can't delete expired key", "key", key, "err", err)
+		}
+		return nil, fmt.Errorf("%w: %s expired", sql.ErrNoRows, key)
+	}
+
+	return db, err
+}
+
+// quarantineKey excludes key from queries because of the decoding error err.
+func quarantineKey(key string, err error) {
+	quarantine.Lock()
+	defer quarantine.Unlock()
+
+	slog.Error("CORRUPTION: quarantining", "key", key, "err", err)
+	quarantine.keys[key] = err
+	quarantinedGauge().Set(int64(len(quarantine.keys)))
+}
+
+// QuarantinedKeys returns the quarantined keys along with their decoding errors, sorted by key.
+func QuarantinedKeys() []QuarantinedKey {
+	quarantine.Lock()
+	defer quarantine.Unlock()
+
+	keys := make([]QuarantinedKey, 0, len(quarantine.keys))
+	for key, err := range quarantine.keys {
+		keys = append(keys, QuarantinedKey{Key: key, Error: err.Error()})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+
+	return keys
+}
+
+// ReleaseQuarantine lets key be loaded again on its next access, e.g. once its row was repaired,
+// and returns false if it wasn't quarantined.
+func ReleaseQuarantine(key string) bool {
+	quarantine.Lock()
+	defer quarantine.Unlock()
+
+	if _, ok := quarantine.keys[key]; !ok {
+		return false
+	}
+	delete(quarantine.keys, key)
+	quarantinedGauge().Set(int64(len(quarantine.keys)))
+
+	return true
+}
@@ -0,0 +1,68 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/service"
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestQuarantine(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("quarantine-", time.Now().UnixNano())
+
+	// Persist a key whose Bloom filter doesn't match its checksum, as if the row had been corrupted
+	_, err := postgres.DbClient.Exec(
+		`INSERT INTO hyperblooms (key, format_version, bloombyte, hyperbyte) VALUES ($1, $2, $3, $3)`,
+		key, models.FormatVersion, []byte("corrupted"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = postgres.DbClient.Exec(
+		`INSERT INTO hyperblooms_metadata (key, max_cardinality, false_positive, bit_capacity, no_hash_func, decay_sec)
+		VALUES ($1, 1000, 0.01, 9586, 7, 120000000000)`,
+		key,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.ReleaseQuarantine(key)
+
+	// Excluded from queries
+	if db := service.BloomGet(context.Background(), key); db != nil {
+		t.Fatal("corrupted key was loaded")
+	}
+	quarantined := false
+	for _, q := range service.QuarantinedKeys() {
+		quarantined = quarantined || q.Key == key
+	}
+	if !quarantined {
+		t.Fatalf("%s missing from the quarantined keys", key)
+	}
+
+	// Writes fail instead of overwriting the row with a new key
+	if err = service.BloomHash(context.Background(), key, "value"); !errors.Is(err, service.ErrKeyQuarantined) {
+		t.Errorf("expected ErrKeyQuarantined, got %v", err)
+	}
+	if _, err = service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{}, service.OnExistsFail); !errors.Is(err, service.ErrKeyExists) {
+		t.Errorf("expected ErrKeyExists, got %v", err)
+	}
+
+	// Replacing the key repairs it
+	if _, err = service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{}, service.OnExistsReplace); err != nil {
+		t.Fatal(err)
+	}
+	if service.ReleaseQuarantine(key) {
+		t.Error("replaced key still quarantined")
+	}
+	if err = service.BloomHash(context.Background(), key, "value"); err != nil {
+		t.Errorf("can't hash into the replaced key: %v", err)
+	}
+}
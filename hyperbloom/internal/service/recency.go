@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MinHalfLife is the shortest half-life of a time-decayed distinct count, shorter ones would start a new sketch
+// for nearly every insert.
+const MinHalfLife = time.Second
+
+// ErrInvalidHalfLife is returned when creating a key with a half-life below MinHalfLife.
+var ErrInvalidHalfLife = errors.New("invalid half-life")
+
+// ErrRecencyNotTracked is returned when querying the time-decayed distinct count of a key created without one.
+var ErrRecencyNotTracked = errors.New("decayed cardinality not tracked")
+
+// BloomDecayedCardinality returns the time-decayed distinct count of the HyperBloom identified by key, where every
+// distinct value weighs 2^(-age/half-life) with age the time since it was last inserted, and the half-life.
+// Unlike the lifetime cardinality, it fades once insertions stop, see models.Recency for its accuracy.
+// It returns ErrKeyNotFound if the key doesn't exist, or ErrRecencyNotTracked if the key was created without a half-life.
+func BloomDecayedCardinality(ctx context.Context, key string) (float64, time.Duration, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return 0, 0, ErrKeyNotFound
+	}
+	if db.Recency() == nil {
+		return 0, 0, ErrRecencyNotTracked
+	}
+
+	return db.Recency().Estimate(time.Now()), db.Recency().HalfLife(), nil
+}
@@ -0,0 +1,47 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomDecayedCardinality(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	decayed := fmt.Sprint("recency-", suffix)
+	plain := fmt.Sprint("recency-plain-", suffix)
+
+	if _, err := service.BloomCreateKey(context.Background(), decayed, 10000, 0.01, service.CreateOptions{HalfLife: time.Hour}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.BloomCreateKey(context.Background(), plain, 10000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values inserted moments ago weigh nearly 1 with a one hour half-life
+	for i := 0; i < 2000; i++ {
+		service.BloomHash(context.Background(), decayed, strconv.Itoa(i))
+	}
+	count, halfLife, err := service.BloomDecayedCardinality(context.Background(), decayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if halfLife != time.Hour || math.Abs(count-2000) > 200 {
+		t.Errorf("decayed cardinality = (%.1f, %s), want about (2000, 1h)", count, halfLife)
+	}
+
+	if _, _, err = service.BloomDecayedCardinality(context.Background(), plain); !errors.Is(err, service.ErrRecencyNotTracked) {
+		t.Errorf("key without half-life: %v, want ErrRecencyNotTracked", err)
+	}
+	if _, err = service.BloomCreateKey(context.Background(), plain+"-short", 10000, 0.01, service.CreateOptions{HalfLife: time.Millisecond}, service.OnExistsFail); !errors.Is(err, service.ErrInvalidHalfLife) {
+		t.Errorf("half-life of 1ms: %v, want ErrInvalidHalfLife", err)
+	}
+}
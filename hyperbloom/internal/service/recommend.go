@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/axiomhq/hyperloglog"
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// MaxRecommendSample caps the number of sample values analyzed by BloomRecommend.
+const MaxRecommendSample = 10000
+
+// recommendProbes is the number of values outside the sample queried to measure the false positive rate.
+const recommendProbes = 10000
+
+// Candidate configurations evaluated by BloomRecommend.
+var (
+	recommendFPRFactors = []float64{10, 1, 0.1} // Multipliers of the target false positive rate
+
+	// HyperLogLog precisions, with the constructor of their sketch: the sketch package only builds these
+	recommendSketches = []struct {
+		precision uint8
+		new       func() *hyperloglog.Sketch
+	}{
+		{14, hyperloglog.New14},
+		{16, hyperloglog.New16},
+	}
+)
+
+// ErrInvalidSample is returned when the sample given to BloomRecommend is empty or too large.
+var ErrInvalidSample = errors.New("invalid sample")
+
+// HyperCandidate is the measured accuracy of a HyperLogLog precision on a sample.
+type HyperCandidate struct {
+	Precision uint8   // Number of bits used for register indexes
+	Bytes     uint64  // Size of the dense registers
+	Estimate  uint64  // Estimated cardinality of the sample
+	Error     float64 // Relative error of the estimate against the exact cardinality
+}
+
+// BloomCandidate is the measured false positive rate of Bloom filter parameters on a sample.
+type BloomCandidate struct {
+	FalsePositive float64 // Configured false positive rate
+	M             uint    // Bit array size
+	K             uint    // Number of hash functions
+	Bytes         uint64  // Size of the bit array
+	Measured      float64 // False positive rate measured over values outside the sample
+}
+
+// Recommendation is the result of analyzing a sample with BloomRecommend.
+type Recommendation struct {
+	Distinct  uint64           // Exact number of distinct values in the sample
+	Hyper     []HyperCandidate // Every evaluated HyperLogLog precision
+	Bloom     []BloomCandidate // Every evaluated Bloom filter parameters
+	BestHyper int              // Index of the recommended HyperLogLog precision
+	BestBloom int              // Index of the recommended Bloom filter parameters
+}
+
+// BloomRecommend inserts the sample into a few candidate configurations and measures their accuracy.
+// The recommended HyperLogLog precision is the smallest one within maxError of the exact cardinality,
+// and the recommended Bloom filter is the smallest one whose measured false positive rate doesn't exceed fpr.
+// When no candidate meets the target, the most accurate one is recommended.
+// It returns ErrInvalidSample if the sample is empty or larger than MaxRecommendSample.
+func BloomRecommend(sample []string, fpr, maxError float64) (*Recommendation, error) {
+	if len(sample) == 0 || len(sample) > MaxRecommendSample {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d, got %d", ErrInvalidSample, MaxRecommendSample, len(sample))
+	}
+
+	// Count the distinct values exactly
+	distinct := make(map[string]struct{}, len(sample))
+	for _, value := range sample {
+		distinct[value] = struct{}{}
+	}
+	rec := &Recommendation{Distinct: uint64(len(distinct))}
+
+	for _, candidate := range recommendSketches {
+		precision := candidate.precision
+		hll := candidate.new()
+		for _, value := range sample {
+			hll.Insert([]byte(value))
+		}
+
+		estimate := hll.Estimate()
+		rec.Hyper = append(rec.Hyper, HyperCandidate{
+			Precision: precision,
+			Bytes:     uint64(1) << precision / 2,
+			Estimate:  estimate,
+			Error:     math.Abs(float64(estimate)-float64(rec.Distinct)) / float64(rec.Distinct),
+		})
+	}
+
+	// Probe with values guaranteed to be outside the sample
+	probes := make([][]byte, 0, recommendProbes)
+	for i := 0; len(probes) < recommendProbes; i++ {
+		probe := "probe:" + strconv.Itoa(i)
+		if _, ok := distinct[probe]; !ok {
+			probes = append(probes, []byte(probe))
+		}
+	}
+
+	for _, factor := range recommendFPRFactors {
+		candidateFPR := fpr * factor
+		if candidateFPR >= 1 {
+			continue
+		}
+
+		bf := bloom.NewWithEstimates(uint(len(distinct)), candidateFPR)
+		for value := range distinct {
+			bf.Add([]byte(value))
+		}
+
+		positives := 0
+		for _, probe := range probes {
+			if bf.Test(probe) {
+				positives++
+			}
+		}
+
+		rec.Bloom = append(rec.Bloom, BloomCandidate{
+			FalsePositive: candidateFPR,
+			M:             bf.Cap(),
+			K:             bf.K(),
+			Bytes:         uint64((bf.Cap()+63)/64) * 8,
+			Measured:      float64(positives) / float64(len(probes)),
+		})
+	}
+
+	rec.BestHyper = pickCandidate(len(rec.Hyper), func(i int) (uint64, float64) {
+		return rec.Hyper[i].Bytes, rec.Hyper[i].Error
+	}, maxError)
+	rec.BestBloom = pickCandidate(len(rec.Bloom), func(i int) (uint64, float64) {
+		return rec.Bloom[i].Bytes, rec.Bloom[i].Measured
+	}, fpr)
+
+	return rec, nil
+}
+
+// pickCandidate returns the index of the smallest candidate whose error is within target,
+// or of the candidate with the lowest error if none is.
+func pickCandidate(n int, candidate func(i int) (uint64, float64), target float64) int {
+	best, bestFits := -1, false
+	var bestBytes uint64
+	var bestErr float64
+
+	for i := 0; i < n; i++ {
+		bytes, err := candidate(i)
+		fits := err <= target
+
+		switch {
+		case best == -1,
+			fits && !bestFits,
+			fits && bytes < bestBytes,
+			!fits && !bestFits && err < bestErr:
+			best, bestFits, bestBytes, bestErr = i, fits, bytes, err
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,56 @@
+package service_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomRecommend(t *testing.T) {
+	sample := make([]string, 0, 6000)
+	for i := 0; i < 3000; i++ {
+		// Every value appears twice
+		sample = append(sample, "value-"+strconv.Itoa(i), "value-"+strconv.Itoa(i))
+	}
+
+	rec, err := service.BloomRecommend(sample, 0.01, 0.02)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Distinct != 3000 {
+		t.Errorf("distinct = %d, want 3000", rec.Distinct)
+	}
+
+	hyper := rec.Hyper[rec.BestHyper]
+	if hyper.Error > 0.02 {
+		t.Errorf("recommended precision %d has error %.4f above the target", hyper.Precision, hyper.Error)
+	}
+	for _, c := range rec.Hyper {
+		if c.Error <= 0.02 && c.Bytes < hyper.Bytes {
+			t.Errorf("precision %d meets the target with less memory than %d", c.Precision, hyper.Precision)
+		}
+	}
+
+	bf := rec.Bloom[rec.BestBloom]
+	if bf.Measured > 0.01 {
+		t.Errorf("recommended fpr %g measured %.4f above the target", bf.FalsePositive, bf.Measured)
+	}
+	for _, c := range rec.Bloom {
+		if c.Measured <= 0.01 && c.Bytes < bf.Bytes {
+			t.Errorf("fpr %g meets the target with less memory than %g", c.FalsePositive, bf.FalsePositive)
+		}
+	}
+}
+
+func TestBloomRecommendSampleCap(t *testing.T) {
+	if _, err := service.BloomRecommend(nil, 0.01, 0.02); !errors.Is(err, service.ErrInvalidSample) {
+		t.Errorf("empty sample: expected ErrInvalidSample, got %v", err)
+	}
+
+	sample := make([]string, service.MaxRecommendSample+1)
+	if _, err := service.BloomRecommend(sample, 0.01, 0.02); !errors.Is(err, service.ErrInvalidSample) {
+		t.Errorf("oversized sample: expected ErrInvalidSample, got %v", err)
+	}
+}
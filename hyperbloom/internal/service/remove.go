@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+// ErrNotCounting is returned when removing a value from a key not created as a counting filter.
+var ErrNotCounting = errors.New("key isn't a counting filter")
+
+// BloomRemove removes value from the HyperBloom identified by key, created as a counting filter (see BloomCreateKey),
+// and returns whether it was reported present. Removals are approximate, see models.Counting: removing a value
+// never inserted may make others read as absent, and the HyperLogLog cardinality doesn't drop. The removal is written
+// by the next flush. It returns ErrKeyNotFound if the key doesn't exist, ErrNotCounting if it isn't a counting filter,
+// and fails like bloomLookup otherwise.
+func BloomRemove(ctx context.Context, key, value string) (bool, error) {
+	operationsCounter().Add(operationRemove, 1)
+	db, err := bloomLookup(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := db.Remove(value)
+	if errors.Is(err, models.ErrNotCounting) {
+		return false, ErrNotCounting
+	}
+	if err != nil || !removed {
+		return false, err
+	}
+
+	// Keep the instance in memory until the removal is flushed
+	dbs.Set(db, key)
+	markDirty(ctx, key)
+
+	return true, nil
+}
+
+// BloomRemoveBatch removes several values from the HyperBloom identified by key like BloomRemove, taking the lock
+// of the instance once for the whole batch, and returns whether each value was reported present. The counters of a
+// value reported absent are already at 0 for some of its bits: it was likely never inserted or already removed,
+// and is left alone rather than underflowing counters owed to other values. The removals are written by the next
+// flush as a single change. It fails like BloomRemove, removing none of the values.
+func BloomRemoveBatch(ctx context.Context, key string, values []string) ([]bool, error) {
+	operationsCounter().Add(operationRemove, int64(len(values)))
+	db, err := bloomLookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := db.RemoveBatch(values)
+	if errors.Is(err, models.ErrNotCounting) {
+		return nil, ErrNotCounting
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the instance in memory until the removals are flushed
+	if slices.Contains(removed, true) {
+		dbs.Set(db, key)
+		markDirty(ctx, key)
+	}
+
+	return removed, nil
+}
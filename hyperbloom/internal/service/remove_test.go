@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomRemove(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("remove-", time.Now().UnixNano())
+	if _, err := service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{Counting: true}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	service.BloomHash(context.Background(), key, "kept")
+	service.BloomHash(context.Background(), key, "removed")
+
+	removed, err := service.BloomRemove(context.Background(), key, "removed")
+	if err != nil || !removed {
+		t.Fatalf("BloomRemove = %t, %v, want true", removed, err)
+	}
+
+	// The removal survives a flush
+	service.FlushAll(context.Background())
+	if bloomExists(t, context.Background(), key, "removed") {
+		t.Error("removed value still present")
+	}
+	if !bloomExists(t, context.Background(), key, "kept") {
+		t.Error("kept value absent after removing another")
+	}
+
+	plain := key + "-plain"
+	if _, err = service.BloomCreateKey(context.Background(), plain, 1000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = service.BloomRemove(context.Background(), plain, "kept"); !errors.Is(err, service.ErrNotCounting) {
+		t.Errorf("standard key: %v, want ErrNotCounting", err)
+	}
+	if _, err = service.BloomRemove(context.Background(), key+"-missing", "kept"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("missing key: %v, want ErrKeyNotFound", err)
+	}
+	if _, err = service.BloomCreateKey(context.Background(), key+"-strict", 1000, 0.01, service.CreateOptions{Strict: true, Counting: true}, service.OnExistsFail); !errors.Is(err, service.ErrInvalidCounting) {
+		t.Errorf("strict counting key: %v, want ErrInvalidCounting", err)
+	}
+}
+
+func TestBloomRemoveBatch(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("remove-batch-", time.Now().UnixNano())
+	if _, err := service.BloomCreateKey(context.Background(), key, 1000, 0.01, service.CreateOptions{Counting: true}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.BloomHashBatch(context.Background(), key, []string{"kept", "removed", "twice", "twice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values at zero, never inserted or already removed within the batch, are reported rather than decremented
+	removed, err := service.BloomRemoveBatch(context.Background(), key, []string{"removed", "removed", "twice", "never"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{true, false, true, false}; !slices.Equal(removed, want) {
+		t.Errorf("BloomRemoveBatch = %v, want %v", removed, want)
+	}
+
+	// The removals survive a flush, and the values at zero took nothing from the others
+	service.FlushAll(context.Background())
+	if bloomExists(t, context.Background(), key, "removed") {
+		t.Error("removed value still present")
+	}
+	for _, value := range []string{"kept", "twice"} {
+		if !bloomExists(t, context.Background(), key, value) {
+			t.Errorf("%q absent after removing others", value)
+		}
+	}
+
+	plain := key + "-plain"
+	if _, err = service.BloomCreateKey(context.Background(), plain, 1000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = service.BloomRemoveBatch(context.Background(), plain, []string{"kept"}); !errors.Is(err, service.ErrNotCounting) {
+		t.Errorf("standard key: %v, want ErrNotCounting", err)
+	}
+	if _, err = service.BloomRemoveBatch(context.Background(), key+"-missing", []string{"kept"}); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("missing key: %v, want ErrKeyNotFound", err)
+	}
+}
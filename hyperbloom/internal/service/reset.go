@@ -0,0 +1,23 @@
+package service
+
+import "context"
+
+// BloomReset empties the HyperBloom identified by key while keeping its configuration and metadata,
+// see models.HyperBloom.Reset, so that it can be rebuilt without sizing it again. The emptied filters are written
+// by the next flush. It returns ErrKeyNotFound if the key doesn't exist, and fails like bloomLookup otherwise.
+func BloomReset(ctx context.Context, key string) error {
+	db, err := bloomLookup(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if err = db.Reset(); err != nil {
+		return err
+	}
+
+	// Keep the emptied instance in memory until it is flushed
+	dbs.Set(db, key)
+	markDirty(ctx, key)
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomReset(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("reset-", time.Now().UnixNano())
+	if _, err := service.BloomCreateKey(context.Background(), key, 10000, 0.001, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+	service.FlushAll(context.Background())
+
+	// Hold the flush so the reset is still waiting for it when checked
+	service.PauseFlush()
+	if err := service.BloomReset(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(service.FlushBacklog(), key) {
+		t.Errorf("%s missing from the backlog %v", key, service.FlushBacklog())
+	}
+	service.ResumeFlush()
+	service.FlushAll(context.Background())
+
+	// The key is empty but keeps its sizing
+	bCard, hCard := bloomCardinality(t, context.Background(), key)
+	if bCard != 0 || hCard != 0 {
+		t.Errorf("cardinalities after reset = (%d, %d), want (0, 0)", bCard, hCard)
+	}
+	if bloomExists(t, context.Background(), key, "0") {
+		t.Error("value still present after reset")
+	}
+	stats, err := service.BloomStats(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Capacity != 10000 || stats.TargetFPR != float64(float32(0.001)) {
+		t.Errorf("sizing after reset = (%d, %g), want (10000, 0.001)", stats.Capacity, stats.TargetFPR)
+	}
+
+	if err = service.BloomReset(context.Background(), key+"-missing"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("missing key: %v, want ErrKeyNotFound", err)
+	}
+}
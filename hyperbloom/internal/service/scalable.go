@@ -0,0 +1,40 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gopds/hyperbloom/internal/config"
+)
+
+// ErrInvalidScalable is returned when creating a scalable key with a growth factor below 1 or a tightening ratio
+// outside (0, 1), or with strict membership, whose second filter doesn't grow.
+var ErrInvalidScalable = errors.New("invalid scalable parameters")
+
+// Scalable describes how the Bloom filter of a key grows once saturated, see models.Scalable.
+// A zero field falls back to the configured default (HB_SCALABLE_GROWTH and HB_SCALABLE_TIGHTENING).
+type Scalable struct {
+	Growth     float64 // Capacity of a layer relative to the previous one, at least 1
+	Tightening float64 // False positive rate of a layer relative to the previous one, between 0 and 1
+}
+
+// withDefaults returns the parameters with the zero fields replaced by the configured defaults.
+func (s Scalable) withDefaults() Scalable {
+	cfg := config.HyperBloomCfg()
+	if s.Growth == 0 {
+		s.Growth = cfg.ScalableGrowth
+	}
+	if s.Tightening == 0 {
+		s.Tightening = cfg.ScalableTightening
+	}
+	return s
+}
+
+// validateScalable checks that a growth factor and tightening ratio make the layers grow and their compound
+// false positive rate converge.
+func validateScalable(growth, tightening float64) error {
+	if !(growth >= 1) || !(tightening > 0 && tightening < 1) {
+		return fmt.Errorf("%w: growth %g must be at least 1 and tightening %g between 0 and 1", ErrInvalidScalable, growth, tightening)
+	}
+	return nil
+}
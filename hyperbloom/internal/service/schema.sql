@@ -0,0 +1,60 @@
+-- Schema of the HyperBloom storage, applied by Migrate in a single transaction.
+-- Every statement is idempotent: it creates the tables of a fresh database, and adds what newer versions
+-- introduced to the tables of an older one. Append new columns and indexes, never edit applied statements.
+
+-- Serialize the migrations of instances starting at once, the lock is released with the transaction
+SELECT pg_advisory_xact_lock(hashtext('hyperblooms_schema'));
+
+-- Filters and sketches of every key, in the blob format recorded by format_version (see models.FormatVersion)
+CREATE TABLE IF NOT EXISTS hyperblooms (
+	key VARCHAR PRIMARY KEY,
+	bloombyte BYTEA,
+	hyperbyte BYTEA
+);
+
+-- Optional first insert times, strict membership filter, time-decayed distinct count, scalable layers,
+-- counters of counting filters and most frequent values, added to tables created before they existed
+ALTER TABLE hyperblooms ADD COLUMN IF NOT EXISTS firstseen BYTEA;
+ALTER TABLE hyperblooms ADD COLUMN IF NOT EXISTS strictbyte BYTEA;
+ALTER TABLE hyperblooms ADD COLUMN IF NOT EXISTS recencybyte BYTEA;
+ALTER TABLE hyperblooms ADD COLUMN IF NOT EXISTS scalablebyte BYTEA;
+ALTER TABLE hyperblooms ADD COLUMN IF NOT EXISTS countingbyte BYTEA;
+ALTER TABLE hyperblooms ADD COLUMN IF NOT EXISTS topkbyte BYTEA;
+
+-- Cardinality and time of the last write for listing keys without loading them,
+-- rows written before they were recorded are left NULL until their next write
+ALTER TABLE hyperblooms ADD COLUMN IF NOT EXISTS cardinality BIGINT, ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+-- Format of the blobs, rows written before the format was versioned are raw (models.FormatRaw)
+ALTER TABLE hyperblooms ADD COLUMN IF NOT EXISTS format_version INTEGER NOT NULL DEFAULT 1;
+
+-- Parameters every key was created with
+CREATE TABLE IF NOT EXISTS hyperblooms_metadata (
+	key VARCHAR,
+	max_cardinality INTEGER,
+	false_positive REAL,
+	bit_capacity INTEGER,
+	no_hash_func INTEGER,
+	decay_sec BIGINT,
+	FOREIGN KEY (key) REFERENCES hyperblooms(key)
+);
+
+-- Optional value type policy, creation time and expiry time, added to tables created before they existed.
+-- Rows written before creation times were recorded are left NULL, as are the keys that never expire
+ALTER TABLE hyperblooms_metadata ADD COLUMN IF NOT EXISTS value_type VARCHAR;
+ALTER TABLE hyperblooms_metadata ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE hyperblooms_metadata ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+-- Keys are loaded, listed and deleted by joining their metadata, and swept once expired
+CREATE INDEX IF NOT EXISTS hyperblooms_metadata_key_idx ON hyperblooms_metadata (key);
+CREATE INDEX IF NOT EXISTS hyperblooms_metadata_expires_at_idx ON hyperblooms_metadata (expires_at) WHERE expires_at IS NOT NULL;
+
+-- Count-Min Sketches and Cuckoo filters, keyed like HyperBlooms
+CREATE TABLE IF NOT EXISTS countmins (
+	key VARCHAR PRIMARY KEY,
+	sketch BYTEA
+);
+CREATE TABLE IF NOT EXISTS cuckoos (
+	key VARCHAR PRIMARY KEY,
+	filter BYTEA
+);
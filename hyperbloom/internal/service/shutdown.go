@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+// shutdownCtx bounds the final flush, Shutdown sets it before signaling the update goroutine.
+var shutdownCtx = context.Background()
+
+// flushPending holds the keys the current FlushAll hasn't persisted yet.
+var flushPending = struct {
+	sync.Mutex
+	keys map[string]bool
+}{keys: map[string]bool{}}
+
+// FlushAll writes every in-memory HyperBloom and every changed Count-Min Sketch and Cuckoo filter to the database, stopping when
+// ctx expires, and returns the HyperBloom keys that couldn't be written, sorted. UnflushedKeys reports its progress meanwhile.
+func FlushAll(ctx context.Context) []string {
+	blooms := dbs.GetInMemoryHyperBlooms()
+	markPending(blooms)
+
+	for _, db := range blooms {
+		if ctx.Err() != nil {
+			break
+		}
+
+		slog.Debug("Flush Hyperbloom object to database", "key", db.Key())
+		if err := bloomUpdateContext(ctx, db); err != nil {
+			slog.Error("Can't flush", "key", db.Key(), "err", err)
+			continue
+		}
+
+		flushPending.Lock()
+		delete(flushPending.keys, db.Key())
+		flushPending.Unlock()
+	}
+
+	// The Count-Min Sketches and Cuckoo filters aren't tracked as pending, a failed write is only logged
+	flushCountMins(ctx)
+	flushCuckoos(ctx)
+
+	return UnflushedKeys()
+}
+
+// markPending records every key of blooms as not flushed yet.
+func markPending(blooms []*models.HyperBloom) {
+	flushPending.Lock()
+	defer flushPending.Unlock()
+
+	flushPending.keys = map[string]bool{}
+	for _, db := range blooms {
+		flushPending.keys[db.Key()] = true
+	}
+}
+
+// UnflushedKeys returns the keys the last FlushAll hasn't written yet, sorted.
+func UnflushedKeys() []string {
+	flushPending.Lock()
+	defer flushPending.Unlock()
+
+	keys := make([]string, 0, len(flushPending.keys))
+	for key := range flushPending.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Shutdown stops the expiry sweeper and the asynchronous update process, which flushes every in-memory HyperBloom
+// one last time, and waits for both until ctx expires. It returns the keys that weren't flushed, and ctx.Err() if
+// they didn't stop in time, e.g. because the database doesn't answer. Shutdown can only be called once.
+func Shutdown(ctx context.Context) ([]string, error) {
+	// Until the final flush starts, e.g. while a periodic flush is still running, every key is pending
+	markPending(dbs.GetInMemoryHyperBlooms())
+
+	// Interrupt a sweep in progress, the keys it didn't reach are swept after the restart
+	stopExpirySweep()
+
+	// Closing the channel publishes shutdownCtx to the update goroutine
+	shutdownCtx = ctx
+	close(StopAsyncBloomUpdate)
+
+	for _, done := range []chan struct{}{expirySweepDone, AsyncBloomUpdateDone} {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return UnflushedKeys(), ctx.Err()
+		}
+	}
+	return UnflushedKeys(), nil
+}
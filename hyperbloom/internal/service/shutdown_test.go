@@ -0,0 +1,55 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestFlushAllHangingDatabase(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("flush-hang-", time.Now().UnixNano())
+	service.BloomHash(context.Background(), key, "value")
+
+	// Hold an exclusive lock on the table from another connection, the flush blocks on it like on an unresponsive database
+	client, err := sql.Open("postgres", config.PostgresCfg.GetDataSourceName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	tx, err := client.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec(`LOCK TABLE hyperblooms IN ACCESS EXCLUSIVE MODE`); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+
+	// The flush gives up at the deadline and reports the key
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	unflushed := service.FlushAll(ctx)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("flush took %s past a 300ms deadline", elapsed)
+	}
+	if !slices.Contains(unflushed, key) || !slices.Contains(service.UnflushedKeys(), key) {
+		t.Errorf("%s missing from the unflushed keys %v", key, unflushed)
+	}
+
+	// Once the database answers again, the key is flushed
+	tx.Rollback()
+	if unflushed = service.FlushAll(context.Background()); slices.Contains(unflushed, key) {
+		t.Errorf("%s still unflushed after the lock was released", key)
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+// Behaviors of a similarity query between keys whose Bloom filters can't be compared bit by bit.
+const (
+	OnMismatchError = "error" // Fail with ErrIncompatibleFilters
+	OnMismatchHyper = "hll"   // Estimate the similarity from the HyperLogLog sketches, which don't depend on the filter size
+)
+
+// ErrIncompatibleFilters is returned when the Bloom filters of two keys differ in size or number of hash functions.
+var ErrIncompatibleFilters = errors.New("incompatible filters")
+
+// ErrInvalidOnMismatch is returned for an unknown behavior on incompatible filters.
+var ErrInvalidOnMismatch = errors.New("invalid behavior on incompatible filters")
+
+// validateOnMismatch returns ErrInvalidOnMismatch for an unknown behavior on incompatible filters.
+func validateOnMismatch(onMismatch string) error {
+	if onMismatch != "" && onMismatch != OnMismatchError && onMismatch != OnMismatchHyper {
+		return fmt.Errorf("%w: %q, expected %s or %s", ErrInvalidOnMismatch, onMismatch, OnMismatchError, OnMismatchHyper)
+	}
+	return nil
+}
+
+// checkFilters decides how the Bloom filters of db1 and db2 can be compared under onMismatch. Filters of the same size
+// and number of hash functions are compared bit by bit. Otherwise it reports whether to fall back to the HyperLogLog
+// sketches with OnMismatchHyper, and returns ErrIncompatibleFilters with OnMismatchError or an empty onMismatch.
+func checkFilters(db1, db2 *models.HyperBloom, onMismatch string) (bool, error) {
+	bf1, bf2 := db1.Bloom(), db2.Bloom()
+	if bf1.Cap() == bf2.Cap() && bf1.K() == bf2.K() {
+		return false, nil
+	}
+
+	if onMismatch != OnMismatchHyper {
+		return false, fmt.Errorf("%w: %s has %d bits and %d hash functions, %s has %d bits and %d hash functions",
+			ErrIncompatibleFilters, db1.Key(), bf1.Cap(), bf1.K(), db2.Key(), bf2.Cap(), bf2.K())
+	}
+	return true, nil
+}
+
+// BloomSimilarityOnMismatch calculates the Jaccard similarity between the keys like BloomSimilarity, and reports
+// whether it fell back to the HyperLogLog sketches. Comparing bit arrays only makes sense for filters of the same size
+// and number of hash functions: otherwise it returns ErrIncompatibleFilters with OnMismatchError (the default when
+// onMismatch is empty), or estimates |A∩B|/|A∪B| by inclusion-exclusion on the sketches with OnMismatchHyper,
+// see BloomHyperSimilarity. The similarity is 0 if one of the keys doesn't exist.
+// It returns ErrInvalidOnMismatch for an unknown behavior.
+func BloomSimilarityOnMismatch(ctx context.Context, key1, key2, onMismatch string) (float32, bool, error) {
+	if err := validateOnMismatch(onMismatch); err != nil {
+		return 0, false, err
+	}
+
+	operationsCounter().Add(operationSimilarity, 1)
+
+	db1, db2 := BloomGet(ctx, key1), BloomGet(ctx, key2)
+	if db1 == nil || db2 == nil {
+		return 0, false, nil
+	}
+
+	fallback, err := checkFilters(db1, db2, onMismatch)
+	if err != nil {
+		return 0, false, err
+	}
+	if !fallback {
+		return models.JaccardSimBF(db1, db2), false, nil
+	}
+
+	sim, err := BloomHyperSimilarity(ctx, key1, key2)
+	return sim, true, err
+}
+
+// BloomHyperSimilarity estimates the Jaccard similarity |A∩B|/|A∪B| of the keys from their HyperLogLog sketches,
+// the intersection being |A|+|B|-|A∪B| clamped to zero, see BloomOverlap. Two empty keys are identical.
+// It returns ErrKeyNotFound if one of the keys doesn't exist, and ErrIncompatibleSketch if their precisions differ.
+//
+// The two estimators fail differently. The Bloom filter estimate compares bits rather than values: bits shared
+// by chance inflate it as the filters fill up, so it is reliable for filters well below their capacity,
+// drifts towards 1 as they saturate, and can't compare filters of different sizes. The HyperLogLog estimate doesn't
+// depend on the filters, but the error of the three cardinalities is relative to the sets and lands on the
+// intersection: it is reliable for sets of similar size that overlap substantially, and noisy for small similarities
+// or a set much smaller than the other, where the error can exceed the intersection itself.
+func BloomHyperSimilarity(ctx context.Context, key1, key2 string) (float32, error) {
+	c, err := BloomOverlap(ctx, key1, key2)
+	if err != nil {
+		return 0, err
+	}
+
+	// Two empty sketches represent the same (empty) set
+	if c.Union == 0 {
+		return 1, nil
+	}
+	return float32(c.Intersection) / float32(c.Union), nil
+}
@@ -0,0 +1,112 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomSimilarityOnMismatch(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	small := fmt.Sprint("similarity-small-", suffix)
+	large := fmt.Sprint("similarity-large-", suffix)
+	same := fmt.Sprint("similarity-same-", suffix)
+
+	// small and same share their size, large is ten times bigger
+	for key, capacity := range map[string]uint{small: 10000, large: 100000, same: 10000} {
+		if _, err := service.BloomCreateKey(context.Background(), key, capacity, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each pair overlaps by a third: |A∩B| = 1000, |A∪B| = 3000
+	for i := 0; i < 2000; i++ {
+		service.BloomHash(context.Background(), small, strconv.Itoa(i))
+		service.BloomHash(context.Background(), large, strconv.Itoa(i+1000))
+		service.BloomHash(context.Background(), same, strconv.Itoa(i+1000))
+	}
+
+	// Filters of the same size are compared bit by bit whatever the behavior
+	for _, onMismatch := range []string{"", service.OnMismatchError, service.OnMismatchHyper} {
+		sim, fallback, err := service.BloomSimilarityOnMismatch(context.Background(), small, same, onMismatch)
+		if err != nil || fallback {
+			t.Fatalf("%q on compatible filters: fallback %t, %v", onMismatch, fallback, err)
+		}
+		if want, _ := service.BloomSimilarity(context.Background(), small, same); sim != want {
+			t.Errorf("%q on compatible filters = %f, want %f", onMismatch, sim, want)
+		}
+	}
+
+	// Incompatible filters are an error by default, and always for BloomSimilarity
+	for _, onMismatch := range []string{"", service.OnMismatchError} {
+		if _, _, err := service.BloomSimilarityOnMismatch(context.Background(), small, large, onMismatch); !errors.Is(err, service.ErrIncompatibleFilters) {
+			t.Errorf("%q on incompatible filters: %v, want ErrIncompatibleFilters", onMismatch, err)
+		}
+	}
+	if _, err := service.BloomSimilarity(context.Background(), small, large); !errors.Is(err, service.ErrIncompatibleFilters) {
+		t.Errorf("BloomSimilarity on incompatible filters: %v, want ErrIncompatibleFilters", err)
+	}
+
+	// Or estimated from the sketches
+	sim, fallback, err := service.BloomSimilarityOnMismatch(context.Background(), small, large, service.OnMismatchHyper)
+	if err != nil || !fallback {
+		t.Fatalf("hll on incompatible filters: fallback %t, %v", fallback, err)
+	}
+	if math.Abs(float64(sim)-1.0/3) > 0.1 {
+		t.Errorf("hll similarity = %f, want about %f", sim, 1.0/3)
+	}
+
+	if _, _, err = service.BloomSimilarityOnMismatch(context.Background(), small, large, "guess"); !errors.Is(err, service.ErrInvalidOnMismatch) {
+		t.Errorf("unknown behavior: %v, want ErrInvalidOnMismatch", err)
+	}
+}
+
+func TestBloomHyperSimilarity(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	a := fmt.Sprint("hyper-similarity-a-", suffix)
+	b := fmt.Sprint("hyper-similarity-b-", suffix)
+	disjoint := fmt.Sprint("hyper-similarity-disjoint-", suffix)
+	for _, key := range []string{a, b, disjoint} {
+		if _, err := service.BloomCreateKey(context.Background(), key, 10000, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a and b overlap by a third: |A∩B| = 1000, |A∪B| = 3000, disjoint shares nothing with a
+	for i := 0; i < 2000; i++ {
+		service.BloomHash(context.Background(), a, strconv.Itoa(i))
+		service.BloomHash(context.Background(), b, strconv.Itoa(i+1000))
+		service.BloomHash(context.Background(), disjoint, fmt.Sprint("other-", i))
+	}
+
+	sim, err := service.BloomHyperSimilarity(context.Background(), a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(float64(sim)-1.0/3) > 0.1 {
+		t.Errorf("similarity = %f, want about %f", sim, 1.0/3)
+	}
+
+	// The union estimate may exceed the sum of the inputs, the intersection is clamped rather than negative
+	sim, err = service.BloomHyperSimilarity(context.Background(), a, disjoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim < 0 || sim > 0.05 {
+		t.Errorf("similarity of disjoint keys = %f, want about 0", sim)
+	}
+
+	if _, err = service.BloomHyperSimilarity(context.Background(), a, fmt.Sprint("hyper-similarity-missing-", suffix)); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("missing key: %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"gopds/hyperbloom/pkg/models"
+
+	"github.com/bits-and-blooms/bitset"
+)
+
+// BloomSimilarityMatrix computes the pairwise Jaccard similarities between the Bloom filters of keys.
+// Each filter is fetched and its bit array snapshotted once up front, so the n² comparisons neither
+// go back to the registry (or the database) nor observe inserts happening meanwhile.
+// The similarity involving a key that doesn't exist is 0, as with BloomSimilarity.
+// Pairs of filters that differ in size or number of hash functions are handled as in BloomSimilarityOnMismatch:
+// the matrix fails with ErrIncompatibleFilters, or their similarity is estimated from the sketches with OnMismatchHyper.
+// It returns ErrInvalidOnMismatch for an unknown behavior.
+func BloomSimilarityMatrix(ctx context.Context, keys []string, onMismatch string) ([][]float32, error) {
+	if err := validateOnMismatch(onMismatch); err != nil {
+		return nil, err
+	}
+
+	// Snapshot every involved bit array, a nil HyperBloom marks a missing key
+	dbs := make([]*models.HyperBloom, len(keys))
+	snapshots := make([]*bitset.BitSet, len(keys))
+	for i, key := range keys {
+		if db := BloomGet(ctx, key); db != nil {
+			dbs[i], snapshots[i] = db, db.BitSet()
+		}
+	}
+
+	matrix := make([][]float32, len(keys))
+	for i := range matrix {
+		matrix[i] = make([]float32, len(keys))
+	}
+
+	// The matrix is symmetric, compute each pair once
+	for i := range keys {
+		if dbs[i] == nil {
+			continue
+		}
+		for j := i; j < len(keys); j++ {
+			if dbs[j] == nil {
+				continue
+			}
+
+			fallback, err := checkFilters(dbs[i], dbs[j], onMismatch)
+			if err != nil {
+				return nil, err
+			}
+
+			var sim float32
+			if fallback {
+				if sim, err = BloomHyperSimilarity(ctx, keys[i], keys[j]); err != nil {
+					return nil, err
+				}
+			} else {
+				sim = models.JaccardSimBitSet(snapshots[i], snapshots[j])
+			}
+			matrix[i][j], matrix[j][i] = sim, sim
+		}
+	}
+
+	return matrix, nil
+}
@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+// similarityKeys creates n HyperBlooms sharing part of their values.
+func similarityKeys(tb testing.TB, n int) []string {
+	tb.Helper()
+
+	suffix := time.Now().UnixNano()
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprint("simmatrix-", i, "-", suffix)
+		for j := 0; j < 1000; j++ {
+			service.BloomHash(context.Background(), keys[i], strconv.Itoa(i*100+j))
+		}
+	}
+	return keys
+}
+
+func TestBloomSimilarityMatrix(t *testing.T) {
+	requireDatabase(t)
+
+	keys := append(similarityKeys(t, 5), "simmatrix-missing")
+	matrix, err := service.BloomSimilarityMatrix(context.Background(), keys, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range keys {
+		for j := range keys {
+			want := float32(0)
+			if i < 5 && j < 5 {
+				want, _ = service.BloomSimilarity(context.Background(), keys[i], keys[j])
+			}
+			if matrix[i][j] != want {
+				t.Errorf("matrix[%d][%d] = %f, want %f", i, j, matrix[i][j], want)
+			}
+		}
+	}
+
+	// A filter of another size fails the matrix by default, or is compared through its sketch
+	small := fmt.Sprint("simmatrix-small-", time.Now().UnixNano())
+	if _, err := service.BloomCreateKey(context.Background(), small, 100, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	service.BloomHash(context.Background(), small, "0")
+	keys = []string{keys[0], keys[1], small}
+
+	for _, onMismatch := range []string{"", service.OnMismatchError} {
+		if _, err := service.BloomSimilarityMatrix(context.Background(), keys, onMismatch); !errors.Is(err, service.ErrIncompatibleFilters) {
+			t.Errorf("%q on incompatible filters: %v, want ErrIncompatibleFilters", onMismatch, err)
+		}
+	}
+
+	matrix, err = service.BloomSimilarityMatrix(context.Background(), keys, service.OnMismatchHyper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := service.BloomSimilarity(context.Background(), keys[0], keys[1]); matrix[0][1] != want {
+		t.Errorf("compatible pair = %f, want %f", matrix[0][1], want)
+	}
+	if want, _ := service.BloomHyperSimilarity(context.Background(), keys[0], small); matrix[0][2] != want || matrix[2][0] != want {
+		t.Errorf("incompatible pair = (%f, %f), want %f", matrix[0][2], matrix[2][0], want)
+	}
+
+	if _, err := service.BloomSimilarityMatrix(context.Background(), keys, "guess"); !errors.Is(err, service.ErrInvalidOnMismatch) {
+		t.Errorf("unknown behavior: %v, want ErrInvalidOnMismatch", err)
+	}
+}
+
+// BenchmarkSimilarityPairwise computes a 50-key matrix with one BloomSimilarity call per pair.
+func BenchmarkSimilarityPairwise(b *testing.B) {
+	keys := similarityKeys(b, 50)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		for i := range keys {
+			for j := range keys {
+				service.BloomSimilarity(context.Background(), keys[i], keys[j])
+			}
+		}
+	}
+}
+
+// BenchmarkSimilarityMatrix computes the same matrix from snapshots taken once per key.
+func BenchmarkSimilarityMatrix(b *testing.B) {
+	keys := similarityKeys(b, 50)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		service.BloomSimilarityMatrix(context.Background(), keys, "")
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gopds/hyperbloom/internal/config"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// hyperRegisterBytes is the size of a dense HyperLogLog sketch at the default precision (2^14 four-bit registers).
+const hyperRegisterBytes = (1 << 14) / 2
+
+// ErrInfeasibleSizing is returned when the requested capacity and false positive rate can't be satisfied
+// within the configured limits.
+var ErrInfeasibleSizing = errors.New("infeasible sizing")
+
+// ValidateSizing checks that an expected cardinality n and false positive rate fpr can be served
+// without exceeding the configured minimum false positive rate and maximum bit array size.
+func ValidateSizing(n uint, fpr float64) error {
+	return validateSizing(*config.HyperBloomCfg(), n, fpr)
+}
+
+// ValidateConfig checks that a HyperBloom configuration can be served: the default parameters of
+// auto-created keys must fit its own limits, the flush interval must be positive, the flush batch size and the
+// expiry sweep interval must not be negative and the transformation fallback policy must be known.
+func ValidateConfig(cfg config.HyperBloomConfig) error {
+	if cfg.UpdateRate <= 0 {
+		return fmt.Errorf("invalid update rate %s: must be positive", cfg.UpdateRate)
+	}
+	if cfg.FlushStallTimeout < 0 || (cfg.FlushStallTimeout > 0 && cfg.FlushStallTimeout <= cfg.UpdateRate) {
+		return fmt.Errorf("invalid HB_FLUSH_STALL_TIMEOUT %s: must be 0 or above the update rate %s", cfg.FlushStallTimeout, cfg.UpdateRate)
+	}
+	if cfg.FlushBatchSize < 0 {
+		return fmt.Errorf("invalid HB_FLUSH_BATCH_SIZE %d: must not be negative", cfg.FlushBatchSize)
+	}
+	if cfg.ExpirySweepInterval < 0 {
+		return fmt.Errorf("invalid HB_EXPIRY_SWEEP_INTERVAL %s: must not be negative", cfg.ExpirySweepInterval)
+	}
+	if cfg.CacheMaxKeys < 0 {
+		return fmt.Errorf("invalid HB_CACHE_MAX_KEYS %d: must not be negative", cfg.CacheMaxKeys)
+	}
+	if cfg.CacheMaxBytes < 0 {
+		return fmt.Errorf("invalid HB_CACHE_MAX_BYTES %d: must not be negative", cfg.CacheMaxBytes)
+	}
+	if err := validateTransformFallback(cfg.TransformFallback); err != nil {
+		return err
+	}
+	if cfg.CountMinEpsilon <= 0 || cfg.CountMinEpsilon >= 1 || cfg.CountMinDelta <= 0 || cfg.CountMinDelta >= 1 {
+		return fmt.Errorf("invalid HB_CMS_EPSILON %g or HB_CMS_DELTA %g: must be between 0 and 1", cfg.CountMinEpsilon, cfg.CountMinDelta)
+	}
+	if cfg.CuckooCapacity == 0 || cfg.CuckooCapacity > maxCuckooCapacity {
+		return fmt.Errorf("invalid HB_CUCKOO_CAPACITY %d: must be between 1 and %d", cfg.CuckooCapacity, maxCuckooCapacity)
+	}
+	if err := validateScalable(cfg.ScalableGrowth, cfg.ScalableTightening); err != nil {
+		return fmt.Errorf("invalid HB_SCALABLE_GROWTH or HB_SCALABLE_TIGHTENING: %w", err)
+	}
+	if _, err := ParseOperator(cfg.DefaultOperator); err != nil {
+		return fmt.Errorf("invalid HB_DEFAULT_OPERATOR: %w", err)
+	}
+
+	return validateSizing(cfg, cfg.Cardinality, cfg.FalsePositive)
+}
+
+// validateSizing checks n and fpr against the limits of cfg.
+func validateSizing(cfg config.HyperBloomConfig, n uint, fpr float64) error {
+	// Reject rates below the configured floor before they blow up the bit array size
+	if fpr < cfg.MinFalsePositive {
+		return fmt.Errorf(
+			"%w: false positive rate %g is below the minimum of %g",
+			ErrInfeasibleSizing, fpr, cfg.MinFalsePositive,
+		)
+	}
+
+	// Compute m in floating point first, converting an oversized value to uint would overflow
+	bits := math.Ceil(-1 * float64(n) * math.Log(fpr) / math.Pow(math.Log(2), 2))
+	if bits > float64(cfg.MaxBits) {
+		return fmt.Errorf(
+			"%w: %d elements at false positive rate %g need %.0f bits, above the maximum of %d",
+			ErrInfeasibleSizing, n, fpr, bits, cfg.MaxBits,
+		)
+	}
+
+	return nil
+}
+
+// TheoreticalFPR computes the false positive rate of a Bloom filter of m bits with k hash functions
+// once it holds n elements: (1 - e^(-kn/m))^k.
+func TheoreticalFPR(n, m, k uint) float64 {
+	return math.Pow(1-math.Exp(-float64(k)*float64(n)/float64(m)), float64(k))
+}
+
+// BloomSizing computes the Bloom filter parameters for an expected cardinality n and false positive rate fpr,
+// along with the estimated memory footprint in bytes (bit array + HyperLogLog registers), without creating anything.
+// It returns ErrInfeasibleSizing if the parameters fall outside the configured limits.
+func BloomSizing(n uint, fpr float64) (uint, uint, uint64, error) {
+	if err := ValidateSizing(n, fpr); err != nil {
+		return 0, 0, 0, err
+	}
+
+	// Derive the bit array size (m) and number of hash functions (k) from the sizing formulas
+	m, k := bloom.EstimateParameters(n, fpr)
+
+	// The bit array is stored as 64-bit words
+	bytes := uint64((m+63)/64)*8 + hyperRegisterBytes
+
+	return m, k, bytes, nil
+}
@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomSizing(t *testing.T) {
+	// Expected values computed from m = ceil(-n ln(p) / ln(2)^2) and k = ceil(ln(2) m / n)
+	cases := []struct {
+		n     uint
+		fpr   float64
+		m     uint
+		k     uint
+		bytes uint64
+	}{
+		{1000000, 0.001, 14377588, 10, 1797200 + 8192},
+		{10000, 0.0081, 100237, 7, 12536 + 8192},
+		{1000, 0.01, 9586, 7, 1200 + 8192},
+	}
+
+	for _, c := range cases {
+		m, k, bytes, err := service.BloomSizing(c.n, c.fpr)
+		if err != nil {
+			t.Fatalf("BloomSizing(%d, %g) returned error: %v", c.n, c.fpr, err)
+		}
+		if m != c.m || k != c.k || bytes != c.bytes {
+			t.Errorf(
+				"BloomSizing(%d, %g) = (%d, %d, %d), want (%d, %d, %d)",
+				c.n, c.fpr, m, k, bytes, c.m, c.k, c.bytes,
+			)
+		}
+	}
+}
+
+func TestBloomSizingLimits(t *testing.T) {
+	// Restore the configured limits once done
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) {
+		cfg.MinFalsePositive = 1e-9
+		cfg.MaxBits = 9586
+	})
+
+	cases := []struct {
+		name     string
+		n        uint
+		fpr      float64
+		feasible bool
+	}{
+		{"at the bit cap", 1000, 0.01, true},
+		{"just over the cap", 1000, 0.0099, false},
+		{"at the minimum rate", 1, 1e-9, true},
+		{"below the minimum rate", 1, 9.99e-10, false},
+		{"absurdly low rate", 1000000, 1e-30, false},
+		{"overflowing cardinality", math.MaxUint, 0.01, false},
+	}
+
+	for _, c := range cases {
+		_, _, _, err := service.BloomSizing(c.n, c.fpr)
+		if c.feasible && err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if !c.feasible && !errors.Is(err, service.ErrInfeasibleSizing) {
+			t.Errorf("%s: expected ErrInfeasibleSizing, got %v", c.name, err)
+		}
+	}
+}
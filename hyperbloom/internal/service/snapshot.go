@@ -0,0 +1,293 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// snapshotMagic starts every snapshot file so foreign files are rejected before anything is parsed.
+const snapshotMagic = "HYPERBLOOM-SNAPSHOT\n"
+
+// snapshotNilBlob is the length written in place of a blob that is nil, e.g. the strict filter of a non-strict key.
+const snapshotNilBlob = math.MaxUint32
+
+// ErrInvalidSnapshot is returned when restoring from a file that isn't a valid snapshot.
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// snapshotManifest is the header of a snapshot file. The blobs of the entries follow it in the same order.
+type snapshotManifest struct {
+	FormatVersion int             `json:"format_version"` // Format of the blobs, see models.FormatVersion
+	CreatedAt     time.Time       `json:"created_at"`     // Time the snapshot was taken
+	Entries       []snapshotEntry `json:"entries"`        // Keys in the snapshot
+}
+
+// snapshotEntry describes one HyperBloom instance in a snapshot.
+type snapshotEntry struct {
+	Key     string        `json:"key"`                  // Unique key of the HyperBloom instance
+	Decay   time.Duration `json:"decay"`                // Decay duration of the HyperBloom instance
+	Type    string        `json:"value_type,omitempty"` // Value type policy, empty if none was established
+	Created time.Time     `json:"created_at"`           // Creation time of the HyperBloom instance, zero if unknown
+	Expires time.Time     `json:"expires_at"`           // Expiry time of the HyperBloom instance, zero if it never expires
+}
+
+// BloomSnapshot writes every in-memory HyperBloom instance to the file at path and returns the number of keys written.
+// The file holds snapshotMagic, the length-prefixed JSON manifest, then the blobs of every key in the persisted format.
+// It is written next to path first and renamed into place, so an existing snapshot is never left half-written.
+func BloomSnapshot(path string) (int, error) {
+	manifest := snapshotManifest{
+		FormatVersion: models.FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		Entries:       []snapshotEntry{},
+	}
+
+	// Encode every key up front, the file only needs to be opened once all of them succeeded
+	blobs := []*models.Blobs{}
+	for _, db := range dbs.GetInMemoryHyperBlooms() {
+		encoded, err := db.EncodeBlobs()
+		if err != nil {
+			return 0, fmt.Errorf("can't encode %s: %w", db.Key(), err)
+		}
+		manifest.Entries = append(manifest.Entries, snapshotEntry{
+			Key:     db.Key(),
+			Decay:   db.Decay(),
+			Type:    db.ValueType(),
+			Created: db.CreatedAt(),
+			Expires: db.ExpiresAt(),
+		})
+		blobs = append(blobs, encoded)
+	}
+
+	header, err := json.Marshal(manifest)
+	if err != nil {
+		return 0, err
+	}
+
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpPath) // No-op once the file has been renamed
+
+	// Write the magic, the manifest and the blobs of every key
+	writer := bufio.NewWriter(file)
+	writer.WriteString(snapshotMagic)
+	writeSnapshotBlob(writer, header)
+	for _, entry := range blobs {
+		for _, blob := range [][]byte{entry.Bloom, entry.Hyper, entry.FirstSeen, entry.Strict, entry.Recency, entry.Scalable, entry.Counting, entry.TopK} {
+			writeSnapshotBlob(writer, blob)
+		}
+	}
+
+	// Flush and sync before renaming, so the rename never exposes a partial file
+	err = writer.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = os.Rename(tmpPath, path); err != nil {
+		return 0, err
+	}
+
+	return len(manifest.Entries), nil
+}
+
+// BloomRestore loads every HyperBloom instance from the snapshot file at path and returns the number of keys restored.
+// The whole file is validated before anything is loaded, a file that fails validation leaves the registry untouched
+// and returns ErrInvalidSnapshot. Restored keys replace the in-memory ones and are written to the database;
+// keys without metadata get metadata derived from the size of their Bloom filter.
+func BloomRestore(ctx context.Context, path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// Reject files that aren't snapshots
+	if !bytes.HasPrefix(content, []byte(snapshotMagic)) {
+		return 0, fmt.Errorf("%w: missing header", ErrInvalidSnapshot)
+	}
+	reader := bytes.NewReader(content[len(snapshotMagic):])
+
+	// Parse the manifest
+	header, err := readSnapshotBlob(reader)
+	if err != nil || header == nil {
+		return 0, fmt.Errorf("%w: can't read manifest", ErrInvalidSnapshot)
+	}
+
+	manifest := snapshotManifest{}
+	if err = json.Unmarshal(header, &manifest); err != nil {
+		return 0, fmt.Errorf("%w: can't parse manifest: %v", ErrInvalidSnapshot, err)
+	}
+
+	// Decode every key, checksums and format versions are verified by the models package
+	restored := make([]*models.HyperBloom, 0, len(manifest.Entries))
+	seen := map[string]bool{}
+	for _, entry := range manifest.Entries {
+		if entry.Key == "" || seen[entry.Key] {
+			return 0, fmt.Errorf("%w: empty or duplicate key %q", ErrInvalidSnapshot, entry.Key)
+		}
+		seen[entry.Key] = true
+
+		// Snapshots taken before time-decayed counts, scalable layers, counters or most frequent values existed have no blob for them
+		blobs := models.Blobs{}
+		fields := []*[]byte{&blobs.Bloom, &blobs.Hyper, &blobs.FirstSeen, &blobs.Strict}
+		if manifest.FormatVersion >= models.FormatRecency {
+			fields = append(fields, &blobs.Recency)
+		}
+		if manifest.FormatVersion >= models.FormatScalable {
+			fields = append(fields, &blobs.Scalable)
+		}
+		if manifest.FormatVersion >= models.FormatCounting {
+			fields = append(fields, &blobs.Counting)
+		}
+		if manifest.FormatVersion >= models.FormatTopK {
+			fields = append(fields, &blobs.TopK)
+		}
+		for _, blob := range fields {
+			if *blob, err = readSnapshotBlob(reader); err != nil {
+				return 0, fmt.Errorf("%w: truncated at %s", ErrInvalidSnapshot, entry.Key)
+			}
+		}
+
+		db, err := models.NewHyperBloomFromBlobs(entry.Key, entry.Decay, entry.Created, manifest.FormatVersion, &blobs)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		if entry.Type != "" {
+			db.ClaimValueType(entry.Type)
+		}
+		db.SetExpiry(entry.Expires)
+		restored = append(restored, db)
+	}
+
+	// Anything after the last entry means the manifest doesn't describe the file
+	if reader.Len() > 0 {
+		return 0, fmt.Errorf("%w: %d trailing bytes", ErrInvalidSnapshot, reader.Len())
+	}
+
+	// Install the restored keys and persist them
+	for _, db := range restored {
+		dbs.Set(db, db.Key())
+		if err = bloomPersistRestored(ctx, db); err != nil {
+			return 0, fmt.Errorf("can't persist %s: %w", db.Key(), err)
+		}
+	}
+
+	return len(restored), nil
+}
+
+// bloomPersistRestored writes a restored HyperBloom instance to the database, creating its metadata if it's missing.
+// The snapshot doesn't carry the capacity and false positive rate the key was created with, so they are derived
+// from the size of the Bloom filter, assuming it was sized optimally: k = (m/n)·ln 2 and p = 2^-k.
+func bloomPersistRestored(ctx context.Context, db *models.HyperBloom) error {
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		return err
+	}
+
+	// Begin a database transaction
+	tx, err := postgres.DbClient.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Insert or replace the persisted filters
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO hyperblooms (key, format_version, bloombyte, hyperbyte, firstseen, strictbyte, recencybyte, scalablebyte, countingbyte, topkbyte, cardinality, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET format_version = EXCLUDED.format_version,
+			bloombyte = EXCLUDED.bloombyte,
+			hyperbyte = EXCLUDED.hyperbyte,
+			firstseen = EXCLUDED.firstseen,
+			strictbyte = EXCLUDED.strictbyte,
+			recencybyte = EXCLUDED.recencybyte,
+			scalablebyte = EXCLUDED.scalablebyte,
+			countingbyte = EXCLUDED.countingbyte,
+			topkbyte = EXCLUDED.topkbyte,
+			cardinality = EXCLUDED.cardinality,
+			updated_at = EXCLUDED.updated_at`,
+		db.Key(), models.FormatVersion, blobs.Bloom, blobs.Hyper, blobs.FirstSeen, blobs.Strict, blobs.Recency, blobs.Scalable, blobs.Counting, blobs.TopK, db.HyperCardinality(),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Insert metadata unless the key already has some
+	m, k := db.Bloom().Cap(), db.Bloom().K()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO hyperblooms_metadata (key, max_cardinality, false_positive, bit_capacity, no_hash_func, decay_sec, created_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE NOT EXISTS (SELECT 1 FROM hyperblooms_metadata WHERE key = $1)`,
+		db.Key(),
+		uint(float64(m)*math.Ln2/float64(k)),
+		math.Pow(2, -float64(k)),
+		m,
+		k,
+		db.Decay(),
+		sql.NullTime{Time: db.CreatedAt(), Valid: !db.CreatedAt().IsZero()},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Take the value type policy and the expiry from the snapshot
+	_, err = tx.ExecContext(ctx,
+		`UPDATE hyperblooms_metadata SET value_type = NULLIF($2, ''), expires_at = $3 WHERE key = $1`,
+		db.Key(), db.ValueType(), sql.NullTime{Time: db.ExpiresAt(), Valid: !db.ExpiresAt().IsZero()},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Commit the database transaction
+	return tx.Commit()
+}
+
+// writeSnapshotBlob writes blob prefixed with its length, or snapshotNilBlob if it's nil.
+func writeSnapshotBlob(w io.Writer, blob []byte) {
+	length := uint32(len(blob))
+	if blob == nil {
+		length = snapshotNilBlob
+	}
+	binary.Write(w, binary.BigEndian, length)
+	w.Write(blob)
+}
+
+// readSnapshotBlob reads a blob written by writeSnapshotBlob, checking that it fits in what's left of the file.
+func readSnapshotBlob(r *bytes.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length == snapshotNilBlob {
+		return nil, nil
+	}
+	if int64(length) > int64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	blob := make([]byte, length)
+	_, err := io.ReadFull(r, blob)
+	return blob, err
+}
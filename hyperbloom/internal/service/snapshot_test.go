@@ -0,0 +1,103 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomSnapshotRoundTrip(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	plain := fmt.Sprint("snapshot-plain-", suffix)
+	strict := fmt.Sprint("snapshot-strict-", suffix)
+
+	for i := 0; i < 100; i++ {
+		service.BloomHash(context.Background(), plain, strconv.Itoa(i))
+	}
+	if _, err := service.BloomCreateKey(context.Background(), strict, 1000, 0.01, service.CreateOptions{Strict: true}, service.OnExistsFail); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		service.BloomHash(context.Background(), strict, strconv.Itoa(i))
+	}
+
+	path := filepath.Join(t.TempDir(), "registry.snapshot")
+	count, err := service.BloomSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count < 2 {
+		t.Fatalf("expected at least 2 keys in the snapshot, got %d", count)
+	}
+
+	// Changes made after the snapshot are undone by the restore
+	for i := 100; i < 200; i++ {
+		service.BloomHash(context.Background(), plain, strconv.Itoa(i))
+	}
+
+	restored, err := service.BloomRestore(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != count {
+		t.Errorf("restored %d keys, snapshot has %d", restored, count)
+	}
+
+	if _, hCard := bloomCardinality(t, context.Background(), plain); hCard != 100 {
+		t.Errorf("expected the cardinality from the snapshot (100), got %d", hCard)
+	}
+	for i := 0; i < 50; i++ {
+		if !bloomExists(t, context.Background(), strict, strconv.Itoa(i)) {
+			t.Fatalf("value %d missing from %s after restore", i, strict)
+		}
+	}
+	if !service.BloomGet(context.Background(), strict).Strict() {
+		t.Error("strict mode lost by the restore")
+	}
+}
+
+func TestBloomRestoreInvalid(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("snapshot-invalid-", time.Now().UnixNano())
+	service.BloomHash(context.Background(), key, "value")
+
+	path := filepath.Join(t.TempDir(), "registry.snapshot")
+	if _, err := service.BloomSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupted := append([]byte{}, content...)
+	corrupted[len(corrupted)-1] ^= 0xFF
+
+	cases := map[string][]byte{
+		"foreign file": []byte("not a snapshot"),
+		"truncated":    content[:len(content)-10],
+		"trailing":     append(append([]byte{}, content...), 0),
+		"corrupted":    corrupted,
+	}
+	for name, data := range cases {
+		invalid := filepath.Join(t.TempDir(), "invalid.snapshot")
+		os.WriteFile(invalid, data, 0o600)
+		if _, err = service.BloomRestore(context.Background(), invalid); !errors.Is(err, service.ErrInvalidSnapshot) {
+			t.Errorf("%s: expected ErrInvalidSnapshot, got %v", name, err)
+		}
+	}
+
+	if _, err = service.BloomRestore(context.Background(), filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+
+	"gopds/hyperbloom/internal/database/postgres"
+)
+
+// HyperBloomStats describes how saturated the Bloom filter of a HyperBloom is against its configuration.
+type HyperBloomStats struct {
+	HashFunctions uint    // Number of hash functions of the Bloom filter (k)
+	BitCapacity   uint    // Size of the Bloom filter bit array (m)
+	FillRatio     float64 // Fraction of the bits set
+	Capacity      uint    // Expected number of elements the filter was sized for, 0 if unknown
+	TargetFPR     float64 // False positive rate the filter was sized for, 0 if unknown
+	EstimatedFPR  float64 // False positive rate at the cardinality estimated by the HyperLogLog sketch
+	Saturated     bool    // Whether EstimatedFPR exceeds TargetFPR, the filter needs rebuilding at a larger size
+	Precision     uint8   // Precision of the HyperLogLog sketch (p)
+	Registers     uint    // Number of registers of the sketch (2^p), one byte each once dense
+	StandardError float64 // Relative standard error of the cardinality estimate, about 1.04/sqrt(2^p)
+}
+
+// BloomStats returns the parameters and saturation of the Bloom filter and the precision of the HyperLogLog sketch
+// of the HyperBloom identified by key, or ErrKeyNotFound if it doesn't exist. The capacity and target rate it was
+// created with are read from its metadata, and reported as 0 for keys without any (e.g. imported sketches or
+// snapshots of older versions).
+func BloomStats(ctx context.Context, key string) (*HyperBloomStats, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	m, k := db.Bloom().Cap(), db.Bloom().K()
+	stats := &HyperBloomStats{
+		HashFunctions: k,
+		BitCapacity:   m,
+		FillRatio:     float64(db.SetBits()) / float64(m),
+		EstimatedFPR:  TheoreticalFPR(uint(db.HyperCardinality()), m, k),
+		Precision:     db.HyperPrecision(),
+	}
+	stats.Registers = 1 << stats.Precision
+	stats.StandardError = 1.04 / math.Sqrt(float64(stats.Registers))
+
+	// Read the sizing the key was created with
+	var err error
+	if stats.Capacity, stats.TargetFPR, err = bloomSizing(ctx, key); err != nil {
+		return nil, err
+	}
+
+	stats.Saturated = stats.TargetFPR > 0 && stats.EstimatedFPR > stats.TargetFPR
+
+	return stats, nil
+}
+
+// bloomSizing reads the capacity and false positive rate the key was created with from its metadata,
+// 0 for keys without any.
+func bloomSizing(ctx context.Context, key string) (uint, float64, error) {
+	// The columns are nullable
+	var capacity sql.NullInt64
+	var falsePositive sql.NullFloat64
+	err := postgres.DbClient.QueryRowContext(
+		ctx,
+		`SELECT max_cardinality, false_positive FROM hyperblooms_metadata WHERE key = $1`,
+		key,
+	).Scan(&capacity, &falsePositive)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, err
+	}
+
+	return uint(capacity.Int64), falsePositive.Float64, nil
+}
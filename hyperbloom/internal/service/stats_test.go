@@ -0,0 +1,56 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomStats(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("stats-", time.Now().UnixNano())
+	if _, err := service.BloomCreate(context.Background(), 1000, 0.01, key, service.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+
+	stats, err := service.BloomStats(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := service.BloomGet(context.Background(), key)
+	if stats.BitCapacity != db.Bloom().Cap() || stats.HashFunctions != db.Bloom().K() {
+		t.Errorf("(m, k) = (%d, %d), want (%d, %d)", stats.BitCapacity, stats.HashFunctions, db.Bloom().Cap(), db.Bloom().K())
+	}
+	if want := float64(db.SetBits()) / float64(db.Bloom().Cap()); stats.FillRatio != want {
+		t.Errorf("fill ratio = %g, want %g", stats.FillRatio, want)
+	}
+	if stats.Capacity != 1000 || stats.TargetFPR < 0.0099 || stats.TargetFPR > 0.0101 {
+		t.Errorf("sizing = (%d, %g), want (1000, 0.01)", stats.Capacity, stats.TargetFPR)
+	}
+	if stats.Saturated || stats.EstimatedFPR <= 0 || stats.EstimatedFPR >= stats.TargetFPR {
+		t.Errorf("half full filter: estimated rate %g, saturated %t", stats.EstimatedFPR, stats.Saturated)
+	}
+
+	// Overfilling the filter pushes the rate past the target
+	for i := 500; i < 5000; i++ {
+		service.BloomHash(context.Background(), key, strconv.Itoa(i))
+	}
+	if stats, err = service.BloomStats(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Saturated || stats.EstimatedFPR <= stats.TargetFPR {
+		t.Errorf("overfilled filter: estimated rate %g, saturated %t", stats.EstimatedFPR, stats.Saturated)
+	}
+
+	if _, err = service.BloomStats(context.Background(), key+"-missing"); err != service.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
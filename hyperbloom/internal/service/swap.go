@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"gopds/hyperbloom/internal/database/postgres"
+)
+
+// ErrKeyNotFound is returned when an operation targets a key that has no HyperBloom.
+var ErrKeyNotFound = errors.New("key not found")
+
+// BloomSwap atomically swaps the HyperBlooms behind key1 and key2, e.g. to promote a "staging" dataset to "active".
+// The registry stays locked while the database is updated in a single transaction, so readers
+// observe either the old or the new assignment, never a mix of both. Flushes are held off like for
+// a deletion, so that a write in progress can't put the old blobs back over the swapped rows.
+// The keys are loaded, and the database updated, within ctx.
+func BloomSwap(ctx context.Context, key1, key2 string) error {
+	deleteMutex.Lock()
+	defer deleteMutex.Unlock()
+
+	err := dbs.Swap(ctx, key1, key2, func() error {
+		// Begin a database transaction
+		tx, err := postgres.DbClient.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// Swap the persisted filters, the FROM clause sees both rows as they were before the update
+		_, err = tx.ExecContext(ctx, `
+			UPDATE hyperblooms AS hb
+			SET format_version = other.format_version,
+				bloombyte = other.bloombyte,
+				hyperbyte = other.hyperbyte,
+				firstseen = other.firstseen,
+				strictbyte = other.strictbyte,
+				recencybyte = other.recencybyte,
+				scalablebyte = other.scalablebyte,
+				countingbyte = other.countingbyte,
+				topkbyte = other.topkbyte,
+				cardinality = other.cardinality,
+				updated_at = NOW()
+			FROM hyperblooms AS other
+			WHERE (hb.key = $1 AND other.key = $2)
+			OR (hb.key = $2 AND other.key = $1)`,
+			key1, key2,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Swap the metadata by exchanging the keys of its rows
+		_, err = tx.ExecContext(ctx, `
+			UPDATE hyperblooms_metadata
+			SET key = CASE key WHEN $1 THEN $2 ELSE $1 END
+			WHERE key IN ($1, $2)`,
+			key1, key2,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Commit the database transaction
+		return tx.Commit()
+	})
+
+	// One of the keys has never been created
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrKeyNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,80 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomSwapAtomic(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	active := fmt.Sprint("swap-active-", suffix)
+	staging := fmt.Sprint("swap-staging-", suffix)
+
+	// Give both keys a distinct cardinality
+	for i := 0; i < 10; i++ {
+		service.BloomHash(context.Background(), active, strconv.Itoa(i))
+	}
+	for i := 0; i < 20; i++ {
+		service.BloomHash(context.Background(), staging, strconv.Itoa(i))
+	}
+
+	// Readers must only ever observe one of the two datasets behind the active key
+	stop := make(chan struct{})
+	var invalid atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				if _, hCard := bloomCardinality(t, context.Background(), active); hCard != 10 && hCard != 20 {
+					invalid.Add(1)
+				}
+			}
+		}()
+	}
+
+	if err := service.BloomSwap(context.Background(), active, staging); err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+
+	if invalid.Load() > 0 {
+		t.Errorf("readers observed %d inconsistent reads during the swap", invalid.Load())
+	}
+
+	// The datasets must have traded places
+	if _, hCard := bloomCardinality(t, context.Background(), active); hCard != 20 {
+		t.Errorf("cardinality of %s after swap = %d, want 20", active, hCard)
+	}
+	if _, hCard := bloomCardinality(t, context.Background(), staging); hCard != 10 {
+		t.Errorf("cardinality of %s after swap = %d, want 10", staging, hCard)
+	}
+}
+
+func TestBloomSwapMissingKey(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("swap-existing-", time.Now().UnixNano())
+	service.BloomHash(context.Background(), key, "value")
+
+	if err := service.BloomSwap(context.Background(), key, key+"-missing"); err != service.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+// ErrTopKNotTracked is returned when querying the most frequent values of a key created without tracking them.
+var ErrTopKNotTracked = errors.New("most frequent values not tracked")
+
+// BloomTopK returns the k most frequent values inserted in the HyperBloom identified by key, highest count first,
+// as preprocessed by the transformation webhook. Counts are estimates, see models.TopK, and k is capped at the
+// number of values tracked. It returns ErrKeyNotFound if the key doesn't exist, or ErrTopKNotTracked if the key
+// was created without HB_TOPK_CAPACITY.
+func BloomTopK(ctx context.Context, key string, k int) ([]models.TopKItem, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	items, ok := db.TopK(k)
+	if !ok {
+		return nil, ErrTopKNotTracked
+	}
+	return items, nil
+}
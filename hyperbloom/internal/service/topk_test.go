@@ -0,0 +1,52 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomTopK(t *testing.T) {
+	requireDatabase(t)
+
+	ctx := context.Background()
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.TopKCapacity = 10 })
+
+	key := fmt.Sprint("topk-", time.Now().UnixNano())
+	if _, err := service.BloomCreate(context.Background(), 1000, 0.01, key, service.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		service.BloomHash(ctx, key, strconv.Itoa(i%4))
+	}
+	service.BloomHash(ctx, key, "0")
+
+	// The tracked values survive a flush
+	service.FlushAll(ctx)
+	items, err := service.BloomTopK(ctx, key, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Value != "0" || items[0].Count != 26 || items[1].Value != "1" || items[1].Count != 25 {
+		t.Errorf("BloomTopK(2) = %+v", items)
+	}
+
+	// Keys created without tracking report it
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.TopKCapacity = 0 })
+	untracked := key + "-untracked"
+	service.BloomHash(ctx, untracked, "value")
+	if _, err = service.BloomTopK(ctx, untracked, 2); !errors.Is(err, service.ErrTopKNotTracked) {
+		t.Errorf("expected ErrTopKNotTracked, got %v", err)
+	}
+	if _, err = service.BloomTopK(ctx, key+"-missing", 2); err != service.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
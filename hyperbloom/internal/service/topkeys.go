@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Dimensions the largest keys can be ranked by.
+const (
+	TopKeysByCardinality = "cardinality" // Estimated number of distinct values, from the HyperLogLog sketch
+	TopKeysByMemory      = "memory"      // Approximate memory footprint, see models.HyperBloom.MemoryBytes
+	TopKeysByFillRatio   = "fill_ratio"  // Share of the Bloom filter bits set
+)
+
+// ErrInvalidTopKeysBy is returned for an unknown dimension to rank the largest keys by.
+var ErrInvalidTopKeysBy = errors.New("invalid top keys dimension")
+
+// KeyUsage is how much a key holds and costs.
+type KeyUsage struct {
+	Key         string  // Key of the HyperBloom
+	Cardinality uint64  // Estimated cardinality from the HyperLogLog sketch
+	MemoryBytes uint64  // Memory used by the filters and the sketch
+	FillRatio   float64 // Share of the Bloom filter bits set
+}
+
+// BloomTopKeys returns the usage of the at most limit in-memory keys ranking highest by the dimension by,
+// TopKeysByCardinality, TopKeysByMemory (the default when by is empty) or TopKeysByFillRatio, sorted descending
+// with ties broken by key. The registry is snapshotted first, so keys created meanwhile may be missed, and keys
+// only in the database (e.g. decayed ones) are not considered. It returns ErrInvalidTopKeysBy for an unknown dimension.
+func BloomTopKeys(by string, limit int) ([]KeyUsage, error) {
+	var value func(u KeyUsage) float64
+	switch by {
+	case TopKeysByCardinality:
+		value = func(u KeyUsage) float64 { return float64(u.Cardinality) }
+	case "", TopKeysByMemory:
+		value = func(u KeyUsage) float64 { return float64(u.MemoryBytes) }
+	case TopKeysByFillRatio:
+		value = func(u KeyUsage) float64 { return u.FillRatio }
+	default:
+		return nil, fmt.Errorf("%w: %q, expected %s, %s or %s",
+			ErrInvalidTopKeysBy, by, TopKeysByCardinality, TopKeysByMemory, TopKeysByFillRatio)
+	}
+
+	blooms := dbs.GetInMemoryHyperBlooms()
+	usages := make([]KeyUsage, 0, len(blooms))
+	for _, db := range blooms {
+		usages = append(usages, KeyUsage{
+			Key:         db.Key(),
+			Cardinality: db.HyperCardinality(),
+			MemoryBytes: db.MemoryBytes(),
+			FillRatio:   float64(db.SetBits()) / float64(db.Bloom().Cap()),
+		})
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if vi, vj := value(usages[i]), value(usages[j]); vi != vj {
+			return vi > vj
+		}
+		return usages[i].Key < usages[j].Key
+	})
+
+	if limit >= 0 && len(usages) > limit {
+		usages = usages[:limit]
+	}
+	return usages, nil
+}
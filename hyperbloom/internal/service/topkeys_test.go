@@ -0,0 +1,71 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomTopKeys(t *testing.T) {
+	requireDatabase(t)
+
+	suffix := time.Now().UnixNano()
+	wide := fmt.Sprint("top-wide-", suffix)     // Large filter, few values
+	busy := fmt.Sprint("top-busy-", suffix)     // Most values
+	packed := fmt.Sprint("top-packed-", suffix) // Small filter, nearly full
+
+	for key, capacity := range map[string]uint{wide: 1000000, busy: 100000, packed: 1000} {
+		if _, err := service.BloomCreateKey(context.Background(), key, capacity, 0.01, service.CreateOptions{}, service.OnExistsFail); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for key, values := range map[string]int{wide: 100, busy: 20000, packed: 5000} {
+		for i := 0; i < values; i++ {
+			service.BloomHash(context.Background(), key, strconv.Itoa(i))
+		}
+	}
+
+	// Other tests leave keys in memory, only the relative order of these three is known
+	cases := []struct {
+		by   string
+		want []string
+	}{
+		{service.TopKeysByMemory, []string{wide, busy, packed}},
+		{"", []string{wide, busy, packed}},
+		{service.TopKeysByCardinality, []string{busy, packed, wide}},
+		{service.TopKeysByFillRatio, []string{packed, busy, wide}},
+	}
+	for _, c := range cases {
+		usages, err := service.BloomTopKeys(c.by, math.MaxInt)
+		if err != nil {
+			t.Fatalf("by %q: %v", c.by, err)
+		}
+
+		var got []string
+		for _, u := range usages {
+			if u.Key == wide || u.Key == busy || u.Key == packed {
+				got = append(got, u.Key)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("by %q ranked %v, want %v", c.by, got, c.want)
+		}
+	}
+
+	// The limit keeps the highest ranking keys
+	all, _ := service.BloomTopKeys(service.TopKeysByMemory, math.MaxInt)
+	top, _ := service.BloomTopKeys(service.TopKeysByMemory, 2)
+	if len(top) != 2 || top[0] != all[0] || top[1] != all[1] {
+		t.Errorf("top 2 = %v, want the first two of %v", top, all)
+	}
+
+	if _, err := service.BloomTopKeys("size", 10); !errors.Is(err, service.ErrInvalidTopKeysBy) {
+		t.Errorf("unknown dimension: %v, want ErrInvalidTopKeysBy", err)
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// Policies applied when the transformation webhook fails (HB_TRANSFORM_FALLBACK).
+const (
+	TransformFallbackReject   = "reject"   // Fail the request
+	TransformFallbackOriginal = "original" // Use the untransformed values
+)
+
+// ErrTransformFailed is returned when the transformation webhook fails and HB_TRANSFORM_FALLBACK is reject.
+var ErrTransformFailed = errors.New("value transformation failed")
+
+// transformClient calls the transformation webhook, the deadline comes from the context of each call.
+var transformClient = &http.Client{}
+
+// Bounds of the answer of the transformation webhook, relative to the request: transformGrowth times its size,
+// plus transformValueSlack bytes per value for transformations lengthening short values, e.g. hashing them.
+const (
+	transformGrowth     = 4
+	transformValueSlack = 256
+)
+
+// transformBody is the request and response body of the transformation webhook.
+type transformBody struct {
+	Values []string `json:"values"`
+}
+
+// validateTransformFallback checks that policy is a known HB_TRANSFORM_FALLBACK policy.
+func validateTransformFallback(policy string) error {
+	if policy != TransformFallbackReject && policy != TransformFallbackOriginal {
+		return fmt.Errorf("invalid HB_TRANSFORM_FALLBACK %q: must be %q or %q", policy, TransformFallbackReject, TransformFallbackOriginal)
+	}
+	return nil
+}
+
+// TransformValues returns values as transformed by the webhook at HB_TRANSFORM_URL, or values untouched if none
+// is configured. They are sent in a single call as {"values": [...]}, to be answered with the transformed values
+// in the same order and shape, within HB_TRANSFORM_TIMEOUT. When the call fails, the original values are returned
+// if HB_TRANSFORM_FALLBACK is original, ErrTransformFailed otherwise.
+// Calls, failures and their cumulated latency are published as transform_requests_total, transform_failures_total
+// and transform_latency_seconds_sum.
+func TransformValues(ctx context.Context, values []string) ([]string, error) {
+	cfg := *config.HyperBloomCfg()
+	if cfg.TransformURL == "" || len(values) == 0 {
+		return values, nil
+	}
+
+	start := time.Now()
+	transformed, err := callTransform(ctx, cfg, values)
+	metrics.Int("transform_requests_total").Add(1)
+	metrics.Float("transform_latency_seconds_sum").Add(time.Since(start).Seconds())
+	if err == nil {
+		return transformed, nil
+	}
+
+	metrics.Int("transform_failures_total").Add(1)
+	if cfg.TransformFallback == TransformFallbackOriginal {
+		slog.WarnContext(ctx, "Value transformation failed, using the original values", "err", err)
+		return values, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrTransformFailed, err)
+}
+
+// TransformValue transforms a single value, see TransformValues.
+func TransformValue(ctx context.Context, value string) (string, error) {
+	transformed, err := TransformValues(ctx, []string{value})
+	if err != nil {
+		return "", err
+	}
+	return transformed[0], nil
+}
+
+// callTransform posts values to the webhook of cfg and decodes its answer, which may be at most a few times as large
+// as the request (see transformGrowth).
+func callTransform(ctx context.Context, cfg config.HyperBloomConfig, values []string) ([]string, error) {
+	if cfg.TransformTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.TransformTimeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(transformBody{Values: values})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TransformURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := transformClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+
+	// A misbehaving webhook can't make the answer exhaust the memory
+	limit := int64(len(body))*transformGrowth + int64(len(values))*transformValueSlack
+	encoded, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("can't read webhook answer: %w", err)
+	}
+	if int64(len(encoded)) > limit {
+		return nil, fmt.Errorf("webhook answer larger than %d bytes", limit)
+	}
+
+	answer := transformBody{}
+	if err = json.Unmarshal(encoded, &answer); err != nil {
+		return nil, fmt.Errorf("invalid webhook answer: %w", err)
+	}
+	if len(answer.Values) != len(values) {
+		return nil, fmt.Errorf("webhook answered %d values for %d", len(answer.Values), len(values))
+	}
+
+	return answer.Values, nil
+}
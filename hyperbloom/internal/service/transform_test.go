@@ -0,0 +1,74 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestTransformValues(t *testing.T) {
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+
+	// The webhook upper-cases values, answers "drop" with one value less, hangs on "slow" and answers "huge" with a
+	// value of a megabyte
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Values []string `json:"values"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		for i, value := range body.Values {
+			switch value {
+			case "drop":
+				body.Values = body.Values[:i]
+			case "slow":
+				time.Sleep(time.Second)
+			case "huge":
+				value = strings.Repeat("x", 1<<20)
+			}
+			if i < len(body.Values) {
+				body.Values[i] = strings.ToUpper(value)
+			}
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer webhook.Close()
+
+	// Values are untouched without a webhook
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.TransformURL = "" })
+	if got, err := service.TransformValues(context.Background(), []string{"a"}); err != nil || !slices.Equal(got, []string{"a"}) {
+		t.Errorf("without webhook: %v, %v", got, err)
+	}
+
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) {
+		cfg.TransformURL = webhook.URL
+		cfg.TransformTimeout = 100 * time.Millisecond
+		cfg.TransformFallback = service.TransformFallbackReject
+	})
+
+	got, err := service.TransformValues(context.Background(), []string{"a", "b"})
+	if err != nil || !slices.Equal(got, []string{"A", "B"}) {
+		t.Errorf("transformed: %v, %v", got, err)
+	}
+
+	for _, values := range [][]string{{"a", "drop"}, {"slow"}, {"a", "huge"}} {
+		if _, err = service.TransformValues(context.Background(), values); !errors.Is(err, service.ErrTransformFailed) {
+			t.Errorf("%v with reject fallback: expected ErrTransformFailed, got %v", values, err)
+		}
+	}
+
+	// The original values are used on failure with the original fallback
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.TransformFallback = service.TransformFallbackOriginal })
+	if got, err = service.TransformValues(context.Background(), []string{"slow"}); err != nil || !slices.Equal(got, []string{"slow"}) {
+		t.Errorf("with original fallback: %v, %v", got, err)
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gopds/hyperbloom/internal/database/postgres"
+)
+
+// ErrValueTypeMismatch is returned when hashing a value whose declared type differs from the key's value type policy.
+var ErrValueTypeMismatch = errors.New("value type mismatch")
+
+// BloomHashTyped adds a value of the declared valueType to the HyperBloom identified by key, e.g. "raw",
+// "normalized" or "json", so that incompatible encodings of the same data aren't mixed into one filter.
+// The first typed value establishes the key's policy, later values of another type are rejected with
+// ErrValueTypeMismatch. Untyped values (empty valueType) are accepted regardless of the policy, like BloomHash.
+func BloomHashTyped(ctx context.Context, key, value, valueType string) error {
+	if valueType == "" {
+		return BloomHash(ctx, key, value)
+	}
+
+	db, err := bloomGetOrCreate(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	// Establish the policy with the first typed value, or check against the established one
+	established, claimed := db.ClaimValueType(valueType)
+	if established != valueType {
+		return fmt.Errorf("%w: %s holds %q values, got %q", ErrValueTypeMismatch, key, established, valueType)
+	}
+
+	// Persist a newly established policy, so it survives the key decaying from memory
+	if claimed {
+		_, err = postgres.DbClient.ExecContext(
+			ctx,
+			`UPDATE hyperblooms_metadata SET value_type = $2 WHERE key = $1 AND value_type IS NULL`,
+			key, valueType,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Hash the value using Bloom filter and HyperLogLog
+	db.Hash(value)
+
+	// Add the HyperBloom instance into memory (or update if already exists)
+	dbs.Set(db, key)
+	markDirty(ctx, key)
+
+	return nil
+}
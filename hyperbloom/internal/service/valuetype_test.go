@@ -0,0 +1,48 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomHashTyped(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("valuetype-", time.Now().UnixNano())
+
+	// The first typed value establishes the policy, values of the same type are accepted
+	for _, value := range []string{"a", "b", "c"} {
+		if err := service.BloomHashTyped(context.Background(), key, value, "normalized"); err != nil {
+			t.Fatalf("matching type rejected: %v", err)
+		}
+	}
+
+	// Values of another type are rejected and not inserted
+	err := service.BloomHashTyped(context.Background(), key, `{"id": 1}`, "json")
+	if !errors.Is(err, service.ErrValueTypeMismatch) {
+		t.Fatalf("expected ErrValueTypeMismatch, got %v", err)
+	}
+	if bloomExists(t, context.Background(), key, `{"id": 1}`) {
+		t.Error("mismatched value was inserted")
+	}
+
+	// Untyped values are accepted regardless of the policy
+	if err = service.BloomHashTyped(context.Background(), key, "d", ""); err != nil {
+		t.Errorf("untyped value rejected: %v", err)
+	}
+
+	if _, hCard := bloomCardinality(t, context.Background(), key); hCard != 4 {
+		t.Errorf("expected 4 values, got %d", hCard)
+	}
+
+	// Policies are per key
+	other := fmt.Sprint(key, "-other")
+	if err = service.BloomHashTyped(context.Background(), other, `{"id": 1}`, "json"); err != nil {
+		t.Errorf("policy of %s applied to %s: %v", key, other, err)
+	}
+}
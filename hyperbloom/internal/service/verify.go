@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+)
+
+// BloomVerifyMembers checks that every value of members, known to have been inserted in the HyperBloom
+// identified by key, is reported as present, and returns the ones that aren't.
+// A Bloom filter never yields false negatives, so anything returned points at a bug (e.g. in a merge,
+// a migration or the serialization) and is logged loudly. The count is recorded in the bloom_false_negatives gauge.
+// It returns ErrKeyNotFound if the key doesn't exist.
+func BloomVerifyMembers(ctx context.Context, key string, members []string) ([]string, error) {
+	db := BloomGet(ctx, key)
+	if db == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	falseNegatives := []string{}
+	for _, value := range members {
+		if !db.CheckExists(value) {
+			falseNegatives = append(falseNegatives, value)
+		}
+	}
+
+	recordFalseNegatives(key, len(falseNegatives))
+
+	if len(falseNegatives) > 0 {
+		slog.Error(
+			"CORRUPTION: known members are reported absent",
+			"key", key, "absent", len(falseNegatives), "members", len(members), "first", falseNegatives[0],
+		)
+	}
+
+	return falseNegatives, nil
+}
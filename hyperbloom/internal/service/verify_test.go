@@ -0,0 +1,41 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestBloomVerifyMembers(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("verify-", time.Now().UnixNano())
+	members := make([]string, 5000)
+	for i := range members {
+		members[i] = strconv.Itoa(i)
+		service.BloomHash(context.Background(), key, members[i])
+	}
+
+	falseNegatives, err := service.BloomVerifyMembers(context.Background(), key, members)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(falseNegatives) != 0 {
+		t.Errorf("found %d false negatives, first one %q", len(falseNegatives), falseNegatives[0])
+	}
+
+	// Values that were never inserted are reported, save for the odd false positive
+	falseNegatives, _ = service.BloomVerifyMembers(context.Background(), key, []string{"never-1", "never-2", "never-3"})
+	if len(falseNegatives) == 0 {
+		t.Error("values never inserted were all reported present")
+	}
+
+	if _, err = service.BloomVerifyMembers(context.Background(), "verify-missing", members); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/metrics"
+)
+
+// flushHeartbeat holds the time (in Unix nanoseconds) the update goroutine last made progress:
+// a key written, a flush completed, a tick skipped while paused or a sampling completed.
+var flushHeartbeat atomic.Int64
+
+// touchFlushHeartbeat records that the update goroutine made progress.
+func touchFlushHeartbeat() {
+	flushHeartbeat.Store(time.Now().UnixNano())
+}
+
+// flushRestartsCounter returns the counter of the update goroutines restarted by the watchdog.
+func flushRestartsCounter() *expvar.Int {
+	return metrics.Int("flush_restarts_total")
+}
+
+// FlushStalledFor returns how long the update goroutine has gone without progress.
+func FlushStalledFor() time.Duration {
+	return time.Since(time.Unix(0, flushHeartbeat.Load()))
+}
+
+// FlushRestarts returns the number of update goroutines restarted by the watchdog since startup.
+func FlushRestarts() int64 {
+	return flushRestartsCounter().Value()
+}
+
+// watchdogInterval returns how often the watchdog checks the heartbeat, a quarter of the stall timeout,
+// or a second while the watchdog is disabled so enabling it by a reload takes effect.
+func watchdogInterval() time.Duration {
+	if timeout := config.HyperBloomCfg().FlushStallTimeout; timeout > 0 {
+		return timeout / 4
+	}
+	return time.Second
+}
+
+// superviseFlush runs flushLoop and replaces it whenever it goes HB_FLUSH_STALL_TIMEOUT without progress,
+// e.g. blocked by a database that doesn't answer, or when it panics. It returns once a loop stopped because
+// of done, so the final flush runs exactly once.
+//
+// The stalled loop is abandoned rather than stopped: a goroutine can't be killed. Its context is canceled
+// before the replacement starts, which aborts its pending database write and makes any later write fail,
+// so the replacement never races with the stalled loop recovering, and the stalled loop never writes an
+// instance older than the one the replacement wrote. Once it recovers, the stalled loop exits without flushing.
+func superviseFlush(ticker *time.Ticker, done chan bool) {
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan bool, 1)
+		touchFlushHeartbeat()
+
+		go func() {
+			// A panic stops this loop only, the watchdog starts another one
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("ALERT: update goroutine panicked", "panic", r)
+					stopped <- false
+				}
+			}()
+			stopped <- flushLoop(ctx, ticker, done)
+		}()
+
+		if watchFlush(stopped) {
+			cancel()
+			return
+		}
+
+		cancel()
+		flushRestartsCounter().Add(1)
+		slog.Warn("Restarting the update goroutine")
+	}
+}
+
+// watchFlush waits until the loop reporting to stopped stops or stalls. It returns true if the loop stopped
+// because of done, false if it has to be replaced.
+func watchFlush(stopped chan bool) bool {
+	for {
+		select {
+		case finished := <-stopped:
+			return finished
+
+		case <-time.After(watchdogInterval()):
+			// The timeout is read at each check, so a reload applies to the running loop
+			timeout := config.HyperBloomCfg().FlushStallTimeout
+			if stalled := FlushStalledFor(); timeout > 0 && stalled > timeout {
+				slog.Error("ALERT: update goroutine stalled", "stalled", stalled.Round(time.Millisecond), "timeout", timeout)
+				return false
+			}
+		}
+	}
+}
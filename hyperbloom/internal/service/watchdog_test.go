@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/service"
+)
+
+func TestFlushWatchdog(t *testing.T) {
+	requireDatabase(t)
+
+	key := fmt.Sprint("watchdog-", time.Now().UnixNano())
+
+	saved := *config.HyperBloomCfg()
+	defer func() {
+		config.SetHyperBloomCfg(saved)
+		service.ApplyConfig()
+	}()
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) {
+		cfg.UpdateRate = 20 * time.Millisecond
+		cfg.FlushStallTimeout = 200 * time.Millisecond
+	})
+	service.ApplyConfig()
+
+	// Stall the flusher: its next write waits for the lock held by this transaction
+	tx, err := postgres.DbClient.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec(`LOCK TABLE hyperblooms IN ACCESS EXCLUSIVE MODE`); err != nil {
+		t.Fatal(err)
+	}
+	restarts := service.FlushRestarts()
+	service.BloomHash(context.Background(), key, "value")
+
+	// The watchdog notices the stall and restarts the flusher
+	deadline := time.Now().Add(5 * time.Second)
+	for service.FlushRestarts() == restarts {
+		if time.Now().After(deadline) {
+			t.Fatalf("no restart after stalling for %s", service.FlushStalledFor())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Once the lock is released, the replacement writes the key
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for slices.Contains(service.FlushBacklog(), key) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s still in the backlog after the restart", key)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The replacement makes progress, so the watchdog leaves it alone
+	restarts = service.FlushRestarts()
+	time.Sleep(3 * config.HyperBloomCfg().FlushStallTimeout)
+	if service.FlushRestarts() != restarts {
+		t.Errorf("%d restarts of a healthy flusher", service.FlushRestarts()-restarts)
+	}
+	if !bloomExists(t, context.Background(), key, "value") {
+		t.Error("value lost by the restart")
+	}
+}
+
+func TestReplacedFlushLoop(t *testing.T) {
+	if databaseReady {
+		t.Skip("the running update goroutine competes for the wake-ups")
+	}
+
+	// A loop replaced by the watchdog has its context canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	key := fmt.Sprint("replaced-", time.Now().UnixNano())
+	service.MarkDirty(context.Background(), key)
+	dirty := service.DirtyKeys()
+
+	for i := 0; i < 100; i++ {
+		// Have a tick and a wake-up ready along with the cancellation
+		service.WakeFlush("request")
+		time.Sleep(2 * time.Millisecond)
+
+		if service.FlushLoop(ctx, ticker, make(chan bool)) {
+			t.Fatal("replaced loop reported the final flush")
+		}
+
+		// The wake-up is left to the replacement
+		if id, ok := service.TakeWakeUp(); !ok || id != "request" {
+			t.Fatalf("wake-up taken by the replaced loop (%q, %t)", id, ok)
+		}
+	}
+
+	// Nothing was flushed, the changed keys still count towards the replacement's next flush
+	if got := service.DirtyKeys(); got != dirty {
+		t.Errorf("%d changed keys left, want %d", got, dirty)
+	}
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// MaxCount is the value counters of a counting filter saturate at.
+const MaxCount = 255
+
+// ErrNotCounting is returned when removing a value from a HyperBloom instance without counters.
+var ErrNotCounting = errors.New("not a counting filter")
+
+// Counting keeps a counter next to every bit of the primary Bloom filter of a HyperBloom, so that values can be
+// removed: inserting a value increments the counters of its bits, removing it decrements them and clears the bits
+// whose counter drops to 0, so a value is reported present as long as all its counters are non-zero. Every insert
+// is counted, a value inserted twice must be removed twice. Counters saturate at MaxCount and are then never
+// decremented, so that an overflow can't clear a bit still shared by other values; the bit stays set for good.
+//
+// Removals are approximate. Removing a value that was never inserted but is reported present (a false positive)
+// decrements counters owed to other values, which may then be reported absent: a false negative, which a standard
+// Bloom filter never has. The HyperLogLog sketch can't forget values, the cardinality it estimates doesn't drop
+// with removals, unlike the one of the Bloom filter. Each counter costs a byte, 8 times the bit array memory.
+//
+// The counters are guarded by the mutex of the HyperBloom instance, like the bits they mirror.
+type Counting struct {
+	counters []uint8 // Number of inserts setting each bit of the primary filter, capped at MaxCount
+}
+
+// EnableCounting switches the HyperBloom instance to a counting filter, see Counting. Values inserted before
+// have no counters, so it must be enabled on an empty instance.
+func (db *HyperBloom) EnableCounting() {
+	db.counting = &Counting{counters: make([]uint8, db.bloom.Cap())}
+}
+
+// Counting reports whether values can be removed from the HyperBloom instance.
+func (db *HyperBloom) Counting() bool {
+	return db.counting != nil
+}
+
+// add inserts value in primary and increments the counters of its bits.
+func (c *Counting) add(primary *bloom.BloomFilter, value string) {
+	primary.AddString(value)
+	for _, location := range bloom.Locations([]byte(value), primary.K()) {
+		if i := location % uint64(len(c.counters)); c.counters[i] < MaxCount {
+			c.counters[i]++
+		}
+	}
+}
+
+// remove decrements the counters of the bits of value and clears the ones dropping to 0. It returns false,
+// changing nothing, if value isn't reported present.
+func (c *Counting) remove(primary *bloom.BloomFilter, value string) bool {
+	if !primary.TestString(value) {
+		return false
+	}
+
+	for _, location := range bloom.Locations([]byte(value), primary.K()) {
+		i := location % uint64(len(c.counters))
+		if c.counters[i] == 0 || c.counters[i] == MaxCount {
+			continue
+		}
+		if c.counters[i]--; c.counters[i] == 0 {
+			primary.BitSet().Clear(uint(i))
+		}
+	}
+	return true
+}
+
+// reset zeroes the counters, the primary filter is cleared by the caller.
+func (c *Counting) reset() {
+	clear(c.counters)
+}
+
+// memoryBytes returns the memory used by the counters.
+func (c *Counting) memoryBytes() uint64 {
+	return uint64(len(c.counters))
+}
+
+// Remove removes value from the HyperBloom instance, see Counting for the caveats, and returns whether it was
+// reported present. It returns ErrNotCounting if the instance has no counters.
+func (db *HyperBloom) Remove(value string) (bool, error) {
+	if db.counting == nil {
+		return false, ErrNotCounting
+	}
+
+	db.mutex.Lock()
+	removed := db.counting.remove(db.bloom, value)
+	db.mutex.Unlock()
+	if !removed {
+		return false, nil
+	}
+
+	// Invalidate the cached cardinalities and have the removal flushed
+	atomic.AddUint64(&db.version, 1)
+	return true, nil
+}
+
+// RemoveBatch removes several values from the HyperBloom instance like Remove, under a single lock for the whole
+// batch, and returns whether each value was reported present. A value reported absent, some of its counters being
+// already at 0, changes nothing, so that removing it can't underflow the counters it shares with other values.
+// It returns ErrNotCounting, removing none of the values, if the instance has no counters.
+func (db *HyperBloom) RemoveBatch(values []string) ([]bool, error) {
+	if db.counting == nil {
+		return nil, ErrNotCounting
+	}
+
+	removed := make([]bool, len(values))
+	changed := false
+	db.mutex.Lock()
+	for i, value := range values {
+		removed[i] = db.counting.remove(db.bloom, value)
+		changed = changed || removed[i]
+	}
+	db.mutex.Unlock()
+
+	// Invalidate the cached cardinalities and have the removals flushed
+	if changed {
+		atomic.AddUint64(&db.version, 1)
+	}
+	return removed, nil
+}
+
+// MarshalBinary encodes the counters, one byte each.
+func (c *Counting) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), c.counters...), nil
+}
+
+// UnmarshalBinary decodes counters encoded by MarshalBinary.
+func (c *Counting) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("%w: no counters", ErrCorruptedBlob)
+	}
+	c.counters = append([]uint8(nil), data...)
+	return nil
+}
@@ -0,0 +1,169 @@
+package models_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestCountingRemove(t *testing.T) {
+	db := models.NewHyperBloomFromParams(10000, 0.001, "counting")
+	db.EnableCounting()
+	for i := 0; i < 1000; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+
+	// Removed values read as absent, the others are kept
+	for i := 0; i < 500; i++ {
+		if removed, err := db.Remove(strconv.Itoa(i)); err != nil || !removed {
+			t.Fatalf("Remove(%d) = %t, %v, want true", i, removed, err)
+		}
+	}
+	absent := 0
+	for i := 0; i < 500; i++ {
+		if !db.CheckExists(strconv.Itoa(i)) {
+			absent++
+		}
+	}
+	if absent < 490 {
+		t.Errorf("%d of 500 removed values read as absent", absent)
+	}
+	for i := 500; i < 1000; i++ {
+		if !db.CheckExists(strconv.Itoa(i)) {
+			t.Fatalf("value %d reported absent after removing others", i)
+		}
+	}
+
+	// A value inserted twice must be removed twice
+	db.Hash("twice")
+	db.Hash("twice")
+	db.Remove("twice")
+	if !db.CheckExists("twice") {
+		t.Error("value inserted twice absent after one removal")
+	}
+	db.Remove("twice")
+	if db.CheckExists("twice") {
+		t.Error("value inserted twice present after two removals")
+	}
+
+	// Removing an absent value changes nothing
+	if removed, err := db.Remove("never inserted"); err != nil || removed {
+		t.Errorf("Remove(absent) = %t, %v, want false", removed, err)
+	}
+
+	plain := models.NewHyperBloomFromParams(1000, 0.01, "plain")
+	plain.Hash("kept")
+	plain.Hash("removed")
+	if _, err := plain.Remove("value"); !errors.Is(err, models.ErrNotCounting) {
+		t.Errorf("Remove on a standard filter: %v, want ErrNotCounting", err)
+	}
+}
+
+func TestCountingRemoveBatch(t *testing.T) {
+	db := models.NewHyperBloomFromParams(10000, 0.001, "counting-batch")
+	db.EnableCounting()
+	twin := models.NewHyperBloomFromParams(10000, 0.001, "counting-twin")
+	twin.EnableCounting()
+	for i := 0; i < 1000; i++ {
+		db.Hash(strconv.Itoa(i))
+		twin.Hash(strconv.Itoa(i))
+	}
+
+	// Every value below 500 twice, then as many values never inserted
+	values := []string{}
+	for i := 0; i < 500; i++ {
+		values = append(values, strconv.Itoa(i), strconv.Itoa(i))
+	}
+	for i := 0; i < 500; i++ {
+		values = append(values, "never-"+strconv.Itoa(i))
+	}
+
+	removed, err := db.RemoveBatch(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch removes like one Remove per value
+	for i, value := range values {
+		if want, _ := twin.Remove(value); removed[i] != want {
+			t.Fatalf("RemoveBatch reported %q removed = %t, Remove %t", value, removed[i], want)
+		}
+	}
+	if !db.BitSet().Equal(twin.BitSet()) {
+		t.Error("RemoveBatch and Remove left different bits")
+	}
+
+	// Only the first removal of each value counts, the second and the values never inserted are already at zero
+	for i, value := range values {
+		if want := i < 1000 && i%2 == 0; removed[i] != want {
+			t.Errorf("RemoveBatch reported %q removed = %t, want %t", value, removed[i], want)
+		}
+	}
+
+	// Removals at zero don't underflow the counters the kept values rely on
+	for i := 500; i < 1000; i++ {
+		if !db.CheckExists(strconv.Itoa(i)) {
+			t.Fatalf("value %d reported absent after removing others", i)
+		}
+	}
+
+	plain := models.NewHyperBloomFromParams(1000, 0.01, "plain")
+	plain.Hash("kept")
+	if _, err := plain.RemoveBatch([]string{"kept"}); !errors.Is(err, models.ErrNotCounting) {
+		t.Errorf("RemoveBatch on a standard filter: %v, want ErrNotCounting", err)
+	}
+	if !plain.CheckExists("kept") {
+		t.Error("RemoveBatch on a standard filter removed a value")
+	}
+}
+
+func TestCountingSaturation(t *testing.T) {
+	db := models.NewHyperBloomFromParams(1000, 0.01, "saturated")
+	db.EnableCounting()
+	for i := 0; i < models.MaxCount+10; i++ {
+		db.Hash("value")
+	}
+
+	// Saturated counters are never decremented, the value stays present for good
+	for i := 0; i < models.MaxCount+10; i++ {
+		db.Remove("value")
+	}
+	if !db.CheckExists("value") {
+		t.Error("value with saturated counters removed")
+	}
+}
+
+func TestCountingEncoding(t *testing.T) {
+	db := models.NewHyperBloomFromParams(1000, 0.01, "counting")
+	db.EnableCounting()
+	db.Hash("kept")
+	db.Hash("removed")
+
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &models.HyperBloom{}
+	if err = decoded.DecodeBlobs(models.FormatVersion, blobs); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Counting() || !decoded.CheckExists("kept") {
+		t.Fatal("counting filter lost in the encoding")
+	}
+
+	// The counters survive, so removals still work once decoded
+	if removed, err := decoded.Remove("removed"); err != nil || !removed || decoded.CheckExists("removed") {
+		t.Errorf("Remove after decoding = %t, %v, want the value removed", removed, err)
+	}
+
+	// Counting costs a byte per bit
+	plain := models.NewHyperBloomFromParams(1000, 0.01, "plain")
+	plain.Hash("kept")
+	plain.Hash("removed")
+	if extra := db.MemoryBytes() - plain.MemoryBytes(); extra != uint64(db.Bloom().Cap()) {
+		t.Errorf("counting costs %d extra bytes, want one per bit", extra)
+	}
+}
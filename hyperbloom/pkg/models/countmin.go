@@ -0,0 +1,179 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// countMinFormat is the version byte of the binary encoding of a CountMin.
+const countMinFormat = 1
+
+// ErrInvalidCountMin is returned when decoding a CountMin from a malformed encoding.
+var ErrInvalidCountMin = errors.New("invalid count-min sketch encoding")
+
+// CountMin is a Count-Min Sketch estimating the frequency of values: depth rows of width counters, every value
+// incrementing one counter per row. Collisions only ever add to a counter, so the estimate, the smallest of the
+// counters of a value, never undercounts; it overcounts by at most epsilon * Total() with probability 1 - delta
+// when sized by NewCountMinWithEstimates.
+type CountMin struct {
+	mutex   sync.RWMutex // Mutex guarding the counters, adds and estimates may run concurrently
+	key     string       // Key of the sketch
+	width   uint         // Number of counters per row
+	depth   uint         // Number of rows, one hash function each
+	counts  []uint64     // Counters, row after row
+	total   uint64       // Sum of the counts added
+	version uint64       // Incremented by every add
+	flushed uint64       // Version last written to the database
+}
+
+// NewCountMin creates an empty CountMin of depth rows of width counters.
+func NewCountMin(width, depth uint, key string) *CountMin {
+	width, depth = max(width, 1), max(depth, 1)
+	return &CountMin{
+		key:    key,
+		width:  width,
+		depth:  depth,
+		counts: make([]uint64, width*depth),
+	}
+}
+
+// EstimateCountMinParameters returns the width and depth of a CountMin overcounting by at most epsilon times
+// the total count with probability 1 - delta: width = ⌈e / epsilon⌉ and depth = ⌈ln(1 / delta)⌉.
+func EstimateCountMinParameters(epsilon, delta float64) (uint, uint) {
+	return uint(math.Ceil(math.E / epsilon)), uint(math.Ceil(math.Log(1 / delta)))
+}
+
+// NewCountMinWithEstimates creates an empty CountMin sized for the error bounds, see EstimateCountMinParameters.
+func NewCountMinWithEstimates(epsilon, delta float64, key string) *CountMin {
+	width, depth := EstimateCountMinParameters(epsilon, delta)
+	return NewCountMin(width, depth, key)
+}
+
+// Key returns the key of the sketch.
+func (cm *CountMin) Key() string {
+	return cm.key
+}
+
+// Width returns the number of counters per row.
+func (cm *CountMin) Width() uint {
+	return cm.width
+}
+
+// Depth returns the number of rows.
+func (cm *CountMin) Depth() uint {
+	return cm.depth
+}
+
+// Total returns the sum of the counts added.
+func (cm *CountMin) Total() uint64 {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	return cm.total
+}
+
+// Version returns the number of adds since the sketch was created or loaded.
+func (cm *CountMin) Version() uint64 {
+	return atomic.LoadUint64(&cm.version)
+}
+
+// Dirty reports whether the sketch changed since it was last written to the database.
+func (cm *CountMin) Dirty() bool {
+	return atomic.LoadUint64(&cm.version) != atomic.LoadUint64(&cm.flushed)
+}
+
+// MarkFlushed records that the sketch was written to the database as of version.
+func (cm *CountMin) MarkFlushed(version uint64) {
+	atomic.StoreUint64(&cm.flushed, version)
+}
+
+// indexes returns the counter of value in every row, by double hashing: the i-th row uses h1 + i * h2.
+func (cm *CountMin) indexes(value string) []uint {
+	hash := fnv.New128a()
+	hash.Write([]byte(value))
+	sum := hash.Sum(nil)
+	h1, h2 := binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])
+
+	indexes := make([]uint, cm.depth)
+	for i := range indexes {
+		indexes[i] = uint(i)*cm.width + uint((h1+uint64(i)*h2)%uint64(cm.width))
+	}
+	return indexes
+}
+
+// Add counts count more occurrences of value. Counters saturate instead of wrapping around.
+func (cm *CountMin) Add(value string, count uint64) {
+	indexes := cm.indexes(value)
+
+	cm.mutex.Lock()
+	for _, index := range indexes {
+		cm.counts[index] = saturatingAdd(cm.counts[index], count)
+	}
+	cm.total = saturatingAdd(cm.total, count)
+	cm.mutex.Unlock()
+
+	atomic.AddUint64(&cm.version, 1)
+}
+
+// Estimate returns the estimated number of occurrences of value, never below the actual one.
+func (cm *CountMin) Estimate(value string) uint64 {
+	indexes := cm.indexes(value)
+
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	estimate := uint64(math.MaxUint64)
+	for _, index := range indexes {
+		estimate = min(estimate, cm.counts[index])
+	}
+	return estimate
+}
+
+// saturatingAdd returns a + b, or the largest uint64 if the sum overflows.
+func saturatingAdd(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// MarshalBinary encodes the sketch as its format version, width and depth (4 bytes each), total and counters
+// (8 bytes each), big-endian.
+func (cm *CountMin) MarshalBinary() ([]byte, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	encoded := make([]byte, 0, 1+4+4+8+8*len(cm.counts))
+	encoded = append(encoded, countMinFormat)
+	encoded = binary.BigEndian.AppendUint32(encoded, uint32(cm.width))
+	encoded = binary.BigEndian.AppendUint32(encoded, uint32(cm.depth))
+	encoded = binary.BigEndian.AppendUint64(encoded, cm.total)
+	for _, count := range cm.counts {
+		encoded = binary.BigEndian.AppendUint64(encoded, count)
+	}
+	return encoded, nil
+}
+
+// DecodeCountMin decodes a sketch encoded by MarshalBinary under key.
+// It returns ErrInvalidCountMin if the encoding is malformed.
+func DecodeCountMin(encoded []byte, key string) (*CountMin, error) {
+	if len(encoded) < 17 || encoded[0] != countMinFormat {
+		return nil, ErrInvalidCountMin
+	}
+	width := uint(binary.BigEndian.Uint32(encoded[1:5]))
+	depth := uint(binary.BigEndian.Uint32(encoded[5:9]))
+	if width == 0 || depth == 0 || uint64(len(encoded)-17) != 8*uint64(width)*uint64(depth) {
+		return nil, ErrInvalidCountMin
+	}
+
+	cm := NewCountMin(width, depth, key)
+	cm.total = binary.BigEndian.Uint64(encoded[9:17])
+	for i := range cm.counts {
+		cm.counts[i] = binary.BigEndian.Uint64(encoded[17+8*i:])
+	}
+	return cm, nil
+}
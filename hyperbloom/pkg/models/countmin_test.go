@@ -0,0 +1,92 @@
+package models_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestCountMinEstimate(t *testing.T) {
+	epsilon, delta := 0.001, 0.01
+	cm := models.NewCountMinWithEstimates(epsilon, delta, "cms")
+	if cm.Width() != 2719 || cm.Depth() != 5 {
+		t.Errorf("(width, depth) = (%d, %d), want (2719, 5)", cm.Width(), cm.Depth())
+	}
+
+	// Value i occurs i times
+	for i := 1; i <= 1000; i++ {
+		cm.Add(strconv.Itoa(i), uint64(i))
+	}
+	if want := uint64(1000 * 1001 / 2); cm.Total() != want {
+		t.Errorf("total = %d, want %d", cm.Total(), want)
+	}
+
+	// Estimates never undercount, and rarely overcount by more than epsilon * total
+	bound := uint64(epsilon * float64(cm.Total()))
+	over := 0
+	for i := 1; i <= 1000; i++ {
+		got := cm.Estimate(strconv.Itoa(i))
+		if got < uint64(i) {
+			t.Fatalf("estimate of %d = %d, undercounts", i, got)
+		}
+		if got-uint64(i) > bound {
+			over++
+		}
+	}
+	if over > 10+int(delta*1000) {
+		t.Errorf("%d estimates overcount by more than %d", over, bound)
+	}
+
+	if got := cm.Estimate("never added"); got > bound {
+		t.Errorf("estimate of a missing value = %d, above %d", got, bound)
+	}
+}
+
+func TestCountMinSaturates(t *testing.T) {
+	cm := models.NewCountMin(10, 2, "cms")
+	cm.Add("value", math.MaxUint64-1)
+	cm.Add("value", 5)
+	if got := cm.Estimate("value"); got != math.MaxUint64 {
+		t.Errorf("estimate = %d, want the counters saturated", got)
+	}
+}
+
+func TestCountMinEncoding(t *testing.T) {
+	cm := models.NewCountMin(100, 4, "cms")
+	for i := 0; i < 500; i++ {
+		cm.Add(strconv.Itoa(i%50), 3)
+	}
+	if !cm.Dirty() {
+		t.Error("adds didn't mark the sketch dirty")
+	}
+	cm.MarkFlushed(cm.Version())
+	if cm.Dirty() {
+		t.Error("flushed sketch still dirty")
+	}
+
+	encoded, err := cm.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := models.DecodeCountMin(encoded, "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Key() != "copy" || decoded.Width() != 100 || decoded.Depth() != 4 || decoded.Total() != cm.Total() {
+		t.Errorf("decoded (%s, %d, %d, %d)", decoded.Key(), decoded.Width(), decoded.Depth(), decoded.Total())
+	}
+	for i := 0; i < 60; i++ {
+		if got, want := decoded.Estimate(strconv.Itoa(i)), cm.Estimate(strconv.Itoa(i)); got != want {
+			t.Errorf("decoded estimate of %d = %d, want %d", i, got, want)
+		}
+	}
+
+	// Truncated or foreign encodings are rejected
+	for _, invalid := range [][]byte{nil, encoded[:len(encoded)-1], append([]byte{9}, encoded[1:]...)} {
+		if _, err = models.DecodeCountMin(invalid, "invalid"); err != models.ErrInvalidCountMin {
+			t.Errorf("decoding %d bytes: %v, want ErrInvalidCountMin", len(invalid), err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math/bits"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// Parameters of a Cuckoo filter.
+const (
+	cuckooFormat     = 1    // Version byte of the binary encoding of a Cuckoo
+	cuckooBucketSize = 4    // Number of fingerprints per bucket
+	cuckooMaxKicks   = 500  // Number of relocations tried before an insert fails
+	cuckooLoadFactor = 0.95 // Occupancy a filter of 4-slot buckets reliably reaches, used for sizing
+)
+
+// ErrCuckooFull is returned when a value can't be added to a Cuckoo filter, because both its buckets
+// are full and relocating their fingerprints didn't free a slot.
+var ErrCuckooFull = errors.New("cuckoo filter full")
+
+// ErrInvalidCuckoo is returned when decoding a Cuckoo from a malformed encoding.
+var ErrInvalidCuckoo = errors.New("invalid cuckoo filter encoding")
+
+// Cuckoo is a Cuckoo filter: a membership filter like a Bloom filter, which also supports deletion. It stores
+// a 16-bit fingerprint of every value in one of two candidate buckets, relocating fingerprints between their
+// candidates to make room. Its false positive rate is about 2 * 4 / 2^16 (0.012%) regardless of the load, and
+// deleting a value that was never added may delete a colliding one.
+type Cuckoo struct {
+	mutex   sync.RWMutex // Mutex guarding the buckets, lookups may run concurrently
+	key     string       // Key of the filter
+	buckets []uint16     // Fingerprints, bucket after bucket, 0 for an empty slot
+	mask    uint64       // Number of buckets minus one, the number of buckets is a power of 2
+	count   uint64       // Number of fingerprints stored
+	version uint64       // Incremented by every change
+	flushed uint64       // Version last written to the database
+}
+
+// NewCuckoo creates an empty Cuckoo sized to hold capacity values.
+func NewCuckoo(capacity uint, key string) *Cuckoo {
+	buckets := uint64(float64(max(capacity, 1))/(cuckooBucketSize*cuckooLoadFactor)) + 1
+	buckets = 1 << bits.Len64(buckets-1) // Round up to a power of 2
+	return newCuckoo(buckets, key)
+}
+
+// newCuckoo creates an empty Cuckoo of a power of 2 number of buckets.
+func newCuckoo(buckets uint64, key string) *Cuckoo {
+	return &Cuckoo{
+		key:     key,
+		buckets: make([]uint16, buckets*cuckooBucketSize),
+		mask:    buckets - 1,
+	}
+}
+
+// Key returns the key of the filter.
+func (cf *Cuckoo) Key() string {
+	return cf.key
+}
+
+// Capacity returns the number of fingerprint slots of the filter, inserts start failing somewhat below.
+func (cf *Cuckoo) Capacity() uint64 {
+	return uint64(len(cf.buckets))
+}
+
+// Count returns the number of values in the filter.
+func (cf *Cuckoo) Count() uint64 {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+
+	return cf.count
+}
+
+// Version returns the number of changes since the filter was created or loaded.
+func (cf *Cuckoo) Version() uint64 {
+	return atomic.LoadUint64(&cf.version)
+}
+
+// Dirty reports whether the filter changed since it was last written to the database.
+func (cf *Cuckoo) Dirty() bool {
+	return atomic.LoadUint64(&cf.version) != atomic.LoadUint64(&cf.flushed)
+}
+
+// MarkFlushed records that the filter was written to the database as of version.
+func (cf *Cuckoo) MarkFlushed(version uint64) {
+	atomic.StoreUint64(&cf.flushed, version)
+}
+
+// fingerprint returns the fingerprint of value, never 0, and its first candidate bucket.
+func (cf *Cuckoo) fingerprint(value string) (uint16, uint64) {
+	hash := fnv.New64a()
+	hash.Write([]byte(value))
+	sum := hash.Sum64()
+
+	fp := uint16(sum >> 48)
+	if fp == 0 {
+		fp = 1
+	}
+	return fp, sum & cf.mask
+}
+
+// alternate returns the other candidate bucket of fingerprint fp stored in bucket i. Both candidates are derived
+// from each other and the fingerprint alone, so a fingerprint can be relocated without knowing its value.
+func (cf *Cuckoo) alternate(i uint64, fp uint16) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte{byte(fp >> 8), byte(fp)})
+	return (i ^ hash.Sum64()) & cf.mask
+}
+
+// slots returns the fingerprint slots of bucket i.
+func (cf *Cuckoo) slots(i uint64) []uint16 {
+	return cf.buckets[i*cuckooBucketSize : (i+1)*cuckooBucketSize]
+}
+
+// insert stores fp in an empty slot of bucket i, and reports whether there was one.
+func (cf *Cuckoo) insert(i uint64, fp uint16) bool {
+	slots := cf.slots(i)
+	for j, slot := range slots {
+		if slot == 0 {
+			slots[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds value to the filter. Adding a value twice stores it twice, so that it survives one deletion.
+// It returns ErrCuckooFull if no slot can be freed for the value, leaving the filter unchanged.
+func (cf *Cuckoo) Add(value string) error {
+	fp, i1 := cf.fingerprint(value)
+
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	i2 := cf.alternate(i1, fp)
+	if !cf.insert(i1, fp) && !cf.insert(i2, fp) && !cf.relocate(i1, i2, fp) {
+		return ErrCuckooFull
+	}
+
+	cf.count++
+	atomic.AddUint64(&cf.version, 1)
+	return nil
+}
+
+// relocate stores fp by kicking fingerprints to their alternate bucket, starting from bucket i1 or i2,
+// until one lands in an empty slot. If none does within cuckooMaxKicks, the kicks are undone and it returns false,
+// rather than dropping the last fingerprint kicked out.
+func (cf *Cuckoo) relocate(i1, i2 uint64, fp uint16) bool {
+	type kick struct {
+		bucket  uint64 // Bucket of the slot
+		slot    int    // Slot within the bucket
+		evicted uint16 // Fingerprint the slot held
+	}
+	kicks := make([]kick, 0, cuckooMaxKicks)
+
+	i := i1
+	if rand.IntN(2) == 1 {
+		i = i2
+	}
+	for range cuckooMaxKicks {
+		j := rand.IntN(cuckooBucketSize)
+		slots := cf.slots(i)
+		kicks = append(kicks, kick{bucket: i, slot: j, evicted: slots[j]})
+		fp, slots[j] = slots[j], fp
+
+		i = cf.alternate(i, fp)
+		if cf.insert(i, fp) {
+			return true
+		}
+	}
+
+	// Put every kicked fingerprint back where it was
+	for k := len(kicks) - 1; k >= 0; k-- {
+		cf.slots(kicks[k].bucket)[kicks[k].slot] = kicks[k].evicted
+	}
+	return false
+}
+
+// Contains reports whether value is probably in the filter. There are no false negatives, unless a colliding
+// value that was never added was deleted.
+func (cf *Cuckoo) Contains(value string) bool {
+	fp, i1 := cf.fingerprint(value)
+
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+
+	for _, i := range []uint64{i1, cf.alternate(i1, fp)} {
+		for _, slot := range cf.slots(i) {
+			if slot == fp {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Delete removes one occurrence of value from the filter, and reports whether it was found.
+// Only delete values that were added: a value merely colliding with another one deletes the other one.
+func (cf *Cuckoo) Delete(value string) bool {
+	fp, i1 := cf.fingerprint(value)
+
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	for _, i := range []uint64{i1, cf.alternate(i1, fp)} {
+		slots := cf.slots(i)
+		for j, slot := range slots {
+			if slot == fp {
+				slots[j] = 0
+				cf.count--
+				atomic.AddUint64(&cf.version, 1)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MarshalBinary encodes the filter as its format version, number of buckets (4 bytes), count (8 bytes)
+// and fingerprints (2 bytes each), big-endian.
+func (cf *Cuckoo) MarshalBinary() ([]byte, error) {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+
+	encoded := make([]byte, 0, 1+4+8+2*len(cf.buckets))
+	encoded = append(encoded, cuckooFormat)
+	encoded = binary.BigEndian.AppendUint32(encoded, uint32(cf.mask+1))
+	encoded = binary.BigEndian.AppendUint64(encoded, cf.count)
+	for _, fp := range cf.buckets {
+		encoded = binary.BigEndian.AppendUint16(encoded, fp)
+	}
+	return encoded, nil
+}
+
+// DecodeCuckoo decodes a filter encoded by MarshalBinary under key.
+// It returns ErrInvalidCuckoo if the encoding is malformed.
+func DecodeCuckoo(encoded []byte, key string) (*Cuckoo, error) {
+	if len(encoded) < 13 || encoded[0] != cuckooFormat {
+		return nil, ErrInvalidCuckoo
+	}
+	buckets := uint64(binary.BigEndian.Uint32(encoded[1:5]))
+	if buckets == 0 || buckets&(buckets-1) != 0 || uint64(len(encoded)-13) != 2*cuckooBucketSize*buckets {
+		return nil, ErrInvalidCuckoo
+	}
+
+	cf := newCuckoo(buckets, key)
+	cf.count = binary.BigEndian.Uint64(encoded[5:13])
+	for i := range cf.buckets {
+		cf.buckets[i] = binary.BigEndian.Uint16(encoded[13+2*i:])
+	}
+	return cf, nil
+}
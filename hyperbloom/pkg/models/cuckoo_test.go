@@ -0,0 +1,137 @@
+package models_test
+
+import (
+	"strconv"
+	"testing"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestCuckooAddContainsDelete(t *testing.T) {
+	cf := models.NewCuckoo(10000, "cuckoo")
+	for i := 0; i < 10000; i++ {
+		if err := cf.Add(strconv.Itoa(i)); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	if cf.Count() != 10000 {
+		t.Errorf("count = %d, want 10000", cf.Count())
+	}
+
+	// No false negatives, and about 0.012% false positives
+	for i := 0; i < 10000; i++ {
+		if !cf.Contains(strconv.Itoa(i)) {
+			t.Fatalf("%d missing", i)
+		}
+	}
+	falsePositives := 0
+	for i := 10000; i < 110000; i++ {
+		if cf.Contains(strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 50 {
+		t.Errorf("%d false positives in 100000 lookups", falsePositives)
+	}
+
+	// Deleted values are gone, the others stay
+	for i := 0; i < 5000; i++ {
+		if !cf.Delete(strconv.Itoa(i)) {
+			t.Fatalf("delete %d: not found", i)
+		}
+	}
+	if cf.Count() != 5000 {
+		t.Errorf("count after deletes = %d, want 5000", cf.Count())
+	}
+	gone := 0
+	for i := 0; i < 5000; i++ {
+		if !cf.Contains(strconv.Itoa(i)) {
+			gone++
+		}
+	}
+	if gone < 4990 {
+		t.Errorf("only %d of 5000 deleted values gone", gone)
+	}
+	for i := 5000; i < 10000; i++ {
+		if !cf.Contains(strconv.Itoa(i)) {
+			t.Fatalf("%d missing after deleting others", i)
+		}
+	}
+
+	// A value added twice survives one deletion
+	cf.Add("twice")
+	cf.Add("twice")
+	cf.Delete("twice")
+	if !cf.Contains("twice") {
+		t.Error("value added twice gone after one deletion")
+	}
+}
+
+func TestCuckooFull(t *testing.T) {
+	cf := models.NewCuckoo(100, "cuckoo")
+
+	// Fill the filter until an insert fails
+	added := 0
+	for ; added < int(cf.Capacity()); added++ {
+		if err := cf.Add(strconv.Itoa(added)); err != nil {
+			if err != models.ErrCuckooFull {
+				t.Fatalf("add %d: %v, want ErrCuckooFull", added, err)
+			}
+			break
+		}
+	}
+	if added == int(cf.Capacity()) {
+		t.Fatalf("no insert failed after %d values", added)
+	}
+	if added < int(0.8*float64(cf.Capacity())) {
+		t.Errorf("filter full after %d values of %d slots", added, cf.Capacity())
+	}
+
+	// The failed insert dropped nothing
+	if cf.Count() != uint64(added) {
+		t.Errorf("count = %d, want %d", cf.Count(), added)
+	}
+	for i := 0; i < added; i++ {
+		if !cf.Contains(strconv.Itoa(i)) {
+			t.Fatalf("%d dropped by the failed insert", i)
+		}
+	}
+}
+
+func TestCuckooEncoding(t *testing.T) {
+	cf := models.NewCuckoo(1000, "cuckoo")
+	for i := 0; i < 500; i++ {
+		cf.Add(strconv.Itoa(i))
+	}
+	if !cf.Dirty() {
+		t.Error("adds didn't mark the filter dirty")
+	}
+	cf.MarkFlushed(cf.Version())
+	if cf.Dirty() {
+		t.Error("flushed filter still dirty")
+	}
+
+	encoded, err := cf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := models.DecodeCuckoo(encoded, "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Key() != "copy" || decoded.Capacity() != cf.Capacity() || decoded.Count() != 500 {
+		t.Errorf("decoded (%s, %d, %d)", decoded.Key(), decoded.Capacity(), decoded.Count())
+	}
+	for i := 0; i < 500; i++ {
+		if !decoded.Contains(strconv.Itoa(i)) {
+			t.Fatalf("%d missing from the decoded filter", i)
+		}
+	}
+
+	// Truncated or foreign encodings are rejected
+	for _, invalid := range [][]byte{nil, encoded[:len(encoded)-1], append([]byte{9}, encoded[1:]...)} {
+		if _, err = models.DecodeCuckoo(invalid, "invalid"); err != models.ErrInvalidCuckoo {
+			t.Errorf("decoding %d bytes: %v, want ErrInvalidCuckoo", len(invalid), err)
+		}
+	}
+}
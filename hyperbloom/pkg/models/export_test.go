@@ -0,0 +1,8 @@
+package models
+
+import "context"
+
+// SetLoader replaces how dbs loads the keys missing from memory, GetBloomFromDB otherwise.
+func (dbs *HyperBlooms) SetLoader(load func(ctx context.Context, key string) (*HyperBloom, error)) {
+	dbs.load = load
+}
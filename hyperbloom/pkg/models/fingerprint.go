@@ -0,0 +1,33 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Fingerprint returns the hex-encoded SHA-256 of the canonical serialization of the filters of the HyperBloom
+// instance: the Bloom filter, then the strict filter if enabled. The serialization only depends on the size,
+// number of hash functions and bits of the filters, so bit-identical filters share a fingerprint however they were
+// built, persisted or loaded. The HyperLogLog sketch is left out, its sparse representation is serialized
+// in map iteration order.
+func (db *HyperBloom) Fingerprint() (string, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	h := sha256.New()
+	if _, err := db.bloom.WriteTo(h); err != nil {
+		return "", err
+	}
+
+	// Tell a strict filter apart from a plain one with the same bits
+	if db.strict == nil {
+		h.Write([]byte{0})
+	} else {
+		h.Write([]byte{1})
+		if _, err := db.strict.WriteTo(h); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
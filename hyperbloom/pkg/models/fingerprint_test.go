@@ -0,0 +1,49 @@
+package models_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestFingerprint(t *testing.T) {
+	db := models.NewHyperBloomFromParams(10000, 0.01, "fingerprint")
+	twin := models.NewHyperBloomFromParams(10000, 0.01, "fingerprint-twin")
+	for i := 0; i < 500; i++ {
+		db.Hash(strconv.Itoa(i))
+		// Insertion order doesn't matter
+		twin.Hash(strconv.Itoa(499 - i))
+	}
+
+	fingerprint, err := db.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := db.Fingerprint(); again != fingerprint {
+		t.Errorf("fingerprint changed without a write: %s then %s", fingerprint, again)
+	}
+	if other, _ := twin.Fingerprint(); other != fingerprint {
+		t.Errorf("bit-identical filters have fingerprints %s and %s", fingerprint, other)
+	}
+
+	// Stable across a serialization round-trip
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := models.NewHyperBloomFromBlobs("fingerprint", time.Hour, time.Time{}, models.FormatVersion, blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, _ := loaded.Fingerprint(); reloaded != fingerprint {
+		t.Errorf("fingerprint changed by a round-trip: %s then %s", fingerprint, reloaded)
+	}
+
+	// Changes after a write
+	db.Hash("new value")
+	if changed, _ := db.Fingerprint(); changed == fingerprint {
+		t.Error("fingerprint unchanged after a write")
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// FirstSeen records, next to a Bloom filter, the coarse time at which values were first inserted.
+// Every value maps to k buckets with the same hashing as the Bloom filter, and each bucket keeps the time
+// (in whole seconds) of the first insert that touched it. The first insert of a value happened at the
+// latest of its buckets' times or after, so that time is reported as an approximation from below:
+// earlier values sharing all of a value's buckets make the answer too early, increasingly so as the buckets fill up.
+// Each bucket costs 4 bytes.
+type FirstSeen struct {
+	k       uint     // Number of buckets a value maps to
+	buckets []uint32 // First insert time of every bucket in seconds since the Unix epoch, 0 if never touched
+}
+
+// NewFirstSeen creates a FirstSeen of n buckets, each value mapping to k of them.
+func NewFirstSeen(n, k uint) *FirstSeen {
+	return &FirstSeen{k: k, buckets: make([]uint32, max(n, 1))}
+}
+
+// Add records that value was inserted at now, buckets already touched keep their earlier time.
+func (fs *FirstSeen) Add(value []byte, now time.Time) {
+	// 0 marks untouched buckets, clamp the timestamp above it
+	seconds := uint32(max(now.Unix(), 1))
+
+	for _, location := range bloom.Locations(value, fs.k) {
+		atomic.CompareAndSwapUint32(&fs.buckets[location%uint64(len(fs.buckets))], 0, seconds)
+	}
+}
+
+// Lookup returns the approximate time value was first inserted, and false if one of its buckets was never touched,
+// in which case the value was never inserted.
+func (fs *FirstSeen) Lookup(value []byte) (time.Time, bool) {
+	var latest uint32
+
+	for _, location := range bloom.Locations(value, fs.k) {
+		seconds := atomic.LoadUint32(&fs.buckets[location%uint64(len(fs.buckets))])
+		if seconds == 0 {
+			return time.Time{}, false
+		}
+		latest = max(latest, seconds)
+	}
+
+	return time.Unix(int64(latest), 0).UTC(), true
+}
+
+// MemoryBytes returns the size of the buckets.
+func (fs *FirstSeen) MemoryBytes() uint64 {
+	return uint64(len(fs.buckets)) * 4
+}
+
+// MarshalBinary encodes k followed by the buckets as little-endian 32-bit integers.
+func (fs *FirstSeen) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 4+4*len(fs.buckets))
+	binary.LittleEndian.PutUint32(data, uint32(fs.k))
+	for i := range fs.buckets {
+		binary.LittleEndian.PutUint32(data[4+4*i:], atomic.LoadUint32(&fs.buckets[i]))
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary.
+func (fs *FirstSeen) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 || len(data)%4 != 0 {
+		return errors.New("invalid first seen encoding")
+	}
+
+	fs.k = uint(binary.LittleEndian.Uint32(data))
+	if fs.k == 0 {
+		return errors.New("invalid first seen encoding: no hash function")
+	}
+	fs.buckets = make([]uint32, len(data)/4-1)
+	for i := range fs.buckets {
+		fs.buckets[i] = binary.LittleEndian.Uint32(data[4+4*i:])
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package models_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestFirstSeen(t *testing.T) {
+	fs := models.NewFirstSeen(1<<16, 7)
+	early := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+
+	for i := 0; i < 100; i++ {
+		fs.Add([]byte("early-"+strconv.Itoa(i)), early)
+	}
+	for i := 0; i < 100; i++ {
+		fs.Add([]byte("late-"+strconv.Itoa(i)), late)
+		// Inserting again keeps the first time
+		fs.Add([]byte("early-"+strconv.Itoa(i)), late)
+	}
+
+	for i := 0; i < 100; i++ {
+		if seen, ok := fs.Lookup([]byte("early-" + strconv.Itoa(i))); !ok || !seen.Equal(early) {
+			t.Errorf("early-%d first seen = (%s, %v), want %s", i, seen, ok, early)
+		}
+
+		// Collisions may only pull the answer earlier, never later
+		seen, ok := fs.Lookup([]byte("late-" + strconv.Itoa(i)))
+		if !ok || seen.After(late) || seen.Before(early) {
+			t.Errorf("late-%d first seen = (%s, %v), want within [%s, %s]", i, seen, ok, early, late)
+		}
+	}
+
+	if _, ok := fs.Lookup([]byte("never")); ok {
+		t.Error("value never inserted was reported as seen")
+	}
+}
+
+func TestFirstSeenMarshalBinary(t *testing.T) {
+	fs := models.NewFirstSeen(1024, 5)
+	now := time.Now().Truncate(time.Second)
+	fs.Add([]byte("value"), now)
+
+	data, err := fs.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 4+1024*4 || fs.MemoryBytes() != 1024*4 {
+		t.Errorf("encoded %d bytes for %d bytes of buckets", len(data), fs.MemoryBytes())
+	}
+
+	decoded := &models.FirstSeen{}
+	if err = decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if seen, ok := decoded.Lookup([]byte("value")); !ok || !seen.Equal(now) {
+		t.Errorf("decoded first seen = (%s, %v), want %s", seen, ok, now)
+	}
+
+	if err = decoded.UnmarshalBinary(data[:6]); err == nil {
+		t.Error("expected an error for a truncated encoding")
+	}
+}
@@ -0,0 +1,496 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync/atomic"
+
+	"github.com/axiomhq/hyperloglog"
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// Versions of the persisted format, stored in the format_version column next to the blobs.
+const (
+	// FormatRaw is the original format: the gob-encoded Bloom filter, the binary HyperLogLog sketch
+	// and the binary first insert times, as written before the format was versioned.
+	FormatRaw = 1
+
+	// FormatChecksummed appends the CRC-32C of every blob to it, so corruption is detected on load.
+	FormatChecksummed = 2
+
+	// FormatRecency adds the optional time-decayed distinct count blob, encoded by Recency.MarshalBinary.
+	FormatRecency = 3
+
+	// FormatScalable adds the optional scalable layers blob, encoded by Scalable.MarshalBinary.
+	FormatScalable = 4
+
+	// FormatCounting adds the optional counters blob of counting filters, encoded by Counting.MarshalBinary.
+	FormatCounting = 5
+
+	// FormatTopK adds the optional most frequent values blob, encoded by TopK.MarshalBinary.
+	FormatTopK = 6
+
+	// FormatVersion is the format written by this binary.
+	FormatVersion = FormatTopK
+)
+
+// ErrUnsupportedFormat is returned when loading blobs written in a format newer than this binary understands.
+var ErrUnsupportedFormat = errors.New("unsupported format version")
+
+// ErrCorruptedBlob is returned when a blob doesn't match its checksum or its encoding is structurally invalid.
+var ErrCorruptedBlob = errors.New("corrupted blob")
+
+// DecodeError is returned when a persisted blob can't be decoded: corrupted, truncated, inconsistent
+// with the other blobs or written in an unsupported format.
+type DecodeError struct {
+	Blob string // Blob that failed: bloom, hyper, firstseen, strict, recency, scalable, counting, topk, or format for the format version
+	Err  error  // Cause of the failure
+}
+
+// Error describes the failure along with the blob it affects.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("invalid %s blob: %v", e.Blob, e.Err)
+}
+
+// Unwrap returns the cause of the failure, e.g. ErrCorruptedBlob or ErrUnsupportedFormat.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// errMissingBlob is the cause of a DecodeError for a required blob that is NULL.
+var errMissingBlob = errors.New("missing")
+
+// bloomHeaderBytes is the size of the header of an encoded Bloom filter: its size m, its number of
+// hash functions k and the length of its bit array, as big-endian 64-bit integers.
+const bloomHeaderBytes = 24
+
+// castagnoli is the CRC-32C table used for the blob checksums.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Blobs holds the persisted representation of a HyperBloom instance.
+type Blobs struct {
+	Bloom     []byte // Bloom filter
+	Hyper     []byte // HyperLogLog sketch
+	FirstSeen []byte // First insert times, nil if not tracked
+	Strict    []byte // Strict membership filter, nil if disabled
+	Recency   []byte // Time-decayed distinct count, nil if disabled
+	Scalable  []byte // Scalable layers, nil if disabled
+	Counting  []byte // Counters of a counting filter, nil if disabled
+	TopK      []byte // Most frequent values, nil if not tracked
+}
+
+// migrations upgrade blobs from the format at their index to the next one, in place.
+var migrations = map[int]func(*Blobs) error{
+	FormatRaw: func(blobs *Blobs) error {
+		blobs.Bloom = sealBlob(blobs.Bloom)
+		blobs.Hyper = sealBlob(blobs.Hyper)
+		blobs.FirstSeen = sealBlob(blobs.FirstSeen)
+		blobs.Strict = sealBlob(blobs.Strict)
+		blobs.Recency = sealBlob(blobs.Recency)
+		blobs.Scalable = sealBlob(blobs.Scalable)
+		blobs.Counting = sealBlob(blobs.Counting)
+		blobs.TopK = sealBlob(blobs.TopK)
+		return nil
+	},
+
+	// Keys written before time-decayed counts existed don't keep one
+	FormatChecksummed: func(blobs *Blobs) error {
+		return nil
+	},
+
+	// Keys written before scalable layers existed don't grow
+	FormatRecency: func(blobs *Blobs) error {
+		return nil
+	},
+
+	// Keys written before counting filters existed can't remove values
+	FormatScalable: func(blobs *Blobs) error {
+		return nil
+	},
+
+	// Keys written before the most frequent values were tracked don't track them
+	FormatCounting: func(blobs *Blobs) error {
+		return nil
+	},
+}
+
+// MigrateBlobs upgrades blobs written in format version to FormatVersion, one version at a time.
+// It returns ErrUnsupportedFormat if version is newer than FormatVersion or unknown.
+func MigrateBlobs(version int, blobs *Blobs) error {
+	if version > FormatVersion {
+		return fmt.Errorf("%w: %d, this binary understands up to %d", ErrUnsupportedFormat, version, FormatVersion)
+	}
+
+	for ; version < FormatVersion; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrUnsupportedFormat, version)
+		}
+		if err := migrate(blobs); err != nil {
+			return fmt.Errorf("can't migrate format %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+// EncodeBlobs serializes the HyperBloom instance in FormatVersion, consistently: values inserted meanwhile
+// are either in all the blobs or in none.
+func (db *HyperBloom) EncodeBlobs() (*Blobs, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	bloomByterepr, err := db.bloom.GobEncode()
+	if err != nil {
+		return nil, err
+	}
+	hyperByterepr, err := db.hyper.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var firstSeenByterepr []byte
+	if db.firstSeen != nil {
+		if firstSeenByterepr, err = db.firstSeen.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+
+	var strictByterepr []byte
+	if db.strict != nil {
+		if strictByterepr, err = db.strict.GobEncode(); err != nil {
+			return nil, err
+		}
+	}
+
+	var recencyByterepr []byte
+	if db.recency != nil {
+		if recencyByterepr, err = db.recency.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+
+	var scalableByterepr []byte
+	if db.scalable != nil {
+		if scalableByterepr, err = db.scalable.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+
+	var countingByterepr []byte
+	if db.counting != nil {
+		if countingByterepr, err = db.counting.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+
+	var topKByterepr []byte
+	if db.topK != nil {
+		if topKByterepr, err = db.topK.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Blobs{
+		Bloom:     sealBlob(bloomByterepr),
+		Hyper:     sealBlob(hyperByterepr),
+		FirstSeen: sealBlob(firstSeenByterepr),
+		Strict:    sealBlob(strictByterepr),
+		Recency:   sealBlob(recencyByterepr),
+		Scalable:  sealBlob(scalableByterepr),
+		Counting:  sealBlob(countingByterepr),
+		TopK:      sealBlob(topKByterepr),
+	}, nil
+}
+
+// DecodeBlobs populates the HyperBloom instance from blobs written in format version, migrating them first if needed.
+// Every blob is checked against its checksum and the structural invariants of its encoding before being decoded,
+// so that a corrupted blob yields a *DecodeError instead of a panic, a huge allocation or a garbage filter.
+// blobs is left untouched.
+func (db *HyperBloom) DecodeBlobs(version int, blobs *Blobs) error {
+	// Work on a copy, the migrations rewrite the blobs
+	migrated := *blobs
+	if err := MigrateBlobs(version, &migrated); err != nil {
+		return &DecodeError{Blob: "format", Err: err}
+	}
+
+	var bf, strict *bloom.BloomFilter
+	hll := &hyperloglog.Sketch{}
+	var firstSeen *FirstSeen
+	var recency *Recency
+	var scalable *Scalable
+	var counting *Counting
+	var topK *TopK
+
+	err := decodeBlob("bloom", migrated.Bloom, func(data []byte) error {
+		if data == nil {
+			return errMissingBlob
+		}
+		var err error
+		bf, err = decodeBloom(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = decodeBlob("hyper", migrated.Hyper, func(data []byte) error {
+		if data == nil {
+			return errMissingBlob
+		}
+		return hll.UnmarshalBinary(data)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys created without tracking have no first insert times
+	err = decodeBlob("firstseen", migrated.FirstSeen, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		firstSeen = &FirstSeen{}
+		return firstSeen.UnmarshalBinary(data)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys created without strict membership have a single filter, of the same size as the primary one otherwise
+	err = decodeBlob("strict", migrated.Strict, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		var err error
+		if strict, err = decodeBloom(data); err != nil {
+			return err
+		}
+		if strict.Cap() != bf.Cap() || strict.K() != bf.K() {
+			return fmt.Errorf("filter (m = %d, k = %d) doesn't match the primary one (m = %d, k = %d)", strict.Cap(), strict.K(), bf.Cap(), bf.K())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys created without a time-decayed count have none
+	err = decodeBlob("recency", migrated.Recency, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		recency = &Recency{}
+		return recency.UnmarshalBinary(data)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys created without scalable layers keep their primary filter only
+	err = decodeBlob("scalable", migrated.Scalable, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		scalable = &Scalable{}
+		return scalable.UnmarshalBinary(data)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys created without counters can't remove values, they have a counter per bit of the primary filter otherwise
+	err = decodeBlob("counting", migrated.Counting, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		counting = &Counting{}
+		if err := counting.UnmarshalBinary(data); err != nil {
+			return err
+		}
+		if uint(len(counting.counters)) != bf.Cap() {
+			return fmt.Errorf("%d counters don't match the primary filter (m = %d)", len(counting.counters), bf.Cap())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys created without tracking have no most frequent values
+	err = decodeBlob("topk", migrated.TopK, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		topK = &TopK{}
+		return topK.UnmarshalBinary(data)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only touch the instance once every blob was decoded
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.bloom, db.hyper, db.firstSeen, db.strict, db.recency, db.scalable = bf, hll, firstSeen, strict, recency, scalable
+	db.counting, db.topK = counting, topK
+
+	// Have migrated blobs written back in the current format by the next flush
+	if version < FormatVersion {
+		atomic.AddUint64(&db.version, 1)
+	}
+
+	return nil
+}
+
+// binaryMagic starts the self-contained encoding of MarshalBinary, telling it apart from a bare blob.
+const binaryMagic = "HBLM"
+
+// MarshalBinary encodes the HyperBloom instance as a self-contained blob: binaryMagic, the format version
+// as a big-endian 16-bit integer, then the blobs of EncodeBlobs, each prefixed by its length plus one as
+// a big-endian 32-bit integer (0 for a nil blob). The key, decay and metadata aren't part of the encoding.
+func (db *HyperBloom) MarshalBinary() ([]byte, error) {
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := append([]byte(binaryMagic), 0, 0)
+	binary.BigEndian.PutUint16(encoded[len(binaryMagic):], FormatVersion)
+	for _, blob := range []([]byte){blobs.Bloom, blobs.Hyper, blobs.FirstSeen, blobs.Strict, blobs.Recency, blobs.Scalable, blobs.Counting, blobs.TopK} {
+		length := uint32(0)
+		if blob != nil {
+			length = uint32(len(blob)) + 1
+		}
+		encoded = binary.BigEndian.AppendUint32(encoded, length)
+		encoded = append(encoded, blob...)
+	}
+
+	return encoded, nil
+}
+
+// UnmarshalBinary populates the HyperBloom instance from an encoding of MarshalBinary, migrating it from an older
+// format version like DecodeBlobs. It returns a *DecodeError wrapping ErrUnsupportedFormat for a version newer than
+// FormatVersion, or ErrCorruptedBlob for data that isn't such an encoding, and leaves the instance untouched then.
+func (db *HyperBloom) UnmarshalBinary(data []byte) error {
+	corrupted := func(reason string) error {
+		return &DecodeError{Blob: "format", Err: fmt.Errorf("%w: %s", ErrCorruptedBlob, reason)}
+	}
+
+	header := len(binaryMagic) + 2
+	if len(data) < header || string(data[:len(binaryMagic)]) != binaryMagic {
+		return corrupted("not a HyperBloom encoding")
+	}
+	version := int(binary.BigEndian.Uint16(data[len(binaryMagic):]))
+
+	// Split the length-prefixed blobs, checking every length against what is left. Encodings in a format
+	// older than FormatScalable have no scalable layers blob, older than FormatCounting no counters blob,
+	// older than FormatTopK no most frequent values blob.
+	blobs := &Blobs{}
+	fields := []*[]byte{&blobs.Bloom, &blobs.Hyper, &blobs.FirstSeen, &blobs.Strict, &blobs.Recency}
+	if version >= FormatScalable {
+		fields = append(fields, &blobs.Scalable)
+	}
+	if version >= FormatCounting {
+		fields = append(fields, &blobs.Counting)
+	}
+	if version >= FormatTopK {
+		fields = append(fields, &blobs.TopK)
+	}
+	rest := data[header:]
+	for _, blob := range fields {
+		if len(rest) < 4 {
+			return corrupted("truncated blob length")
+		}
+		length := binary.BigEndian.Uint32(rest)
+		rest = rest[4:]
+		if length == 0 {
+			continue
+		}
+		if uint64(length-1) > uint64(len(rest)) {
+			return corrupted("truncated blob")
+		}
+		*blob, rest = rest[:length-1], rest[length-1:]
+	}
+	if len(rest) != 0 {
+		return corrupted("trailing bytes")
+	}
+
+	return db.DecodeBlobs(version, blobs)
+}
+
+// decodeBlob verifies the checksum of the blob called name and hands its data to decode, nil for a NULL blob.
+// Failures, panics of the decoders included, are reported as a *DecodeError.
+func decodeBlob(name string, blob []byte, decode func([]byte) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &DecodeError{Blob: name, Err: fmt.Errorf("%w: %v", ErrCorruptedBlob, r)}
+		}
+	}()
+
+	data, err := openBlob(blob)
+	if err == nil {
+		err = decode(data)
+	}
+	if err != nil {
+		return &DecodeError{Blob: name, Err: err}
+	}
+	return nil
+}
+
+// decodeBloom decodes a Bloom filter encoded by GobEncode, after checking that its header is consistent
+// and that its bit array holds exactly m bits, so that a corrupted length can't trigger a huge allocation.
+func decodeBloom(data []byte) (*bloom.BloomFilter, error) {
+	if len(data) < bloomHeaderBytes {
+		return nil, fmt.Errorf("%w: truncated header", ErrCorruptedBlob)
+	}
+
+	m := binary.BigEndian.Uint64(data)
+	k := binary.BigEndian.Uint64(data[8:])
+	length := binary.BigEndian.Uint64(data[16:])
+	if m == 0 || k == 0 || k > m {
+		return nil, fmt.Errorf("%w: invalid parameters m = %d, k = %d", ErrCorruptedBlob, m, k)
+	}
+	if length != m {
+		return nil, fmt.Errorf("%w: bit array of %d bits for m = %d", ErrCorruptedBlob, length, m)
+	}
+	if words := m/64 + min(m%64, 1); uint64(len(data)-bloomHeaderBytes) != words*8 {
+		return nil, fmt.Errorf("%w: %d bytes of bit array for %d words", ErrCorruptedBlob, len(data)-bloomHeaderBytes, words)
+	}
+
+	bf := &bloom.BloomFilter{}
+	if err := bf.GobDecode(data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptedBlob, err)
+	}
+	return bf, nil
+}
+
+// sealBlob returns data followed by its big-endian CRC-32C, or nil for nil data.
+func sealBlob(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+
+	blob := make([]byte, len(data), len(data)+4)
+	copy(blob, data)
+	return binary.BigEndian.AppendUint32(blob, crc32.Checksum(data, castagnoli))
+}
+
+// openBlob verifies the checksum appended by sealBlob and returns the data without it, or nil for nil.
+func openBlob(blob []byte) ([]byte, error) {
+	if blob == nil {
+		return nil, nil
+	}
+	if len(blob) < 4 {
+		return nil, ErrCorruptedBlob
+	}
+
+	data, sum := blob[:len(blob)-4], binary.BigEndian.Uint32(blob[len(blob)-4:])
+	if crc32.Checksum(data, castagnoli) != sum {
+		return nil, ErrCorruptedBlob
+	}
+
+	return data, nil
+}
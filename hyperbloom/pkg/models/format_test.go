@@ -0,0 +1,241 @@
+package models_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/pkg/models"
+
+	"github.com/axiomhq/hyperloglog"
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// rawFixture returns blobs in FormatRaw, as persisted before the format was versioned, holding values 0 to n-1.
+func rawFixture(t *testing.T, n int) *models.Blobs {
+	t.Helper()
+
+	bf := bloom.NewWithEstimates(10000, 0.0081)
+	hll := hyperloglog.New()
+	for i := 0; i < n; i++ {
+		bf.AddString(strconv.Itoa(i))
+		hll.Insert([]byte(strconv.Itoa(i)))
+	}
+
+	bloomByterepr, err := bf.GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hyperByterepr, err := hll.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &models.Blobs{Bloom: bloomByterepr, Hyper: hyperByterepr}
+}
+
+func TestDecodeBlobsMigratesRawFormat(t *testing.T) {
+	raw := rawFixture(t, 500)
+	saved := append([]byte(nil), raw.Bloom...)
+
+	db := &models.HyperBloom{}
+	if err := db.DecodeBlobs(models.FormatRaw, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 500; i++ {
+		if !db.CheckExists(strconv.Itoa(i)) {
+			t.Fatalf("value %d lost in the migration", i)
+		}
+	}
+	if card := db.Hyper().Estimate(); card < 490 || card > 510 {
+		t.Errorf("migrated cardinality = %d, want about 500", card)
+	}
+	if db.FirstSeen() != nil {
+		t.Error("first insert times appeared in the migration")
+	}
+	if string(raw.Bloom) != string(saved) {
+		t.Error("the migration modified the given blobs")
+	}
+
+	// Encoding the migrated instance yields the current format, which decodes to the same content
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &models.HyperBloom{}
+	if err = decoded.DecodeBlobs(models.FormatVersion, blobs); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Bloom().Equal(db.Bloom()) {
+		t.Error("re-encoded Bloom filter differs from the migrated one")
+	}
+}
+
+func TestDecodeBlobsRejectsNewerFormat(t *testing.T) {
+	err := (&models.HyperBloom{}).DecodeBlobs(models.FormatVersion+1, rawFixture(t, 10))
+	if !errors.Is(err, models.ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestDecodeBlobsDetectsCorruption(t *testing.T) {
+	blobs := rawFixture(t, 10)
+	if err := models.MigrateBlobs(models.FormatRaw, blobs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit of the Bloom filter
+	blobs.Bloom[len(blobs.Bloom)/2] ^= 1
+	err := (&models.HyperBloom{}).DecodeBlobs(models.FormatVersion, blobs)
+	if !errors.Is(err, models.ErrCorruptedBlob) {
+		t.Errorf("expected ErrCorruptedBlob, got %v", err)
+	}
+}
+
+// resealed returns data followed by a valid CRC-32C, as if it had been persisted corrupted.
+func resealed(data []byte) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte(nil), data...), crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+}
+
+func TestDecodeBlobsValidatesStructure(t *testing.T) {
+	raw := rawFixture(t, 10)
+	strict, err := bloom.New(64, 3).GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blobs with a valid checksum over invalid content
+	hugeLength := append([]byte(nil), raw.Bloom...)
+	binary.BigEndian.PutUint64(hugeLength[16:], 1<<60)
+	noHashes := append([]byte(nil), raw.Bloom...)
+	binary.BigEndian.PutUint64(noHashes[8:], 0)
+
+	cases := map[string]struct {
+		blob  string
+		blobs models.Blobs
+	}{
+		"truncated bloom":    {"bloom", models.Blobs{Bloom: resealed(raw.Bloom[:len(raw.Bloom)-8]), Hyper: resealed(raw.Hyper)}},
+		"truncated header":   {"bloom", models.Blobs{Bloom: resealed(raw.Bloom[:10]), Hyper: resealed(raw.Hyper)}},
+		"huge bit array":     {"bloom", models.Blobs{Bloom: resealed(hugeLength), Hyper: resealed(raw.Hyper)}},
+		"no hash function":   {"bloom", models.Blobs{Bloom: resealed(noHashes), Hyper: resealed(raw.Hyper)}},
+		"missing bloom":      {"bloom", models.Blobs{Hyper: resealed(raw.Hyper)}},
+		"garbage hyper":      {"hyper", models.Blobs{Bloom: resealed(raw.Bloom), Hyper: resealed([]byte{1, 2, 3})}},
+		"truncated hyper":    {"hyper", models.Blobs{Bloom: resealed(raw.Bloom), Hyper: resealed(raw.Hyper[:len(raw.Hyper)/2])}},
+		"mismatched strict":  {"strict", models.Blobs{Bloom: resealed(raw.Bloom), Hyper: resealed(raw.Hyper), Strict: resealed(strict)}},
+		"truncated checksum": {"bloom", models.Blobs{Bloom: []byte{1, 2}, Hyper: resealed(raw.Hyper)}},
+	}
+	for name, c := range cases {
+		db := models.NewHyperBloomFromParams(100, 0.01, "decode")
+		err := db.DecodeBlobs(models.FormatVersion, &c.blobs)
+
+		var decodeErr *models.DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Blob != c.blob {
+			t.Errorf("%s: expected a DecodeError for the %s blob, got %v", name, c.blob, err)
+		}
+		// The instance is left as it was
+		if db.Bloom().Cap() != models.NewHyperBloomFromParams(100, 0.01, "decode").Bloom().Cap() {
+			t.Errorf("%s: instance modified by a failed decoding", name)
+		}
+	}
+
+	err = (&models.HyperBloom{}).DecodeBlobs(models.FormatVersion+1, raw)
+	var decodeErr *models.DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Blob != "format" || !errors.Is(err, models.ErrUnsupportedFormat) {
+		t.Errorf("expected a DecodeError wrapping ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestMarshalBinaryRoundTrip(t *testing.T) {
+	db := models.NewHyperBloomFromParams(10000, 0.01, "marshal")
+	db.EnableStrict()
+	db.EnableRecency(time.Hour)
+	for i := 0; i < 1000; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+
+	encoded, err := db.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &models.HyperBloom{}
+	if err = decoded.UnmarshalBinary(encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.BloomCardinality() != db.BloomCardinality() || decoded.HyperCardinality() != db.HyperCardinality() {
+		t.Errorf("cardinalities = (%d, %d), want (%d, %d)",
+			decoded.BloomCardinality(), decoded.HyperCardinality(), db.BloomCardinality(), db.HyperCardinality())
+	}
+	if !decoded.Strict() || decoded.Recency() == nil {
+		t.Error("strict filter or time-decayed count lost")
+	}
+	for i := 0; i < 1000; i++ {
+		if !decoded.CheckExists(strconv.Itoa(i)) {
+			t.Fatalf("%d missing after the round trip", i)
+		}
+	}
+}
+
+func TestUnmarshalBinaryMigratesRawFormat(t *testing.T) {
+	raw := rawFixture(t, 500)
+
+	// Encode the raw blobs under the FormatRaw header, as an older binary would have
+	encoded := append([]byte("HBLM"), 0, models.FormatRaw)
+	for _, blob := range [][]byte{raw.Bloom, raw.Hyper, nil, nil, nil} {
+		length := uint32(0)
+		if blob != nil {
+			length = uint32(len(blob)) + 1
+		}
+		encoded = binary.BigEndian.AppendUint32(encoded, length)
+		encoded = append(encoded, blob...)
+	}
+
+	db := &models.HyperBloom{}
+	if err := db.UnmarshalBinary(encoded); err != nil {
+		t.Fatal(err)
+	}
+	if !db.CheckExists("499") {
+		t.Error("value missing after migrating")
+	}
+}
+
+func TestUnmarshalBinaryRejectsInvalidData(t *testing.T) {
+	db := models.NewHyperBloomFromParams(1000, 0.01, "reject")
+	db.Hash("value")
+	encoded, err := db.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A newer version is rejected with a clear error instead of being misread
+	newer := append([]byte{}, encoded...)
+	binary.BigEndian.PutUint16(newer[4:], models.FormatVersion+1)
+	if err = (&models.HyperBloom{}).UnmarshalBinary(newer); !errors.Is(err, models.ErrUnsupportedFormat) {
+		t.Errorf("newer format: %v, want ErrUnsupportedFormat", err)
+	}
+
+	// Anything else that isn't a complete encoding is corrupted
+	flipped := append([]byte{}, encoded...)
+	flipped[len(flipped)/2] ^= 0xff
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"bare blob": encoded[6:],
+		"truncated": encoded[:len(encoded)-1],
+		"trailing":  append(append([]byte{}, encoded...), 0),
+		"flipped":   flipped,
+	} {
+		decoded := models.NewHyperBloomFromParams(1000, 0.01, "reject")
+		err = decoded.UnmarshalBinary(data)
+		var decodeErr *models.DecodeError
+		if !errors.Is(err, models.ErrCorruptedBlob) || !errors.As(err, &decodeErr) {
+			t.Errorf("%s: %v, want a DecodeError wrapping ErrCorruptedBlob", name, err)
+		}
+		if decoded.CheckExists("value") {
+			t.Errorf("%s: instance modified by a failed decoding", name)
+		}
+	}
+}
@@ -4,6 +4,13 @@
 package models
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopds/hyperbloom/internal/config"
@@ -16,137 +23,595 @@ import (
 
 // HyperBloom represents a data structure combining a Bloom filter and a HyperLogLog sketch.
 // It supports operations for hashing values, checking existence, cardinality estimation,
-// and database serialization.
+// and database serialization. It is safe for concurrent use: requests and the background flush
+// may read and write the same instance at once.
 type HyperBloom struct {
+	// Mutex guarding the bits of the Bloom filters, the HyperLogLog sketch and which filters and sketches
+	// the instance holds. Lookups share it, inserts hold it exclusively, and so do reads of the sketch,
+	// which may compact its sparse representation.
+	mutex sync.RWMutex
+
 	bloom    *bloom.BloomFilter  // Bloom filter for membership testing
 	hyper    *hyperloglog.Sketch // HyperLogLog sketch for cardinality estimation
 	key      string              // Unique identifier for the HyperBloom instance
 	decay    time.Duration       // Time duration after which the instance is considered decayed
-	lastUsed time.Time           // Timestamp of the last operation on the instance
+	lastUsed atomic.Int64        // Unix nanoseconds of the last operation on the instance
+	created  time.Time           // Timestamp of the creation of the instance, zero if unknown
+	expires  time.Time           // Timestamp after which the instance is considered absent, zero if it never expires
+	version  uint64              // Counter incremented on every insert
+	flushed  uint64              // Version last written to the database
+	deleted  atomic.Bool         // Whether the instance was deleted, it must then never be written back
+
+	firstSeen *FirstSeen         // Coarse first insert times, nil unless tracking was enabled when the instance was created
+	strict    *bloom.BloomFilter // Second filter with independent hash functions, nil unless strict membership is enabled
+	recency   *Recency           // Time-decayed distinct count, nil unless enabled when the instance was created
+	scalable  *Scalable          // Layers added when the filter is saturated, nil unless enabled when the instance was created
+	counting  *Counting          // Counters of the bits of the filter for removals, nil unless enabled when the instance was created
+	topK      *TopK              // Most frequent values, nil unless tracking was enabled when the instance was created
+
+	typeMutex sync.Mutex // Mutex guarding valueType
+	valueType string     // Declared type of the inserted values, empty until a typed value is inserted
+
+	valueBytes uint64 // Total length of the inserted values, only tracked if enabled in the configuration
+	valueCount uint64 // Number of inserted values accounted in valueBytes
+
+	cardMutex sync.Mutex        // Mutex guarding the cached cardinalities
+	card      *cardinalityCache // Cardinalities computed at a given version, nil until first computed
+}
+
+// cardinalityCache holds the cardinality estimates of a HyperBloom at a given version.
+type cardinalityCache struct {
+	version uint64 // Version of the HyperBloom the estimates were computed at
+	bloom   uint32 // Estimated cardinality of the Bloom filter
+	hyper   uint64 // Estimated cardinality of the HyperLogLog sketch
 }
 
 // NewHyperBloom creates a new HyperBloom instance initialized with given Bloom filter,
 // HyperLogLog sketch, and metadata.
+// First insert times are tracked if HB_FIRST_SEEN_BUCKETS is set, the most frequent values if HB_TOPK_CAPACITY is.
 func NewHyperBloom(bf *bloom.BloomFilter, hll *hyperloglog.Sketch, key string) *HyperBloom {
-	return &HyperBloom{
-		bloom:    bf,
-		hyper:    hll,
-		key:      key,
-		lastUsed: time.Now().UTC(),
-		decay:    config.HyperBloomCfg.Decay,
+	cfg := config.HyperBloomCfg()
+	db := &HyperBloom{
+		bloom:   bf,
+		hyper:   hll,
+		key:     key,
+		created: time.Now().UTC(),
+		decay:   cfg.Decay,
+	}
+	db.Refresh()
+
+	if buckets := cfg.FirstSeenBuckets; buckets > 0 {
+		db.firstSeen = NewFirstSeen(buckets, bf.K())
+	}
+	if capacity := cfg.TopKCapacity; capacity > 0 {
+		db.topK = NewTopK(capacity)
 	}
+
+	return db
 }
 
 // NewHyperBloomFromParams creates a new HyperBloom instance with specified capacity,
 // false positive rate, and metadata.
 func NewHyperBloomFromParams(capacity uint, falsePositive float64, key string) *HyperBloom {
 	bf := bloom.NewWithEstimates(capacity, falsePositive)
-	hll := hyperloglog.New()
+	preallocate(bf.BitSet())
+	hll := newSketch()
+	return NewHyperBloom(bf, hll, key)
+}
+
+// NewHyperBloomFromSize creates a new HyperBloom instance with a Bloom filter of m bits and k hash functions,
+// for callers that choose the parameters themselves instead of deriving them from a capacity.
+func NewHyperBloomFromSize(m, k uint, key string) *HyperBloom {
+	bf := bloom.New(m, k)
+	preallocate(bf.BitSet())
+	hll := newSketch()
 	return NewHyperBloom(bf, hll, key)
 }
 
 // NewDefaultHyperBloom creates a new HyperBloom instance with default configuration
 // specified in the application's configuration.
 func NewDefaultHyperBloom(key string) *HyperBloom {
-	capacity := config.HyperBloomCfg.Cardinality
-	falsePositive := config.HyperBloomCfg.FalsePositive
-	return NewHyperBloomFromParams(capacity, falsePositive, key)
+	cfg := config.HyperBloomCfg()
+	return NewHyperBloomFromParams(cfg.Cardinality, cfg.FalsePositive, key)
 }
 
 // GETTERS
 
-// Bloom returns the Bloom filter instance of the HyperBloom.
+// Bloom returns the Bloom filter instance of the HyperBloom. Its size and number of hash functions never change,
+// but its bits may be written concurrently: read them through BitSet or SetBits.
 func (db *HyperBloom) Bloom() *bloom.BloomFilter {
 	return db.bloom
 }
 
-// Hyper returns the HyperLogLog sketch instance of the HyperBloom.
+// Precisions p a HyperLogLog sketch can be created with, which has 2^p registers and estimates
+// with a standard error of about 1.04/sqrt(2^p): each extra bit doubles the memory and divides the error by √2.
+// The sketch package only builds these two.
+const (
+	DefaultHyperPrecision = 14
+	HighHyperPrecision    = 16
+)
+
+// HyperPrecisions lists the supported precisions of a HyperLogLog sketch, see DefaultHyperPrecision.
+var HyperPrecisions = []uint8{DefaultHyperPrecision, HighHyperPrecision}
+
+// ValidHyperPrecision reports whether a HyperLogLog sketch can be created with the given precision.
+func ValidHyperPrecision(precision uint8) bool {
+	return precision == DefaultHyperPrecision || precision == HighHyperPrecision
+}
+
+// Hyper returns a copy of the HyperLogLog sketch of the HyperBloom, which values inserted meanwhile don't change.
 func (db *HyperBloom) Hyper() *hyperloglog.Sketch {
-	return db.hyper
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	return db.hyper.Clone()
 }
 
 // Key returns the unique identifier (key) of the HyperBloom.
 func (db *HyperBloom) Key() string {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
 	return db.key
 }
 
+// rename changes the key of the HyperBloom, see HyperBlooms.Swap.
+func (db *HyperBloom) rename(key string) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.key = key
+}
+
 // Decay returns the decay duration after which the HyperBloom instance is considered decayed.
 func (db *HyperBloom) Decay() time.Duration {
 	return db.decay
 }
 
+// FirstSeen returns the first insert times of the HyperBloom instance, or nil if they are not tracked.
+func (db *HyperBloom) FirstSeen() *FirstSeen {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return db.firstSeen
+}
+
+// FirstSeenBytes returns the serialized first insert times of the HyperBloom instance, or nil if they are not tracked.
+func (db *HyperBloom) FirstSeenBytes() []byte {
+	firstSeen := db.FirstSeen()
+	if firstSeen == nil {
+		return nil
+	}
+	data, _ := firstSeen.MarshalBinary()
+	return data
+}
+
+// CreatedAt returns the creation time of the HyperBloom instance,
+// zero for keys created before creation times were recorded.
+func (db *HyperBloom) CreatedAt() time.Time {
+	return db.created
+}
+
+// ExpiresAt returns the time after which the HyperBloom instance is considered absent, zero if it never expires.
+func (db *HyperBloom) ExpiresAt() time.Time {
+	return db.expires
+}
+
+// SetExpiry sets the time after which the HyperBloom instance is considered absent, zero for never.
+func (db *HyperBloom) SetExpiry(t time.Time) {
+	db.expires = t
+}
+
+// Expired reports whether the HyperBloom instance has an expiry time that is not after now.
+func (db *HyperBloom) Expired(now time.Time) bool {
+	return !db.expires.IsZero() && !now.Before(db.expires)
+}
+
+// Empty reports whether no value was ever inserted in the HyperBloom instance, i.e. no bit is set in its Bloom filter.
+func (db *HyperBloom) Empty() bool {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return db.bloom.BitSet().None()
+}
+
 // LastUsed returns the timestamp of the last operation on the HyperBloom instance.
 func (db *HyperBloom) LastUsed() time.Time {
-	return db.lastUsed
+	return time.Unix(0, db.lastUsed.Load())
+}
+
+// Version returns the number of inserts applied to the HyperBloom instance since it was loaded.
+func (db *HyperBloom) Version() uint64 {
+	return atomic.LoadUint64(&db.version)
 }
 
-// BitSet returns the underlying BitSet of the Bloom filter in the HyperBloom instance.
+// Dirty reports whether inserts were applied to the HyperBloom instance since it was last written to the database.
+func (db *HyperBloom) Dirty() bool {
+	return db.Version() != atomic.LoadUint64(&db.flushed)
+}
+
+// MarkFlushed records that the HyperBloom instance was written to the database as of version,
+// the Version read before encoding it: inserts racing with the write leave it dirty.
+func (db *HyperBloom) MarkFlushed(version uint64) {
+	atomic.StoreUint64(&db.flushed, version)
+}
+
+// Deleted reports whether the HyperBloom instance was deleted from the collection holding it, see HyperBlooms.Delete.
+func (db *HyperBloom) Deleted() bool {
+	return db.deleted.Load()
+}
+
+// AverageValueLength returns the average length in bytes of the values inserted since the instance was loaded,
+// and false if value lengths are not tracked or nothing was inserted yet.
+func (db *HyperBloom) AverageValueLength() (float64, bool) {
+	count := atomic.LoadUint64(&db.valueCount)
+	if count == 0 {
+		return 0, false
+	}
+	return float64(atomic.LoadUint64(&db.valueBytes)) / float64(count), true
+}
+
+// MemoryBytes returns the approximate memory footprint of the Bloom filter bit array and HyperLogLog sketch,
+// and of the first insert times, strict filter, time-decayed count, scalable layers, counters and most frequent
+// values when enabled.
+func (db *HyperBloom) MemoryBytes() uint64 {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	hyperByterepr, _ := db.hyper.MarshalBinary()
+	bytes := uint64(len(db.bloom.BitSet().Bytes()))*8 + uint64(len(hyperByterepr))
+	if db.firstSeen != nil {
+		bytes += db.firstSeen.MemoryBytes()
+	}
+	if db.strict != nil {
+		bytes += uint64(len(db.strict.BitSet().Bytes())) * 8
+	}
+	if db.recency != nil {
+		bytes += db.recency.MemoryBytes()
+	}
+	if db.scalable != nil {
+		bytes += db.scalable.memoryBytes()
+	}
+	if db.counting != nil {
+		bytes += db.counting.memoryBytes()
+	}
+	if db.topK != nil {
+		bytes += db.topK.memoryBytes()
+	}
+	return bytes
+}
+
+// BitSet returns a copy of the bit array of the Bloom filter in the HyperBloom instance,
+// which values inserted meanwhile don't change.
 func (db *HyperBloom) BitSet() *bitset.BitSet {
-	return db.bloom.BitSet()
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return db.bloom.BitSet().Clone()
+}
+
+// SetBits returns the number of bits set in the Bloom filter of the HyperBloom instance.
+// Like JaccardSimBF, bit arrays of at least HB_SIM_PARALLEL_WORDS words are counted by several goroutines.
+func (db *HyperBloom) SetBits() uint64 {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	bs := db.bloom.BitSet()
+	cfg := config.HyperBloomCfg()
+	if cfg.SimParallelWords <= 0 || len(bs.Bytes()) < cfg.SimParallelWords {
+		return uint64(bs.Count())
+	}
+
+	// Without a second array every word only contributes to the union count
+	_, count := parallelPopCount(bs.Bytes(), nil, cfg.SimWorkers)
+	return count
 }
 
-// BloomCardinality returns the estimated cardinality of the Bloom filter in the HyperBloom instance.
+// BloomCardinality returns the estimated cardinality of the Bloom filter in the HyperBloom instance,
+// summed over its layers when it is scalable.
 func (db *HyperBloom) BloomCardinality() uint32 {
-	return db.bloom.ApproximatedSize()
+	bCard, _ := db.cardinalities()
+	return bCard
 }
 
 // HyperCardinality returns the estimated cardinality of the HyperLogLog sketch in the HyperBloom instance.
 func (db *HyperBloom) HyperCardinality() uint64 {
-	return db.hyper.Estimate()
+	_, hCard := db.cardinalities()
+	return hCard
+}
+
+// cardinalities returns the estimated cardinalities of the Bloom filter and HyperLogLog sketch.
+// When caching is enabled, the estimates are only recomputed after an insert bumped the version,
+// so repeated reads between writes don't walk the bit array and registers again.
+func (db *HyperBloom) cardinalities() (uint32, uint64) {
+	if !config.HyperBloomCfg().CacheCardinality {
+		return db.estimates()
+	}
+
+	db.cardMutex.Lock()
+	defer db.cardMutex.Unlock()
+
+	// Recompute the estimates if nothing is cached yet or an insert happened since
+	version := db.Version()
+	if db.card == nil || db.card.version != version {
+		bCard, hCard := db.estimates()
+		db.card = &cardinalityCache{version: version, bloom: bCard, hyper: hCard}
+	}
+
+	return db.card.bloom, db.card.hyper
+}
+
+// estimates computes the estimated cardinalities of the Bloom filter and HyperLogLog sketch.
+func (db *HyperBloom) estimates() (uint32, uint64) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	return db.bloomSize(), db.hyper.Estimate()
+}
+
+// bloomSize returns the estimated cardinality of the Bloom filter, or of all the layers when it is scalable.
+// The caller holds the mutex.
+func (db *HyperBloom) bloomSize() uint32 {
+	if db.scalable != nil {
+		return db.scalable.cardinality(db.bloom)
+	}
+	return db.bloom.ApproximatedSize()
 }
 
 // SETTERS
 
-// Hash adds a value to both the Bloom filter and HyperLogLog sketch of the HyperBloom instance.
-func (db *HyperBloom) Hash(value string) {
+// addBloom adds a value to the Bloom filter, or to the newest layer when it is scalable,
+// counting it when the filter is a counting one. The caller holds the mutex exclusively.
+func (db *HyperBloom) addBloom(value string) {
+	if db.scalable != nil {
+		db.scalable.add(db.bloom, value)
+		return
+	}
+	if db.counting != nil {
+		db.counting.add(db.bloom, value)
+		return
+	}
 	db.bloom.AddString(value)
+}
+
+// Hash adds a value to both the Bloom filter and HyperLogLog sketch of the HyperBloom instance,
+// to the strict filter, first insert times, time-decayed count and most frequent values when enabled.
+func (db *HyperBloom) Hash(value string) {
+	db.mutex.Lock()
+	db.addBloom(value)
 	db.hyper.Insert([]byte(value))
+	if db.strict != nil {
+		db.strict.AddString(strictValue(value))
+	}
+	if db.firstSeen != nil {
+		db.firstSeen.Add([]byte(value), time.Now())
+	}
+	if db.recency != nil {
+		db.recency.Add([]byte(value), time.Now())
+	}
+	if db.topK != nil {
+		db.topK.add(value)
+	}
+	db.mutex.Unlock()
+
+	// Invalidate the cached cardinalities
+	atomic.AddUint64(&db.version, 1)
+
+	// Account the value length, two atomic adds are cheap enough to leave on the insert path
+	if config.HyperBloomCfg().TrackValueLength {
+		atomic.AddUint64(&db.valueBytes, uint64(len(value)))
+		atomic.AddUint64(&db.valueCount, 1)
+	}
+}
+
+// HashBatch adds several values like Hash, invalidating the cached cardinalities once for the whole batch.
+func (db *HyperBloom) HashBatch(values []string) {
+	now := time.Now()
+	valueBytes := uint64(0)
+	db.mutex.Lock()
+	for _, value := range values {
+		db.addBloom(value)
+		db.hyper.Insert([]byte(value))
+		if db.strict != nil {
+			db.strict.AddString(strictValue(value))
+		}
+		if db.firstSeen != nil {
+			db.firstSeen.Add([]byte(value), now)
+		}
+		if db.recency != nil {
+			db.recency.Add([]byte(value), now)
+		}
+		if db.topK != nil {
+			db.topK.add(value)
+		}
+		valueBytes += uint64(len(value))
+	}
+	db.mutex.Unlock()
+
+	// Invalidate the cached cardinalities
+	atomic.AddUint64(&db.version, 1)
+
+	// Account the value lengths of the whole batch at once
+	if config.HyperBloomCfg().TrackValueLength {
+		atomic.AddUint64(&db.valueBytes, valueBytes)
+		atomic.AddUint64(&db.valueCount, uint64(len(values)))
+	}
+}
+
+// MergeHyper merges a HyperLogLog sketch of the same precision into the sketch of the HyperBloom instance,
+// leaving its Bloom filters untouched: the merged values are counted but not reported as members.
+func (db *HyperBloom) MergeHyper(sketch *hyperloglog.Sketch) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if err := db.hyper.Merge(sketch); err != nil {
+		return err
+	}
+
+	// Invalidate the cached cardinalities and have the merge flushed
+	atomic.AddUint64(&db.version, 1)
+	return nil
+}
+
+// SetHyperPrecision replaces the HyperLogLog sketch of the HyperBloom instance with an empty one of the given
+// precision, see HyperPrecisions. Values counted before are lost, so it must be called on an empty instance.
+// It returns an error if the precision isn't supported.
+func (db *HyperBloom) SetHyperPrecision(precision uint8) error {
+	sketch, err := newSketchPrecision(precision)
+	if err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	db.hyper = sketch
+	db.mutex.Unlock()
+
+	atomic.AddUint64(&db.version, 1)
+	return nil
+}
+
+// Reset empties the HyperBloom instance while keeping its configuration: the Bloom filters and counters are cleared
+// and the layers of a scalable filter dropped, the HyperLogLog sketch is replaced by an empty one of the same
+// precision, and the first insert times, time-decayed count, most frequent values and value lengths start over. The creation time, expiry
+// and declared value type are kept. The reset is written by the next flush like an insert.
+func (db *HyperBloom) Reset() error {
+	sketch, err := newSketchPrecision(db.HyperPrecision())
+	if err != nil {
+		return err
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	db.bloom.ClearAll()
+	db.hyper = sketch
+	if db.strict != nil {
+		db.strict.ClearAll()
+	}
+	if db.scalable != nil {
+		db.scalable.reset()
+	}
+	if db.counting != nil {
+		db.counting.reset()
+	}
+	if db.firstSeen != nil {
+		db.firstSeen = NewFirstSeen(uint(len(db.firstSeen.buckets)), db.firstSeen.k)
+	}
+	if db.recency != nil {
+		db.recency = NewRecency(db.recency.HalfLife())
+	}
+	if db.topK != nil {
+		db.topK.reset()
+	}
+	atomic.StoreUint64(&db.valueBytes, 0)
+	atomic.StoreUint64(&db.valueCount, 0)
+
+	// Invalidate the cached cardinalities and have the reset flushed
+	atomic.AddUint64(&db.version, 1)
+	return nil
+}
+
+// HyperPrecision returns the precision of the HyperLogLog sketch, which is persisted with its registers.
+func (db *HyperBloom) HyperPrecision() uint8 {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return SketchPrecision(db.hyper)
 }
 
 // Refresh updates the last used timestamp of the HyperBloom instance to the current time.
 func (db *HyperBloom) Refresh() {
-	db.lastUsed = time.Now()
+	db.lastUsed.Store(time.Now().UnixNano())
 }
 
 // MORE LOGICS
 
-// CheckExists checks if a value exists in the Bloom filter of the HyperBloom instance.
+// CheckExists checks if a value exists in the Bloom filter of the HyperBloom instance, or in any of its layers
+// when it is scalable, and in the strict filter too when strict membership is enabled.
 func (db *HyperBloom) CheckExists(value string) bool {
-	return db.bloom.TestString(value)
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	if db.scalable != nil {
+		if !db.scalable.contains(db.bloom, value) {
+			return false
+		}
+	} else if !db.bloom.TestString(value) {
+		return false
+	}
+	return db.strict == nil || db.strict.TestString(strictValue(value))
+}
+
+// CheckExistsHyper reports whether inserting value would leave the cardinality estimate of the HyperLogLog sketch
+// unchanged, by inserting it into a copy of the sketch. Inserted values always leave it unchanged, so there are no
+// false negatives, but neither is this a membership test: a register only keeps the highest rank hashed to it, and
+// every value ranking lower in the same register is reported present too. The false positive rate is far above the
+// Bloom filter's and climbs towards 1 as the sketch fills up.
+func (db *HyperBloom) CheckExistsHyper(value string) bool {
+	sketch := db.Hyper()
+	before := sketch.Estimate()
+	sketch.Insert([]byte(value))
+	return sketch.Estimate() == before
 }
 
 // CheckDecayed checks if the HyperBloom instance has decayed based on the last used timestamp.
 func (db *HyperBloom) CheckDecayed(timemark time.Time) bool {
-	durationDiff := timemark.Sub(db.lastUsed)
+	durationDiff := timemark.Sub(db.LastUsed())
 	return durationDiff >= db.decay
 }
 
-// GetBloomFromDB fetches a HyperBloom instance from the database by its unique key.
-func GetBloomFromDB(key string) (*HyperBloom, error) {
+// GetBloomFromDB fetches a HyperBloom instance from the database by its unique key, giving up when ctx expires.
+// Blobs persisted in an older format are migrated to the current one, see MigrateBlobs.
+func GetBloomFromDB(ctx context.Context, key string) (*HyperBloom, error) {
 	var err error
 
 	// Query the database for the serialized data of the HyperBloom instance.
 	record := &struct {
-		Key       string // Unique key of the HyperBloom instance
-		Bloombyte []byte // Serialized data of the Bloom filter
-		Hyperbyte []byte // Serialized data of the HyperLogLog sketch
-		Decay     uint64 // Decay duration in seconds
+		Key     string       // Unique key of the HyperBloom instance
+		Version int          // Format version of the blobs
+		Blobs   Blobs        // Serialized Bloom filter, HyperLogLog sketch, and optional structures (NULL if disabled)
+		Decay   uint64       // Decay duration in seconds
+		Type    string       // Value type policy, empty if none was established
+		Created sql.NullTime // Creation time, NULL for keys created before creation times were recorded
+		Expires sql.NullTime // Expiry time, NULL for keys that never expire
 	}{}
 
-	err = postgres.DbClient.QueryRow(
-		`SELECT 
+	query := `SELECT 
 			hb.key,
 			decay_sec,
+			COALESCE(value_type, ''),
+			created_at,
+			expires_at,
+			format_version,
 			bloombyte, 
-			hyperbyte 
+			hyperbyte,
+			firstseen,
+			strictbyte,
+			recencybyte,
+			scalablebyte,
+			countingbyte,
+			topkbyte
 		FROM hyperblooms hb
 		JOIN hyperblooms_metadata hb_meta
 		ON hb.key = hb_meta.key
-		WHERE hb.key = $1`, key).Scan(
+		WHERE hb.key = $1`
+
+	// Read from the primary: the instance is flushed back, a stale copy from a replica would overwrite newer rows
+	err = postgres.DbClient.QueryRowContext(ctx, query, key).Scan(
 		&record.Key,
 		&record.Decay,
-		&record.Bloombyte,
-		&record.Hyperbyte,
+		&record.Type,
+		&record.Created,
+		&record.Expires,
+		&record.Version,
+		&record.Blobs.Bloom,
+		&record.Blobs.Hyper,
+		&record.Blobs.FirstSeen,
+		&record.Blobs.Strict,
+		&record.Blobs.Recency,
+		&record.Blobs.Scalable,
+		&record.Blobs.Counting,
+		&record.Blobs.TopK,
 	)
 
 	if err != nil {
@@ -154,34 +619,121 @@ func GetBloomFromDB(key string) (*HyperBloom, error) {
 	}
 
 	// Create a new HyperBloom instance and populate it with the deserialized data.
+	db, err := NewHyperBloomFromBlobs(key, time.Duration(record.Decay), record.Created.Time, record.Version, &record.Blobs)
+	if err != nil {
+		return nil, err
+	}
+	db.valueType = record.Type
+	db.expires = record.Expires.Time
+
+	return db, nil
+}
+
+// NewHyperBloomFromBlobs creates a HyperBloom instance created at created (zero if unknown) from blobs
+// persisted in format version. Blobs in an older format are migrated to the current one, see MigrateBlobs.
+func NewHyperBloomFromBlobs(key string, decay time.Duration, created time.Time, version int, blobs *Blobs) (*HyperBloom, error) {
 	db := &HyperBloom{
-		key:      key,
-		hyper:    &hyperloglog.Sketch{},
-		bloom:    &bloom.BloomFilter{},
-		decay:    time.Duration(record.Decay),
-		lastUsed: time.Now().UTC(),
+		key:     key,
+		decay:   decay,
+		created: created,
 	}
+	db.Refresh()
 
-	err = db.hyper.UnmarshalBinary(record.Hyperbyte)
-	if err != nil {
-		return nil, err
+	if err := db.DecodeBlobs(version, blobs); err != nil {
+		return nil, fmt.Errorf("can't decode %s: %w", key, err)
 	}
 
-	err = db.bloom.GobDecode(record.Bloombyte)
-	if err != nil {
-		return nil, err
+	return db, nil
+}
+
+// NewHyperBloomFromBinary creates a HyperBloom instance created at created (zero if unknown) from an encoding
+// of MarshalBinary, e.g. a backup of another instance. Encodings in an older format are migrated like NewHyperBloomFromBlobs.
+func NewHyperBloomFromBinary(key string, decay time.Duration, created time.Time, data []byte) (*HyperBloom, error) {
+	db := &HyperBloom{
+		key:     key,
+		decay:   decay,
+		created: created,
+	}
+	db.Refresh()
+
+	if err := db.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("can't decode %s: %w", key, err)
 	}
 
 	return db, nil
 }
 
 // JaccardSimBF calculates the Jaccard similarity between the Bloom filters of two HyperBloom instances.
+// Bit arrays of at least HB_SIM_PARALLEL_WORDS words are split into word ranges counted by several goroutines,
+// smaller ones are counted sequentially so they don't pay the goroutine overhead.
 func JaccardSimBF(db1, db2 *HyperBloom) float32 {
-	bs1 := db1.BitSet()
-	bs2 := db2.BitSet()
+	return JaccardSimBitSet(db1.BitSet(), db2.BitSet())
+}
+
+// JaccardSimBitSet calculates the Jaccard similarity between two Bloom filter bit arrays, see JaccardSimBF.
+func JaccardSimBitSet(bs1, bs2 *bitset.BitSet) float32 {
+	cfg := config.HyperBloomCfg()
+	if cfg.SimParallelWords <= 0 || len(bs1.Bytes()) < cfg.SimParallelWords {
+		andCardinality := bs1.IntersectionCardinality(bs2)
+		orCardinality := bs1.UnionCardinality(bs2)
 
-	andCardinality := bs1.IntersectionCardinality(bs2)
-	orCardinality := bs1.UnionCardinality(bs2)
+		return float32(andCardinality) / float32(orCardinality)
+	}
+
+	andCardinality, orCardinality := parallelPopCount(bs1.Bytes(), bs2.Bytes(), cfg.SimWorkers)
 
 	return float32(andCardinality) / float32(orCardinality)
 }
+
+// parallelPopCount counts the bits set in the intersection and the union of two bit arrays,
+// splitting the words into contiguous ranges counted by separate goroutines and summing the partial counts.
+func parallelPopCount(words1, words2 []uint64, workers int) (uint64, uint64) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Make words1 the longest array, the extra words only contribute to the union
+	if len(words2) > len(words1) {
+		words1, words2 = words2, words1
+	}
+
+	chunk := (len(words1) + workers - 1) / workers
+	andCounts := make([]uint64, workers)
+	orCounts := make([]uint64, workers)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		start := worker * chunk
+		end := min(start+chunk, len(words1))
+		if start >= end {
+			break
+		}
+
+		wg.Add(1)
+		go func(worker, start, end int) {
+			defer wg.Done()
+
+			// Accumulate locally, writing to the shared slices once avoids false sharing
+			var andCount, orCount int
+			for i := start; i < end; i++ {
+				if i < len(words2) {
+					andCount += bits.OnesCount64(words1[i] & words2[i])
+					orCount += bits.OnesCount64(words1[i] | words2[i])
+				} else {
+					orCount += bits.OnesCount64(words1[i])
+				}
+			}
+			andCounts[worker], orCounts[worker] = uint64(andCount), uint64(orCount)
+		}(worker, start, end)
+	}
+	wg.Wait()
+
+	// Merge the partial counts
+	var andCardinality, orCardinality uint64
+	for worker := 0; worker < workers; worker++ {
+		andCardinality += andCounts[worker]
+		orCardinality += orCounts[worker]
+	}
+
+	return andCardinality, orCardinality
+}
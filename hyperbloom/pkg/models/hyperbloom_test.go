@@ -0,0 +1,448 @@
+package models_test
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// benchmarkCardinalityReadHeavy polls the cardinality of a well-filled HyperBloom, writing once every 100 reads.
+func benchmarkCardinalityReadHeavy(b *testing.B, cache bool) {
+	saved := config.HyperBloomCfg().CacheCardinality
+	defer config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.CacheCardinality = saved })
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.CacheCardinality = cache })
+
+	db := models.NewHyperBloomFromParams(1000000, 0.001, "bench")
+	for i := 0; i < 100000; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%100 == 0 {
+			db.Hash(strconv.Itoa(i))
+		}
+		db.BloomCardinality()
+		db.HyperCardinality()
+	}
+}
+
+func BenchmarkCardinalityReadHeavyCached(b *testing.B) {
+	benchmarkCardinalityReadHeavy(b, true)
+}
+
+func BenchmarkCardinalityReadHeavyUncached(b *testing.B) {
+	benchmarkCardinalityReadHeavy(b, false)
+}
+
+func TestCardinalityCacheInvalidation(t *testing.T) {
+	saved := config.HyperBloomCfg().CacheCardinality
+	defer config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.CacheCardinality = saved })
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.CacheCardinality = true })
+
+	db := models.NewHyperBloomFromParams(1000, 0.01, "cache")
+	if db.HyperCardinality() != 0 || db.BloomCardinality() != 0 {
+		t.Fatal("expected an empty HyperBloom to have zero cardinality")
+	}
+
+	// Inserting must invalidate the cached estimates
+	db.Hash("a")
+	db.Hash("b")
+	if db.HyperCardinality() != 2 || db.BloomCardinality() != 2 {
+		t.Errorf("cardinality = (%d, %d), want (2, 2)", db.BloomCardinality(), db.HyperCardinality())
+	}
+}
+
+// benchmarkJaccardSimBF compares two large, half-filled Bloom filters (about 144 Mbit each).
+func benchmarkJaccardSimBF(b *testing.B, parallelWords int) {
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) {
+		cfg.SimParallelWords = parallelWords
+		cfg.SimWorkers = 0
+	})
+
+	db1 := models.NewHyperBloomFromParams(10000000, 0.001, "bench-1")
+	db2 := models.NewHyperBloomFromParams(10000000, 0.001, "bench-2")
+	for i := 0; i < 5000000; i++ {
+		db1.Hash(strconv.Itoa(i))
+		db2.Hash(strconv.Itoa(i + 2500000))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		models.JaccardSimBF(db1, db2)
+	}
+}
+
+func BenchmarkJaccardSimBFSequential(b *testing.B) {
+	benchmarkJaccardSimBF(b, 0)
+}
+
+func BenchmarkJaccardSimBFParallel(b *testing.B) {
+	benchmarkJaccardSimBF(b, 1)
+}
+
+// benchmarkSetBits counts the bits set in a large, half-filled Bloom filter (about 144 Mbit).
+func benchmarkSetBits(b *testing.B, parallelWords int) {
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) {
+		cfg.SimParallelWords = parallelWords
+		cfg.SimWorkers = 0
+	})
+
+	db := models.NewHyperBloomFromParams(10000000, 0.001, "bench-fill")
+	for i := 0; i < 5000000; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.SetBits()
+	}
+}
+
+func BenchmarkSetBitsSequential(b *testing.B) {
+	benchmarkSetBits(b, 0)
+}
+
+func BenchmarkSetBitsParallel(b *testing.B) {
+	benchmarkSetBits(b, 1)
+}
+
+func TestJaccardSimBFParallelMatchesSequential(t *testing.T) {
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+
+	db1 := models.NewHyperBloomFromParams(100000, 0.01, "sim-1")
+	db2 := models.NewHyperBloomFromParams(100000, 0.01, "sim-2")
+	for i := 0; i < 50000; i++ {
+		db1.Hash(strconv.Itoa(i))
+		db2.Hash(strconv.Itoa(i + 25000))
+	}
+
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.SimParallelWords = 0 })
+	sequential := models.JaccardSimBF(db1, db2)
+
+	// Uneven word ranges must not lose or double count any word
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.SimParallelWords = 1 })
+	for _, workers := range []int{1, 3, 7, 64} {
+		config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.SimWorkers = workers })
+		if parallel := models.JaccardSimBF(db1, db2); parallel != sequential {
+			t.Errorf("parallel similarity with %d workers = %f, want %f", workers, parallel, sequential)
+		}
+	}
+}
+
+func TestSetBitsParallelMatchesSequential(t *testing.T) {
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+
+	db := models.NewHyperBloomFromParams(100000, 0.01, "fill")
+	for i := 0; i < 50000; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.SimParallelWords = 0 })
+	sequential := db.SetBits()
+
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) {
+		cfg.SimParallelWords = 1
+		cfg.SimWorkers = 7
+	})
+	if parallel := db.SetBits(); parallel != sequential {
+		t.Errorf("parallel count %d, sequential count %d", parallel, sequential)
+	}
+}
+
+// benchmarkWriteHeavy creates a key and fills it with 20000 values per iteration, reporting garbage collections.
+func benchmarkWriteHeavy(b *testing.B, preallocate bool) {
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.Preallocate = preallocate })
+
+	values := make([]string, 20000)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		db := models.NewHyperBloomFromParams(1000000, 0.001, "bench-write")
+		for _, value := range values {
+			db.Hash(value)
+		}
+	}
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gc/op")
+}
+
+func BenchmarkWriteHeavyLazy(b *testing.B) {
+	benchmarkWriteHeavy(b, false)
+}
+
+func BenchmarkWriteHeavyPreallocated(b *testing.B) {
+	benchmarkWriteHeavy(b, true)
+}
+
+func TestHashBatch(t *testing.T) {
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) {
+		cfg.CacheCardinality = true
+		cfg.TrackValueLength = true
+	})
+
+	one := models.NewHyperBloomFromParams(10000, 0.01, "one")
+	batch := models.NewHyperBloomFromParams(10000, 0.01, "batch")
+	values := make([]string, 1000)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+		one.Hash(values[i])
+	}
+
+	// Read the cardinality first, the batch must invalidate the cached one
+	batch.HyperCardinality()
+	version := batch.Version()
+	batch.HashBatch(values)
+	if batch.Version() != version+1 {
+		t.Errorf("version moved by %d, want 1 for the whole batch", batch.Version()-version)
+	}
+
+	// A batch hashes the same values as one insert per value
+	if got, want := batch.HyperCardinality(), one.HyperCardinality(); got != want {
+		t.Errorf("hyperloglog cardinality = %d, want %d", got, want)
+	}
+	if got, want := batch.BloomCardinality(), one.BloomCardinality(); got != want {
+		t.Errorf("bloom cardinality = %d, want %d", got, want)
+	}
+	for _, value := range values {
+		if !batch.CheckExists(value) {
+			t.Fatalf("%s missing after the batch", value)
+		}
+	}
+	got, _ := batch.AverageValueLength()
+	if want, _ := one.AverageValueLength(); got != want {
+		t.Errorf("average value length = %g, want %g", got, want)
+	}
+}
+
+func TestMergeHyper(t *testing.T) {
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.CacheCardinality = true })
+
+	db := models.NewHyperBloomFromParams(10000, 0.01, "merge")
+	other := models.NewHyperBloomFromParams(10000, 0.01, "other")
+	for i := 0; i < 1000; i++ {
+		db.Hash(strconv.Itoa(i))
+		other.Hash(strconv.Itoa(i + 500))
+	}
+
+	// Read the cardinality first, the merge must invalidate the cached one
+	before := db.HyperCardinality()
+	version := db.Version()
+	if err := db.MergeHyper(other.Hyper()); err != nil {
+		t.Fatal(err)
+	}
+
+	union := db.Hyper().Clone()
+	if got := db.HyperCardinality(); got <= before || got != union.Estimate() {
+		t.Errorf("cardinality after merge = %d, was %d, sketch estimates %d", got, before, union.Estimate())
+	}
+	if db.Version() == version || !db.Dirty() {
+		t.Error("merge didn't mark the instance for flushing")
+	}
+
+	// The Bloom filter is left untouched
+	if db.CheckExists("1499") {
+		t.Error("merged value reported as a member")
+	}
+}
+
+func TestHyperPrecision(t *testing.T) {
+	db := models.NewHyperBloomFromParams(1000, 0.01, "precision")
+	if p := db.HyperPrecision(); p != models.DefaultHyperPrecision {
+		t.Errorf("default precision = %d, want %d", p, models.DefaultHyperPrecision)
+	}
+
+	for _, invalid := range []uint8{4, 10, 15, 18} {
+		if err := db.SetHyperPrecision(invalid); err == nil {
+			t.Errorf("precision %d: expected an error", invalid)
+		}
+	}
+	if err := db.SetHyperPrecision(models.HighHyperPrecision); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+
+	// The precision is persisted with the registers
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &models.HyperBloom{}
+	if err = decoded.DecodeBlobs(models.FormatVersion, blobs); err != nil {
+		t.Fatal(err)
+	}
+	if p := decoded.HyperPrecision(); p != models.HighHyperPrecision {
+		t.Errorf("decoded precision = %d, want %d", p, models.HighHyperPrecision)
+	}
+
+	// Sketches of different precisions don't merge
+	other := models.NewHyperBloomFromParams(1000, 0.01, "other")
+	if err = db.MergeHyper(other.Hyper()); err == nil {
+		t.Error("expected an error merging precisions 16 and 14")
+	}
+
+	// Dense sketches keep the precision, including across a reset
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.Preallocate = true })
+
+	dense := models.NewHyperBloomFromParams(1000, 0.01, "dense")
+	if err = dense.SetHyperPrecision(models.HighHyperPrecision); err != nil {
+		t.Fatal(err)
+	}
+	if err = dense.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if p := dense.HyperPrecision(); p != models.HighHyperPrecision {
+		t.Errorf("dense precision after reset = %d, want %d", p, models.HighHyperPrecision)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	db := models.NewHyperBloomFromParams(1000, 0.01, "expiry")
+	now := time.Now().UTC()
+	if db.Expired(now) {
+		t.Error("a key without an expiry time expired")
+	}
+
+	db.SetExpiry(now.Add(time.Minute))
+	if db.Expired(now) {
+		t.Error("expired before its expiry time")
+	}
+	if !db.Expired(now.Add(time.Minute)) {
+		t.Error("not expired at its expiry time")
+	}
+}
+
+func TestReset(t *testing.T) {
+	db := models.NewHyperBloomFromParams(1000, 0.01, "reset")
+	db.EnableStrict()
+	db.EnableScalable(1000, 0.01, 2, 0.8)
+	if err := db.SetHyperPrecision(models.HighHyperPrecision); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5000; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+	db.MarkFlushed(db.Version())
+	m, k := db.Bloom().Cap(), db.Bloom().K()
+
+	if err := db.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The contents are gone, the configuration is kept
+	if !db.Empty() || db.BloomCardinality() != 0 || db.HyperCardinality() != 0 {
+		t.Errorf("after reset: empty %t, cardinalities (%d, %d)", db.Empty(), db.BloomCardinality(), db.HyperCardinality())
+	}
+	if db.Bloom().Cap() != m || db.Bloom().K() != k || db.HyperPrecision() != models.HighHyperPrecision || !db.Strict() {
+		t.Errorf("configuration changed to m = %d, k = %d, p = %d, strict %t", db.Bloom().Cap(), db.Bloom().K(), db.HyperPrecision(), db.Strict())
+	}
+	if layers := db.Scalable().Layers(); layers != 1 {
+		t.Errorf("%d layers after reset, want 1", layers)
+	}
+	if db.CheckExists("0") {
+		t.Error("value still present after reset")
+	}
+
+	// The reset must be flushed, and the key takes values again
+	if !db.Dirty() {
+		t.Error("reset not marked for the flush")
+	}
+	db.Hash("again")
+	if !db.CheckExists("again") || db.HyperCardinality() != 1 {
+		t.Error("value inserted after reset missing")
+	}
+}
+
+// TestConcurrentAccess hammers a single HyperBloom with inserts, lookups, estimates and encodings from many
+// goroutines, as requests and the background flush do. Run with -race to catch unsynchronized accesses.
+func TestConcurrentAccess(t *testing.T) {
+	saved := config.HyperBloomCfg().CacheCardinality
+	defer config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.CacheCardinality = saved })
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.CacheCardinality = true })
+
+	db := models.NewHyperBloomFromParams(100000, 0.001, "concurrent")
+	db.EnableRecency(time.Hour)
+
+	const writers, perWriter = 8, 1000
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				value := strconv.Itoa(w*perWriter + i)
+				if i%10 == 0 {
+					db.HashBatch([]string{value})
+				} else {
+					db.Hash(value)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter/10; i++ {
+				db.CheckExists(strconv.Itoa(i))
+				db.CheckExistsHyper(strconv.Itoa(i))
+				db.BloomCardinality()
+				db.HyperCardinality()
+				db.SetBits()
+				db.Empty()
+				db.MemoryBytes()
+				db.Refresh()
+				db.CheckDecayed(time.Now())
+				if _, err := db.EncodeBlobs(); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := db.Fingerprint(); err != nil {
+					t.Error(err)
+					return
+				}
+				models.JaccardSimBF(db, db)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// No insert was lost, the filter and sketch match the ones built sequentially
+	sequential := models.NewHyperBloomFromParams(100000, 0.001, "sequential")
+	for i := 0; i < writers*perWriter; i++ {
+		sequential.Hash(strconv.Itoa(i))
+	}
+	if !db.BitSet().Equal(sequential.BitSet()) {
+		t.Error("bit array differs from the one built sequentially")
+	}
+	if hCard, want := db.HyperCardinality(), sequential.HyperCardinality(); hCard != want {
+		t.Errorf("HyperLogLog cardinality = %d, want %d as built sequentially", hCard, want)
+	}
+}
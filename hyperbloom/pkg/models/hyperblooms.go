@@ -3,29 +3,59 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"time"
 )
 
 // HyperBlooms manages a collection of HyperBloom instances.
 type HyperBlooms struct {
-	blooms map[string]*HyperBloom // Map to store HyperBloom instances by key
+	blooms     map[string]*HyperBloom // Map to store HyperBloom instances by key
+	fetches    map[string]*fetch      // Loads from the database in progress by key, shared by the requests for the key
+	generation uint64                 // Incremented when keys are removed or swapped, so that a load started before is discarded
+	mutex      sync.RWMutex           // Mutex guarding the map, the loads and the generation, never held while loading
+
+	load func(ctx context.Context, key string) (*HyperBloom, error) // Loads a missing key, GetBloomFromDB
+}
+
+// fetch is a load of a key from the database, which the requests for the key arriving meanwhile wait for.
+type fetch struct {
+	done  chan struct{} // Closed once the load is over
+	db    *HyperBloom   // Loaded instance, installed in the collection, if err is nil and retry false
+	err   error         // Error of the load
+	retry bool          // Whether the key was removed or swapped during the load, which must then start over
 }
 
 // NewHyperBlooms creates a new HyperBlooms instance initialized with an empty map.
 func NewHyperBlooms() *HyperBlooms {
 	return &HyperBlooms{
-		blooms: make(map[string]*HyperBloom),
+		blooms:  make(map[string]*HyperBloom),
+		fetches: make(map[string]*fetch),
+		load:    GetBloomFromDB,
 	}
 }
 
 // GETTERS
 
+// keys returns a snapshot of the keys currently in the 'blooms' map.
+func (dbs *HyperBlooms) keys() []string {
+	dbs.mutex.RLock()
+	defer dbs.mutex.RUnlock()
+
+	output := make([]string, 0, len(dbs.blooms))
+	for key := range dbs.blooms {
+		output = append(output, key)
+	}
+	return output
+}
+
 // GetInMemoryHyperBlooms retrieves all HyperBloom instances that are currently in memory.
 func (dbs *HyperBlooms) GetInMemoryHyperBlooms() []*HyperBloom {
 	output := []*HyperBloom{} // Initialize an empty slice to store output
 
 	// Iterate over each key in the 'blooms' map
-	for key := range dbs.blooms {
+	for _, key := range dbs.keys() {
 		// Attempt to fetch the HyperBloom for the current 'key'
 		db, ok := dbs.GetHyperBloom(key)
 
@@ -44,9 +74,9 @@ func (dbs *HyperBlooms) GetHyperBlooms() []*HyperBloom {
 	output := []*HyperBloom{} // Initialize an empty slice to store output
 
 	// Iterate over each key in the 'blooms' map
-	for key := range dbs.blooms {
+	for _, key := range dbs.keys() {
 		// Attempt to fetch or retrieve the HyperBloom for the current 'key'
-		db, err := dbs.GetOrFetchHyperBloom(key)
+		db, err := dbs.GetOrFetchHyperBloom(context.Background(), key)
 
 		// If fetching the HyperBloom was successful (no error)
 		if err == nil {
@@ -64,9 +94,9 @@ func (dbs *HyperBlooms) GetHyperBloomKeys() []string {
 	output := []string{}
 
 	// Iterate over each key in the 'blooms' map
-	for key := range dbs.blooms {
+	for _, key := range dbs.keys() {
 		// Attempt to fetch the hyperbloom for the current 'key'
-		db, err := dbs.GetOrFetchHyperBloom(key)
+		db, err := dbs.GetOrFetchHyperBloom(context.Background(), key)
 
 		// If fetching the hyperbloom was successful (no error)
 		if err == nil {
@@ -88,7 +118,7 @@ func (dbs *HyperBlooms) GetInMemoryHyperBloomKeys() []string {
 	output := []string{}
 
 	// Iterate over each key in the 'blooms' map
-	for key := range dbs.blooms {
+	for _, key := range dbs.keys() {
 		// Attempt to fetch the hyperbloom for the current 'key'
 		db, ok := dbs.GetHyperBloom(key)
 
@@ -106,24 +136,86 @@ func (dbs *HyperBlooms) GetInMemoryHyperBloomKeys() []string {
 }
 
 // GetOrFetchHyperBloom retrieves a HyperBloom instance from the collection or fetches it from the database.
-func (dbs *HyperBlooms) GetOrFetchHyperBloom(key string) (*HyperBloom, error) {
-	var db *HyperBloom
-	var ok bool
-	var err error
-
-	db, ok = dbs.GetHyperBloom(key)
-	if !ok {
-		db, err = GetBloomFromDB(key)
+// The database is queried without holding the collection, the requests for a key being loaded wait for that load
+// rather than querying again. A load racing with a removal or a swap is discarded and started over, so that a stale
+// row never replaces the instance in memory. It gives up when ctx expires, even while waiting for another request's load.
+func (dbs *HyperBlooms) GetOrFetchHyperBloom(ctx context.Context, key string) (*HyperBloom, error) {
+	for {
+		// Fast path, the HyperBloom is already in memory
+		if db, ok := dbs.GetHyperBloom(key); ok {
+			db.Refresh()
+			return db, nil
+		}
+
+		// Join the load in progress, or start one
+		dbs.mutex.Lock()
+		if db, ok := dbs.blooms[key]; ok {
+			dbs.mutex.Unlock()
+			db.Refresh()
+			return db, nil
+		}
+		f, loading := dbs.fetches[key]
+		if !loading {
+			f = &fetch{done: make(chan struct{})}
+			dbs.fetches[key] = f
+		}
+		generation := dbs.generation
+		dbs.mutex.Unlock()
+
+		if !loading {
+			dbs.fetch(ctx, key, f, generation)
+		} else {
+			select {
+			case <-f.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		// Start over if the load was discarded, or given up by another request whose context expired
+		if f.retry || (isContextError(f.err) && ctx.Err() == nil) {
+			continue
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+		f.db.Refresh()
+		return f.db, nil
 	}
-	if err != nil {
-		return nil, err
+}
+
+// fetch loads key into f and installs it in the collection, unless the key was added meanwhile or the collection
+// changed since generation, then wakes up the requests waiting for f.
+func (dbs *HyperBlooms) fetch(ctx context.Context, key string, f *fetch, generation uint64) {
+	db, err := dbs.load(ctx, key)
+
+	dbs.mutex.Lock()
+	delete(dbs.fetches, key)
+	if err == nil {
+		if existing, ok := dbs.blooms[key]; ok {
+			db = existing
+		} else if dbs.generation != generation {
+			f.retry = true
+		} else {
+			dbs.blooms[key] = db
+		}
 	}
-	dbs.Set(db, key)
-	return db, nil
+	f.db, f.err = db, err
+	dbs.mutex.Unlock()
+
+	close(f.done)
+}
+
+// isContextError reports whether err comes from a canceled or expired context.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
 
 // GetHyperBloom retrieves a HyperBloom instance by key from the collection.
 func (dbs *HyperBlooms) GetHyperBloom(key string) (*HyperBloom, bool) {
+	dbs.mutex.RLock()
+	defer dbs.mutex.RUnlock()
+
 	db, ok := dbs.blooms[key]
 	return db, ok
 }
@@ -132,18 +224,108 @@ func (dbs *HyperBlooms) GetHyperBloom(key string) (*HyperBloom, bool) {
 
 // Remove deletes a HyperBloom instance from the HyperBlooms collection by key.
 func (dbs *HyperBlooms) Remove(key string) {
+	dbs.mutex.Lock()
+	defer dbs.mutex.Unlock()
+
 	delete(dbs.blooms, key)
+	dbs.generation++
+}
+
+// Evict removes db from the collection to free memory, unless it changed since its last flush or was replaced
+// in the meantime. The check and the removal are atomic with the loads of the key, so that an instance reloaded
+// from the database can't miss a change. It returns whether db was removed.
+func (dbs *HyperBlooms) Evict(db *HyperBloom) bool {
+	dbs.mutex.Lock()
+	defer dbs.mutex.Unlock()
+
+	if dbs.blooms[db.key] != db || db.Dirty() {
+		return false
+	}
+
+	delete(dbs.blooms, db.key)
+	dbs.generation++
+	return true
 }
 
 // Set adds a HyperBloom instance to the HyperBlooms collection.
+// Deleted instances, e.g. still held by a request that raced with the deletion, are not added back.
 func (dbs *HyperBlooms) Set(db *HyperBloom, key string) {
 	// Refresh the last used timestamp of the HyperBloom instance
 	db.Refresh()
 
+	dbs.mutex.Lock()
+	defer dbs.mutex.Unlock()
+
+	if db.Deleted() {
+		return
+	}
+
 	// Add the HyperBloom instance to the 'blooms' map in HyperBlooms
 	dbs.blooms[key] = db
 }
 
+// Swap atomically exchanges the HyperBloom instances behind key1 and key2, loading them from the database if needed.
+// The keys are loaded before locking the collection, giving up when ctx expires. persist is called while the
+// collection is locked, so it can mirror the swap in the database before any reader observes it. If persist fails,
+// nothing is swapped.
+func (dbs *HyperBlooms) Swap(ctx context.Context, key1, key2 string, persist func() error) error {
+	for {
+		// Both HyperBlooms must exist
+		db1, err := dbs.GetOrFetchHyperBloom(ctx, key1)
+		if err != nil {
+			return err
+		}
+		db2, err := dbs.GetOrFetchHyperBloom(ctx, key2)
+		if err != nil {
+			return err
+		}
+
+		dbs.mutex.Lock()
+
+		// One of the instances was evicted or replaced since it was loaded, load it again
+		if dbs.blooms[key1] != db1 || dbs.blooms[key2] != db2 {
+			dbs.mutex.Unlock()
+			continue
+		}
+
+		if err = persist(); err != nil {
+			dbs.mutex.Unlock()
+			return err
+		}
+
+		// Rename the instances so that their next flush lands on their new key
+		db1.rename(key2)
+		db2.rename(key1)
+		dbs.blooms[key1], dbs.blooms[key2] = db2, db1
+		dbs.generation++
+		dbs.mutex.Unlock()
+
+		return nil
+	}
+}
+
+// Delete removes the HyperBloom instance behind key from the collection and marks it deleted, so that
+// neither a flush nor a request still holding it writes it back. persist is called while the collection is locked,
+// so it can delete the key from the database before the key can be loaded again. If persist fails, nothing
+// is deleted. It returns whether the key was in memory.
+func (dbs *HyperBlooms) Delete(key string, persist func() error) (bool, error) {
+	dbs.mutex.Lock()
+	defer dbs.mutex.Unlock()
+
+	if err := persist(); err != nil {
+		return false, err
+	}
+
+	db, ok := dbs.blooms[key]
+	if ok {
+		db.deleted.Store(true)
+		delete(dbs.blooms, key)
+	}
+	dbs.generation++
+
+	return ok, nil
+}
+
 // CheckDecayed checks if a HyperBloom instance has decayed based on the last used timestamp.
 func (dbs *HyperBlooms) CheckDecayed(key string, timemark time.Time) bool {
 	// Retrieve the HyperBloom instance for the given key
@@ -0,0 +1,190 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestEvict(t *testing.T) {
+	dbs := models.NewHyperBlooms()
+
+	// A clean instance is evicted
+	db := models.NewHyperBloomFromParams(1000, 0.01, "key")
+	dbs.Set(db, "key")
+	if !dbs.Evict(db) {
+		t.Fatal("clean instance not evicted")
+	}
+	if _, ok := dbs.GetHyperBloom("key"); ok {
+		t.Fatal("evicted instance still in memory")
+	}
+
+	// An instance with unflushed inserts stays until written
+	dbs.Set(db, "key")
+	db.Hash("value")
+	if dbs.Evict(db) {
+		t.Fatal("dirty instance evicted")
+	}
+	db.MarkFlushed(db.Version())
+	if !dbs.Evict(db) {
+		t.Fatal("flushed instance not evicted")
+	}
+
+	// An instance replaced in the meantime doesn't take its replacement along
+	replacement := models.NewHyperBloomFromParams(1000, 0.01, "key")
+	dbs.Set(replacement, "key")
+	if dbs.Evict(db) {
+		t.Fatal("replaced instance evicted")
+	}
+	if got, ok := dbs.GetHyperBloom("key"); !ok || got != replacement {
+		t.Fatal("replacement removed by the eviction of the old instance")
+	}
+}
+
+func TestSwapConcurrentKey(t *testing.T) {
+	dbs := models.NewHyperBlooms()
+	a := models.NewHyperBloomFromParams(1000, 0.01, "a")
+	b := models.NewHyperBloomFromParams(1000, 0.01, "b")
+	dbs.Set(a, "a")
+	dbs.Set(b, "b")
+
+	// Read the keys like a flush does while they are swapped, run with -race
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				for _, db := range dbs.GetInMemoryHyperBlooms() {
+					_ = db.Key()
+				}
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		if err := dbs.Swap(context.Background(), "a", "b", func() error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// An even number of swaps restores the assignment
+	if got, _ := dbs.GetHyperBloom("a"); got != a || a.Key() != "a" || b.Key() != "b" {
+		t.Errorf("after 100 swaps, a is %q and b is %q", a.Key(), b.Key())
+	}
+}
+
+func TestGetOrFetchHyperBloomConcurrent(t *testing.T) {
+	dbs := models.NewHyperBlooms()
+	cached := models.NewHyperBloomFromParams(1000, 0.01, "cached")
+	dbs.Set(cached, "cached")
+
+	// The load of "slow" blocks until released, and counts how many times the database is queried
+	release := make(chan struct{})
+	var loads atomic.Int32
+	dbs.SetLoader(func(ctx context.Context, key string) (*models.HyperBloom, error) {
+		loads.Add(1)
+		<-release
+		return models.NewHyperBloomFromParams(1000, 0.01, key), nil
+	})
+
+	const requests = 10
+	results := make(chan *models.HyperBloom, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			db, err := dbs.GetOrFetchHyperBloom(context.Background(), "slow")
+			if err != nil {
+				t.Error(err)
+			}
+			results <- db
+		}()
+	}
+	for loads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The collection stays usable while the load is in progress
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if db, err := dbs.GetOrFetchHyperBloom(context.Background(), "cached"); err != nil || db != cached {
+			t.Errorf("in-memory key during a load = %v, %v", db, err)
+		}
+		dbs.Set(models.NewHyperBloomFromParams(1000, 0.01, "other"), "other")
+		dbs.GetInMemoryHyperBlooms()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-memory access blocked by a load")
+	}
+
+	// A request giving up doesn't wait for the load
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := dbs.GetOrFetchHyperBloom(ctx, "slow"); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled request: %v, want context.Canceled", err)
+	}
+
+	// The requests share a single load and instance
+	close(release)
+	first := <-results
+	for i := 1; i < requests; i++ {
+		if db := <-results; db != first {
+			t.Fatal("concurrent requests got different instances")
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("database queried %d times, want once", n)
+	}
+	if db, ok := dbs.GetHyperBloom("slow"); !ok || db != first {
+		t.Error("loaded instance not kept in memory")
+	}
+}
+
+func TestGetOrFetchHyperBloomDeleted(t *testing.T) {
+	dbs := models.NewHyperBlooms()
+
+	// The first load returns a row deleted while it was in flight, the next one finds nothing
+	loading, release := make(chan struct{}), make(chan struct{})
+	var loads atomic.Int32
+	dbs.SetLoader(func(ctx context.Context, key string) (*models.HyperBloom, error) {
+		if loads.Add(1) > 1 {
+			return nil, sql.ErrNoRows
+		}
+		close(loading)
+		<-release
+		return models.NewHyperBloomFromParams(1000, 0.01, key), nil
+	})
+
+	errs := make(chan error)
+	go func() {
+		_, err := dbs.GetOrFetchHyperBloom(context.Background(), "deleted")
+		errs <- err
+	}()
+	<-loading
+	if _, err := dbs.Delete("deleted", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	// The stale row is discarded rather than resurrecting the key
+	if err := <-errs; !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("load racing with a deletion: %v, want sql.ErrNoRows", err)
+	}
+	if _, ok := dbs.GetHyperBloom("deleted"); ok {
+		t.Error("deleted key back in memory")
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+
+	"gopds/hyperbloom/internal/config"
+
+	"github.com/axiomhq/hyperloglog"
+	"github.com/bits-and-blooms/bitset"
+)
+
+// pageWords is the number of 64-bit words in a 4 KiB memory page.
+const pageWords = 4096 / 8
+
+// newSketch returns an empty HyperLogLog sketch. With HB_PREALLOCATE, the sketch is dense from the start:
+// its registers are allocated once instead of growing from the sparse representation and being converted
+// under load, at the cost of the better accuracy of the sparse representation at low cardinalities
+// and of persisting all the registers.
+func newSketch() *hyperloglog.Sketch {
+	if config.HyperBloomCfg().Preallocate {
+		return hyperloglog.NewNoSparse()
+	}
+	return hyperloglog.New()
+}
+
+// newSketchPrecision returns an empty HyperLogLog sketch of the given precision, dense with HB_PREALLOCATE like newSketch.
+func newSketchPrecision(precision uint8) (*hyperloglog.Sketch, error) {
+	switch precision {
+	case DefaultHyperPrecision:
+		return newSketch(), nil
+	case HighHyperPrecision:
+		if config.HyperBloomCfg().Preallocate {
+			return hyperloglog.New16NoSparse(), nil
+		}
+		return hyperloglog.New16(), nil
+	}
+	return nil, fmt.Errorf("precision %d not in %v", precision, HyperPrecisions)
+}
+
+// SketchPrecision returns the precision of a HyperLogLog sketch from its binary encoding, which starts with a version
+// byte followed by the precision. It returns 0 if the sketch can't be encoded.
+func SketchPrecision(sketch *hyperloglog.Sketch) uint8 {
+	encoded, err := sketch.MarshalBinary()
+	if err != nil || len(encoded) < 2 {
+		return 0
+	}
+	return encoded[1]
+}
+
+// preallocate makes the memory of a new, empty bit array resident with HB_PREALLOCATE, by writing to each of its
+// pages: a large allocation is only reserved, the OS backs its pages on their first write, which would otherwise
+// happen as values are inserted. The words hold no pointers, so the garbage collector never scans them.
+func preallocate(bs *bitset.BitSet) {
+	if !config.HyperBloomCfg().Preallocate {
+		return
+	}
+
+	words := bs.Bytes()
+	for i := 0; i < len(words); i += pageWords {
+		words[i] = 0
+	}
+}
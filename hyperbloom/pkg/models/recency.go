@@ -0,0 +1,193 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/axiomhq/hyperloglog"
+)
+
+// RecencyBuckets is the number of time buckets of a Recency, each spanning half a half-life: values last inserted
+// more than 8 half-lives ago, which would weigh less than 0.4%, are forgotten.
+const RecencyBuckets = 16
+
+// Recency estimates an exponentially time-decayed distinct count, next to the lifetime count of the HyperLogLog
+// sketch: every distinct value weighs 2^(-age/halfLife), age being the time since it was last inserted.
+// Values are inserted in the HyperLogLog sketch of the current time bucket. The estimate walks the buckets from
+// the newest, credits the growth of their running union to the bucket where the new values were last seen,
+// and weighs it by the age of that bucket. Ages are rounded to a bucket and each bucket adds the error of a sketch,
+// so the estimate is coarser than the lifetime count: it trades its exactness for recency-weighting.
+type Recency struct {
+	mutex    sync.Mutex            // Mutex guarding the buckets, inserts and estimates may run concurrently
+	halfLife time.Duration         // Time for the weight of a value to halve
+	epochs   []int64               // Index of the time bucket each slot holds, counted in bucket widths since the Unix epoch
+	sketches []*hyperloglog.Sketch // Values inserted during the time bucket of each slot, nil if the slot was never used
+}
+
+// NewRecency creates an empty Recency whose values weigh half as much every halfLife.
+func NewRecency(halfLife time.Duration) *Recency {
+	return &Recency{
+		halfLife: halfLife,
+		epochs:   make([]int64, RecencyBuckets),
+		sketches: make([]*hyperloglog.Sketch, RecencyBuckets),
+	}
+}
+
+// EnableRecency makes the HyperBloom instance keep a time-decayed distinct count of the values inserted from now on,
+// whose weight halves every halfLife, see Recency. It costs up to RecencyBuckets HyperLogLog sketches.
+func (db *HyperBloom) EnableRecency(halfLife time.Duration) {
+	db.recency = NewRecency(halfLife)
+}
+
+// Recency returns the time-decayed distinct count of the HyperBloom instance, or nil if it isn't kept.
+func (db *HyperBloom) Recency() *Recency {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return db.recency
+}
+
+// HalfLife returns the time for the weight of a value to halve.
+func (rc *Recency) HalfLife() time.Duration {
+	return rc.halfLife
+}
+
+// width returns the time spanned by a bucket.
+func (rc *Recency) width() time.Duration {
+	return max(rc.halfLife/2, 1)
+}
+
+// epoch returns the index of the time bucket holding t.
+func (rc *Recency) epoch(t time.Time) int64 {
+	return t.UnixNano() / int64(rc.width())
+}
+
+// Add records that value was inserted at now. The slot of the current bucket is reset if it held an older one.
+func (rc *Recency) Add(value []byte, now time.Time) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	epoch := rc.epoch(now)
+	slot := int(uint64(epoch) % RecencyBuckets)
+	if rc.sketches[slot] == nil || rc.epochs[slot] != epoch {
+		rc.sketches[slot] = newSketch()
+		rc.epochs[slot] = epoch
+	}
+	rc.sketches[slot].Insert(value)
+}
+
+// Estimate returns the decayed distinct count at now.
+func (rc *Recency) Estimate(now time.Time) float64 {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	// Only the buckets of the window ending now count, newest first
+	current := rc.epoch(now)
+	slots := []int{}
+	for slot, sketch := range rc.sketches {
+		if sketch != nil && rc.epochs[slot] <= current && rc.epochs[slot] > current-RecencyBuckets {
+			slots = append(slots, slot)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return rc.epochs[slots[i]] > rc.epochs[slots[j]] })
+
+	var union *hyperloglog.Sketch
+	var counted uint64
+	estimate := 0.0
+	for _, slot := range slots {
+		if union == nil {
+			union = rc.sketches[slot].Clone()
+		} else if err := union.Merge(rc.sketches[slot]); err != nil {
+			continue
+		}
+
+		// The values the union gained were last seen in this bucket, the current one weighs 1
+		total := union.Estimate()
+		if total <= counted {
+			continue
+		}
+		age := time.Duration(current-rc.epochs[slot]) * rc.width()
+		estimate += float64(total-counted) * math.Exp2(-age.Seconds()/rc.halfLife.Seconds())
+		counted = total
+	}
+
+	return estimate
+}
+
+// MemoryBytes returns the size of the sketches of the buckets.
+func (rc *Recency) MemoryBytes() uint64 {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	bytes := uint64(0)
+	for _, sketch := range rc.sketches {
+		if sketch != nil {
+			data, _ := sketch.MarshalBinary()
+			bytes += uint64(len(data))
+		}
+	}
+	return bytes
+}
+
+// MarshalBinary encodes the half-life in nanoseconds, then for every used slot its index, its bucket
+// and the length of its sketch followed by the sketch, as little-endian integers.
+func (rc *Recency) MarshalBinary() ([]byte, error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	data := binary.LittleEndian.AppendUint64(nil, uint64(rc.halfLife))
+	for slot, sketch := range rc.sketches {
+		if sketch == nil {
+			continue
+		}
+		encoded, err := sketch.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(slot))
+		data = binary.LittleEndian.AppendUint64(data, uint64(rc.epochs[slot]))
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(encoded)))
+		data = append(data, encoded...)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary.
+func (rc *Recency) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("invalid recency encoding")
+	}
+
+	halfLife := time.Duration(binary.LittleEndian.Uint64(data))
+	if halfLife <= 0 {
+		return errors.New("invalid recency encoding: non-positive half-life")
+	}
+	decoded := NewRecency(halfLife)
+
+	for data = data[8:]; len(data) > 0; {
+		if len(data) < 16 {
+			return errors.New("invalid recency encoding: truncated bucket")
+		}
+		slot := binary.LittleEndian.Uint32(data)
+		epoch := int64(binary.LittleEndian.Uint64(data[4:]))
+		length := binary.LittleEndian.Uint32(data[12:])
+		data = data[16:]
+		if slot >= RecencyBuckets || decoded.sketches[slot] != nil || uint64(length) > uint64(len(data)) {
+			return errors.New("invalid recency encoding: bucket out of range")
+		}
+
+		sketch := &hyperloglog.Sketch{}
+		if err := sketch.UnmarshalBinary(data[:length]); err != nil {
+			return err
+		}
+		decoded.sketches[slot], decoded.epochs[slot] = sketch, epoch
+		data = data[length:]
+	}
+
+	rc.halfLife, rc.epochs, rc.sketches = decoded.halfLife, decoded.epochs, decoded.sketches
+	return nil
+}
@@ -0,0 +1,86 @@
+package models_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestRecencyDecays(t *testing.T) {
+	rc := models.NewRecency(time.Hour)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 1000 distinct values, each inserted three times, then nothing
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 1000; i++ {
+			rc.Add([]byte(strconv.Itoa(i)), start)
+		}
+	}
+
+	// Right after the inserts every value weighs 1, duplicates don't count
+	if got := rc.Estimate(start); math.Abs(got-1000) > 50 {
+		t.Errorf("estimate right after the inserts = %.1f, want about 1000", got)
+	}
+
+	// The weights halve every half-life once insertions stop
+	previous := math.Inf(1)
+	for _, halfLives := range []float64{1, 2, 4} {
+		now := start.Add(time.Duration(halfLives * float64(time.Hour)))
+		got := rc.Estimate(now)
+		if want := 1000 / math.Exp2(halfLives); math.Abs(got-want) > want*0.1 {
+			t.Errorf("estimate after %g half-lives = %.1f, want about %.1f", halfLives, got, want)
+		}
+		if got >= previous {
+			t.Errorf("estimate after %g half-lives = %.1f, didn't decay from %.1f", halfLives, got, previous)
+		}
+		previous = got
+	}
+
+	// Past the window, the values are forgotten
+	if got := rc.Estimate(start.Add(9 * time.Hour)); got != 0 {
+		t.Errorf("estimate after 9 half-lives = %.1f, want 0", got)
+	}
+
+	// A value inserted again counts at its latest insert
+	later := start.Add(2 * time.Hour)
+	for i := 0; i < 500; i++ {
+		rc.Add([]byte(strconv.Itoa(i)), later)
+	}
+	if got, want := rc.Estimate(later), 500+500/4.0; math.Abs(got-want) > want*0.1 {
+		t.Errorf("estimate after re-inserting half of the values = %.1f, want about %.1f", got, want)
+	}
+}
+
+func TestRecencyEncoding(t *testing.T) {
+	db := models.NewHyperBloomFromParams(1000, 0.01, "recency")
+	db.EnableRecency(time.Minute)
+	for i := 0; i < 100; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := models.NewHyperBloomFromBlobs("recency", time.Hour, time.Time{}, models.FormatVersion, blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Recency() == nil || decoded.Recency().HalfLife() != time.Minute {
+		t.Fatal("decoded instance lost its time-decayed count")
+	}
+	now := time.Now()
+	if got, want := decoded.Recency().Estimate(now), db.Recency().Estimate(now); got != want {
+		t.Errorf("decoded estimate = %.1f, want %.1f", got, want)
+	}
+
+	// Keys without one stay without one
+	plain := models.NewHyperBloomFromParams(1000, 0.01, "plain")
+	if blobs, _ = plain.EncodeBlobs(); blobs.Recency != nil {
+		t.Error("recency blob written for a key without a time-decayed count")
+	}
+}
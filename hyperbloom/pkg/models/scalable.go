@@ -0,0 +1,224 @@
+package models
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// scalableFormat is the version byte of the binary encoding of a Scalable.
+const scalableFormat = 1
+
+// scalableHeaderBytes is the size of the header of an encoded Scalable: its format, growth factor, tightening ratio,
+// false positive rate and capacity of the primary filter, number of values in the newest layer and number of layers.
+const scalableHeaderBytes = 1 + 8 + 8 + 8 + 8 + 8 + 4
+
+// maxScalableLayers bounds the number of layers of a decoded Scalable, far above what any growth factor reaches.
+const maxScalableLayers = 64
+
+// Scalable chains Bloom filter layers behind the primary filter of a HyperBloom, so that it keeps its false positive
+// rate when more values are inserted than it was sized for. Values go to the newest layer, and a new layer is added
+// once it holds as many values as it was sized for: each layer is sized for growth times the values of the previous
+// one at tightening times its false positive rate, so the compound rate stays below fpr / (1 - tightening).
+// Membership is checked against every layer, and the cardinality is the sum of the cardinalities of the layers.
+type Scalable struct {
+	mutex      sync.RWMutex         // Mutex guarding the layers, lookups may run concurrently
+	growth     float64              // Capacity of a layer relative to the previous one, at least 1
+	tightening float64              // False positive rate of a layer relative to the previous one, between 0 and 1
+	capacity   uint                 // Number of values the primary filter was sized for
+	fpr        float64              // False positive rate the primary filter was sized for
+	layers     []*bloom.BloomFilter // Layers added after the primary filter, oldest first
+	active     uint                 // Number of values inserted in the newest layer, the primary filter if there is none
+}
+
+// EnableScalable makes the HyperBloom instance grow by adding Bloom filter layers when its filter is saturated,
+// see Scalable. capacity and falsePositive are the parameters its filter was sized for. Values inserted before
+// are unknown to the layers, so it must be enabled on an empty instance.
+func (db *HyperBloom) EnableScalable(capacity uint, falsePositive, growth, tightening float64) {
+	db.scalable = &Scalable{
+		growth:     growth,
+		tightening: tightening,
+		capacity:   max(capacity, 1),
+		fpr:        falsePositive,
+	}
+}
+
+// Scalable returns the layers of the HyperBloom instance, or nil if it doesn't grow.
+func (db *HyperBloom) Scalable() *Scalable {
+	return db.scalable
+}
+
+// Layers returns the number of Bloom filter layers, the primary filter included.
+func (s *Scalable) Layers() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.layers) + 1
+}
+
+// Growth returns the capacity of a layer relative to the previous one.
+func (s *Scalable) Growth() float64 {
+	return s.growth
+}
+
+// Tightening returns the false positive rate of a layer relative to the previous one.
+func (s *Scalable) Tightening() float64 {
+	return s.tightening
+}
+
+// layerSizing returns the capacity and false positive rate of layer i, 0 being the primary filter.
+func (s *Scalable) layerSizing(i int) (uint, float64) {
+	return uint(float64(s.capacity) * math.Pow(s.growth, float64(i))), s.fpr * math.Pow(s.tightening, float64(i))
+}
+
+// add inserts value in the newest layer, adding a layer first if it is full. Values already reported present
+// aren't inserted again, so that the layers fill up with distinct values only.
+func (s *Scalable) add(primary *bloom.BloomFilter, value string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.containsLocked(primary, value) {
+		return
+	}
+
+	if capacity, _ := s.layerSizing(len(s.layers)); s.active >= capacity {
+		bf := bloom.NewWithEstimates(s.layerSizing(len(s.layers) + 1))
+		preallocate(bf.BitSet())
+		s.layers = append(s.layers, bf)
+		s.active = 0
+	}
+
+	newest := primary
+	if len(s.layers) > 0 {
+		newest = s.layers[len(s.layers)-1]
+	}
+	newest.AddString(value)
+	s.active++
+}
+
+// reset drops the layers added after the primary filter, which is cleared by the caller.
+func (s *Scalable) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.layers = nil
+	s.active = 0
+}
+
+// contains reports whether value is probably in one of the layers.
+func (s *Scalable) contains(primary *bloom.BloomFilter, value string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.containsLocked(primary, value)
+}
+
+// containsLocked reports whether value is probably in one of the layers. The caller must hold the mutex.
+func (s *Scalable) containsLocked(primary *bloom.BloomFilter, value string) bool {
+	if primary.TestString(value) {
+		return true
+	}
+	for _, bf := range s.layers {
+		if bf.TestString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// cardinality returns the sum of the estimated cardinalities of the layers.
+func (s *Scalable) cardinality(primary *bloom.BloomFilter) uint32 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	card := uint64(primary.ApproximatedSize())
+	for _, bf := range s.layers {
+		card += uint64(bf.ApproximatedSize())
+	}
+	return uint32(min(card, math.MaxUint32))
+}
+
+// memoryBytes returns the size of the bit arrays of the layers added after the primary filter.
+func (s *Scalable) memoryBytes() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	bytes := uint64(0)
+	for _, bf := range s.layers {
+		bytes += uint64(len(bf.BitSet().Bytes())) * 8
+	}
+	return bytes
+}
+
+// MarshalBinary encodes the layers as a header of their format, growth factor, tightening ratio and false positive
+// rate (as IEEE 754 bits), capacity and number of values in the newest layer (8 bytes each) and number of layers
+// (4 bytes), followed by every layer after the primary filter, encoded by GobEncode and prefixed by its length
+// (4 bytes), big-endian. The primary filter is encoded with the HyperBloom.
+func (s *Scalable) MarshalBinary() ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	encoded := make([]byte, 0, scalableHeaderBytes)
+	encoded = append(encoded, scalableFormat)
+	encoded = binary.BigEndian.AppendUint64(encoded, math.Float64bits(s.growth))
+	encoded = binary.BigEndian.AppendUint64(encoded, math.Float64bits(s.tightening))
+	encoded = binary.BigEndian.AppendUint64(encoded, math.Float64bits(s.fpr))
+	encoded = binary.BigEndian.AppendUint64(encoded, uint64(s.capacity))
+	encoded = binary.BigEndian.AppendUint64(encoded, uint64(s.active))
+	encoded = binary.BigEndian.AppendUint32(encoded, uint32(len(s.layers)))
+	for _, bf := range s.layers {
+		layer, err := bf.GobEncode()
+		if err != nil {
+			return nil, err
+		}
+		encoded = binary.BigEndian.AppendUint32(encoded, uint32(len(layer)))
+		encoded = append(encoded, layer...)
+	}
+	return encoded, nil
+}
+
+// UnmarshalBinary decodes layers encoded by MarshalBinary, checking their parameters and every layer
+// like the primary filter. It returns an error wrapping ErrCorruptedBlob if the encoding is malformed.
+func (s *Scalable) UnmarshalBinary(data []byte) error {
+	if len(data) < scalableHeaderBytes || data[0] != scalableFormat {
+		return fmt.Errorf("%w: truncated header or unknown format", ErrCorruptedBlob)
+	}
+
+	growth := math.Float64frombits(binary.BigEndian.Uint64(data[1:]))
+	tightening := math.Float64frombits(binary.BigEndian.Uint64(data[9:]))
+	fpr := math.Float64frombits(binary.BigEndian.Uint64(data[17:]))
+	capacity := binary.BigEndian.Uint64(data[25:])
+	active := binary.BigEndian.Uint64(data[33:])
+	count := binary.BigEndian.Uint32(data[41:])
+	if !(growth >= 1) || !(tightening > 0 && tightening < 1) || !(fpr > 0 && fpr < 1) || capacity == 0 || count > maxScalableLayers {
+		return fmt.Errorf("%w: invalid parameters", ErrCorruptedBlob)
+	}
+
+	layers := make([]*bloom.BloomFilter, 0, count)
+	rest := data[scalableHeaderBytes:]
+	for range count {
+		if len(rest) < 4 {
+			return fmt.Errorf("%w: truncated layer length", ErrCorruptedBlob)
+		}
+		length := binary.BigEndian.Uint32(rest)
+		rest = rest[4:]
+		if uint64(length) > uint64(len(rest)) {
+			return fmt.Errorf("%w: truncated layer", ErrCorruptedBlob)
+		}
+		bf, err := decodeBloom(rest[:length])
+		if err != nil {
+			return err
+		}
+		layers = append(layers, bf)
+		rest = rest[length:]
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("%w: trailing bytes", ErrCorruptedBlob)
+	}
+
+	s.growth, s.tightening, s.fpr, s.capacity, s.active, s.layers = growth, tightening, fpr, uint(capacity), uint(active), layers
+	return nil
+}
@@ -0,0 +1,92 @@
+package models_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestScalableGrows(t *testing.T) {
+	db := models.NewHyperBloomFromParams(1000, 0.01, "scalable")
+	db.EnableScalable(1000, 0.01, 2, 0.8)
+
+	// Ten times the capacity: 1000 + 2000 + 4000 + 8000 values fit in 4 layers
+	for i := 0; i < 10000; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+	if got := db.Scalable().Layers(); got != 4 {
+		t.Errorf("%d layers, want 4", got)
+	}
+
+	// No false negatives across the layers
+	for i := 0; i < 10000; i++ {
+		if !db.CheckExists(strconv.Itoa(i)) {
+			t.Fatalf("%d missing", i)
+		}
+	}
+
+	// The compound false positive rate stays below fpr / (1 - tightening)
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if db.CheckExists("absent-" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.05 {
+		t.Errorf("false positive rate = %g, want at most 0.05", rate)
+	}
+
+	// The cardinality adds up the layers
+	if got := float64(db.BloomCardinality()); math.Abs(got-10000) > 1000 {
+		t.Errorf("cardinality = %.0f, want about 10000", got)
+	}
+}
+
+func TestScalableEncoding(t *testing.T) {
+	db := models.NewHyperBloomFromParams(100, 0.01, "scalable")
+	db.EnableScalable(100, 0.01, 3, 0.5)
+	for i := 0; i < 1000; i++ {
+		db.Hash(strconv.Itoa(i))
+	}
+
+	encoded, err := db.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &models.HyperBloom{}
+	if err = decoded.UnmarshalBinary(encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Scalable() == nil || decoded.Scalable().Layers() != db.Scalable().Layers() {
+		t.Fatal("decoded instance lost its layers")
+	}
+	if decoded.Scalable().Growth() != 3 || decoded.Scalable().Tightening() != 0.5 {
+		t.Errorf("growth and tightening = (%g, %g), want (3, 0.5)", decoded.Scalable().Growth(), decoded.Scalable().Tightening())
+	}
+	if decoded.BloomCardinality() != db.BloomCardinality() {
+		t.Errorf("cardinality = %d, want %d", decoded.BloomCardinality(), db.BloomCardinality())
+	}
+	for i := 0; i < 1000; i++ {
+		if !decoded.CheckExists(strconv.Itoa(i)) {
+			t.Fatalf("%d missing after the round trip", i)
+		}
+	}
+
+	// Growth continues in the newest layer after decoding
+	for i := 1000; i < 2000; i++ {
+		decoded.Hash(strconv.Itoa(i))
+		db.Hash(strconv.Itoa(i))
+	}
+	if decoded.Scalable().Layers() != db.Scalable().Layers() {
+		t.Errorf("%d layers after decoding and inserting, want %d", decoded.Scalable().Layers(), db.Scalable().Layers())
+	}
+
+	// Keys without layers stay without
+	plain := models.NewHyperBloomFromParams(1000, 0.01, "plain")
+	if blobs, _ := plain.EncodeBlobs(); blobs.Scalable != nil {
+		t.Error("scalable blob written for a key without layers")
+	}
+}
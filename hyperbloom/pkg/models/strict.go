@@ -0,0 +1,30 @@
+package models
+
+import (
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// strictSalt is appended to the values hashed in the strict filter, so that its hash functions
+// are independent from the ones of the primary filter.
+const strictSalt = "\x00strict"
+
+// EnableStrict switches the HyperBloom instance to strict membership: values are also hashed into a second
+// filter of the same size with independent hash functions, and only reported present if both filters agree.
+// A false positive then needs both filters to be wrong at once, so the effective false positive rate is
+// roughly the square of the configured one (e.g. 0.0081 becomes about 0.00007), at twice the bit array memory.
+// Values inserted before strict membership is enabled are missing from the second filter, so it must be
+// enabled on an empty instance.
+func (db *HyperBloom) EnableStrict() {
+	db.strict = bloom.New(db.bloom.Cap(), db.bloom.K())
+	preallocate(db.strict.BitSet())
+}
+
+// Strict reports whether the HyperBloom instance checks membership against two filters.
+func (db *HyperBloom) Strict() bool {
+	return db.strict != nil
+}
+
+// strictValue returns the representation of value hashed in the strict filter.
+func strictValue(value string) string {
+	return value + strictSalt
+}
@@ -0,0 +1,67 @@
+package models_test
+
+import (
+	"strconv"
+	"testing"
+
+	"gopds/hyperbloom/pkg/models"
+)
+
+func TestStrictMembership(t *testing.T) {
+	plain := models.NewHyperBloomFromParams(1000, 0.1, "plain")
+	strict := models.NewHyperBloomFromParams(1000, 0.1, "strict")
+	strict.EnableStrict()
+
+	for i := 0; i < 1000; i++ {
+		plain.Hash(strconv.Itoa(i))
+		strict.Hash(strconv.Itoa(i))
+	}
+
+	// Never a false negative
+	for i := 0; i < 1000; i++ {
+		if !strict.CheckExists(strconv.Itoa(i)) {
+			t.Fatalf("value %d reported absent in strict mode", i)
+		}
+	}
+
+	// The false positive rate drops from about 0.1 to about 0.01
+	var plainPositives, strictPositives int
+	for i := 1000; i < 21000; i++ {
+		if plain.CheckExists(strconv.Itoa(i)) {
+			plainPositives++
+		}
+		if strict.CheckExists(strconv.Itoa(i)) {
+			strictPositives++
+		}
+	}
+	if rate := float64(strictPositives) / 20000; rate > 0.03 {
+		t.Errorf("strict false positive rate = %.4f, want about 0.01", rate)
+	}
+	if strictPositives >= plainPositives {
+		t.Errorf("strict mode didn't reduce false positives (%d vs %d)", strictPositives, plainPositives)
+	}
+
+	// Twice the bit array memory
+	if extra := strict.MemoryBytes() - plain.MemoryBytes(); extra != uint64(len(plain.BitSet().Bytes()))*8 {
+		t.Errorf("strict mode costs %d extra bytes, want the size of the bit array", extra)
+	}
+}
+
+func TestStrictMembershipEncoding(t *testing.T) {
+	db := models.NewHyperBloomFromParams(1000, 0.1, "strict")
+	db.EnableStrict()
+	db.Hash("value")
+
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &models.HyperBloom{}
+	if err = decoded.DecodeBlobs(models.FormatVersion, blobs); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Strict() || !decoded.CheckExists("value") {
+		t.Error("strict membership lost in the encoding")
+	}
+}
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TopK tracks the most frequent values inserted in a HyperBloom with the Space-Saving algorithm (Metwally et al.):
+// up to capacity values are counted, and a value that isn't tracked once they are all taken replaces the one with
+// the lowest count, inheriting that count plus one. Counts are overestimated by at most the error recorded with
+// each value, the count of the value it replaced, and every value inserted more than n/capacity times out of n
+// inserts is guaranteed to be tracked. The counters are kept in a min-heap on their count, so an insert costs
+// O(log capacity). Each counter costs the length of its value plus about 40 bytes.
+//
+// The counters are guarded by the mutex of the HyperBloom instance.
+type TopK struct {
+	capacity int            // Largest number of values tracked
+	heap     []TopKItem     // Tracked values, a min-heap on their count
+	index    map[string]int // Position of every tracked value in heap
+}
+
+// TopKItem is a value tracked by TopK with its estimated count.
+type TopKItem struct {
+	Value string // Value inserted
+	Count uint64 // Estimated number of inserts, never below the actual number
+	Error uint64 // Largest overestimation of Count, the actual number of inserts is at least Count - Error
+}
+
+// NewTopK creates a TopK tracking up to capacity values.
+func NewTopK(capacity uint) *TopK {
+	capacity = max(capacity, 1)
+	return &TopK{capacity: int(capacity), index: make(map[string]int)}
+}
+
+// Capacity returns the largest number of values tracked.
+func (t *TopK) Capacity() int {
+	return t.capacity
+}
+
+// add counts an insert of value.
+func (t *TopK) add(value string) {
+	// Tracked values move down the heap as their count grows
+	if i, ok := t.index[value]; ok {
+		t.heap[i].Count++
+		t.down(i)
+		return
+	}
+
+	// Track new values while there's room
+	if len(t.heap) < t.capacity {
+		t.heap = append(t.heap, TopKItem{Value: value, Count: 1})
+		t.index[value] = len(t.heap) - 1
+		t.up(len(t.heap) - 1)
+		return
+	}
+
+	// Replace the least frequent value, its count becomes the error of the new one
+	evicted := t.heap[0]
+	delete(t.index, evicted.Value)
+	t.heap[0] = TopKItem{Value: value, Count: evicted.Count + 1, Error: evicted.Count}
+	t.index[value] = 0
+	t.down(0)
+}
+
+// up moves the counter at i up the heap until its parent has a lower count.
+func (t *TopK) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if t.heap[parent].Count <= t.heap[i].Count {
+			return
+		}
+		t.swap(i, parent)
+		i = parent
+	}
+}
+
+// down moves the counter at i down the heap until its children have higher counts.
+func (t *TopK) down(i int) {
+	for {
+		smallest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(t.heap) && t.heap[child].Count < t.heap[smallest].Count {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			return
+		}
+		t.swap(i, smallest)
+		i = smallest
+	}
+}
+
+// swap exchanges the counters at i and j, keeping the index up to date.
+func (t *TopK) swap(i, j int) {
+	t.heap[i], t.heap[j] = t.heap[j], t.heap[i]
+	t.index[t.heap[i].Value] = i
+	t.index[t.heap[j].Value] = j
+}
+
+// top returns the k values with the highest counts, highest first, ties ordered by value.
+func (t *TopK) top(k int) []TopKItem {
+	items := slices.Clone(t.heap)
+	slices.SortFunc(items, func(a, b TopKItem) int {
+		if a.Count != b.Count {
+			if a.Count > b.Count {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Value, b.Value)
+	})
+	return items[:min(k, len(items))]
+}
+
+// reset forgets every tracked value.
+func (t *TopK) reset() {
+	t.heap = nil
+	clear(t.index)
+}
+
+// memoryBytes returns the approximate memory used by the counters and their values.
+func (t *TopK) memoryBytes() uint64 {
+	bytes := uint64(0)
+	for _, item := range t.heap {
+		bytes += uint64(len(item.Value)) + 40
+	}
+	return bytes
+}
+
+// TopK returns the k most frequent values inserted in the HyperBloom instance with their estimated counts, see TopK,
+// and false if they aren't tracked.
+func (db *HyperBloom) TopK(k int) ([]TopKItem, bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	if db.topK == nil {
+		return nil, false
+	}
+	return db.topK.top(k), true
+}
+
+// TopKCapacity returns the largest number of values whose frequency the HyperBloom instance tracks,
+// 0 if it doesn't track them.
+func (db *HyperBloom) TopKCapacity() int {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	if db.topK == nil {
+		return 0
+	}
+	return db.topK.capacity
+}
+
+// MarshalBinary encodes the capacity and number of tracked values as big-endian 32-bit integers, then for every
+// value its count and error as big-endian 64-bit integers, its length as a big-endian 32-bit integer and its bytes.
+func (t *TopK) MarshalBinary() ([]byte, error) {
+	encoded := binary.BigEndian.AppendUint32(nil, uint32(t.capacity))
+	encoded = binary.BigEndian.AppendUint32(encoded, uint32(len(t.heap)))
+	for _, item := range t.heap {
+		encoded = binary.BigEndian.AppendUint64(encoded, item.Count)
+		encoded = binary.BigEndian.AppendUint64(encoded, item.Error)
+		encoded = binary.BigEndian.AppendUint32(encoded, uint32(len(item.Value)))
+		encoded = append(encoded, item.Value...)
+	}
+	return encoded, nil
+}
+
+// UnmarshalBinary decodes counters encoded by MarshalBinary, checking every length against the data left.
+func (t *TopK) UnmarshalBinary(data []byte) error {
+	corrupted := func(reason string) error {
+		return fmt.Errorf("%w: %s", ErrCorruptedBlob, reason)
+	}
+
+	if len(data) < 8 {
+		return corrupted("truncated header")
+	}
+	capacity, n := binary.BigEndian.Uint32(data), binary.BigEndian.Uint32(data[4:])
+	if capacity == 0 || n > capacity {
+		return corrupted(fmt.Sprintf("%d values tracked out of a capacity of %d", n, capacity))
+	}
+
+	// Every value takes 20 bytes at least, bound the allocation by the data
+	rest := data[8:]
+	if uint64(n)*20 > uint64(len(rest)) {
+		return corrupted("truncated values")
+	}
+	heap := make([]TopKItem, n)
+	index := make(map[string]int, n)
+	for i := range heap {
+		if len(rest) < 20 {
+			return corrupted("truncated value")
+		}
+		item := TopKItem{Count: binary.BigEndian.Uint64(rest), Error: binary.BigEndian.Uint64(rest[8:])}
+		length := binary.BigEndian.Uint32(rest[16:])
+		rest = rest[20:]
+		if uint64(length) > uint64(len(rest)) {
+			return corrupted("truncated value")
+		}
+		item.Value, rest = string(rest[:length]), rest[length:]
+
+		if item.Count == 0 || item.Error >= item.Count {
+			return corrupted(fmt.Sprintf("count %d with error %d", item.Count, item.Error))
+		}
+		if _, ok := index[item.Value]; ok {
+			return corrupted(fmt.Sprintf("duplicate value %q", item.Value))
+		}
+		heap[i], index[item.Value] = item, i
+	}
+	if len(rest) != 0 {
+		return corrupted("trailing bytes")
+	}
+
+	t.capacity, t.heap, t.index = int(capacity), heap, index
+
+	// Restore the heap order rather than trusting the encoding
+	for i := len(t.heap)/2 - 1; i >= 0; i-- {
+		t.down(i)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package models_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/pkg/models"
+)
+
+// topKHyperBloom creates a HyperBloom tracking the capacity most frequent values.
+func topKHyperBloom(t *testing.T, capacity uint) *models.HyperBloom {
+	t.Helper()
+
+	saved := *config.HyperBloomCfg()
+	defer config.SetHyperBloomCfg(saved)
+	config.UpdateHyperBloomCfg(func(cfg *config.HyperBloomConfig) { cfg.TopKCapacity = capacity })
+
+	return models.NewHyperBloomFromParams(10000, 0.01, "topk")
+}
+
+func TestTopKHeavyHitters(t *testing.T) {
+	db := topKHyperBloom(t, 20)
+
+	// 5 heavy hitters inserted 1000, 900... 600 times among 5000 values inserted once
+	for i := 0; i < 5000; i++ {
+		db.Hash("rare-" + strconv.Itoa(i))
+		for h := 0; h < 5; h++ {
+			if i < 1000-100*h {
+				db.Hash("heavy-" + strconv.Itoa(h))
+			}
+		}
+	}
+
+	items, ok := db.TopK(5)
+	if !ok {
+		t.Fatal("most frequent values not tracked")
+	}
+	if len(items) != 5 {
+		t.Fatalf("%d values, want 5", len(items))
+	}
+	for h, item := range items {
+		want := uint64(1000 - 100*h)
+		if item.Value != "heavy-"+strconv.Itoa(h) {
+			t.Errorf("rank %d = %q, want heavy-%d", h, item.Value, h)
+		}
+
+		// Counts are never underestimated, and overestimated by at most their error
+		if item.Count < want || item.Count-item.Error > want {
+			t.Errorf("%s counted %d ± %d, inserted %d times", item.Value, item.Count, item.Error, want)
+		}
+	}
+
+	// k is capped at the capacity
+	if items, _ = db.TopK(100); len(items) != 20 {
+		t.Errorf("%d values for k = 100, want the 20 tracked", len(items))
+	}
+	if db.TopKCapacity() != 20 {
+		t.Errorf("capacity = %d, want 20", db.TopKCapacity())
+	}
+
+	// Reset forgets the values
+	db.Reset()
+	if items, _ = db.TopK(5); len(items) != 0 {
+		t.Errorf("%d values after reset", len(items))
+	}
+
+	untracked := models.NewHyperBloomFromParams(1000, 0.01, "untracked")
+	if _, ok = untracked.TopK(5); ok || untracked.TopKCapacity() != 0 {
+		t.Error("most frequent values tracked without HB_TOPK_CAPACITY")
+	}
+}
+
+func TestTopKEvictionError(t *testing.T) {
+	db := topKHyperBloom(t, 2)
+	for _, value := range []string{"a", "a", "a", "b", "b", "c"} {
+		db.Hash(value)
+	}
+
+	// c replaced b, inheriting its count of 2 as error
+	items, _ := db.TopK(2)
+	want := []models.TopKItem{{Value: "a", Count: 3}, {Value: "c", Count: 3, Error: 2}}
+	if len(items) != 2 || items[0] != want[0] || items[1] != want[1] {
+		t.Errorf("TopK(2) = %+v, want %+v", items, want)
+	}
+}
+
+func TestTopKEncoding(t *testing.T) {
+	db := topKHyperBloom(t, 10)
+	for i := 0; i < 100; i++ {
+		db.Hash(strconv.Itoa(i % 15))
+	}
+	want, _ := db.TopK(10)
+
+	// The values survive the persisted blobs
+	blobs, err := db.EncodeBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := models.NewHyperBloomFromBlobs("topk", 0, db.CreatedAt(), models.FormatVersion, blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := decoded.TopK(10)
+	if !ok || len(got) != len(want) {
+		t.Fatalf("decoded %d values, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("decoded value %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// Inserts keep counting after decoding
+	decoded.Hash(want[0].Value)
+	if got, _ = decoded.TopK(1); got[0].Value != want[0].Value || got[0].Count != want[0].Count+1 {
+		t.Errorf("after an insert of %s, top value = %+v", want[0].Value, got[0])
+	}
+
+	encoded, _ := (&models.TopK{}).MarshalBinary()
+	cases := map[string][]byte{
+		"empty":                nil,
+		"truncated header":     encoded[:4],
+		"zero capacity":        {0, 0, 0, 0, 0, 0, 0, 0},
+		"more than capacity":   {0, 0, 0, 1, 0, 0, 0, 2},
+		"truncated value":      {0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5, 'a'},
+		"error above count":    {0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1, 'a'},
+		"trailing bytes":       {0, 0, 0, 1, 0, 0, 0, 0, 0},
+		"claims huge capacity": {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	for name, data := range cases {
+		if err := (&models.TopK{}).UnmarshalBinary(data); !errors.Is(err, models.ErrCorruptedBlob) {
+			t.Errorf("%s: expected ErrCorruptedBlob, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+// ValueType returns the declared type of the values inserted in the HyperBloom instance,
+// or an empty string if no typed value was inserted yet.
+func (db *HyperBloom) ValueType() string {
+	db.typeMutex.Lock()
+	defer db.typeMutex.Unlock()
+	return db.valueType
+}
+
+// ClaimValueType establishes valueType as the type of the values inserted in the HyperBloom instance
+// if none is established yet. It returns the established type and whether it was claimed by this call.
+func (db *HyperBloom) ClaimValueType(valueType string) (string, bool) {
+	db.typeMutex.Lock()
+	defer db.typeMutex.Unlock()
+
+	if db.valueType != "" {
+		return db.valueType, false
+	}
+	db.valueType = valueType
+	return valueType, true
+}
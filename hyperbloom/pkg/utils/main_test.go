@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/database/postgres"
+	"gopds/hyperbloom/internal/service"
+)
+
+// databaseReady is set once TestMain has connected to the database and initialized the service.
+var databaseReady bool
+
+// TestMain connects to the database described by the DB_* variables and initializes the service, as main does.
+// Unless DB_HOST or DB_DSN is set, it only loads the configuration, and the tests needing the database are skipped.
+func TestMain(m *testing.M) {
+	if err := config.LoadConfigPostgres(); err != nil {
+		log.Fatal(err)
+	}
+	if os.Getenv("DB_HOST") == "" && os.Getenv("DB_DSN") == "" {
+		config.LoadConfigHyperBloom()
+		os.Exit(m.Run())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.PostgresCfg.ConnectTimeout)
+	err := postgres.Connect(ctx, config.PostgresCfg)
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+	service.Init()
+	databaseReady = true
+
+	os.Exit(m.Run())
+}
+
+// requireDatabase skips the test unless TestMain connected to the database.
+func requireDatabase(t *testing.T) {
+	t.Helper()
+	if !databaseReady {
+		t.Skip("no database configured, set DB_HOST or DB_DSN")
+	}
+}
@@ -1,40 +1,126 @@
 package utils
 
 import (
-	"fmt"
+	"context"
+	"gopds/hyperbloom/internal/config"
 	"gopds/hyperbloom/internal/database/postgres"
 	"gopds/hyperbloom/internal/service"
+	"log/slog"
+	"net/http"
 	"os"
+	"strings"
 	"sync"
+	"syscall"
+
+	"google.golang.org/grpc"
 )
 
-// Cleanup handles OS interrupt signals to perform graceful shutdown tasks.
-// It waits for a signal on osChan, shuts down the hyperbloom update coroutine,
-// closes the PostgreSQL database connection, and then exits the program.
-func Cleanup(osChan chan os.Signal, wg *sync.WaitGroup) {
-	defer wg.Done() // Mark this goroutine as done when function exits
+// exit terminates the program, it is swapped out by tests exercising the shutdown path.
+var exit = os.Exit
 
-	// Wait for an OS interrupt signal
-	sig := <-osChan
+// Cleanup handles OS signals to reload the configuration and perform graceful shutdown tasks.
+// On SIGHUP it reloads the configuration and keeps waiting. On any other signal on osChan, or an error on serveErr
+// reported by a server that stopped serving, it stops server and grpcServer from accepting requests and waits for those in flight, so that nothing is hashed after the final
+// flush, then shuts down the hyperbloom update coroutine, waits for it to flush the in-memory HyperBlooms,
+// closes the PostgreSQL database connection, and then exits the program. If the flush doesn't complete
+// within SHUTDOWN_TIMEOUT, it logs the keys that weren't flushed and exits with status 1.
+// server may be nil, e.g. when it failed to start, and grpcServer when GRPC_ADDR is empty.
+func Cleanup(osChan chan os.Signal, serveErr <-chan error, wg *sync.WaitGroup, server *http.Server, grpcServer *grpc.Server) {
+	defer wg.Done() // Mark this goroutine as done when function exits
 
-	// Print the received signal
-	fmt.Println("Encountered signal:", sig.String())
+	// Wait for an OS interrupt signal or a server failure, reloading the configuration on the way
+	for shutdown := false; !shutdown; {
+		select {
+		case sig := <-osChan:
+			slog.Info("Encountered signal", "signal", sig.String())
+			if sig == syscall.SIGHUP {
+				Reload()
+				continue
+			}
+		case err := <-serveErr:
+			slog.Error("Server stopped serving", "err", err)
+		}
+		shutdown = true
+	}
 
 	// Perform shutdown tasks
-	fmt.Println("Shutting down hyperbloom update coroutine and closing DB conn")
+	slog.Info("Shutting down hyperbloom update coroutine and closing DB conn")
 
-	// Send signal to stop async updates
-	close(service.StopAsyncBloomUpdate)
+	// Bound the final flush, an unresponsive database must not block the shutdown forever
+	ctx := context.Background()
+	if timeout := config.ApplicationCfg().ShutdownTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-	// Close the PostgreSQL database connection
-	postgres.DbClient.Close()
+	// Drain the requests and gRPC calls in flight first, their changes must be part of the final flush. Requests still
+	// running when the timeout expires are cut off, the flush still gets whatever time is left
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Warn("Requests still in flight, closing their connections", "err", err)
+			server.Close()
+		}
+	}
+	if grpcServer != nil {
+		stopGRPC(ctx, grpcServer)
+	}
+
+	// Stop async updates and wait for the coroutine to flush pending updates, the DB connection must outlive the final flush
+	unflushed, err := service.Shutdown(ctx)
+	if err != nil {
+		// Force the exit, these keys must be recovered from a snapshot or re-ingested
+		slog.Error("Shutdown timed out, exiting without flushing", "keys", strings.Join(unflushed, ", "))
+		exit(1)
+		return
+	}
+	if len(unflushed) > 0 {
+		slog.Error("Failed to flush", "keys", strings.Join(unflushed, ", "))
+	}
+
+	// Close the PostgreSQL database connections
+	postgres.Close()
 
 	// Close osChan to signal completion of cleanup
 	close(osChan)
 
-	// Print final cleanup message
-	fmt.Println("Cleaned up, exiting the program")
+	// Log final cleanup message
+	slog.Info("Cleaned up, exiting the program")
 
 	// Exit the program with status code 0
-	os.Exit(0)
+	exit(0)
+}
+
+// Reload re-reads the configuration without dropping connections or in-memory state.
+// Settings that can't change at runtime are kept and logged, an invalid configuration is rejected as a whole.
+func Reload() {
+	restart, err := config.Reload(service.ValidateConfig)
+	if err != nil {
+		slog.Error("Can't reload configuration, keeping the current one", "err", err)
+		return
+	}
+
+	// Let the running service pick up the new settings
+	service.ApplyConfig()
+
+	for _, name := range restart {
+		slog.Warn("Setting changed but needs a restart to take effect", "setting", name)
+	}
+	slog.Info("Reloaded configuration")
+}
+
+// stopGRPC stops server from accepting calls and waits for those in flight, cutting them off once ctx expires.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("Calls still in flight, closing their connections", "err", ctx.Err())
+		server.Stop()
+	}
 }
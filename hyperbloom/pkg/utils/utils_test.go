@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"gopds/hyperbloom/internal/config"
+	"gopds/hyperbloom/internal/service"
+
+	"github.com/axiomhq/hyperloglog"
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	requireDatabase(t)
+
+	// Capture the exit in case the handler mistakes the reload for a shutdown
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+	defer func() { exit = os.Exit }()
+
+	// Bring the flush interval down from the default far below what the test waits for
+	path := filepath.Join(t.TempDir(), "hyperbloom.env")
+	if err := os.WriteFile(path, []byte("HB_UPDATE_RATE=200ms\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.ConfigFileEnv, path)
+	t.Setenv("HB_UPDATE_RATE", "")
+	defer func() {
+		config.LoadConfigHyperBloom()
+		service.ApplyConfig()
+	}()
+
+	// Go through the same signal path as the running program, the handler keeps running afterwards
+	osChan := make(chan os.Signal, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go Cleanup(osChan, nil, &wg, nil, nil)
+	osChan <- syscall.SIGHUP
+
+	deadline := time.Now().Add(5 * time.Second)
+	for config.HyperBloomCfg().UpdateRate != 200*time.Millisecond {
+		if time.Now().After(deadline) {
+			t.Fatalf("update rate = %s after SIGHUP, want 200ms", config.HyperBloomCfg().UpdateRate)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A value hashed now must be flushed by the next tick of the new interval
+	key := fmt.Sprint("reload-", time.Now().UnixNano())
+	service.BloomHash(context.Background(), key, "123")
+
+	client, err := sql.Open("postgres", config.PostgresCfg.GetDataSourceName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var count int
+	for count == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("HyperBloom wasn't flushed at the reloaded interval")
+		}
+		time.Sleep(50 * time.Millisecond)
+		if err = client.QueryRow(`SELECT COUNT(*) FROM hyperblooms WHERE key = $1`, key).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case code := <-exited:
+		t.Fatalf("SIGHUP shut down the program with status %d", code)
+	default:
+	}
+}
+
+func TestCleanupFlushesBeforeClosingDB(t *testing.T) {
+	requireDatabase(t)
+
+	// Capture the exit instead of terminating the test binary
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+	defer func() { exit = os.Exit }()
+
+	// Dirty a few HyperBlooms, the update coroutine won't tick before the shutdown
+	suffix := time.Now().UnixNano()
+	ids := map[string][]string{
+		fmt.Sprint("shutdown-a-", suffix): {"123", "456", "789"},
+		fmt.Sprint("shutdown-b-", suffix): {"987", "654"},
+	}
+	for key, values := range ids {
+		for _, value := range values {
+			service.BloomHash(context.Background(), key, value)
+		}
+	}
+
+	// A request still in flight when the signal arrives hashes one more value
+	late := fmt.Sprint("shutdown-late-", suffix)
+	ids[late] = []string{"late"}
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		service.BloomHash(context.Background(), late, "late")
+	}))
+	defer server.Close()
+	go http.Get(server.URL)
+	<-started
+
+	// Go through the same signal path as the running program
+	osChan := make(chan os.Signal, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go Cleanup(osChan, nil, &wg, server.Config, nil)
+	osChan <- syscall.SIGTERM
+
+	select {
+	case code := <-exited:
+		if code != 0 {
+			t.Fatalf("Cleanup exited with status %d", code)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Cleanup didn't exit")
+	}
+
+	// Cleanup closed the service connection pool, check what was persisted through a fresh one
+	client, err := sql.Open("postgres", config.PostgresCfg.GetDataSourceName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for key, values := range ids {
+		var bloombyte, hyperbyte []byte
+		err = client.QueryRow(`SELECT bloombyte, hyperbyte FROM hyperblooms WHERE key = $1`, key).Scan(&bloombyte, &hyperbyte)
+		if err != nil {
+			t.Fatalf("HyperBloom %s was not persisted: %v", key, err)
+		}
+
+		bf := &bloom.BloomFilter{}
+		if err = bf.GobDecode(bloombyte); err != nil {
+			t.Fatal(err)
+		}
+		hll := &hyperloglog.Sketch{}
+		if err = hll.UnmarshalBinary(hyperbyte); err != nil {
+			t.Fatal(err)
+		}
+
+		// Every value hashed before the signal must have made it to the database
+		for _, value := range values {
+			if !bf.TestString(value) {
+				t.Errorf("value %s of %s was lost on shutdown", value, key)
+			}
+		}
+		if hll.Estimate() != uint64(len(values)) {
+			t.Errorf("persisted cardinality of %s = %d, want %d", key, hll.Estimate(), len(values))
+		}
+	}
+}
@@ -0,0 +1,4 @@
+protoc \
+    --go_out=.  --go_opt=paths=source_relative \
+    --go-grpc_out=.  --go-grpc_opt=paths=source_relative \
+    $1
\ No newline at end of file